@@ -0,0 +1,256 @@
+// Package auth authenticates API clients by static API key or bearer JWT,
+// and tells which roles they hold.
+//
+// # Roles
+//
+// RoleViewer, RoleEditor and RoleAdmin are ranked in that order, each
+// granting everything the lower ones do (see Principal.Has).  Role names a
+// client holds but this package does not know are ignored.
+//
+// # API keys
+//
+// A client sends its key in the X-API-Key header.  The configuration holds
+// only the SHA-256 of each key, under the name the client is known by and
+// the role it grants (e.g. "ci:3b5d…:editor", see
+// config.AuthConfig.APIKeys), so a leaked config file leaks no key.  A key
+// without a role grants RoleViewer.  Compute a hash with:
+//
+//	printf %s "$KEY" | sha256sum
+//
+// # Bearer JWTs
+//
+// A client sends a JWT in an "Authorization: Bearer <token>" header.  The
+// token is verified against the HMAC secret (HS256, HS384, HS512) or the
+// public keys of a local JWKS file (RS*, PS*, ES*), selected by its kid
+// header when the set has several; the algorithm is taken from the key, so
+// a token cannot pick a weaker one.  Its exp and nbf claims are enforced,
+// and its iss and aud claims when an issuer or audience is configured.  Its
+// sub claim names the client, and its roles claim (or the claim configured
+// instead) lists its roles, as an array or a space-separated string.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+)
+
+// Headers carrying credentials.
+const (
+	APIKeyHeader        = "X-API-Key"
+	AuthorizationHeader = "Authorization"
+)
+
+// Authentication methods reported by Principal.Method.
+const (
+	MethodAPIKey = "api-key"
+	MethodJWT    = "jwt"
+)
+
+// Errors returned by Authenticate.
+var (
+	// ErrNoCredentials means the request carries neither an API key nor a
+	// bearer token.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means the credentials were rejected.  The
+	// wrapping error says why, for the server log only: clients learn no
+	// more than that they were rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Role is a set of permissions granted to a principal.
+type Role string
+
+// Roles, from the least to the most privileged.
+const (
+	RoleViewer Role = "viewer" // Reads players
+	RoleEditor Role = "editor" // Also creates, updates and restores players
+	RoleAdmin  Role = "admin"  // Also deletes players, imports in bulk and purges the trash
+)
+
+// roleRanks orders the known roles; a role grants every role of lower rank.
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// ParseRole returns the role named name, and whether it is a known role.
+func ParseRole(name string) (Role, bool) {
+	role := Role(name)
+	_, ok := roleRanks[role]
+	return role, ok
+}
+
+// leeway tolerates clock skew between the token issuer and this server when
+// checking exp and nbf.
+const leeway = 30 * time.Second
+
+// Principal is an authenticated client.
+type Principal struct {
+	Subject string // Name of the API key, or sub claim of the JWT
+	Method  string // MethodAPIKey or MethodJWT
+	Roles   []Role // Known roles granted by the key or the token
+}
+
+// Has reports whether the principal holds role, or a role ranked above it.
+func (p Principal) Has(role Role) bool {
+	for _, held := range p.Roles {
+		if roleRanks[held] >= roleRanks[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKey is a configured API key: the name it authenticates, the SHA-256 of
+// the key and the role it grants.
+type apiKey struct {
+	name string
+	hash []byte
+	role Role
+}
+
+// Authenticator verifies the credentials of requests.  It is safe for
+// concurrent use.
+type Authenticator struct {
+	apiKeys    []apiKey
+	keyfunc    jwt.Keyfunc // nil when no JWT key source is configured
+	parser     *jwt.Parser
+	issuer     string
+	audience   string
+	rolesClaim string
+}
+
+// New returns an Authenticator accepting the API keys and JWT key sources of
+// cfg, which must have been validated.  It fails when the JWKS file cannot
+// be read or holds no usable key.
+func New(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{issuer: cfg.JWTIssuer, audience: cfg.JWTAudience, rolesClaim: cfg.JWTRolesClaim}
+	for _, entry := range cfg.APIKeys {
+		name, rest, _ := strings.Cut(entry, ":")
+		digest, roleName, hasRole := strings.Cut(rest, ":")
+		hash, err := hex.DecodeString(digest)
+		if err != nil {
+			return nil, fmt.Errorf("auth: API key %s: %w", name, err)
+		}
+		role := RoleViewer
+		if hasRole {
+			var known bool
+			if role, known = ParseRole(roleName); !known {
+				return nil, fmt.Errorf("auth: API key %s: unknown role %q", name, roleName)
+			}
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: name, hash: hash, role: role})
+	}
+
+	var methods []string
+	var keys *keySet
+	if cfg.JWKSFile != "" {
+		var err error
+		if keys, err = loadJWKS(cfg.JWKSFile); err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		methods = append(methods, keys.algorithms()...)
+	}
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(methods) > 0 {
+		a.keyfunc = func(token *jwt.Token) (any, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+				return secret, nil
+			}
+			kid, _ := token.Header["kid"].(string)
+			return keys.lookup(kid, token.Method.Alg())
+		}
+	}
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithLeeway(leeway), jwt.WithExpirationRequired()}
+	if a.issuer != "" {
+		options = append(options, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		options = append(options, jwt.WithAudience(a.audience))
+	}
+	a.parser = jwt.NewParser(options...)
+	return a, nil
+}
+
+// Configured reports whether any credential can be accepted at all.
+func (a *Authenticator) Configured() bool {
+	return len(a.apiKeys) > 0 || a.keyfunc != nil
+}
+
+// Authenticate returns the principal whose credentials the request headers
+// carry: an API key in APIKeyHeader or else a bearer token in
+// AuthorizationHeader.
+func (a *Authenticator) Authenticate(header http.Header) (Principal, error) {
+	if key := header.Get(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+	scheme, token, found := strings.Cut(header.Get(AuthorizationHeader), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+	return a.authenticateJWT(strings.TrimSpace(token))
+}
+
+// authenticateAPIKey looks key up among the configured keys.  Every hash is
+// compared in constant time, so the response time reveals nothing of them.
+func (a *Authenticator) authenticateAPIKey(key string) (Principal, error) {
+	hash := sha256.Sum256([]byte(key))
+	var found *apiKey
+	for i, candidate := range a.apiKeys {
+		if subtle.ConstantTimeCompare(hash[:], candidate.hash) == 1 {
+			found = &a.apiKeys[i]
+		}
+	}
+	if found == nil {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return Principal{Subject: found.name, Method: MethodAPIKey, Roles: []Role{found.role}}, nil
+}
+
+// authenticateJWT verifies token and returns its subject and roles.
+func (a *Authenticator) authenticateJWT(token string) (Principal, error) {
+	if a.keyfunc == nil {
+		return Principal{}, fmt.Errorf("%w: no JWT key configured", ErrInvalidCredentials)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.keyfunc); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: token has no sub claim", ErrInvalidCredentials)
+	}
+	return Principal{Subject: subject, Method: MethodJWT, Roles: roles(claims[a.rolesClaim])}, nil
+}
+
+// roles returns the known roles listed by a roles claim: an array of names,
+// or a single string of space-separated names (as OAuth 2.0 scopes are).
+func roles(claim any) []Role {
+	var names []string
+	switch value := claim.(type) {
+	case string:
+		names = strings.Fields(value)
+	case []any:
+		for _, item := range value {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	var known []Role
+	for _, name := range names {
+		if role, ok := ParseRole(name); ok {
+			known = append(known, role)
+		}
+	}
+	return known
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+)
+
+// jwk is the part of an RFC 7517 JSON Web Key used to verify signatures.
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	Alg     string `json:"alg"`
+	N       string `json:"n"`   // RSA modulus
+	E       string `json:"e"`   // RSA exponent
+	Curve   string `json:"crv"` // EC or OKP curve
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// verificationKey is a public key of the set with the algorithms it may
+// verify.
+type verificationKey struct {
+	id         string
+	key        any
+	algorithms []string
+}
+
+// keySet holds the public keys of a JWKS file.
+type keySet struct {
+	keys []verificationKey
+}
+
+// Algorithms each key type may verify, when its JWK does not pin one.
+var (
+	rsaAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	ecAlgorithms  = map[string]string{"P-256": "ES256", "P-384": "ES384", "P-521": "ES512"}
+	ecCurves      = map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+)
+
+// loadJWKS reads the JSON Web Key Set at path.  Keys not meant for
+// signatures (use other than "sig") are skipped; a key that cannot be
+// decoded is an error, so a broken file is noticed at startup.
+func loadJWKS(path string) (*keySet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS file: %w", err)
+	}
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("JWKS file %s: %w", path, err)
+	}
+	set := &keySet{}
+	for i, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		parsed, err := key.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS file %s: key %d (%q): %w", path, i, key.KeyID, err)
+		}
+		set.keys = append(set.keys, parsed)
+	}
+	if len(set.keys) == 0 {
+		return nil, fmt.Errorf("JWKS file %s: no signature key", path)
+	}
+	return set, nil
+}
+
+// verificationKey decodes the public key of k.
+func (k jwk) verificationKey() (verificationKey, error) {
+	result := verificationKey{id: k.KeyID}
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return result, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return result, errors.New("e: invalid exponent")
+		}
+		result.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		result.algorithms = rsaAlgorithms
+	case "EC":
+		curve, ok := ecCurves[k.Curve]
+		if !ok {
+			return result, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return result, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return result, fmt.Errorf("y: %w", err)
+		}
+		result.key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		result.algorithms = []string{ecAlgorithms[k.Curve]}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return result, fmt.Errorf("unsupported or invalid %q key", k.Curve)
+		}
+		result.key = ed25519.PublicKey(x)
+		result.algorithms = []string{"EdDSA"}
+	default:
+		return result, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+	if k.Alg != "" {
+		if !slices.Contains(result.algorithms, k.Alg) {
+			return result, fmt.Errorf("algorithm %s does not fit a %s key", k.Alg, k.KeyType)
+		}
+		result.algorithms = []string{k.Alg}
+	}
+	return result, nil
+}
+
+// decodeInt decodes a base64url-encoded big-endian unsigned integer.
+func decodeInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+// algorithms returns every algorithm some key of the set may verify.
+func (s *keySet) algorithms() []string {
+	var algorithms []string
+	for _, key := range s.keys {
+		for _, algorithm := range key.algorithms {
+			if !slices.Contains(algorithms, algorithm) {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	return algorithms
+}
+
+// lookup returns the key verifying alg signatures with the ID kid, or the
+// only key verifying them when the token names no kid.
+func (s *keySet) lookup(kid, alg string) (any, error) {
+	if s == nil {
+		return nil, errors.New("no JWKS configured")
+	}
+	var found []any
+	for _, key := range s.keys {
+		if (kid == "" || key.id == kid) && slices.Contains(key.algorithms, alg) {
+			found = append(found, key.key)
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return nil, fmt.Errorf("no %s key with kid %q", alg, kid)
+	case len(found) > 1:
+		return nil, fmt.Errorf("%d %s keys match kid %q", len(found), alg, kid)
+	}
+	return found[0], nil
+}
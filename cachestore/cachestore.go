@@ -0,0 +1,86 @@
+// Package cachestore opens the response cache backend chosen by
+// config.CacheConfig.Backend:
+//
+//   - memory: a store in the process, lost on restart and private to each
+//     replica; the default.
+//   - redis: a Redis server shared by every replica, so a response cached by
+//     one is served by all, and an eviction by one reaches all.
+//   - none: nothing is cached.
+//
+// # Key indexes
+//
+// The cache key of a response includes its query string, so the keys of a
+// path's query-string variants (e.g. "/players?page=2") cannot be derived
+// when the path has to be evicted.  A KeyIndex records them as they are
+// created.  It lives next to the store: in the process for the memory
+// backend, in Redis for the redis backend, so that a replica evicting a path
+// also finds the variants that other replicas cached.
+package cachestore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gomodule/redigo/redis"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+)
+
+// KeyIndex records the cache keys of the query-string variants of paths.
+// Implementations are safe for concurrent use.
+type KeyIndex interface {
+	// Add records key as the cache key of a variant of path.
+	Add(path string, key string) error
+	// Take returns the keys recorded for paths and forgets them.
+	Take(paths ...string) ([]string, error)
+}
+
+// Open returns the store and key index of the configured backend, or nil
+// ones when the backend is none.  The redis backend is pinged first, so an
+// unreachable server is reported at startup.
+func Open(cfg config.CacheConfig) (persistence.CacheStore, KeyIndex, error) {
+	switch cfg.Backend {
+	case "none":
+		return nil, nil, nil
+	case "redis":
+		pool := newPool(cfg.RedisURL)
+		conn := pool.Get()
+		defer conn.Close()
+		if _, err := conn.Do("PING"); err != nil {
+			_ = pool.Close()
+			return nil, nil, fmt.Errorf("cache: redis: %w", err)
+		}
+		return persistence.NewRedisCacheWithPool(pool, time.Duration(cfg.DefaultTTL)), NewRedisKeyIndex(pool, longestTTL(cfg)), nil
+	default:
+		return persistence.NewInMemoryStore(time.Duration(cfg.DefaultTTL)), NewMemoryKeyIndex(), nil
+	}
+}
+
+// newPool returns a connection pool to the Redis server at url, tuned like
+// the one persistence.NewRedisCacheWithURL would create.
+func newPool(url string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     5,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url, redis.DialConnectTimeout(10*time.Second))
+		},
+		TestOnBorrow: func(conn redis.Conn, idle time.Time) error {
+			if time.Since(idle) < 30*time.Second {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+}
+
+// longestTTL returns the longest expiry of a cached page: no recorded key
+// can be useful for longer.
+func longestTTL(cfg config.CacheConfig) time.Duration {
+	longest := time.Duration(cfg.PageTTL)
+	for _, route := range []config.Duration{cfg.Routes.Players, cfg.Routes.PlayerByID, cfg.Routes.PlayerBySquadNumber} {
+		longest = max(longest, cfg.TTL(route))
+	}
+	return longest
+}
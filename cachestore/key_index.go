@@ -0,0 +1,103 @@
+package cachestore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// memoryKeyIndex is the KeyIndex of the memory backend.
+type memoryKeyIndex struct {
+	mutex sync.Mutex
+	keys  map[string]map[string]struct{} // Cache keys by path
+}
+
+// NewMemoryKeyIndex returns an empty KeyIndex held in the process.
+func NewMemoryKeyIndex() KeyIndex {
+	return &memoryKeyIndex{keys: make(map[string]map[string]struct{})}
+}
+
+// Add records key under path.
+func (i *memoryKeyIndex) Add(path string, key string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.keys[path] == nil {
+		i.keys[path] = make(map[string]struct{})
+	}
+	i.keys[path][key] = struct{}{}
+	return nil
+}
+
+// Take returns and forgets the keys recorded under paths.
+func (i *memoryKeyIndex) Take(paths ...string) ([]string, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	var keys []string
+	for _, path := range paths {
+		for key := range i.keys[path] {
+			keys = append(keys, key)
+		}
+		delete(i.keys, path)
+	}
+	return keys, nil
+}
+
+// redisKeyPrefix prefixes the Redis set holding the keys recorded under a
+// path, out of the way of gin-contrib/cache's own keys.
+const redisKeyPrefix = "cachestore.keys:"
+
+// redisKeyIndex is the KeyIndex of the redis backend: one Redis set per
+// path, shared by every replica.
+type redisKeyIndex struct {
+	pool   *redis.Pool
+	expire time.Duration // Lifetime of a set after its last addition
+}
+
+// NewRedisKeyIndex returns a KeyIndex kept in the Redis server of pool.  A
+// set is dropped expire after its last addition, by when every page whose
+// key it holds has expired too.
+func NewRedisKeyIndex(pool *redis.Pool, expire time.Duration) KeyIndex {
+	return &redisKeyIndex{pool: pool, expire: expire}
+}
+
+// Add records key in the set of path and renews the set's expiry.
+func (i *redisKeyIndex) Add(path string, key string) error {
+	conn := i.pool.Get()
+	defer conn.Close()
+	set := redisKeyPrefix + path
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	_ = conn.Send("SADD", set, key)
+	_ = conn.Send("PEXPIRE", set, i.expire.Milliseconds())
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// Take reads and deletes the set of each path in one transaction, so a key
+// another replica adds meanwhile is either taken here or kept for later.
+func (i *redisKeyIndex) Take(paths ...string) ([]string, error) {
+	conn := i.pool.Get()
+	defer conn.Close()
+	if err := conn.Send("MULTI"); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		_ = conn.Send("SMEMBERS", redisKeyPrefix+path)
+		_ = conn.Send("DEL", redisKeyPrefix+path)
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for j := 0; j < len(replies); j += 2 {
+		members, err := redis.Strings(replies[j], nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, members...)
+	}
+	return keys, nil
+}
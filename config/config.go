@@ -0,0 +1,728 @@
+// Package config defines the application's typed configuration and loads it
+// from its sources.
+//
+// # Sources and precedence
+//
+// Load starts from Default and applies, in order, each overriding the
+// previous one:
+//
+//  1. Environment variables (e.g. DATABASE_URL, SERVER_ADDRESS)
+//  2. An optional YAML (.yaml, .yml) or TOML (.toml) file, named by the
+//     -config flag or the CONFIG_FILE environment variable
+//  3. Command-line flags (e.g. -address, -database-url)
+//
+// Only values a source actually sets are applied, so an empty environment
+// variable or an omitted file key never resets an earlier value.
+//
+// The result is validated before it is returned; every invalid value is
+// reported at once, so a misconfigured deployment fails at startup with the
+// full list of problems rather than at the first request that needs one.
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm/logger"
+)
+
+// Config is the complete application configuration.
+//
+// The struct tags name each value in the config file (yaml, toml) and in the
+// admin endpoint's JSON (json); the env and flag comments give the other two
+// spellings.
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server" json:"server"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database" json:"database"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache" json:"cache"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing" json:"tracing"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth" json:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rateLimit" toml:"rateLimit" json:"rateLimit"`
+	Events      EventsConfig      `yaml:"events" toml:"events" json:"events"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks" toml:"webhooks" json:"webhooks"`
+	Outbox      OutboxConfig      `yaml:"outbox" toml:"outbox" json:"outbox"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency" json:"idempotency"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Address                string   `yaml:"address" toml:"address" json:"address"`                                                                                // SERVER_ADDRESS, -address: host:port to listen on
+	Mode                   string   `yaml:"mode" toml:"mode" json:"mode"`                                                                                         // GIN_MODE, -mode: debug, release or test
+	ReadTimeout            Duration `yaml:"readTimeout" toml:"readTimeout" json:"readTimeout" swaggertype:"string" example:"15s"`                                 // SERVER_READ_TIMEOUT, -read-timeout: limit for reading a whole request
+	WriteTimeout           Duration `yaml:"writeTimeout" toml:"writeTimeout" json:"writeTimeout" swaggertype:"string" example:"1m0s"`                             // SERVER_WRITE_TIMEOUT, -write-timeout: limit for writing a response
+	RequestTimeout         Duration `yaml:"requestTimeout" toml:"requestTimeout" json:"requestTimeout" swaggertype:"string" example:"30s"`                        // SERVER_REQUEST_TIMEOUT, -request-timeout: deadline for handling a request, database queries included
+	IdleTimeout            Duration `yaml:"idleTimeout" toml:"idleTimeout" json:"idleTimeout" swaggertype:"string" example:"2m0s"`                                // SERVER_IDLE_TIMEOUT, -idle-timeout: keep-alive connection lifetime between requests
+	MaxHeaderBytes         int      `yaml:"maxHeaderBytes" toml:"maxHeaderBytes" json:"maxHeaderBytes" example:"1048576"`                                         // SERVER_MAX_HEADER_BYTES, -max-header-bytes: largest accepted request header
+	ShutdownReadinessDelay Duration `yaml:"shutdownReadinessDelay" toml:"shutdownReadinessDelay" json:"shutdownReadinessDelay" swaggertype:"string" example:"5s"` // SERVER_SHUTDOWN_READINESS_DELAY, -shutdown-readiness-delay: time still served after readiness fails on SIGINT/SIGTERM, or 0
+	ShutdownTimeout        Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout" json:"shutdownTimeout" swaggertype:"string" example:"10s"`                     // SERVER_SHUTDOWN_TIMEOUT, -shutdown-timeout: grace period for in-flight requests on SIGINT/SIGTERM
+	TrustedProxies         []string `yaml:"trustedProxies" toml:"trustedProxies" json:"trustedProxies"`                                                           // SERVER_TRUSTED_PROXIES, -trusted-proxies: comma-separated IPs or CIDRs whose X-Forwarded-For is believed
+}
+
+// DatabaseConfig configures the storage backend.
+type DatabaseConfig struct {
+	URL      string `yaml:"url" toml:"url" json:"url"`                // DATABASE_URL (or legacy STORAGE_PATH), -database-url; see data.Parse
+	LogLevel string `yaml:"logLevel" toml:"logLevel" json:"logLevel"` // DATABASE_LOG_LEVEL, -database-log-level: silent, error, warn or info
+}
+
+// CacheConfig configures the response cache; see the cachestore package.
+type CacheConfig struct {
+	Backend    string         `yaml:"backend" toml:"backend" json:"backend"`                                                // CACHE_BACKEND, -cache-backend: memory, redis or none
+	RedisURL   string         `yaml:"redisURL" toml:"redisURL" json:"redisURL"`                                             // CACHE_REDIS_URL, -cache-redis-url: redis://[:password@]host:port[/db] (redis only)
+	DefaultTTL Duration       `yaml:"defaultTTL" toml:"defaultTTL" json:"defaultTTL" swaggertype:"string" example:"1h0m0s"` // CACHE_DEFAULT_TTL, -cache-default-ttl: store-wide default expiry
+	PageTTL    Duration       `yaml:"pageTTL" toml:"pageTTL" json:"pageTTL" swaggertype:"string" example:"1h0m0s"`          // CACHE_PAGE_TTL, -cache-page-ttl: expiry of cached GET responses on routes without a TTL of their own
+	Routes     CacheRouteTTLs `yaml:"routes" toml:"routes" json:"routes"`
+}
+
+// CacheRouteTTLs sets the expiry of each cached route; zero stands for
+// CacheConfig.PageTTL (see CacheConfig.TTL).
+type CacheRouteTTLs struct {
+	Players             Duration `yaml:"players" toml:"players" json:"players" swaggertype:"string" example:"5m0s"`                                       // CACHE_TTL_PLAYERS, -cache-ttl-players: GET /players
+	PlayerByID          Duration `yaml:"playerByID" toml:"playerByID" json:"playerByID" swaggertype:"string" example:"1h0m0s"`                            // CACHE_TTL_PLAYER_BY_ID, -cache-ttl-player-by-id: GET /players/:id
+	PlayerBySquadNumber Duration `yaml:"playerBySquadNumber" toml:"playerBySquadNumber" json:"playerBySquadNumber" swaggertype:"string" example:"1h0m0s"` // CACHE_TTL_PLAYER_BY_SQUAD_NUMBER, -cache-ttl-player-by-squad-number: GET /players/squadnumber/:squadnumber
+}
+
+// TTL returns the expiry of a route whose own TTL is route: route itself,
+// or PageTTL when it is zero.
+func (c CacheConfig) TTL(route Duration) time.Duration {
+	if route == 0 {
+		return time.Duration(c.PageTTL)
+	}
+	return time.Duration(route)
+}
+
+// TracingConfig configures OpenTelemetry tracing; see the tracing package.
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" toml:"exporter" json:"exporter"`          // TRACING_EXPORTER, -tracing-exporter: none, stdout or otlp
+	Endpoint    string `yaml:"endpoint" toml:"endpoint" json:"endpoint"`          // TRACING_ENDPOINT, -tracing-endpoint: OTLP/HTTP collector URL (otlp only)
+	ServiceName string `yaml:"serviceName" toml:"serviceName" json:"serviceName"` // TRACING_SERVICE_NAME, -tracing-service-name: service.name of every span
+}
+
+// AuthConfig configures the authentication of API clients; see the auth
+// package.  With neither API keys nor a JWT key source, every protected
+// route is refused.
+type AuthConfig struct {
+	APIKeys       []string `yaml:"apiKeys" toml:"apiKeys" json:"apiKeys"`                   // AUTH_API_KEYS, -auth-api-keys: comma-separated name:sha256-hex[:role] entries
+	JWTSecret     string   `yaml:"jwtSecret" toml:"jwtSecret" json:"jwtSecret"`             // AUTH_JWT_SECRET, -auth-jwt-secret: HMAC key of HS256/384/512 bearer tokens
+	JWKSFile      string   `yaml:"jwksFile" toml:"jwksFile" json:"jwksFile"`                // AUTH_JWKS_FILE, -auth-jwks-file: JSON Web Key Set of RS*/ES*/PS* bearer tokens
+	JWTIssuer     string   `yaml:"jwtIssuer" toml:"jwtIssuer" json:"jwtIssuer"`             // AUTH_JWT_ISSUER, -auth-jwt-issuer: required iss claim, if set
+	JWTAudience   string   `yaml:"jwtAudience" toml:"jwtAudience" json:"jwtAudience"`       // AUTH_JWT_AUDIENCE, -auth-jwt-audience: required aud claim, if set
+	JWTRolesClaim string   `yaml:"jwtRolesClaim" toml:"jwtRolesClaim" json:"jwtRolesClaim"` // AUTH_JWT_ROLES_CLAIM, -auth-jwt-roles-claim: claim listing the token's roles
+	ProtectReads  bool     `yaml:"protectReads" toml:"protectReads" json:"protectReads"`    // AUTH_PROTECT_READS, -auth-protect-reads: require credentials on GET routes too
+}
+
+// RateLimitConfig configures the per-client rate limits; see the ratelimit
+// package.  Reads and writes are limited separately, so a client reading
+// heavily can still write, and the other way round.  Rejected credentials
+// are limited per IP address, before authentication, so they cannot be
+// guessed at leisure.
+type RateLimitConfig struct {
+	Reads      Rate `yaml:"reads" toml:"reads" json:"reads" swaggertype:"string" example:"300/1m0s"`               // RATE_LIMIT_READS, -rate-limit-reads: requests per period on GET routes, or off
+	Writes     Rate `yaml:"writes" toml:"writes" json:"writes" swaggertype:"string" example:"60/1m0s"`             // RATE_LIMIT_WRITES, -rate-limit-writes: requests per period on mutating routes, or off
+	FailedAuth Rate `yaml:"failedAuth" toml:"failedAuth" json:"failedAuth" swaggertype:"string" example:"10/1m0s"` // RATE_LIMIT_FAILED_AUTH, -rate-limit-failed-auth: rejected credentials per period, per IP address, or off
+}
+
+// EventsConfig configures the player change stream; see the events package.
+type EventsConfig struct {
+	BufferSize int `yaml:"bufferSize" toml:"bufferSize" json:"bufferSize" example:"1000"` // EVENTS_BUFFER_SIZE, -events-buffer-size: latest events kept for clients resuming with Last-Event-ID
+}
+
+// WebhooksConfig configures the delivery of webhooks; see the webhook
+// package.  A failed attempt is retried after Backoff, doubling with every
+// further failure up to MaxBackoff, until MaxAttempts have failed.
+type WebhooksConfig struct {
+	MaxAttempts  int      `yaml:"maxAttempts" toml:"maxAttempts" json:"maxAttempts" example:"8"`                          // WEBHOOK_MAX_ATTEMPTS, -webhook-max-attempts: attempts before a delivery becomes a dead letter
+	Backoff      Duration `yaml:"backoff" toml:"backoff" json:"backoff" swaggertype:"string" example:"1s"`                // WEBHOOK_BACKOFF, -webhook-backoff: delay before the first retry
+	MaxBackoff   Duration `yaml:"maxBackoff" toml:"maxBackoff" json:"maxBackoff" swaggertype:"string" example:"1h0m0s"`   // WEBHOOK_MAX_BACKOFF, -webhook-max-backoff: longest delay between retries
+	Timeout      Duration `yaml:"timeout" toml:"timeout" json:"timeout" swaggertype:"string" example:"10s"`               // WEBHOOK_TIMEOUT, -webhook-timeout: limit for one attempt
+	PollInterval Duration `yaml:"pollInterval" toml:"pollInterval" json:"pollInterval" swaggertype:"string" example:"5s"` // WEBHOOK_POLL_INTERVAL, -webhook-poll-interval: how often retries that came due are looked for
+}
+
+// OutboxConfig configures the relay publishing the player changes written to
+// the outbox; see the outbox package.  The bus publisher hands them to the
+// webhooks, log logs them, and nats sends them to the NATS server at
+// NATSURL, which runs in the process when NATSEmbedded is set.
+type OutboxConfig struct {
+	Publishers   []string `yaml:"publishers" toml:"publishers" json:"publishers"`                                         // OUTBOX_PUBLISHERS, -outbox-publishers: comma-separated bus, log and nats
+	NATSURL      string   `yaml:"natsURL" toml:"natsURL" json:"natsURL"`                                                  // OUTBOX_NATS_URL, -outbox-nats-url: NATS server of the nats publisher
+	NATSSubject  string   `yaml:"natsSubject" toml:"natsSubject" json:"natsSubject"`                                      // OUTBOX_NATS_SUBJECT, -outbox-nats-subject: subject prefix, followed by the event type
+	NATSEmbedded bool     `yaml:"natsEmbedded" toml:"natsEmbedded" json:"natsEmbedded"`                                   // OUTBOX_NATS_EMBEDDED, -outbox-nats-embedded: run the NATS server in the process, listening on the host:port of natsURL
+	NATSStoreDir string   `yaml:"natsStoreDir" toml:"natsStoreDir" json:"natsStoreDir"`                                   // OUTBOX_NATS_STORE_DIR, -outbox-nats-store-dir: JetStream storage of the embedded NATS server
+	PollInterval Duration `yaml:"pollInterval" toml:"pollInterval" json:"pollInterval" swaggertype:"string" example:"1s"` // OUTBOX_POLL_INTERVAL, -outbox-poll-interval: how often the outbox is looked at without a change of this process
+	BatchSize    int      `yaml:"batchSize" toml:"batchSize" json:"batchSize" example:"100"`                              // OUTBOX_BATCH_SIZE, -outbox-batch-size: messages published per round
+}
+
+// IdempotencyConfig configures the replay of responses to requests sent
+// with an Idempotency-Key header; see controller.Idempotency.
+type IdempotencyConfig struct {
+	TTL Duration `yaml:"ttl" toml:"ttl" json:"ttl" swaggertype:"string" example:"24h0m0s"` // IDEMPOTENCY_TTL, -idempotency-ttl: how long a response is replayed to retries
+}
+
+// Rate is a number of requests allowed per period, written "300/1m" (a
+// count, a slash and a Go duration), or "off" for no limit, its zero value.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+// UnmarshalText parses a rate such as "300/1m" or "off".
+func (r *Rate) UnmarshalText(text []byte) error {
+	if string(text) == "off" {
+		*r = Rate{}
+		return nil
+	}
+	count, period, found := strings.Cut(string(text), "/")
+	if !found {
+		return fmt.Errorf("rate %q must be requests/period, e.g. 300/1m, or off", text)
+	}
+	requests, err := strconv.Atoi(count)
+	if err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil {
+		return err
+	}
+	*r = Rate{Requests: requests, Period: duration}
+	return nil
+}
+
+// MarshalText formats the rate as a string such as "300/1m0s", or "off".
+func (r Rate) MarshalText() ([]byte, error) {
+	if r.Off() {
+		return []byte("off"), nil
+	}
+	return []byte(fmt.Sprintf("%d/%s", r.Requests, r.Period)), nil
+}
+
+// Off reports whether the rate sets no limit.
+func (r Rate) Off() bool {
+	return r == Rate{}
+}
+
+// Duration is a time.Duration written as a Go duration string ("90s",
+// "1h30m") in files, environment variables, flags and JSON.
+type Duration time.Duration
+
+// UnmarshalText parses a duration string such as "1h".
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string such as "1h0m0s".
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// logLevels maps the accepted DatabaseConfig.LogLevel values to GORM's.
+var logLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// GormLogLevel returns the GORM logger level named by LogLevel.
+func (d DatabaseConfig) GormLogLevel() logger.LogLevel {
+	return logLevels[d.LogLevel]
+}
+
+// Default returns the configuration used when no source sets a value: the
+// values this application has always used.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:                ":9000",
+			Mode:                   "debug",
+			ReadTimeout:            Duration(15 * time.Second),
+			WriteTimeout:           Duration(time.Minute),
+			RequestTimeout:         Duration(30 * time.Second),
+			IdleTimeout:            Duration(2 * time.Minute),
+			MaxHeaderBytes:         1 << 20,
+			ShutdownReadinessDelay: Duration(5 * time.Second),
+			ShutdownTimeout:        Duration(10 * time.Second),
+		},
+		Database: DatabaseConfig{
+			URL:      "./storage/players-sqlite3.db",
+			LogLevel: "info",
+		},
+		Cache: CacheConfig{
+			Backend:    "memory",
+			RedisURL:   "redis://localhost:6379/0",
+			DefaultTTL: Duration(time.Hour),
+			PageTTL:    Duration(time.Hour),
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "go-samples-gin-restful",
+		},
+		Auth: AuthConfig{
+			JWTRolesClaim: "roles",
+		},
+		RateLimit: RateLimitConfig{
+			Reads:      Rate{Requests: 300, Period: time.Minute},
+			Writes:     Rate{Requests: 60, Period: time.Minute},
+			FailedAuth: Rate{Requests: 10, Period: time.Minute},
+		},
+		Events: EventsConfig{
+			BufferSize: 1000,
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:  8,
+			Backoff:      Duration(time.Second),
+			MaxBackoff:   Duration(time.Hour),
+			Timeout:      Duration(10 * time.Second),
+			PollInterval: Duration(5 * time.Second),
+		},
+		Outbox: OutboxConfig{
+			Publishers:   []string{"bus"},
+			NATSURL:      "nats://localhost:4222",
+			NATSSubject:  "players",
+			NATSStoreDir: "./storage/nats",
+			PollInterval: Duration(time.Second),
+			BatchSize:    100,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: Duration(24 * time.Hour),
+		},
+	}
+}
+
+// setting binds one configuration value to its environment variable and
+// command-line flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(config *Config, value string) error
+}
+
+// settings lists every value that environment variables and flags can set.
+var settings = []setting{
+	{"SERVER_ADDRESS", "address", "host:port to listen on", func(c *Config, v string) error { c.Server.Address = v; return nil }},
+	{"GIN_MODE", "mode", "Gin mode: debug, release or test", func(c *Config, v string) error { c.Server.Mode = v; return nil }},
+	{"SERVER_READ_TIMEOUT", "read-timeout", "limit for reading a whole request", func(c *Config, v string) error { return c.Server.ReadTimeout.UnmarshalText([]byte(v)) }},
+	{"SERVER_WRITE_TIMEOUT", "write-timeout", "limit for writing a response", func(c *Config, v string) error { return c.Server.WriteTimeout.UnmarshalText([]byte(v)) }},
+	{"SERVER_REQUEST_TIMEOUT", "request-timeout", "deadline for handling a request, database queries included", func(c *Config, v string) error { return c.Server.RequestTimeout.UnmarshalText([]byte(v)) }},
+	{"SERVER_IDLE_TIMEOUT", "idle-timeout", "keep-alive connection lifetime between requests", func(c *Config, v string) error { return c.Server.IdleTimeout.UnmarshalText([]byte(v)) }},
+	{"SERVER_MAX_HEADER_BYTES", "max-header-bytes", "largest accepted request header, in bytes", func(c *Config, v string) (err error) {
+		c.Server.MaxHeaderBytes, err = strconv.Atoi(v)
+		return err
+	}},
+	{"SERVER_SHUTDOWN_READINESS_DELAY", "shutdown-readiness-delay", "time still served after readiness fails on SIGINT/SIGTERM, or 0", func(c *Config, v string) error { return c.Server.ShutdownReadinessDelay.UnmarshalText([]byte(v)) }},
+	{"SERVER_SHUTDOWN_TIMEOUT", "shutdown-timeout", "grace period for in-flight requests on SIGINT/SIGTERM", func(c *Config, v string) error { return c.Server.ShutdownTimeout.UnmarshalText([]byte(v)) }},
+	{"SERVER_TRUSTED_PROXIES", "trusted-proxies", "comma-separated IPs or CIDRs of reverse proxies whose X-Forwarded-For is believed", func(c *Config, v string) error {
+		c.Server.TrustedProxies = strings.Split(v, ",")
+		return nil
+	}},
+	{"DATABASE_URL", "database-url", "storage backend URL: postgres://…, sqlite://<path> or memory://", func(c *Config, v string) error { c.Database.URL = v; return nil }},
+	{"DATABASE_LOG_LEVEL", "database-log-level", "GORM log level: silent, error, warn or info", func(c *Config, v string) error { c.Database.LogLevel = v; return nil }},
+	{"CACHE_BACKEND", "cache-backend", "response cache backend: memory, redis or none", func(c *Config, v string) error { c.Cache.Backend = v; return nil }},
+	{"CACHE_REDIS_URL", "cache-redis-url", "Redis server of the redis cache backend: redis://[:password@]host:port[/db]", func(c *Config, v string) error { c.Cache.RedisURL = v; return nil }},
+	{"CACHE_DEFAULT_TTL", "cache-default-ttl", "default expiry of cached entries", func(c *Config, v string) error { return c.Cache.DefaultTTL.UnmarshalText([]byte(v)) }},
+	{"CACHE_PAGE_TTL", "cache-page-ttl", "expiry of cached GET responses on routes without a TTL of their own", func(c *Config, v string) error { return c.Cache.PageTTL.UnmarshalText([]byte(v)) }},
+	{"CACHE_TTL_PLAYERS", "cache-ttl-players", "expiry of cached GET /players responses (default: the page TTL)", func(c *Config, v string) error { return c.Cache.Routes.Players.UnmarshalText([]byte(v)) }},
+	{"CACHE_TTL_PLAYER_BY_ID", "cache-ttl-player-by-id", "expiry of cached GET /players/:id responses (default: the page TTL)", func(c *Config, v string) error { return c.Cache.Routes.PlayerByID.UnmarshalText([]byte(v)) }},
+	{"CACHE_TTL_PLAYER_BY_SQUAD_NUMBER", "cache-ttl-player-by-squad-number", "expiry of cached GET /players/squadnumber/:squadnumber responses (default: the page TTL)", func(c *Config, v string) error {
+		return c.Cache.Routes.PlayerBySquadNumber.UnmarshalText([]byte(v))
+	}},
+	{"TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, stdout or otlp", func(c *Config, v string) error { c.Tracing.Exporter = v; return nil }},
+	{"TRACING_ENDPOINT", "tracing-endpoint", "OTLP/HTTP collector URL", func(c *Config, v string) error { c.Tracing.Endpoint = v; return nil }},
+	{"AUTH_API_KEYS", "auth-api-keys", "comma-separated API keys as name:sha256-hex[:role]", func(c *Config, v string) error { c.Auth.APIKeys = strings.Split(v, ","); return nil }},
+	{"AUTH_JWT_SECRET", "auth-jwt-secret", "HMAC secret of bearer JWTs", func(c *Config, v string) error { c.Auth.JWTSecret = v; return nil }},
+	{"AUTH_JWKS_FILE", "auth-jwks-file", "JWKS file with the public keys of bearer JWTs", func(c *Config, v string) error { c.Auth.JWKSFile = v; return nil }},
+	{"AUTH_JWT_ISSUER", "auth-jwt-issuer", "required iss claim of bearer JWTs", func(c *Config, v string) error { c.Auth.JWTIssuer = v; return nil }},
+	{"AUTH_JWT_AUDIENCE", "auth-jwt-audience", "required aud claim of bearer JWTs", func(c *Config, v string) error { c.Auth.JWTAudience = v; return nil }},
+	{"AUTH_JWT_ROLES_CLAIM", "auth-jwt-roles-claim", "claim listing the roles of bearer JWTs", func(c *Config, v string) error { c.Auth.JWTRolesClaim = v; return nil }},
+	{"AUTH_PROTECT_READS", "auth-protect-reads", "require credentials on GET routes too (true or false)", func(c *Config, v string) (err error) {
+		c.Auth.ProtectReads, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"RATE_LIMIT_READS", "rate-limit-reads", "requests per period allowed on GET routes, per client (e.g. 300/1m, or off)", func(c *Config, v string) error { return c.RateLimit.Reads.UnmarshalText([]byte(v)) }},
+	{"RATE_LIMIT_WRITES", "rate-limit-writes", "requests per period allowed on mutating routes, per client (e.g. 60/1m, or off)", func(c *Config, v string) error { return c.RateLimit.Writes.UnmarshalText([]byte(v)) }},
+	{"RATE_LIMIT_FAILED_AUTH", "rate-limit-failed-auth", "rejected credentials per period allowed, per IP address (e.g. 10/1m, or off)", func(c *Config, v string) error { return c.RateLimit.FailedAuth.UnmarshalText([]byte(v)) }},
+	{"EVENTS_BUFFER_SIZE", "events-buffer-size", "latest player change events kept for clients resuming a stream", func(c *Config, v string) (err error) {
+		c.Events.BufferSize, err = strconv.Atoi(v)
+		return err
+	}},
+	{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts at a webhook delivery before it becomes a dead letter", func(c *Config, v string) (err error) {
+		c.Webhooks.MaxAttempts, err = strconv.Atoi(v)
+		return err
+	}},
+	{"WEBHOOK_BACKOFF", "webhook-backoff", "delay before the first retry of a webhook delivery, doubling with every further failure", func(c *Config, v string) error { return c.Webhooks.Backoff.UnmarshalText([]byte(v)) }},
+	{"WEBHOOK_MAX_BACKOFF", "webhook-max-backoff", "longest delay between retries of a webhook delivery", func(c *Config, v string) error { return c.Webhooks.MaxBackoff.UnmarshalText([]byte(v)) }},
+	{"WEBHOOK_TIMEOUT", "webhook-timeout", "limit for one attempt at a webhook delivery", func(c *Config, v string) error { return c.Webhooks.Timeout.UnmarshalText([]byte(v)) }},
+	{"WEBHOOK_POLL_INTERVAL", "webhook-poll-interval", "how often webhook retries that came due are looked for", func(c *Config, v string) error { return c.Webhooks.PollInterval.UnmarshalText([]byte(v)) }},
+	{"OUTBOX_PUBLISHERS", "outbox-publishers", "comma-separated publishers of player changes: bus, log and nats", func(c *Config, v string) error {
+		c.Outbox.Publishers = strings.Split(v, ",")
+		return nil
+	}},
+	{"OUTBOX_NATS_URL", "outbox-nats-url", "NATS server of the nats outbox publisher: nats://[user:password@]host:port", func(c *Config, v string) error { c.Outbox.NATSURL = v; return nil }},
+	{"OUTBOX_NATS_SUBJECT", "outbox-nats-subject", "prefix of the NATS subjects of player changes, followed by the event type", func(c *Config, v string) error { c.Outbox.NATSSubject = v; return nil }},
+	{"OUTBOX_NATS_EMBEDDED", "outbox-nats-embedded", "run the NATS server in the process, listening on the host:port of the NATS URL (true or false)", func(c *Config, v string) (err error) {
+		c.Outbox.NATSEmbedded, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"OUTBOX_NATS_STORE_DIR", "outbox-nats-store-dir", "directory of the JetStream storage of the embedded NATS server", func(c *Config, v string) error { c.Outbox.NATSStoreDir = v; return nil }},
+	{"OUTBOX_POLL_INTERVAL", "outbox-poll-interval", "how often the outbox is looked at for changes made by other replicas", func(c *Config, v string) error { return c.Outbox.PollInterval.UnmarshalText([]byte(v)) }},
+	{"OUTBOX_BATCH_SIZE", "outbox-batch-size", "outbox messages published per round", func(c *Config, v string) (err error) {
+		c.Outbox.BatchSize, err = strconv.Atoi(v)
+		return err
+	}},
+	{"IDEMPOTENCY_TTL", "idempotency-ttl", "how long the response to a request with an Idempotency-Key is replayed to its retries", func(c *Config, v string) error { return c.Idempotency.TTL.UnmarshalText([]byte(v)) }},
+	{"TRACING_SERVICE_NAME", "tracing-service-name", "service.name recorded on every span", func(c *Config, v string) error { c.Tracing.ServiceName = v; return nil }},
+}
+
+// Load builds the configuration from the environment (read through getenv,
+// normally os.Getenv), the optional config file and the command-line
+// arguments (without the program name), then validates it.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	flags := flag.NewFlagSet("go-samples-gin-restful", flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to a YAML or TOML config file (default $CONFIG_FILE)")
+	values := make(map[string]*string, len(settings))
+	for _, setting := range settings {
+		values[setting.flag] = flags.String(setting.flag, "", setting.usage+" (default $"+setting.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	config := Default()
+	var errs []error
+
+	// 1. Environment variables.  STORAGE_PATH predates DATABASE_URL and is
+	// applied first so that DATABASE_URL wins when both are set.
+	if value := getenv("STORAGE_PATH"); value != "" {
+		config.Database.URL = value
+	}
+	for _, setting := range settings {
+		if value := getenv(setting.env); value != "" {
+			if err := setting.set(&config, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", setting.env, err))
+			}
+		}
+	}
+
+	// 2. The config file.
+	if *configFile == "" {
+		*configFile = getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := loadFile(*configFile, &config); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// 3. Flags given on the command line (Visit skips the ones left unset).
+	flags.Visit(func(f *flag.Flag) {
+		for _, setting := range settings {
+			if setting.flag == f.Name {
+				if err := setting.set(&config, *values[f.Name]); err != nil {
+					errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return config, errors.Join(errs...)
+	}
+	return config, config.Validate()
+}
+
+// loadFile decodes the YAML or TOML file at path, chosen by its extension,
+// over config.  Keys absent from the file leave config untouched, and
+// unknown keys are rejected so a typo does not go unnoticed.
+func loadFile(path string, config *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		// An empty file decodes to io.EOF: it simply sets nothing.
+		if err = decoder.Decode(config); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	default:
+		return fmt.Errorf("config file %s: extension must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// minJWTSecretLength is the shortest accepted AuthConfig.JWTSecret: the
+// output size of SHA-256, as RFC 7518 §3.2 requires for HS256.
+const minJWTSecretLength = 32
+
+// Validate reports every invalid value, joined into one error, or nil.
+func (c Config) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Server.Address); err != nil {
+		errs = append(errs, fmt.Errorf("server.address %q must be host:port: %w", c.Server.Address, err))
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, fmt.Errorf("server.mode %q must be debug, release or test", c.Server.Mode))
+	}
+	for _, timeout := range []struct {
+		name  string
+		value Duration
+	}{
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.requestTimeout", c.Server.RequestTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s %s must be positive", timeout.name, time.Duration(timeout.value)))
+		}
+	}
+	// A request still running at the write timeout could not be answered
+	// anyway.
+	if c.Server.RequestTimeout > c.Server.WriteTimeout {
+		errs = append(errs, fmt.Errorf("server.requestTimeout %s must not be longer than server.writeTimeout %s", time.Duration(c.Server.RequestTimeout), time.Duration(c.Server.WriteTimeout)))
+	}
+	if c.Server.ShutdownReadinessDelay < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownReadinessDelay %s must not be negative", time.Duration(c.Server.ShutdownReadinessDelay)))
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		errs = append(errs, fmt.Errorf("server.maxHeaderBytes %d must be positive", c.Server.MaxHeaderBytes))
+	}
+	for i, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trustedProxies[%d] %q must be an IP address or a CIDR", i, proxy))
+		}
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url must not be empty"))
+	}
+	if _, ok := logLevels[c.Database.LogLevel]; !ok {
+		errs = append(errs, fmt.Errorf("database.logLevel %q must be silent, error, warn or info", c.Database.LogLevel))
+	}
+	if c.Cache.DefaultTTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.defaultTTL %s must be positive", time.Duration(c.Cache.DefaultTTL)))
+	}
+	if c.Cache.PageTTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.pageTTL %s must be positive", time.Duration(c.Cache.PageTTL)))
+	}
+	for _, ttl := range []struct {
+		name  string
+		value Duration
+	}{
+		{"cache.routes.players", c.Cache.Routes.Players},
+		{"cache.routes.playerByID", c.Cache.Routes.PlayerByID},
+		{"cache.routes.playerBySquadNumber", c.Cache.Routes.PlayerBySquadNumber},
+	} {
+		if ttl.value < 0 {
+			errs = append(errs, fmt.Errorf("%s %s must be positive, or zero for the page TTL", ttl.name, time.Duration(ttl.value)))
+		}
+	}
+	switch c.Cache.Backend {
+	case "memory", "none":
+	case "redis":
+		if redisURL, err := url.Parse(c.Cache.RedisURL); err != nil || (redisURL.Scheme != "redis" && redisURL.Scheme != "rediss") || redisURL.Host == "" {
+			errs = append(errs, fmt.Errorf("cache.redisURL %q must be a redis:// or rediss:// URL", redactURL(c.Cache.RedisURL)))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cache.backend %q must be memory, redis or none", c.Cache.Backend))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if endpoint, err := url.Parse(c.Tracing.Endpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint %q must be an http:// or https:// URL", c.Tracing.Endpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q must be none, stdout or otlp", c.Tracing.Exporter))
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.serviceName must not be empty"))
+	}
+	for i, entry := range c.Auth.APIKeys {
+		name, rest, _ := strings.Cut(entry, ":")
+		hash, role, hasRole := strings.Cut(rest, ":")
+		if decoded, err := hex.DecodeString(hash); name == "" || err != nil || len(decoded) != sha256.Size || (hasRole && role == "") {
+			errs = append(errs, fmt.Errorf("auth.apiKeys[%d] must be name:<64 hex digits of the key's SHA-256>[:role]", i))
+		}
+	}
+	if c.Auth.JWTRolesClaim == "" {
+		errs = append(errs, errors.New("auth.jwtRolesClaim must not be empty"))
+	}
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < minJWTSecretLength {
+		errs = append(errs, fmt.Errorf("auth.jwtSecret must be at least %d bytes long", minJWTSecretLength))
+	}
+	for _, limit := range []struct {
+		name  string
+		value Rate
+	}{
+		{"rateLimit.reads", c.RateLimit.Reads},
+		{"rateLimit.writes", c.RateLimit.Writes},
+		{"rateLimit.failedAuth", c.RateLimit.FailedAuth},
+	} {
+		if !limit.value.Off() && (limit.value.Requests <= 0 || limit.value.Period <= 0) {
+			errs = append(errs, fmt.Errorf("%s %d/%s must allow a positive number of requests per positive period, or be off", limit.name, limit.value.Requests, limit.value.Period))
+		}
+	}
+	if c.Events.BufferSize <= 0 {
+		errs = append(errs, fmt.Errorf("events.bufferSize %d must be positive", c.Events.BufferSize))
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.maxAttempts %d must be positive", c.Webhooks.MaxAttempts))
+	}
+	for _, duration := range []struct {
+		name  string
+		value Duration
+	}{
+		{"webhooks.backoff", c.Webhooks.Backoff},
+		{"webhooks.maxBackoff", c.Webhooks.MaxBackoff},
+		{"webhooks.timeout", c.Webhooks.Timeout},
+		{"webhooks.pollInterval", c.Webhooks.PollInterval},
+	} {
+		if duration.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s %s must be positive", duration.name, time.Duration(duration.value)))
+		}
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.Backoff {
+		errs = append(errs, fmt.Errorf("webhooks.maxBackoff %s must not be shorter than webhooks.backoff %s", time.Duration(c.Webhooks.MaxBackoff), time.Duration(c.Webhooks.Backoff)))
+	}
+	if c.Outbox.NATSEmbedded {
+		if !slices.Contains(c.Outbox.Publishers, "nats") {
+			errs = append(errs, errors.New("outbox.natsEmbedded requires the nats publisher in outbox.publishers"))
+		}
+		// The embedded server has neither TLS nor users configured.
+		if natsURL, err := url.Parse(c.Outbox.NATSURL); err == nil && (natsURL.Scheme != "nats" || natsURL.User != nil || natsURL.Port() == "") {
+			errs = append(errs, fmt.Errorf("outbox.natsURL %q must be nats://host:port, without credentials, when outbox.natsEmbedded is set", redactURL(c.Outbox.NATSURL)))
+		}
+		if c.Outbox.NATSStoreDir == "" {
+			errs = append(errs, errors.New("outbox.natsStoreDir must not be empty when outbox.natsEmbedded is set"))
+		}
+	}
+	for i, publisher := range c.Outbox.Publishers {
+		switch publisher {
+		case "bus", "log":
+		case "nats":
+			if natsURL, err := url.Parse(c.Outbox.NATSURL); err != nil || (natsURL.Scheme != "nats" && natsURL.Scheme != "tls") || natsURL.Host == "" {
+				errs = append(errs, fmt.Errorf("outbox.natsURL %q must be a nats:// or tls:// URL", redactURL(c.Outbox.NATSURL)))
+			}
+			if c.Outbox.NATSSubject == "" || strings.ContainsAny(c.Outbox.NATSSubject, " \t*>") {
+				errs = append(errs, fmt.Errorf("outbox.natsSubject %q must be a NATS subject without wildcards", c.Outbox.NATSSubject))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("outbox.publishers[%d] %q must be bus, log or nats", i, publisher))
+		}
+	}
+	if c.Outbox.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("outbox.pollInterval %s must be positive", time.Duration(c.Outbox.PollInterval)))
+	}
+	if c.Outbox.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("outbox.batchSize %d must be positive", c.Outbox.BatchSize))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency.ttl %s must be positive", time.Duration(c.Idempotency.TTL)))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration that is safe to expose:
+// credentials embedded in URLs are masked.  Every secret added to Config
+// must be masked here.
+func (c Config) Redacted() Config {
+	c.Database.URL = redactURL(c.Database.URL)
+	c.Cache.RedisURL = redactURL(c.Cache.RedisURL)
+	c.Tracing.Endpoint = redactURL(c.Tracing.Endpoint)
+	c.Outbox.NATSURL = redactURL(c.Outbox.NATSURL)
+	if c.Auth.JWTSecret != "" {
+		c.Auth.JWTSecret = redacted
+	}
+	// The hashes are not secrets, but there is nothing to learn from them.
+	apiKeys := make([]string, len(c.Auth.APIKeys))
+	for i, entry := range c.Auth.APIKeys {
+		name, rest, _ := strings.Cut(entry, ":")
+		apiKeys[i] = name + ":" + redacted
+		if _, role, hasRole := strings.Cut(rest, ":"); hasRole {
+			apiKeys[i] += ":" + role
+		}
+	}
+	c.Auth.APIKeys = apiKeys
+	return c
+}
+
+// redacted replaces a secret in Redacted output.
+const redacted = "xxxxx"
+
+// redactURL masks the password of a URL with userinfo, and the values of
+// its sensitive query parameters (see sensitiveParameter), such as
+// PostgreSQL's password and sslpassword; other strings, such as SQLite file
+// paths, are returned unchanged.
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	masked := false
+	if parsed.RawQuery != "" {
+		// Rewritten in place rather than through url.Values, which would
+		// reorder the parameters and re-encode those left alone.
+		parameters := strings.Split(parsed.RawQuery, "&")
+		for i, parameter := range parameters {
+			name, _, hasValue := strings.Cut(parameter, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil && hasValue && sensitiveParameter(unescaped) {
+				parameters[i] = name + "=" + redacted
+				masked = true
+			}
+		}
+		parsed.RawQuery = strings.Join(parameters, "&")
+	}
+	if parsed.User == nil && !masked {
+		return value
+	}
+	return parsed.Redacted()
+}
+
+// sensitiveParameters are the fragments of query parameter names whose
+// values redactURL masks: passwords (password, sslpassword, passwd, pwd),
+// secrets, tokens and credentials.
+var sensitiveParameters = []string{"password", "passwd", "pwd", "secret", "token", "credential"}
+
+// sensitiveParameter reports whether the query parameter name, compared
+// case-insensitively, contains any of sensitiveParameters.
+func sensitiveParameter(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range sensitiveParameters {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
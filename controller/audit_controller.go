@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+)
+
+// AuditController holds dependencies for audit trail handlers.
+type AuditController struct {
+	service service.AuditService
+}
+
+// NewAuditController returns an AuditController wired to the given service.
+func NewAuditController(service service.AuditService) *AuditController {
+	return &AuditController{service: service}
+}
+
+// GetHistory retrieves the audit trail of a Player by its internal UUID
+//
+// @Summary Retrieves the change history of a Player by its internal UUID
+// @Description Every create, update, delete, restore and purge of the player, oldest
+// @Description first, with the player before and after the change. Players from the
+// @Description seed data have an empty history until they are first changed.
+// @Tags audit
+// @Produce application/json,application/problem+json
+// @Param id path string true "Player.ID (UUID)"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {array} model.AuditEvent "OK"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized (when reads are protected)"
+// @Failure 403 {object} model.ProblemDetails "Forbidden (when reads are protected)"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /players/{id}/history [get]
+func (c *AuditController) GetHistory(context *gin.Context) {
+	events, err := c.service.History(context.Request.Context(), context.Param("id"))
+	if err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	context.IndentedJSON(http.StatusOK, events)
+}
+
+// GetEvents retrieves the audit trail of all Players
+//
+// @Summary Retrieves the audit trail of all Players
+// @Description Events are returned oldest first. from and to (RFC 3339) restrict
+// @Description the result to events that occurred at or after from and before to.
+// @Tags audit
+// @Produce application/json,application/problem+json
+// @Param from query string false "Earliest occurrence, inclusive (RFC 3339)"
+// @Param to query string false "Latest occurrence, exclusive (RFC 3339)"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {array} model.AuditEvent "OK"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /audit [get]
+func (c *AuditController) GetEvents(context *gin.Context) {
+	var query service.AuditQuery
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		value := context.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeProblem(context, http.StatusBadRequest, bound.name+" must be an RFC 3339 timestamp, e.g. 2022-12-18T18:00:00Z.")
+			return
+		}
+		*bound.target = parsed
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		writeProblem(context, http.StatusBadRequest, "from must be earlier than to.")
+		return
+	}
+	events, err := c.service.Events(context.Request.Context(), query)
+	if err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	context.IndentedJSON(http.StatusOK, events)
+}
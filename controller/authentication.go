@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+)
+
+// PrincipalKey is the gin.Context key under which Authenticate stores the
+// auth.Principal of an authenticated request.
+const PrincipalKey = "controller.principal"
+
+// bearerChallenge is the WWW-Authenticate challenge of 401 responses (RFC
+// 6750 §3); clients holding an API key send it in auth.APIKeyHeader instead.
+const bearerChallenge = `Bearer realm="go-samples-gin-restful"`
+
+// Authenticate is a middleware factory that lets a request through only with
+// credentials authenticator accepts, and records its principal under
+// PrincipalKey.  Anything else is answered with 401 Unauthorized and a
+// WWW-Authenticate challenge; why credentials were rejected is recorded as a
+// request error for the access log, never told to the client.
+func Authenticate(authenticator *auth.Authenticator) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		principal, err := authenticator.Authenticate(context.Request.Header)
+		if err != nil {
+			_ = context.Error(err)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				context.Header("WWW-Authenticate", bearerChallenge)
+				writeProblem(context, http.StatusUnauthorized, "Send an API key in the X-API-Key header or a bearer token in the Authorization header.")
+				return
+			}
+			context.Header("WWW-Authenticate", bearerChallenge+`, error="invalid_token"`)
+			writeProblem(context, http.StatusUnauthorized, "The credentials are invalid or expired.")
+			return
+		}
+		context.Set(PrincipalKey, principal)
+		context.Next()
+	}
+}
+
+// Authorize is a middleware factory that lets a request through only when
+// the principal Authenticate recorded holds role (or a role ranked above it,
+// see auth.Principal.Has).  Anything else is answered with 403 Forbidden,
+// naming the role required and the roles held.  It must run after
+// Authenticate.
+func Authorize(role auth.Role) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		p, _ := principal(context)
+		if !p.Has(role) {
+			held := make([]string, len(p.Roles))
+			for i, r := range p.Roles {
+				held[i] = string(r)
+			}
+			granted := "no role"
+			if len(held) > 0 {
+				granted = "the roles " + strings.Join(held, ", ")
+			}
+			_ = context.Error(fmt.Errorf("principal %q lacks role %s", p.Subject, role))
+			writeProblem(context, http.StatusForbidden, fmt.Sprintf("This operation requires the %s role; the credentials grant %s.", role, granted))
+			return
+		}
+		context.Next()
+	}
+}
+
+// principal returns the principal Authenticate recorded for the request.
+func principal(context *gin.Context) (auth.Principal, bool) {
+	value, ok := context.Get(PrincipalKey)
+	if !ok {
+		return auth.Principal{}, false
+	}
+	p, ok := value.(auth.Principal)
+	return p, ok
+}
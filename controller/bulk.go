@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+const (
+	// NDJSONContentType is the media type of newline-delimited JSON: one
+	// Player object per line.
+	NDJSONContentType = "application/x-ndjson"
+
+	// CSVContentType is the media type of comma-separated values with a
+	// header row naming the columns by their JSON field names.
+	CSVContentType = "text/csv"
+
+	// MaxBulkRows caps the number of rows a single bulk import may carry, so
+	// one request cannot hold the database in a transaction indefinitely.
+	MaxBulkRows = 1000
+
+	// MaxBulkBytes caps the size of a bulk import body, so that one request
+	// cannot hold the server reading rows it will refuse anyway: 1 MiB is
+	// about three times MaxBulkRows players.
+	MaxBulkBytes = 1 << 20
+)
+
+var (
+	// errUnsupportedBulkType is returned by decodeBulk for a Content-Type
+	// other than JSON, NDJSON or CSV.
+	errUnsupportedBulkType = errors.New("unsupported bulk import media type")
+
+	// errTooManyRows is returned by decodeBulk when the body has more than
+	// MaxBulkRows rows.
+	errTooManyRows = fmt.Errorf("a bulk import accepts at most %d rows", MaxBulkRows)
+)
+
+// csvColumn maps one CSV column, named after the Player's JSON field, to the
+// accessors that read and write it.
+type csvColumn struct {
+	name  string
+	get   func(player model.Player) string
+	set   func(player *model.Player, value string) error
+	input bool // false for server-owned columns, which are exported but ignored on import
+}
+
+// csvColumns lists the columns of a player CSV file in export order.
+var csvColumns = []csvColumn{
+	{"id", func(p model.Player) string { return p.ID }, func(p *model.Player, v string) error { return nil }, false},
+	{"firstName", func(p model.Player) string { return p.FirstName }, func(p *model.Player, v string) error { p.FirstName = v; return nil }, true},
+	{"middleName", func(p model.Player) string { return p.MiddleName }, func(p *model.Player, v string) error { p.MiddleName = v; return nil }, true},
+	{"lastName", func(p model.Player) string { return p.LastName }, func(p *model.Player, v string) error { p.LastName = v; return nil }, true},
+	{"dateOfBirth", func(p model.Player) string { return p.DateOfBirth }, func(p *model.Player, v string) error { p.DateOfBirth = v; return nil }, true},
+	{"squadNumber", func(p model.Player) string { return strconv.Itoa(p.SquadNumber) }, func(p *model.Player, v string) error {
+		squadNumber, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("squadNumber must be an integer, got %q", v)
+		}
+		p.SquadNumber = squadNumber
+		return nil
+	}, true},
+	{"position", func(p model.Player) string { return p.Position }, func(p *model.Player, v string) error { p.Position = v; return nil }, true},
+	{"abbrPosition", func(p model.Player) string { return p.AbbrPosition }, func(p *model.Player, v string) error { p.AbbrPosition = v; return nil }, true},
+	{"team", func(p model.Player) string { return p.Team }, func(p *model.Player, v string) error { p.Team = v; return nil }, true},
+	{"league", func(p model.Player) string { return p.League }, func(p *model.Player, v string) error { p.League = v; return nil }, true},
+	{"starting11", func(p model.Player) string { return strconv.FormatBool(p.Starting11) }, func(p *model.Player, v string) error {
+		if v == "" {
+			p.Starting11 = false
+			return nil
+		}
+		starting11, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("starting11 must be a boolean, got %q", v)
+		}
+		p.Starting11 = starting11
+		return nil
+	}, true},
+}
+
+// bulkRow is one decoded row of a bulk import: either a player, or the
+// error that prevented the row from being decoded.  Row-level errors are
+// reported per row instead of failing the whole request.
+type bulkRow struct {
+	player model.Player
+	err    error
+}
+
+// decodeBulk splits a bulk import body into rows according to its media
+// type.  Errors that make the body as a whole unusable (unknown media type,
+// a JSON body that is not an array, a CSV header naming an unknown column,
+// too many rows, a body cut short by http.MaxBytesReader) are returned as
+// the second value.
+func decodeBulk(contentType string, body io.Reader) ([]bulkRow, error) {
+	switch contentType {
+	case "application/json":
+		return decodeBulkJSON(body)
+	case NDJSONContentType:
+		return decodeBulkNDJSON(body)
+	case CSVContentType:
+		return decodeBulkCSV(body)
+	default:
+		return nil, errUnsupportedBulkType
+	}
+}
+
+// decodeBulkJSON decodes a JSON array of players, one element at a time, so
+// that it stops reading at the first row past MaxBulkRows.  Each element is
+// decoded on its own, so a malformed element only fails its row.
+func decodeBulkJSON(body io.Reader) ([]bulkRow, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("the body must be a JSON array of players: %w", err)
+	}
+	if token != json.Delim('[') {
+		return nil, fmt.Errorf("the body must be a JSON array of players, not %v", token)
+	}
+	var rows []bulkRow
+	for decoder.More() {
+		if len(rows) == MaxBulkRows {
+			return nil, errTooManyRows
+		}
+		var element json.RawMessage
+		if err := decoder.Decode(&element); err != nil {
+			return nil, fmt.Errorf("the body must be a JSON array of players: %w", err)
+		}
+		var row bulkRow
+		row.err = json.Unmarshal(element, &row.player)
+		rows = append(rows, row)
+	}
+	// The closing bracket.
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("the body must be a JSON array of players: %w", err)
+	}
+	return rows, nil
+}
+
+// decodeBulkNDJSON decodes one player per non-blank line.
+func decodeBulkNDJSON(body io.Reader) ([]bulkRow, error) {
+	var rows []bulkRow
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == MaxBulkRows {
+			return nil, errTooManyRows
+		}
+		var row bulkRow
+		row.err = json.Unmarshal(line, &row.player)
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// decodeBulkCSV decodes a CSV file whose first record is a header naming
+// the columns, in any order, by the JSON field names used in csvColumns.
+// Columns left out of the header keep their zero value; the id column is
+// accepted (so an export can be re-imported) but ignored.
+func decodeBulkCSV(body io.Reader) ([]bulkRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("the body must start with a CSV header row: %w", err)
+	}
+	columns := make([]csvColumn, len(header))
+	for i, name := range header {
+		found := false
+		for _, column := range csvColumns {
+			if column.name == name {
+				columns[i], found = column, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+	}
+	var rows []bulkRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if len(rows) == MaxBulkRows {
+			return nil, errTooManyRows
+		}
+		var row bulkRow
+		var parseErr *csv.ParseError
+		switch {
+		case err != nil && !errors.As(err, &parseErr):
+			// Reading the body failed, not parsing the record.
+			return nil, err
+		case err != nil:
+			row.err = err
+		case len(record) != len(columns):
+			row.err = fmt.Errorf("expected %d fields, got %d", len(columns), len(record))
+		default:
+			for i, value := range record {
+				if !columns[i].input {
+					continue
+				}
+				if err := columns[i].set(&row.player, value); err != nil {
+					row.err = err
+					break
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// exportEncoder writes players to an export stream in one format.
+type exportEncoder interface {
+	// Encode writes one player.
+	Encode(player model.Player) error
+	// Flush pushes buffered output to the underlying writer.
+	Flush() error
+}
+
+// newExportEncoder returns the encoder and media type for format ("csv" or
+// "ndjson").  The CSV encoder writes its header row immediately.
+func newExportEncoder(format string, writer io.Writer) (exportEncoder, string, error) {
+	switch format {
+	case "ndjson":
+		return ndjsonEncoder{json.NewEncoder(writer)}, NDJSONContentType, nil
+	case "csv":
+		encoder := csvEncoder{csv.NewWriter(writer)}
+		header := make([]string, len(csvColumns))
+		for i, column := range csvColumns {
+			header[i] = column.name
+		}
+		return encoder, CSVContentType + "; charset=utf-8", encoder.writer.Write(header)
+	default:
+		return nil, "", fmt.Errorf("format must be csv or ndjson, got %q", format)
+	}
+}
+
+// ndjsonEncoder writes one compact JSON object per line.  json.Encoder
+// appends the newline and does not buffer, so Flush has nothing to do.
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e ndjsonEncoder) Encode(player model.Player) error { return e.encoder.Encode(player) }
+
+func (e ndjsonEncoder) Flush() error { return nil }
+
+// csvEncoder writes one record per player, in csvColumns order.
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func (e csvEncoder) Encode(player model.Player) error {
+	record := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		record[i] = column.get(player)
+	}
+	return e.writer.Write(record)
+}
+
+func (e csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
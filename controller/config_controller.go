@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+)
+
+// ConfigController exposes the configuration the application was started
+// with.
+type ConfigController struct {
+	config config.Config
+}
+
+// NewConfigController returns a ConfigController serving the given
+// configuration.  Secrets are redacted once, here, so the handler can never
+// leak them.
+func NewConfigController(config config.Config) *ConfigController {
+	return &ConfigController{config: config.Redacted()}
+}
+
+// GetConfig retrieves the effective configuration
+//
+// @Summary Retrieves the effective configuration
+// @Description The configuration after defaults, environment variables, the config file
+// @Description and command-line flags have been applied, in that order. Credentials
+// @Description embedded in the database URL are redacted. Requires the admin role.
+// @Tags admin
+// @Produce application/json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} config.Config "OK"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Router /admin/config [get]
+func (c *ConfigController) GetConfig(context *gin.Context) {
+	context.IndentedJSON(http.StatusOK, c.config)
+}
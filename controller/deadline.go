@@ -0,0 +1,44 @@
+package controller
+
+import (
+	gocontext "context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parentContextKey is the gin.Context key under which Deadline keeps the
+// request's context as it was before the deadline, for liftDeadline.
+const parentContextKey = "controller.parentContext"
+
+// statusClientClosedRequest is the status logged for a request whose client
+// went away before it was answered (nginx's 499); the client never sees it.
+const statusClientClosedRequest = 499
+
+// Deadline is a middleware factory that gives every request timeout to be
+// handled: the request's context, which every PlayerService method is handed
+// and runs its statements with, is cancelled at the deadline, so a slow query
+// is interrupted and answered with 503 Service Unavailable instead of holding
+// its connection until the write timeout.  The context is also
+// cancelled when the client goes away.
+//
+// Long-lived responses, such as the change stream, lift the deadline with
+// liftDeadline.
+func Deadline(timeout time.Duration) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		parent := context.Request.Context()
+		ctx, cancel := gocontext.WithTimeout(parent, timeout)
+		defer cancel()
+		context.Set(parentContextKey, parent)
+		context.Request = context.Request.WithContext(ctx)
+		context.Next()
+	}
+}
+
+// liftDeadline restores the request's context as it was before Deadline, so
+// the request is only cancelled when its client goes away.
+func liftDeadline(context *gin.Context) {
+	if parent, ok := context.Value(parentContextKey).(gocontext.Context); ok {
+		context.Request = context.Request.WithContext(parent)
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+)
+
+// heartbeatInterval is how often an idle stream sends a comment, so proxies
+// and load balancers do not close it for inactivity.
+const heartbeatInterval = 15 * time.Second
+
+// EventsController holds dependencies for the player change stream.
+type EventsController struct {
+	broker *events.Broker
+}
+
+// NewEventsController returns an EventsController streaming the events of
+// broker.
+func NewEventsController(broker *events.Broker) *EventsController {
+	return &EventsController{broker: broker}
+}
+
+// Stream streams player changes as Server-Sent Events
+//
+// @Summary Streams player changes as Server-Sent Events
+// @Description Each change is sent as an event of type player.created, player.updated or
+// @Description player.deleted, with an id and the player as JSON data (as it was before
+// @Description the change for player.deleted). A client reconnecting with Last-Event-ID
+// @Description first receives the events it missed; when those are no longer known, a
+// @Description stream.reset event tells it to reload the players instead.
+// @Tags players
+// @Produce text/event-stream,application/problem+json
+// @Param Last-Event-ID header string false "ID of the last event received, to resume after"
+// @Success 200 {string} string "Event stream"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Router /players/events [get]
+func (c *EventsController) Stream(context *gin.Context) {
+	subscription := c.broker.Subscribe(context.GetHeader("Last-Event-ID"))
+	defer subscription.Close()
+
+	// The stream outlives the server's write timeout, and the request
+	// deadline, by design.
+	_ = http.NewResponseController(context.Writer).SetWriteDeadline(time.Time{})
+	liftDeadline(context)
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+	context.Status(http.StatusOK)
+
+	if subscription.Reset {
+		fmt.Fprintf(context.Writer, "id: %d\nevent: %s\ndata: {}\n\n", subscription.Current, events.StreamReset)
+	}
+	for _, event := range subscription.Replay {
+		writeEvent(context, event)
+	}
+	context.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-context.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// Dropped for falling behind, or shutting down: the client
+				// reconnects with Last-Event-ID.
+				return
+			}
+			writeEvent(context, event)
+		case <-heartbeat.C:
+			fmt.Fprint(context.Writer, ": keep-alive\n\n")
+		}
+		context.Writer.Flush()
+	}
+}
+
+// writeEvent writes event in the text/event-stream format.
+func writeEvent(context *gin.Context, event events.Event) {
+	data, err := json.Marshal(event.Player)
+	if err != nil {
+		_ = context.Error(err)
+		return
+	}
+	fmt.Fprintf(context.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
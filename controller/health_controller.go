@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/health"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+// readinessTimeout bounds a readiness probe, so a hung dependency makes it
+// fail instead of hang (scripts/healthcheck.sh gives up after 2 seconds).
+const readinessTimeout = time.Second
+
+// HealthController answers the liveness and readiness probes.  Readiness
+// runs the dependency checks it was given, and fails without running them
+// once Drain has been called when the server starts shutting down, so load
+// balancers and orchestrators stop routing new requests to an instance that
+// is going away.
+type HealthController struct {
+	checks   []health.Check
+	draining atomic.Bool
+}
+
+// NewHealthController returns a HealthController whose readiness probe runs
+// checks.
+func NewHealthController(checks ...health.Check) *HealthController {
+	return &HealthController{checks: checks}
+}
+
+// Drain makes every later readiness probe fail.  It is safe to call from any
+// goroutine, and more than once.
+func (c *HealthController) Drain() {
+	c.draining.Store(true)
+}
+
+// GetLive reports whether the process is running
+//
+// @Summary Reports whether the process is running
+// @Description Always 200 while the server can answer at all; it checks no dependency, so
+// @Description an outage never gets a healthy instance restarted.
+// @Tags health
+// @Produce application/json
+// @Success 200 {object} model.HealthReport "OK"
+// @Router /health/live [get]
+func (c *HealthController) GetLive(context *gin.Context) {
+	context.JSON(http.StatusOK, model.HealthReport{Status: model.HealthOK})
+}
+
+// GetReady reports whether the server accepts new work
+//
+// @Summary Reports whether the server accepts new work
+// @Description Checks the database connection, the schema migration version against the
+// @Description latest embedded migration, and the response cache, and reports each with its
+// @Description latency. Returns 503 with status "unavailable" when a check fails, or with
+// @Description status "draining" once the server has received SIGINT or SIGTERM.
+// @Tags health
+// @Produce application/json
+// @Success 200 {object} model.HealthReport "OK"
+// @Failure 503 {object} model.HealthReport "Service Unavailable"
+// @Router /health/ready [get]
+func (c *HealthController) GetReady(context *gin.Context) {
+	if c.draining.Load() {
+		context.JSON(http.StatusServiceUnavailable, model.HealthReport{Status: model.HealthDraining})
+		return
+	}
+	checks, healthy := health.Run(context.Request.Context(), readinessTimeout, c.checks)
+	if !healthy {
+		context.JSON(http.StatusServiceUnavailable, model.HealthReport{Status: model.HealthUnavailable, Checks: checks})
+		return
+	}
+	context.JSON(http.StatusOK, model.HealthReport{Status: model.HealthOK, Checks: checks})
+}
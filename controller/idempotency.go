@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"gorm.io/gorm"
+)
+
+// Idempotency headers (draft-ietf-httpapi-idempotency-key-header).
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed" // "true" on a replayed response
+)
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodyBytes caps the size of the body of a request sent with an
+// Idempotency-Key, which is read whole to fingerprint it before the handler
+// runs: 4 KiB is a few times the size of a player.
+const maxIdempotentBodyBytes = 4 << 10
+
+// idempotencyLock is how long a request in progress holds its key, should
+// its process die before the response is stored.
+const idempotencyLock = time.Minute
+
+// replayedHeaders are the response headers stored, and replayed, with the
+// status and body; the others (X-Request-ID, rate limits) belong to the
+// request that receives them.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", PreferenceAppliedHeader}
+
+// Idempotency is a middleware factory that makes retries of a request sent
+// with an Idempotency-Key header safe: the first request runs, and its
+// response is stored in service for ttl; a retry with the same key gets that
+// response again, with Idempotent-Replayed: true, without running the
+// handler.  Keys belong to the client that sent them (see RateLimit), so
+// clients cannot replay each other's responses.
+//
+// Reusing a key for a request with another method, path or body is answered
+// with 422 Unprocessable Entity, and a retry arriving while the first
+// request is still in progress with 409 Conflict and Retry-After.  Server
+// errors (5xx) are not stored, so the request can be retried for real.
+// Requests without the header, or a nil service, run as usual.
+func Idempotency(service service.IdempotencyService, ttl time.Duration) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader(IdempotencyKeyHeader)
+		if service == nil || key == "" {
+			context.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(context, http.StatusBadRequest, fmt.Sprintf("%s must be at most %d characters long.", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(context.Writer, context.Request.Body, maxIdempotentBodyBytes))
+		if writeTooLargeProblem(context, err) {
+			return
+		}
+		if err != nil {
+			writeProblem(context, http.StatusBadRequest, "The request body could not be read: "+err.Error())
+			return
+		}
+		context.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The response is stored even when the client has gone away: it is
+		// the one most likely to retry.
+		ctx := gocontext.WithoutCancel(context.Request.Context())
+		now := time.Now().UTC()
+		record := model.IdempotencyRecord{
+			Owner:       clientKey(context),
+			Key:         key,
+			Fingerprint: requestFingerprint(context.Request, body),
+			Header:      map[string]string{},
+			Body:        []byte{},
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(idempotencyLock),
+		}
+		existing, err := service.Reserve(ctx, record, now)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			replayResponse(context, existing, record.Fingerprint)
+			return
+		}
+		if err != nil {
+			writeServiceProblem(context, err)
+			return
+		}
+
+		stored := false
+		defer func() {
+			// Also reached when the handler panics.
+			if !stored {
+				if err := service.Release(ctx, record.Owner, record.Key); err != nil {
+					slog.Error("idempotency: releasing the key failed", "error", err)
+				}
+			}
+		}()
+		writer := &recordingWriter{ResponseWriter: context.Writer}
+		context.Writer = writer
+		context.Next()
+		context.Writer = writer.ResponseWriter
+		if writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record.Status = writer.Status()
+		for _, name := range replayedHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				record.Header[name] = value
+			}
+		}
+		record.Body = writer.body.Bytes()
+		record.ExpiresAt = time.Now().UTC().Add(ttl)
+		if err := service.Complete(ctx, record); err != nil {
+			slog.Error("idempotency: storing the response failed", "error", err)
+			return
+		}
+		stored = true
+	}
+}
+
+// replayResponse answers a request whose key is already taken by existing.
+func replayResponse(context *gin.Context, existing model.IdempotencyRecord, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		writeProblem(context, http.StatusUnprocessableEntity, fmt.Sprintf("%s was already used for a different request.", IdempotencyKeyHeader))
+	case existing.Status == 0:
+		context.Header(RetryAfterHeader, "1")
+		writeProblem(context, http.StatusConflict, fmt.Sprintf("A request with this %s is still in progress; retry later.", IdempotencyKeyHeader))
+	default:
+		for name, value := range existing.Header {
+			context.Header(name, value)
+		}
+		context.Header(IdempotentReplayedHeader, "true")
+		context.AbortWithStatus(existing.Status)
+		_, _ = context.Writer.Write(existing.Body)
+	}
+}
+
+// requestFingerprint returns a hash of the method, path and body of request.
+// A JSON body is hashed in canonical form, so that a retry serialised with
+// other whitespace or key order is still recognised.
+func requestFingerprint(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", request.Method, strings.TrimSuffix(request.URL.Path, "/"))
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err == nil && !decoder.More() {
+		if canonical, err := json.Marshal(value); err == nil {
+			body = canonical
+		}
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the body it writes.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes data and keeps a copy.
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes s and keeps a copy.
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+const (
+	// MergePatchContentType is the media type of RFC 7396 JSON Merge Patch
+	// documents: a partial Player whose members replace the current values
+	// (null removes a member, i.e. resets it to its zero value).
+	MergePatchContentType = "application/merge-patch+json"
+
+	// JSONPatchContentType is the media type of RFC 6902 JSON Patch
+	// documents: an array of add/remove/replace/move/copy/test operations.
+	JSONPatchContentType = "application/json-patch+json"
+
+	// acceptPatch lists the patch formats PATCH understands, advertised in the
+	// Accept-Patch header (RFC 5789 §3.1) when a client sends another one.
+	acceptPatch = MergePatchContentType + ", " + JSONPatchContentType
+
+	// MaxPatchBytes caps the size of a PATCH body, which is read whole
+	// before the player it applies to is even looked up: 4 KiB is a few
+	// times the size of a complete player.
+	MaxPatchBytes = 4 << 10
+)
+
+// errPatchNotApplicable marks a well-formed patch that cannot be applied to
+// the player's current state, such as a failed "test" operation or a
+// "remove" of a member that is not there.
+var errPatchNotApplicable = errors.New("patch cannot be applied")
+
+// applyPatch applies the patch document in body, of the given media type, to
+// player and returns the patched player.
+//
+// The player is round-tripped through its JSON representation, so patches
+// address members by their JSON names ("/team", not "/Team") and see exactly
+// what GET returns.  The result is not validated here; the caller runs the
+// same binding rules a PUT body goes through.
+//
+// It writes the matching problem body and returns false on failure:
+//
+//   - unsupported media type          → 415 Unsupported Media Type
+//   - malformed patch or result       → 400 Bad Request
+//   - patch not applicable to player  → 409 Conflict
+func applyPatch(context *gin.Context, contentType string, body []byte, player model.Player) (model.Player, bool) {
+	original, err := json.Marshal(player)
+	if err != nil {
+		writeProblem(context, http.StatusInternalServerError, "An unexpected error occurred while processing the request.")
+		return player, false
+	}
+	var patched []byte
+	switch contentType {
+	case MergePatchContentType:
+		patched, err = jsonpatch.MergePatch(original, body)
+	case JSONPatchContentType:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			err = checkPatchOperations(patch)
+		}
+		if err == nil {
+			patched, err = patch.Apply(original)
+			if err != nil {
+				err = fmt.Errorf("%w: %w", errPatchNotApplicable, err)
+			}
+		}
+	default:
+		context.Header("Accept-Patch", acceptPatch)
+		writeProblem(context, http.StatusUnsupportedMediaType,
+			"PATCH requires a Content-Type of "+MergePatchContentType+" or "+JSONPatchContentType+".")
+		return player, false
+	}
+	if errors.Is(err, errPatchNotApplicable) {
+		writeProblem(context, http.StatusConflict, "The patch cannot be applied to the current player ("+err.Error()+").")
+		return player, false
+	}
+	if err != nil {
+		writeProblem(context, http.StatusBadRequest, "The request body is not a valid "+contentType+" document: "+err.Error())
+		return player, false
+	}
+	var result model.Player
+	if err := json.Unmarshal(patched, &result); err != nil {
+		writeProblem(context, http.StatusBadRequest, "The patched document is not a valid Player: "+err.Error())
+		return player, false
+	}
+	return result, true
+}
+
+// checkPatchOperations rejects operations RFC 6902 does not define before
+// the patch is applied, so a typo in "op" is reported as a malformed patch
+// (400) rather than as a conflict with the player's state (409).
+func checkPatchOperations(patch jsonpatch.Patch) error {
+	for i, operation := range patch {
+		switch operation.Kind() {
+		case "add", "remove", "replace", "move", "copy", "test":
+		default:
+			return fmt.Errorf("operation %d has unknown op %q", i, operation.Kind())
+		}
+	}
+	return nil
+}
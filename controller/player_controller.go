@@ -15,11 +15,15 @@ package controller
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/nanotaboada/go-samples-gin-restful/model"
@@ -32,6 +36,7 @@ import (
 // field is unexported because nothing outside this package needs it.
 type PlayerController struct {
 	service service.PlayerService
+	notify  func() // Called after every change; nil by default
 }
 
 // NewPlayerController returns a PlayerController wired to the given service.
@@ -41,38 +46,113 @@ func NewPlayerController(service service.PlayerService) *PlayerController {
 	return &PlayerController{service: service}
 }
 
-// isUniqueConstraintError reports whether err is a SQLite unique constraint
-// violation (SQLITE_CONSTRAINT_UNIQUE, error code 2067 or message containing
-// "UNIQUE constraint failed").
+// WithNotify returns a copy of the controller that calls notify after every
+// change it makes: creations (including bulk-imported and restored
+// players), updates, deletions and purges.  The service has already written
+// the change to the outbox; notify merely wakes the relay publishing it
+// (see outbox.Relay.Notify), which would otherwise wait for its next poll.
+func (c *PlayerController) WithNotify(notify func()) *PlayerController {
+	copied := *c
+	copied.notify = notify
+	return &copied
+}
+
+// changed calls notify, if any.
+func (c *PlayerController) changed() {
+	if c.notify != nil {
+		c.notify()
+	}
+}
+
+// serviceFor returns the service scoped to the request: its changes are
+// attributed to the principal Authenticate recorded (anonymous on
+// unauthenticated routes).  Each call is handed the request's context, so
+// its statements stop when the client goes away and are traced as children
+// of the request.
+func (c *PlayerController) serviceFor(context *gin.Context) service.PlayerService {
+	p, _ := principal(context)
+	return c.service.WithPrincipal(p)
+}
+
+// ChangedPlayersKey is the gin.Context key under which the mutating handlers
+// record the players they changed, as they were before and after the change.
+const ChangedPlayersKey = "controller.changedPlayers"
+
+// ChangedPlayers returns the players the request's handler changed, so the
+// caller can evict what it cached of them; nil when the handler changed
+// nothing.
+func ChangedPlayers(context *gin.Context) []model.Player {
+	value, _ := context.Get(ChangedPlayersKey)
+	players, _ := value.([]model.Player)
+	return players
+}
+
+// recordChanged adds players to the changed players of the request.
+func recordChanged(context *gin.Context, players ...model.Player) {
+	context.Set(ChangedPlayersKey, append(ChangedPlayers(context), players...))
+}
+
+// isUniqueConstraintError reports whether err is a unique constraint
+// violation: gorm.ErrDuplicatedKey, which data.Connect has GORM translate
+// every dialect's violation into, or an untranslated SQLite error whose
+// message contains "UNIQUE constraint failed".
 func isUniqueConstraintError(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed"))
+}
+
+// parseSquadNumber reads the :squadnumber path parameter as an int.  On
+// failure it writes a 400 problem body and returns false, so callers only
+// need to return.
+func parseSquadNumber(context *gin.Context) (int, bool) {
+	raw := context.Param("squadnumber")
+	squadNumber, err := strconv.Atoi(raw)
+	if err != nil {
+		writeProblem(context, http.StatusBadRequest, fmt.Sprintf("Squad number must be an integer, got %q.", raw))
+		return 0, false
+	}
+	return squadNumber, true
 }
 
 // Post creates a Player
 //
 // @Summary Creates a Player
+// @Description With an Idempotency-Key, a retry with the same key and payload gets the
+// @Description original response again (Idempotent-Replayed: true) instead of creating
+// @Description the player twice; the same key with another payload is refused with 422,
+// @Description and a retry while the first request runs with 409.
+// @Description
+// @Description The created player is returned, with its URL in Location, unless the
+// @Description request is sent with Prefer: return=minimal (RFC 7240).
 // @Tags players
 // @Accept application/json
+// @Produce application/json,application/problem+json
 // @Param player body model.Player true "Player"
-// @Success 201 "Created"
-// @Failure 400 "Bad Request"
-// @Failure 409 "Conflict"
-// @Failure 422 "Unprocessable Entity"
-// @Failure 500 "Internal Server Error"
+// @Param Idempotency-Key header string false "Client-chosen key (at most 255 characters) identifying retries of this request"
+// @Param Prefer header string false "return=minimal to leave the created player out of the response"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 201 {object} model.Player "Created"
+// @Header 201 {string} Location "URL of the created player"
+// @Header 201 {string} ETag "Strong entity tag of the created player"
+// @Header 201 {string} Preference-Applied "The return preference applied, when one was sent"
+// @Header 201 {string} Idempotent-Replayed "true when the response is replayed for an Idempotency-Key"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 409 {object} model.ProblemDetails "Conflict"
+// @Failure 413 {object} model.ProblemDetails "Payload Too Large (with an Idempotency-Key)"
+// @Failure 422 {object} model.ProblemDetails "Unprocessable Entity"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
 // @Router /players [post]
 func (c *PlayerController) Post(context *gin.Context) {
 	var player model.Player
 	// ShouldBindJSON deserialises the request body without writing a response
-	// automatically, giving us full control over the status code.
-	// validator.ValidationErrors signals a field-level constraint failure → 422.
-	// Any other error (EOF, syntax) is a malformed request → 400.
+	// automatically, giving us full control over the status code and body.
+	// writeBindingProblem distinguishes validation failures (422) from
+	// malformed JSON (400).
 	if err := context.ShouldBindJSON(&player); err != nil {
-		var ve validator.ValidationErrors
-		if errors.As(err, &ve) {
-			context.Status(http.StatusUnprocessableEntity)
-		} else {
-			context.Status(http.StatusBadRequest)
-		}
+		writeBindingProblem(context, err, "Player")
 		return
 	}
 	// UUID is always generated server-side; any client-provided ID is overwritten.
@@ -80,73 +160,417 @@ func (c *PlayerController) Post(context *gin.Context) {
 	player.ID = uuid.NewString()
 	// Conflict is checked by squadNumber (the user-facing unique identifier).
 	// If RetrieveBySquadNumber returns nil error, the squad number is taken → 409.
-	_, err := c.service.RetrieveBySquadNumber(player.SquadNumber)
+	_, err := c.serviceFor(context).RetrieveBySquadNumber(context.Request.Context(), player.SquadNumber)
 	if err == nil {
-		context.Status(http.StatusConflict)
+		writeProblem(context, http.StatusConflict, fmt.Sprintf("Squad number %d is already taken.", player.SquadNumber))
 		return
 	}
 	// errors.Is unwraps error chains, so it works even if the service wraps
 	// gorm.ErrRecordNotFound in another error.  Any error other than "not found"
 	// is an unexpected DB failure → 500.
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		context.Status(http.StatusInternalServerError)
+		writeServiceProblem(context, err)
 		return
 	}
-	if err := c.service.Create(&player); err != nil {
+	if err := c.serviceFor(context).Create(context.Request.Context(), &player); err != nil {
 		// A unique constraint violation means the squadNumber was inserted by a
-		// concurrent request between the preflight check and the INSERT → 409.
-		if isUniqueConstraintError(err) {
-			context.Status(http.StatusConflict)
-		} else {
-			context.Status(http.StatusInternalServerError)
+		// concurrent request between the preflight check and the INSERT → 409;
+		// writeServiceProblem maps it accordingly.
+		writeServiceProblem(context, err)
+		return
+	}
+	recordChanged(context, player)
+	c.changed()
+	// The player is served by GET /players/{id}, next to the collection it
+	// was posted to.
+	context.Header("Location", strings.TrimSuffix(context.Request.URL.Path, "/")+"/"+player.ID)
+	writeWrittenPlayer(context, player, true)
+}
+
+// BulkCreate creates many Players at once
+//
+// @Summary Creates many Players at once
+// @Description Rows are read from a JSON array, NDJSON (one player per line) or CSV
+// @Description (header row of JSON field names), selected by Content-Type. Every row is
+// @Description validated like a POST body. In atomic mode (default) nothing is inserted
+// @Description unless every row succeeds; in partial mode every valid, non-conflicting
+// @Description row is inserted. The body reports the outcome of each row. A body of more
+// @Description than 1000 rows or 1 MiB is refused with 413.
+// @Tags players
+// @Accept application/json,application/x-ndjson,text/csv
+// @Produce application/json,application/problem+json
+// @Param mode query string false "atomic (default) or partial" Enums(atomic, partial)
+// @Param players body []model.Player true "Players"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 201 {object} model.BulkImportResult "Created"
+// @Success 207 {object} model.BulkImportResult "Multi-Status (partial mode, some rows failed)"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 409 {object} model.BulkImportResult "Conflict (atomic mode, squad numbers taken)"
+// @Failure 413 {object} model.ProblemDetails "Payload Too Large"
+// @Failure 415 {object} model.ProblemDetails "Unsupported Media Type"
+// @Failure 422 {object} model.BulkImportResult "Unprocessable Entity (atomic mode, invalid rows)"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /players/bulk [post]
+func (c *PlayerController) BulkCreate(context *gin.Context) {
+	mode := context.DefaultQuery("mode", "atomic")
+	if mode != "atomic" && mode != "partial" {
+		writeProblem(context, http.StatusBadRequest, fmt.Sprintf("mode must be atomic or partial, got %q.", mode))
+		return
+	}
+	atomic := mode == "atomic"
+	rows, err := decodeBulk(context.ContentType(), http.MaxBytesReader(context.Writer, context.Request.Body, MaxBulkBytes))
+	if writeTooLargeProblem(context, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, errUnsupportedBulkType):
+		writeProblem(context, http.StatusUnsupportedMediaType,
+			"A bulk import requires a Content-Type of application/json, "+NDJSONContentType+" or "+CSVContentType+".")
+		return
+	case errors.Is(err, errTooManyRows):
+		writeProblem(context, http.StatusRequestEntityTooLarge, err.Error()+".")
+		return
+	case err != nil:
+		writeProblem(context, http.StatusBadRequest, err.Error()+".")
+		return
+	case len(rows) == 0:
+		writeProblem(context, http.StatusBadRequest, "A bulk import needs at least one row.")
+		return
+	}
+	result := model.BulkImportResult{Mode: mode, Rows: make([]model.BulkRowResult, len(rows))}
+	// Rows that decode and validate are collected for a single CreateBatch
+	// call; indexes maps each of them back to its position in the input.
+	var players []model.Player
+	var indexes []int
+	for i, row := range rows {
+		result.Rows[i] = model.BulkRowResult{Row: i + 1, SquadNumber: row.player.SquadNumber}
+		if row.err != nil {
+			result.Rows[i].Status = http.StatusBadRequest
+			result.Rows[i].Detail = row.err.Error()
+			continue
+		}
+		var ve validator.ValidationErrors
+		if err := binding.Validator.ValidateStruct(&row.player); errors.As(err, &ve) {
+			result.Rows[i].Status = http.StatusUnprocessableEntity
+			result.Rows[i].Detail = "One or more fields failed validation."
+			result.Rows[i].Errors = fieldErrors(ve)
+			continue
+		}
+		row.player.ID = uuid.NewString()
+		players = append(players, row.player)
+		indexes = append(indexes, i)
+	}
+	// An atomic import with an invalid row is rejected before touching the
+	// database; otherwise the batch decides per row (conflicts included).
+	if !atomic || len(indexes) == len(rows) {
+		rowErrors, err := c.serviceFor(context).CreateBatch(context.Request.Context(), players, atomic)
+		if err != nil {
+			writeServiceProblem(context, err)
+			return
 		}
+		for j, rowErr := range rowErrors {
+			i := indexes[j]
+			if rowErr != nil {
+				result.Rows[i].Status, result.Rows[i].Detail = serviceProblem(rowErr)
+				continue
+			}
+			result.Rows[i].Status = http.StatusCreated
+			result.Rows[i].ID = players[j].ID
+		}
+	}
+	status := tallyBulkResult(&result, atomic)
+	// Rows of a failed atomic import were marked 424 by tallyBulkResult, so
+	// only the rows actually inserted are still 201.
+	for j, i := range indexes {
+		if result.Rows[i].Status == http.StatusCreated {
+			recordChanged(context, players[j])
+		}
+	}
+	if result.Created > 0 {
+		c.changed()
+	}
+	context.JSON(status, result)
+}
+
+// tallyBulkResult fills in the Created and Failed counters and returns the
+// response status.  In an atomic import that failed, rows that were valid
+// (or would have been inserted) are marked 424 Failed Dependency, since
+// nothing was committed; the status is then 409 if every failure is a
+// squad-number conflict and 422 otherwise.  A partial import with failures
+// returns 207 Multi-Status.
+func tallyBulkResult(result *model.BulkImportResult, atomic bool) int {
+	status := http.StatusConflict
+	for _, row := range result.Rows {
+		switch row.Status {
+		case http.StatusCreated, 0:
+		case http.StatusConflict:
+			result.Failed++
+		default:
+			result.Failed++
+			status = http.StatusUnprocessableEntity
+		}
+	}
+	if result.Failed == 0 {
+		result.Created = len(result.Rows)
+		return http.StatusCreated
+	}
+	if !atomic {
+		result.Created = len(result.Rows) - result.Failed
+		return http.StatusMultiStatus
+	}
+	for i, row := range result.Rows {
+		if row.Status == http.StatusCreated || row.Status == 0 {
+			result.Rows[i].Status = http.StatusFailedDependency
+			result.Rows[i].ID = ""
+			result.Rows[i].Detail = "Not inserted because another row failed and the import is atomic."
+		}
+	}
+	return status
+}
+
+// Export streams every Player as CSV or NDJSON
+//
+// @Summary Streams every Player as CSV or NDJSON
+// @Description The format is taken from the format query parameter or, when it is
+// @Description absent, negotiated from the Accept header (NDJSON by default). Rows are
+// @Description ordered by squad number and written as they are read from the database.
+// @Tags players
+// @Produce application/x-ndjson,text/csv,application/problem+json
+// @Param format query string false "csv or ndjson" Enums(csv, ndjson)
+// @Success 200 {array} model.Player "OK"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 406 {object} model.ProblemDetails "Not Acceptable"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /players/export [get]
+func (c *PlayerController) Export(context *gin.Context) {
+	format := context.Query("format")
+	if format == "" {
+		// NegotiateFormat returns the first offer when Accept is absent or */*.
+		switch context.NegotiateFormat(NDJSONContentType, CSVContentType) {
+		case NDJSONContentType:
+			format = "ndjson"
+		case CSVContentType:
+			format = "csv"
+		default:
+			writeProblem(context, http.StatusNotAcceptable, "The export is available as "+NDJSONContentType+" or "+CSVContentType+".")
+			return
+		}
+	}
+	encoder, contentType, err := newExportEncoder(format, context.Writer)
+	if err != nil {
+		writeProblem(context, http.StatusBadRequest, err.Error()+".")
 		return
 	}
-	context.Status(http.StatusCreated)
+	context.Header("Content-Type", contentType)
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="players.%s"`, format))
+	count := 0
+	err = c.serviceFor(context).StreamAll(context.Request.Context(), func(player model.Player) error {
+		if err := encoder.Encode(player); err != nil {
+			return err
+		}
+		// Flushing periodically sends rows to the client while the rest of
+		// the table is still being read, instead of buffering it all.
+		if count++; count%100 == 0 {
+			if err := encoder.Flush(); err != nil {
+				return err
+			}
+			context.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = encoder.Flush()
+	}
+	if err != nil {
+		// Once rows have been sent the status line is gone; the error can
+		// only be recorded and the stream cut short.
+		if context.Writer.Written() {
+			_ = context.Error(err)
+			return
+		}
+		writeServiceProblem(context, err)
+		return
+	}
+	context.Status(http.StatusOK)
 }
 
 // GetAll retrieves all players
 //
 // @Summary Retrieves all players
+// @Description Without query parameters every player is returned. Passing page,
+// @Description pageSize or after returns a single page instead; the total number
+// @Description of matching players is reported in X-Total-Count and navigation
+// @Description links (first, prev, next, last) in the Link header (RFC 8288).
 // @Tags players
-// @Produce application/json
+// @Produce application/json,application/problem+json
+// @Param page query int false "1-based page number (offset pagination)"
+// @Param pageSize query int false "Players per page (1-100, default 20 when paginating)"
+// @Param after query string false "Player.ID of the last player on the previous page (cursor pagination)"
+// @Param sort query string false "Comma-separated fields, prefix with - for descending (e.g. lastName,-squadNumber)"
+// @Param team query string false "Filter by team"
+// @Param league query string false "Filter by league"
+// @Param abbrPosition query string false "Filter by abbreviated position"
+// @Param starting11 query bool false "Filter by starting eleven membership"
 // @Success 200 {array} model.Player "OK"
-// @Failure 500 "Internal Server Error"
+// @Header 200 {integer} X-Total-Count "Number of players matching the filters"
+// @Header 200 {string} Link "Pagination links"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
 // @Router /players [get]
 func (c *PlayerController) GetAll(context *gin.Context) {
-	players, err := c.service.RetrieveAll()
+	query, err := parsePlayerQuery(context)
+	if err != nil {
+		writeProblem(context, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := c.serviceFor(context).RetrievePage(context.Request.Context(), query)
 	if err != nil {
-		context.Status(http.StatusInternalServerError)
+		// ErrInvalidQuery covers semantic problems the parser cannot detect on
+		// its own, such as an unknown sort field or a cursor for a missing
+		// player; writeServiceProblem maps it to 400.
+		writeServiceProblem(context, err)
 		return
 	}
+	context.Header("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if links := paginationLinks(context.Request.URL, query, page); links != "" {
+		context.Header("Link", links)
+	}
 	// IndentedJSON writes a pretty-printed JSON body with the given status code.
 	// Use context.JSON for compact output in production if payload size matters.
-	context.IndentedJSON(http.StatusOK, players)
+	context.IndentedJSON(http.StatusOK, page.Players)
+}
+
+// parsePlayerQuery translates the GET /players query string into a
+// service.PlayerQuery.  Only syntax is checked here (integers, booleans,
+// ranges); whether a sort field exists is decided by the service, which owns
+// the column whitelist.
+func parsePlayerQuery(context *gin.Context) (service.PlayerQuery, error) {
+	query := service.PlayerQuery{
+		After:        context.Query("after"),
+		Team:         context.Query("team"),
+		League:       context.Query("league"),
+		AbbrPosition: context.Query("abbrPosition"),
+	}
+	if raw, ok := context.GetQuery("page"); ok {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return query, fmt.Errorf("page must be a positive integer, got %q", raw)
+		}
+		query.Page = page
+	}
+	if raw, ok := context.GetQuery("pageSize"); ok {
+		pageSize, err := strconv.Atoi(raw)
+		if err != nil || pageSize < 1 || pageSize > service.MaxPageSize {
+			return query, fmt.Errorf("pageSize must be between 1 and %d, got %q", service.MaxPageSize, raw)
+		}
+		query.PageSize = pageSize
+	}
+	if query.Page > 0 && query.After != "" {
+		return query, errors.New("page and after cannot be combined")
+	}
+	// Asking for a page (or a cursor) without a size falls back to the default
+	// size; asking for a size without a page starts at the first page.
+	if (query.Page > 0 || query.After != "") && query.PageSize == 0 {
+		query.PageSize = service.DefaultPageSize
+	}
+	if query.PageSize > 0 && query.Page == 0 && query.After == "" {
+		query.Page = 1
+	}
+	if raw, ok := context.GetQuery("starting11"); ok {
+		starting11, err := strconv.ParseBool(raw)
+		if err != nil {
+			return query, fmt.Errorf("starting11 must be a boolean, got %q", raw)
+		}
+		query.Starting11 = &starting11
+	}
+	if raw := context.Query("sort"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			descending := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			if field == "" {
+				return query, fmt.Errorf("sort contains an empty field: %q", raw)
+			}
+			query.Sort = append(query.Sort, service.SortField{Field: field, Descending: descending})
+		}
+	}
+	return query, nil
+}
+
+// paginationLinks builds an RFC 8288 Link header value for a paginated
+// response.  Links reuse the request's path and query string so filters and
+// sort order carry over; only the pagination parameters are rewritten.
+// An unpaginated response has no links.
+func paginationLinks(requestURL *url.URL, query service.PlayerQuery, page service.PlayerPage) string {
+	if !query.Paginated() {
+		return ""
+	}
+	link := func(rel string, set map[string]string) string {
+		values := requestURL.Query()
+		values.Del("page")
+		values.Del("after")
+		values.Set("pageSize", strconv.Itoa(query.PageSize))
+		for key, value := range set {
+			values.Set(key, value)
+		}
+		return fmt.Sprintf("<%s?%s>; rel=%q", requestURL.Path, values.Encode(), rel)
+	}
+	var links []string
+	if query.After != "" {
+		// Cursor pages only move forward: a full page means there may be more.
+		if len(page.Players) == query.PageSize {
+			last := page.Players[len(page.Players)-1]
+			links = append(links, link("next", map[string]string{"after": last.ID}))
+		}
+		return strings.Join(links, ", ")
+	}
+	lastPage := int((page.Total + int64(query.PageSize) - 1) / int64(query.PageSize))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	links = append(links, link("first", map[string]string{"page": "1"}))
+	if query.Page > 1 {
+		links = append(links, link("prev", map[string]string{"page": strconv.Itoa(min(query.Page-1, lastPage))}))
+	}
+	if query.Page < lastPage {
+		links = append(links, link("next", map[string]string{"page": strconv.Itoa(query.Page + 1)}))
+	}
+	links = append(links, link("last", map[string]string{"page": strconv.Itoa(lastPage)}))
+	return strings.Join(links, ", ")
 }
 
 // GetByID retrieves a Player by its internal UUID
 //
 // @Summary Retrieves a Player by its internal UUID
 // @Tags players
-// @Produce application/json
+// @Produce application/json,application/problem+json
 // @Param id path string true "Player.ID (UUID)"
+// @Param If-None-Match header string false "ETag from a previous response; a match returns 304"
 // @Success 200 {object} model.Player "OK"
-// @Failure 404 "Not Found"
-// @Failure 500 "Internal Server Error"
+// @Header 200 {string} ETag "Strong entity tag of the player's current version"
+// @Success 304 "Not Modified"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
 // @Router /players/{id} [get]
 func (c *PlayerController) GetByID(context *gin.Context) {
 	// context.Param reads a named route parameter defined with ":name" syntax.
 	// context.Param("id") returns the UUID value captured from the URL.
 	id := context.Param("id")
-	player, err := c.service.RetrieveByID(id)
+	player, err := c.serviceFor(context).RetrieveByID(context.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			context.Status(http.StatusNotFound)
-		} else {
-			context.Status(http.StatusInternalServerError)
-		}
+		writeServiceProblem(context, err)
 		return
 	}
+	// If-None-Match is answered by the NotModified middleware registered in
+	// route, which compares it against this header on the way out.
+	context.Header("ETag", playerETag(player))
 	context.IndentedJSON(http.StatusOK, player)
 }
 
@@ -154,121 +578,322 @@ func (c *PlayerController) GetByID(context *gin.Context) {
 //
 // @Summary Retrieves a Player by its Squad Number
 // @Tags players
-// @Produce application/json
+// @Produce application/json,application/problem+json
 // @Param squadnumber path string true "Player.SquadNumber"
+// @Param If-None-Match header string false "ETag from a previous response; a match returns 304"
 // @Success 200 {object} model.Player "OK"
-// @Failure 400 "Bad Request"
-// @Failure 404 "Not Found"
-// @Failure 500 "Internal Server Error"
+// @Header 200 {string} ETag "Strong entity tag of the player's current version"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
 // @Router /players/squadnumber/{squadnumber} [get]
 func (c *PlayerController) GetBySquadNumber(context *gin.Context) {
-	// Route parameters are always strings; strconv.Atoi converts to int.
+	// Route parameters are always strings; parseSquadNumber converts to int.
 	// A non-numeric value (e.g. "/players/squadnumber/abc") returns an error → 400.
-	squadNumber, err := strconv.Atoi(context.Param("squadnumber"))
-	if err != nil {
-		context.Status(http.StatusBadRequest)
+	squadNumber, ok := parseSquadNumber(context)
+	if !ok {
 		return
 	}
-	player, err := c.service.RetrieveBySquadNumber(squadNumber)
+	player, err := c.serviceFor(context).RetrieveBySquadNumber(context.Request.Context(), squadNumber)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			context.Status(http.StatusNotFound)
-		} else {
-			context.Status(http.StatusInternalServerError)
-		}
+		writeServiceProblem(context, err)
 		return
 	}
+	context.Header("ETag", playerETag(player))
 	context.IndentedJSON(http.StatusOK, player)
 }
 
 // Put updates (entirely) a Player by its Squad Number
 //
 // @Summary Updates (entirely) a Player by its Squad Number
+// @Description Nothing is returned, unless the request is sent with
+// @Description Prefer: return=representation (RFC 7240): the updated player is then returned with 200.
 // @Tags players
 // @Accept application/json
+// @Produce application/json,application/problem+json
 // @Param squadnumber path string true "Player.SquadNumber"
+// @Param If-Match header string false "ETag the update is conditional on"
+// @Param Prefer header string false "return=representation to get the updated player back"
 // @Param player body model.Player true "Player"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} model.Player "OK (Prefer: return=representation)"
+// @Header 200 {string} ETag "Strong entity tag of the updated player"
+// @Header 200 {string} Preference-Applied "return=representation"
 // @Success 204 "No Content"
-// @Failure 400 "Bad Request"
-// @Failure 404 "Not Found"
-// @Failure 422 "Unprocessable Entity"
-// @Failure 500 "Internal Server Error"
+// @Header 204 {string} ETag "Strong entity tag of the updated player"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 412 {object} model.ProblemDetails "Precondition Failed"
+// @Failure 422 {object} model.ProblemDetails "Unprocessable Entity"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
 // @Router /players/squadnumber/{squadnumber} [put]
 func (c *PlayerController) Put(context *gin.Context) {
-	squadNumber, err := strconv.Atoi(context.Param("squadnumber"))
-	if err != nil {
-		context.Status(http.StatusBadRequest)
+	squadNumber, ok := parseSquadNumber(context)
+	if !ok {
 		return
 	}
 	var player model.Player
 	// ShouldBindJSON gives us control over the response code.
 	// validator.ValidationErrors → 422; parse/syntax errors → 400.
-	if err = context.ShouldBindJSON(&player); err != nil {
-		var ve validator.ValidationErrors
-		if errors.As(err, &ve) {
-			context.Status(http.StatusUnprocessableEntity)
-		} else {
-			context.Status(http.StatusBadRequest)
-		}
+	if err := context.ShouldBindJSON(&player); err != nil {
+		writeBindingProblem(context, err, "Player")
 		return
 	}
 	// Guard against mismatched URL and body: the squad number in the URL must
 	// equal the one in the JSON body, otherwise the request is ambiguous → 400.
 	if player.SquadNumber != squadNumber {
-		context.Status(http.StatusBadRequest)
+		writeProblem(context, http.StatusBadRequest, fmt.Sprintf(
+			"Squad number in the body (%d) does not match the one in the path (%d).", player.SquadNumber, squadNumber))
 		return
 	}
-	existing, err := c.service.RetrieveBySquadNumber(squadNumber)
+	existing, err := c.serviceFor(context).RetrieveBySquadNumber(context.Request.Context(), squadNumber)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			context.Status(http.StatusNotFound)
-		} else {
-			context.Status(http.StatusInternalServerError)
-		}
+		writeServiceProblem(context, err)
+		return
+	}
+	// A client that sends If-Match only wants the update applied to the
+	// version it last saw; anything else is a lost update → 412.
+	if !checkIfMatch(context, existing) {
 		return
 	}
 	// Preserve the internal UUID — clients identify players by squadNumber, not UUID.
-	// Without this, Save would try to zero out the primary key, causing a DB error.
+	// Without this, the UPDATE would try to zero out the primary key, causing a DB error.
 	player.ID = existing.ID
-	if err = c.service.Update(&player); err != nil {
-		context.Status(http.StatusInternalServerError)
+	// Update is conditional on the version read above, which also catches a
+	// concurrent write between the lookup and the UPDATE (ErrVersionConflict → 412).
+	player.Version = existing.Version
+	if err = c.serviceFor(context).Update(context.Request.Context(), &player); err != nil {
+		writeServiceProblem(context, err)
 		return
 	}
-	// 204 No Content is conventional for a successful PUT with no response body.
-	context.Status(http.StatusNoContent)
+	recordChanged(context, existing, player)
+	c.changed()
+	writeWrittenPlayer(context, player, false)
+}
+
+// Patch updates (partially) a Player by its Squad Number
+//
+// @Summary Updates (partially) a Player by its Squad Number
+// @Description The body is either an RFC 7396 JSON Merge Patch (a partial Player)
+// @Description or an RFC 6902 JSON Patch (an array of operations), selected by
+// @Description Content-Type. The patched player must pass the same validation as a PUT body.
+// @Description Like PUT, nothing is returned unless the request is sent with Prefer: return=representation.
+// @Tags players
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce application/json,application/problem+json
+// @Param squadnumber path string true "Player.SquadNumber"
+// @Param If-Match header string false "ETag the update is conditional on"
+// @Param Prefer header string false "return=representation to get the updated player back"
+// @Param patch body object true "Merge patch or JSON Patch document"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} model.Player "OK (Prefer: return=representation)"
+// @Header 200 {string} ETag "Strong entity tag of the updated player"
+// @Header 200 {string} Preference-Applied "return=representation"
+// @Success 204 "No Content"
+// @Header 204 {string} ETag "Strong entity tag of the updated player"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 409 {object} model.ProblemDetails "Conflict"
+// @Failure 412 {object} model.ProblemDetails "Precondition Failed"
+// @Failure 413 {object} model.ProblemDetails "Payload Too Large"
+// @Failure 415 {object} model.ProblemDetails "Unsupported Media Type"
+// @Failure 422 {object} model.ProblemDetails "Unprocessable Entity"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /players/squadnumber/{squadnumber} [patch]
+func (c *PlayerController) Patch(context *gin.Context) {
+	squadNumber, ok := parseSquadNumber(context)
+	if !ok {
+		return
+	}
+	// The patch is applied to the stored player, so the raw body is read
+	// instead of being bound to a model.Player.
+	body, err := io.ReadAll(http.MaxBytesReader(context.Writer, context.Request.Body, MaxPatchBytes))
+	if writeTooLargeProblem(context, err) {
+		return
+	}
+	if err != nil {
+		writeProblem(context, http.StatusBadRequest, "The request body could not be read.")
+		return
+	}
+	existing, err := c.serviceFor(context).RetrieveBySquadNumber(context.Request.Context(), squadNumber)
+	if err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	if !checkIfMatch(context, existing) {
+		return
+	}
+	// context.ContentType strips parameters such as "; charset=utf-8".
+	player, ok := applyPatch(context, context.ContentType(), body, existing)
+	if !ok {
+		return
+	}
+	// binding.Validator is the validator ShouldBindJSON uses, so a patched
+	// player is held to exactly the same rules as a POST or PUT body.
+	if err := binding.Validator.ValidateStruct(&player); err != nil {
+		writeBindingProblem(context, err, "Player")
+		return
+	}
+	// The UUID and version are server-owned; a patch cannot change them.
+	// Unlike PUT, the squad number may change: a clash with another player
+	// is reported by the unique index (→ 409 via writeServiceProblem).
+	player.ID = existing.ID
+	player.Version = existing.Version
+	if err = c.serviceFor(context).Update(context.Request.Context(), &player); err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	recordChanged(context, existing, player)
+	c.changed()
+	writeWrittenPlayer(context, player, false)
 }
 
 // Delete deletes a Player by its Squad Number
 //
 // @Summary Deletes a Player by its Squad Number
+// @Description The player is soft-deleted: it moves to the trash (GET /players/trash),
+// @Description from where it can be restored until the trash is purged.
 // @Tags players
+// @Produce application/problem+json
 // @Param squadnumber path string true "Player.SquadNumber"
+// @Param If-Match header string false "ETag the deletion is conditional on"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Success 204 "No Content"
-// @Failure 400 "Bad Request"
-// @Failure 404 "Not Found"
-// @Failure 500 "Internal Server Error"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 412 {object} model.ProblemDetails "Precondition Failed"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
 // @Router /players/squadnumber/{squadnumber} [delete]
 func (c *PlayerController) Delete(context *gin.Context) {
-	squadNumber, err := strconv.Atoi(context.Param("squadnumber"))
-	if err != nil {
-		context.Status(http.StatusBadRequest)
+	squadNumber, ok := parseSquadNumber(context)
+	if !ok {
 		return
 	}
 	// Fetch first so GORM has a populated struct (including the primary key)
 	// before issuing the DELETE statement; deleting by struct avoids an
 	// unintended "DELETE FROM players WHERE id = 0" on a zero-value struct.
-	existing, err := c.service.RetrieveBySquadNumber(squadNumber)
+	existing, err := c.serviceFor(context).RetrieveBySquadNumber(context.Request.Context(), squadNumber)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			context.Status(http.StatusNotFound)
-		} else {
-			context.Status(http.StatusInternalServerError)
-		}
+		writeServiceProblem(context, err)
 		return
 	}
-	if err = c.service.Delete(&existing); err != nil {
-		context.Status(http.StatusInternalServerError)
+	if !checkIfMatch(context, existing) {
 		return
 	}
+	if err = c.serviceFor(context).Delete(context.Request.Context(), &existing); err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	recordChanged(context, existing)
+	c.changed()
 	context.Status(http.StatusNoContent)
 }
+
+// GetTrash retrieves all soft-deleted players
+//
+// @Summary Retrieves all soft-deleted players
+// @Description Deleted players stay in the trash, most recently deleted first,
+// @Description until they are restored or purged.
+// @Tags players
+// @Produce application/json,application/problem+json
+// @Success 200 {array} model.DeletedPlayer "OK"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /players/trash [get]
+func (c *PlayerController) GetTrash(context *gin.Context) {
+	players, err := c.serviceFor(context).RetrieveDeleted(context.Request.Context())
+	if err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	deleted := make([]model.DeletedPlayer, 0, len(players))
+	for _, player := range players {
+		deleted = append(deleted, model.DeletedPlayer{Player: player, DeletedAt: player.DeletedAt.Time})
+	}
+	context.IndentedJSON(http.StatusOK, deleted)
+}
+
+// Restore restores a soft-deleted Player by its Squad Number
+//
+// @Summary Restores a soft-deleted Player by its Squad Number
+// @Description When several deleted players had the squad number, the most recently
+// @Description deleted one is restored. If the squad number has been given to another
+// @Description player since, the restore is refused with 409 Conflict.
+// @Tags players
+// @Produce application/json,application/problem+json
+// @Param squadnumber path string true "Player.SquadNumber"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} model.Player "OK"
+// @Header 200 {string} ETag "Strong entity tag of the restored player"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 409 {object} model.ProblemDetails "Conflict"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /players/squadnumber/{squadnumber}/restore [post]
+func (c *PlayerController) Restore(context *gin.Context) {
+	squadNumber, ok := parseSquadNumber(context)
+	if !ok {
+		return
+	}
+	player, err := c.serviceFor(context).Restore(context.Request.Context(), squadNumber)
+	if isUniqueConstraintError(err) {
+		writeProblem(context, http.StatusConflict, fmt.Sprintf(
+			"Squad number %d has been given to another player since the deletion; change or delete that player first.", squadNumber))
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeProblem(context, http.StatusNotFound, fmt.Sprintf("The trash holds no player with squad number %d.", squadNumber))
+		return
+	}
+	if err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	recordChanged(context, player)
+	c.changed()
+	context.Header("ETag", playerETag(player))
+	context.IndentedJSON(http.StatusOK, player)
+}
+
+// Purge permanently deletes every soft-deleted Player
+//
+// @Summary Permanently deletes every soft-deleted Player
+// @Tags admin
+// @Produce application/json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} model.PurgeResult "OK"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 429 {object} model.ProblemDetails "Too Many Requests"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/players/trash [delete]
+func (c *PlayerController) Purge(context *gin.Context) {
+	purged, err := c.serviceFor(context).Purge(context.Request.Context())
+	if err != nil {
+		writeServiceProblem(context, err)
+		return
+	}
+	if purged > 0 {
+		c.changed()
+	}
+	context.IndentedJSON(http.StatusOK, model.PurgeResult{Purged: purged})
+}
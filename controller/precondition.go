@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+// playerETag returns the strong entity tag of a player's representation.
+//
+// The tag combines the player's UUID with its Version, which is incremented
+// on every update, so it changes whenever the representation does.  The UUID
+// keeps tags distinct when a squad number is freed and reassigned to a new
+// player that starts again at version 1.
+func playerETag(player model.Player) string {
+	return fmt.Sprintf(`"%s-%d"`, player.ID, player.Version)
+}
+
+// etagMatches reports whether etag matches one of the entity tags listed in
+// an If-Match or If-None-Match header value, or the header is "*".
+//
+// With weak set, a "W/" prefix is ignored on both sides (RFC 9110 §8.8.3.2
+// weak comparison, used by If-None-Match); otherwise weak tags never match
+// (strong comparison, used by If-Match).
+func etagMatches(header string, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch evaluates an optional If-Match header against the current
+// state of player.  When the header is present and does not match, it writes
+// a 412 problem body and returns false, so callers only need to return.
+func checkIfMatch(context *gin.Context, player model.Player) bool {
+	header := context.GetHeader("If-Match")
+	if header == "" || etagMatches(header, playerETag(player), false) {
+		return true
+	}
+	writeProblem(context, http.StatusPreconditionFailed,
+		"If-Match does not match the current ETag "+playerETag(player)+"; fetch the player again and retry.")
+	return false
+}
+
+// NotModified is a middleware factory that answers conditional GET requests.
+//
+// It wraps handler's response writer so that, when handler (or a cache
+// decorator replaying a stored response) produces a 200 whose ETag matches
+// the request's If-None-Match header, the client receives 304 Not Modified
+// with no body instead.  Because the decision is taken on the way out, it
+// works identically for fresh and cached responses, which is why route wraps
+// cache.CachePage with it rather than the other way round.
+//
+// The wrapped writer keeps reporting the handler's original status, so a
+// cache decorator further in still stores the full 200 response.
+func NotModified(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		ifNoneMatch := context.GetHeader("If-None-Match")
+		if ifNoneMatch == "" {
+			handler(context)
+			return
+		}
+		original := context.Writer
+		writer := &notModifiedWriter{ResponseWriter: original, ifNoneMatch: ifNoneMatch}
+		context.Writer = writer
+		handler(context)
+		// Restore the original writer so the access logger sees the status
+		// actually sent (304) rather than the one the handler intended.
+		context.Writer = original
+	}
+}
+
+// notModifiedWriter decides between the handler's response and 304 on the
+// first body write, when both the status and the headers are final.
+type notModifiedWriter struct {
+	gin.ResponseWriter
+	ifNoneMatch string
+	status      int
+	decided     bool
+	notModified bool
+}
+
+// WriteHeader records the status the handler intends to send.  The decision
+// is deferred to the first Write, since cache replays set the ETag header
+// only after calling WriteHeader.
+func (w *notModifiedWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Status reports the handler's intended status, not the 304 that may have
+// been sent in its place.
+func (w *notModifiedWriter) Status() int {
+	if w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+// Write sends data unless the response has been turned into a 304, in which
+// case it is discarded but reported as written.
+func (w *notModifiedWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		etag := w.Header().Get("ETag")
+		if w.Status() == http.StatusOK && etag != "" && etagMatches(w.ifNoneMatch, etag, true) {
+			w.notModified = true
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+		}
+	}
+	if w.notModified {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString is the string form of Write.
+func (w *notModifiedWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
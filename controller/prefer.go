@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+// Preference headers (RFC 7240).
+const (
+	PreferHeader            = "Prefer"
+	PreferenceAppliedHeader = "Preference-Applied"
+)
+
+// Values of the return preference (RFC 7240 §4.2).
+const (
+	returnMinimal        = "minimal"        // No body: the client already has the player
+	returnRepresentation = "representation" // The player as stored
+)
+
+// returnPreference returns the return preference of the request, "minimal"
+// or "representation", or "" when it states neither.
+//
+// Prefer may be sent several times, each listing comma-separated
+// preferences with optional ";"-separated parameters; as RFC 7240 §2 says,
+// the first return preference wins and unknown values are ignored.
+func returnPreference(context *gin.Context) string {
+	for _, header := range context.Request.Header.Values(PreferHeader) {
+		for _, preference := range strings.Split(header, ",") {
+			name, value, _ := strings.Cut(strings.SplitN(preference, ";", 2)[0], "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "return") {
+				continue
+			}
+			switch value = strings.ToLower(strings.Trim(strings.TrimSpace(value), `"`)); value {
+			case returnMinimal, returnRepresentation:
+				return value
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// writeWrittenPlayer answers a successful write of player, with its ETag and
+// the body the client prefers: by default a created player is returned
+// (201 Created) and an updated one is not (204 No Content).
+// Prefer: return=representation returns an updated player too (200 OK), and
+// Prefer: return=minimal leaves out a created one; either is acknowledged
+// with Preference-Applied.
+func writeWrittenPlayer(context *gin.Context, player model.Player, created bool) {
+	context.Header("ETag", playerETag(player))
+	preference := returnPreference(context)
+	if preference != "" {
+		context.Header(PreferenceAppliedHeader, "return="+preference)
+	}
+	minimal := preference == returnMinimal || (preference == "" && !created)
+	switch {
+	case created && minimal:
+		context.Status(http.StatusCreated)
+	case created:
+		context.IndentedJSON(http.StatusCreated, player)
+	case minimal:
+		// 204 No Content is conventional for a successful update with no body.
+		context.Status(http.StatusNoContent)
+	default:
+		context.IndentedJSON(http.StatusOK, player)
+	}
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"gorm.io/gorm"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies.
+const ProblemContentType = "application/problem+json"
+
+// init makes validator report JSON field names ("squadNumber") instead of Go
+// struct field names ("SquadNumber") in validator.FieldError.Field(), so the
+// errors list in a problem body matches what the client actually sent.
+// Gin's default validator is a package-level singleton, so registering the
+// tag name function once here affects every ShouldBind* call.
+func init() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// writeProblem aborts the request with an RFC 7807 problem body.
+//
+// Gin's JSON renderer only sets Content-Type when the header is still empty,
+// so setting it beforehand is enough to override "application/json".
+// AbortWithStatusJSON is used (rather than JSON) so that any remaining
+// handlers in the chain are skipped, which also lets middleware reuse it.
+func writeProblem(context *gin.Context, status int, detail string, fieldErrors ...model.FieldError) {
+	context.Header("Content-Type", ProblemContentType)
+	context.AbortWithStatusJSON(status, model.ProblemDetails{
+		Type:     "about:blank",
+		Title:    statusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: context.Request.URL.Path,
+		Errors:   fieldErrors,
+	})
+}
+
+// statusText is http.StatusText, knowing statusClientClosedRequest too.
+func statusText(status int) string {
+	if status == statusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+// writeBindingProblem translates a ShouldBindJSON error into a problem body,
+// naming the resource the body should have described.
+// validator.ValidationErrors signals a field-level constraint failure → 422
+// with one FieldError per failed rule.  Any other error (EOF, syntax, type
+// mismatch) is a malformed request → 400.
+func writeBindingProblem(context *gin.Context, err error, resource string) {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		writeProblem(context, http.StatusBadRequest, "The request body is not valid JSON for a "+resource+": "+err.Error())
+		return
+	}
+	writeProblem(context, http.StatusUnprocessableEntity, "One or more fields failed validation.", fieldErrors(ve)...)
+}
+
+// writeTooLargeProblem writes a 413 problem body and returns true if err
+// comes from reading a request body past the limit set by
+// http.MaxBytesReader.  It returns false, writing nothing, otherwise.
+func writeTooLargeProblem(context *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	writeProblem(context, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must be at most %d bytes.", tooLarge.Limit))
+	return true
+}
+
+// fieldErrors converts validator.ValidationErrors into one FieldError per
+// failed rule, named by the JSON field.
+func fieldErrors(ve validator.ValidationErrors) []model.FieldError {
+	fieldErrors := make([]model.FieldError, 0, len(ve))
+	for _, fe := range ve {
+		fieldErrors = append(fieldErrors, model.FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: validationMessage(fe),
+		})
+	}
+	return fieldErrors
+}
+
+// validationMessage renders a human-readable message for the binding rules
+// used on model.Player and model.WebhookSubscription.
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "http_url":
+		return "must be an http or https URL"
+	case "oneof":
+		return "must be one of " + fe.Param()
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
+
+// writeServiceProblem maps an error returned by the service layer to the
+// matching problem body:
+//
+//   - gorm.ErrRecordNotFound      → 404 Not Found
+//   - unique constraint violation → 409 Conflict
+//   - service.ErrInvalidQuery     → 400 Bad Request
+//   - service.ErrVersionConflict  → 412 Precondition Failed
+//   - request deadline exceeded   → 503 Service Unavailable (see Deadline)
+//   - client gone away            → 499, for the logs only
+//   - anything else               → 500 Internal Server Error
+//
+// Unexpected errors are not echoed to the client, since they may carry SQL
+// or driver internals.
+func writeServiceProblem(context *gin.Context, err error) {
+	// A statement interrupted at the request's deadline may report the
+	// interruption rather than the deadline itself.
+	if cause := context.Request.Context().Err(); cause != nil && !errors.Is(err, cause) {
+		err = fmt.Errorf("%w: %w", err, cause)
+	}
+	status, detail := serviceProblem(err)
+	writeProblem(context, status, detail)
+}
+
+// serviceProblem returns the status code and detail writeServiceProblem uses
+// for err.  It is separate so that per-row results of a bulk import can share
+// the same mapping without writing a response.
+func serviceProblem(err error) (int, string) {
+	switch {
+	case errors.Is(err, gocontext.DeadlineExceeded):
+		return http.StatusServiceUnavailable, "The request took longer than the server allows; retry later."
+	case errors.Is(err, gocontext.Canceled):
+		return statusClientClosedRequest, "The client closed the request."
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, "No player matches the given identifier."
+	case isUniqueConstraintError(err):
+		return http.StatusConflict, "A player with the same squad number already exists."
+	case errors.Is(err, service.ErrInvalidQuery):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, service.ErrVersionConflict):
+		return http.StatusPreconditionFailed, "The player was modified by another request; fetch it again and retry."
+	default:
+		return http.StatusInternalServerError, "An unexpected error occurred while processing the request."
+	}
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/ratelimit"
+)
+
+// Rate limit headers (draft-ietf-httpapi-ratelimit-headers, RFC 9110 §10.2.3).
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimit is a middleware factory that charges every request to its
+// client's bucket in limiter, and reports the bucket in the RateLimit-Limit
+// and RateLimit-Remaining headers.  A request finding the bucket empty is
+// answered with 429 Too Many Requests and a Retry-After header, in whole
+// seconds.  A nil limiter lets everything through, without headers.
+//
+// Clients are told apart by their principal when Authenticate ran earlier in
+// the chain (so an API key or token is limited wherever it is used from),
+// and by their IP address otherwise (see gin.Engine.SetTrustedProxies for
+// clients behind a reverse proxy).
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if limiter == nil {
+			context.Next()
+			return
+		}
+		decision := limiter.Allow(clientKey(context))
+		context.Header(RateLimitLimitHeader, strconv.Itoa(decision.Limit))
+		context.Header(RateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+			context.Header(RetryAfterHeader, strconv.Itoa(seconds))
+			writeProblem(context, http.StatusTooManyRequests, fmt.Sprintf("Rate limit of %d requests exceeded; retry in %d seconds.", decision.Limit, seconds))
+			return
+		}
+		context.Next()
+	}
+}
+
+// LimitFailedAuthentication is a middleware factory that guards authenticate
+// (Authenticate) against credential guessing: every request it rejects with
+// 401 Unauthorized takes a token from the bucket of the client's IP address
+// in limiter, and once the bucket is empty, requests from that address are
+// answered with 429 Too Many Requests and Retry-After before their
+// credentials are even checked.  Accepted credentials cost nothing, so the
+// clients they identify are only limited by RateLimit.  A nil limiter
+// returns authenticate unchanged.
+func LimitFailedAuthentication(limiter *ratelimit.Limiter, authenticate gin.HandlerFunc) gin.HandlerFunc {
+	if limiter == nil {
+		return authenticate
+	}
+	return func(context *gin.Context) {
+		key := "ip:" + context.ClientIP()
+		if decision := limiter.Check(key); !decision.Allowed {
+			seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+			context.Header(RetryAfterHeader, strconv.Itoa(seconds))
+			writeProblem(context, http.StatusTooManyRequests, fmt.Sprintf("Too many requests with invalid credentials; retry in %d seconds.", seconds))
+			return
+		}
+		authenticate(context)
+		if _, authenticated := principal(context); !authenticated && context.Writer.Status() == http.StatusUnauthorized {
+			limiter.Allow(key)
+		}
+	}
+}
+
+// clientKey returns the key of the request's client in a rate limiter.
+func clientKey(context *gin.Context) string {
+	if p, ok := principal(context); ok {
+		return p.Method + ":" + p.Subject
+	}
+	return "ip:" + context.ClientIP()
+}
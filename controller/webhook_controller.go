@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/nanotaboada/go-samples-gin-restful/webhook"
+	"gorm.io/gorm"
+)
+
+// WebhookController holds dependencies for the webhook administration
+// handlers.
+type WebhookController struct {
+	service    service.WebhookService
+	dispatcher *webhook.Dispatcher
+}
+
+// NewWebhookController returns a WebhookController managing the
+// subscriptions of service, whose redeliveries dispatcher makes.
+func NewWebhookController(service service.WebhookService, dispatcher *webhook.Dispatcher) *WebhookController {
+	return &WebhookController{service: service, dispatcher: dispatcher}
+}
+
+// writeWebhookProblem maps an error of the webhook service to a problem body
+// like writeServiceProblem, naming what was not found.
+func writeWebhookProblem(context *gin.Context, err error, what string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeProblem(context, http.StatusNotFound, fmt.Sprintf("No %s matches the given identifier.", what))
+		return
+	}
+	writeServiceProblem(context, err)
+}
+
+// PostSubscription creates a webhook subscription
+//
+// @Summary Creates a webhook subscription
+// @Description Player changes of the listed event types (all when events is empty) are
+// @Description POSTed to url, signed with HMAC-SHA256. The generated secret is returned
+// @Description only in this response. Requires the admin role.
+// @Tags webhooks
+// @Accept application/json
+// @Produce application/json,application/problem+json
+// @Param subscription body model.WebhookSubscription true "url and, optionally, events"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 201 {object} model.WebhookSubscription "Created"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 422 {object} model.ProblemDetails "Unprocessable Entity"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/webhooks [post]
+func (c *WebhookController) PostSubscription(context *gin.Context) {
+	var subscription model.WebhookSubscription
+	if err := context.ShouldBindJSON(&subscription); err != nil {
+		writeBindingProblem(context, err, "webhook subscription")
+		return
+	}
+	if err := c.service.Subscribe(context.Request.Context(), &subscription); err != nil {
+		writeWebhookProblem(context, err, "webhook subscription")
+		return
+	}
+	context.Header("Location", context.Request.URL.Path+"/"+subscription.ID)
+	context.IndentedJSON(http.StatusCreated, subscription)
+}
+
+// GetSubscriptions retrieves every webhook subscription
+//
+// @Summary Retrieves every webhook subscription
+// @Description Oldest first, without their secrets. Requires the admin role.
+// @Tags webhooks
+// @Produce application/json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {array} model.WebhookSubscription "OK"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/webhooks [get]
+func (c *WebhookController) GetSubscriptions(context *gin.Context) {
+	subscriptions, err := c.service.Subscriptions(context.Request.Context())
+	if err != nil {
+		writeWebhookProblem(context, err, "webhook subscription")
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	context.IndentedJSON(http.StatusOK, subscriptions)
+}
+
+// GetSubscription retrieves a webhook subscription by its ID
+//
+// @Summary Retrieves a webhook subscription by its ID
+// @Description Without its secret. Requires the admin role.
+// @Tags webhooks
+// @Produce application/json,application/problem+json
+// @Param id path string true "WebhookSubscription.ID (UUID)"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} model.WebhookSubscription "OK"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/webhooks/{id} [get]
+func (c *WebhookController) GetSubscription(context *gin.Context) {
+	subscription, err := c.service.Subscription(context.Request.Context(), context.Param("id"))
+	if err != nil {
+		writeWebhookProblem(context, err, "webhook subscription")
+		return
+	}
+	subscription.Secret = ""
+	context.IndentedJSON(http.StatusOK, subscription)
+}
+
+// DeleteSubscription removes a webhook subscription by its ID
+//
+// @Summary Removes a webhook subscription by its ID
+// @Description Its pending deliveries and dead letters are removed with it. Requires the
+// @Description admin role.
+// @Tags webhooks
+// @Produce application/problem+json
+// @Param id path string true "WebhookSubscription.ID (UUID)"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/webhooks/{id} [delete]
+func (c *WebhookController) DeleteSubscription(context *gin.Context) {
+	if err := c.service.Unsubscribe(context.Request.Context(), context.Param("id")); err != nil {
+		writeWebhookProblem(context, err, "webhook subscription")
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+// GetDeadLetters retrieves the webhook deliveries given up on
+//
+// @Summary Retrieves the webhook deliveries given up on
+// @Description Deliveries whose every attempt failed, most recent first, with the status
+// @Description and error of the last attempt. Requires the admin role.
+// @Tags webhooks
+// @Produce application/json,application/problem+json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {array} model.WebhookDelivery "OK"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/webhooks/dead-letters [get]
+func (c *WebhookController) GetDeadLetters(context *gin.Context) {
+	deliveries, err := c.service.DeadLetters(context.Request.Context())
+	if err != nil {
+		writeWebhookProblem(context, err, "webhook delivery")
+		return
+	}
+	context.IndentedJSON(http.StatusOK, deliveries)
+}
+
+// PostRedelivery queues a webhook delivery again
+//
+// @Summary Queues a webhook delivery again
+// @Description The delivery, typically a dead letter, is attempted again at once with a
+// @Description fresh budget of attempts. Requires the admin role.
+// @Tags webhooks
+// @Produce application/json,application/problem+json
+// @Param id path int true "WebhookDelivery.ID"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 202 {object} model.WebhookDelivery "Accepted"
+// @Failure 400 {object} model.ProblemDetails "Bad Request"
+// @Failure 401 {object} model.ProblemDetails "Unauthorized"
+// @Failure 403 {object} model.ProblemDetails "Forbidden"
+// @Failure 404 {object} model.ProblemDetails "Not Found"
+// @Failure 500 {object} model.ProblemDetails "Internal Server Error"
+// @Router /admin/webhooks/deliveries/{id}/redeliver [post]
+func (c *WebhookController) PostRedelivery(context *gin.Context) {
+	raw := context.Param("id")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		writeProblem(context, http.StatusBadRequest, fmt.Sprintf("Delivery ID must be an integer, got %q.", raw))
+		return
+	}
+	delivery, err := c.dispatcher.Redeliver(context.Request.Context(), id)
+	if err != nil {
+		writeWebhookProblem(context, err, "webhook delivery")
+		return
+	}
+	context.IndentedJSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/nanotaboada/go-samples-gin-restful/migrations"
+	"github.com/pressly/goose/v3"
+	"gorm.io/gorm"
+)
+
+// gooseDialects maps the GORM dialector names of the SQL backends to goose's.
+var gooseDialects = map[string]goose.Dialect{
+	string(SQLite):   goose.DialectSQLite3,
+	string(Postgres): goose.DialectPostgres,
+}
+
+// MigrationVersions returns the version of the latest migration applied to
+// db and the version of the latest migration embedded in this binary for
+// db's dialect.  Once Connect has returned they are equal; a difference means
+// the schema was changed by something else, such as a rollback run with the
+// goose CLI.
+func MigrationVersions(ctx context.Context, db *gorm.DB) (current int64, latest int64, err error) {
+	dialect, ok := gooseDialects[db.Name()]
+	if !ok {
+		return 0, 0, fmt.Errorf("no migrations for dialect %s", db.Name())
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, 0, err
+	}
+	dir, err := fs.Sub(migrations.FS, db.Name())
+	if err != nil {
+		return 0, 0, err
+	}
+	// The provider only borrows sqlDB: it must not be closed, as
+	// Provider.Close would close the application's pool.
+	provider, err := goose.NewProvider(dialect, sqlDB, dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if sources := provider.ListSources(); len(sources) > 0 {
+		latest = sources[len(sources)-1].Version
+	}
+	current, err = provider.GetDBVersion(ctx)
+	return current, latest, err
+}
@@ -4,20 +4,57 @@ package data
 
 import (
 	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/nanotaboada/go-samples-gin-restful/logging"
 	"github.com/nanotaboada/go-samples-gin-restful/migrations"
 	"github.com/pressly/goose/v3"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// Connect initializes and returns a GORM database connection backed by SQLite,
-// then applies all pending versioned migrations via goose.
+// Dialect identifies a storage backend selected by a database URL.
+type Dialect string
+
+const (
+	// SQLite is the default backend: a file, or a shared in-memory database.
+	SQLite Dialect = "sqlite"
+	// Postgres is a PostgreSQL server reached through pgx.
+	Postgres Dialect = "postgres"
+	// Memory keeps players in process memory, without any SQL database.
+	// It is served by service.NewMemoryPlayerService rather than by Connect.
+	Memory Dialect = "memory"
+)
+
+// Parse splits a database URL into its dialect and the data source name
+// handed to the driver.  The scheme selects the dialect:
+//
+//   - postgres://… or postgresql://… — PostgreSQL; the URL is the DSN
+//   - sqlite://<path>                — SQLite; the DSN is <path>
+//   - memory://                      — the in-memory store; there is no DSN
+//
+// Anything else, such as a plain file path or "file::memory:?cache=shared",
+// is taken as a SQLite DSN, so existing STORAGE_PATH values keep working.
+func Parse(databaseURL string) (Dialect, string) {
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		return Postgres, databaseURL
+	case strings.HasPrefix(databaseURL, "memory://"):
+		return Memory, ""
+	default:
+		return SQLite, strings.TrimPrefix(databaseURL, "sqlite://")
+	}
+}
+
+// Connect initializes and returns a GORM database connection for the
+// database URL (see Parse), then applies all pending versioned migrations of
+// its dialect via goose.
 //
-// dataSourceName is a SQLite DSN (Data Source Name).  Two forms are used in
-// this project:
+// Two SQLite forms are used in this project:
 //
 //   - File-based (production): a path such as "./storage/players-sqlite3.db"
 //   - In-memory (tests): "file::memory:?cache=shared"
@@ -25,30 +62,43 @@ import (
 //     the same process share the same in-memory database; without it each
 //     call to gorm.Open would get an empty, isolated database.
 //
-// Schema and seed migrations live in the /migrations directory and are
-// embedded into the binary at compile time.  goose tracks applied migrations
-// in a goose_db_version table and is idempotent: already-applied migrations
-// are skipped on subsequent startups.
-func Connect(dataSourceName string) *gorm.DB {
-	// GORM's built-in logger prints slow queries and all SQL statements.
-	// SlowThreshold defines when a query is considered "slow" and logged at
-	// WARN level; queries above this threshold are highlighted in the output.
+// Schema and seed migrations live in one /migrations subdirectory per
+// dialect and are embedded into the binary at compile time.  goose tracks
+// applied migrations in a goose_db_version table and is idempotent:
+// already-applied migrations are skipped on subsequent startups.
+//
+// logLevel sets how much SQL GORM logs: logger.Info logs every statement,
+// logger.Silent nothing.  Statements are logged as JSON lines to
+// slog.Default(), as it is when Connect is called, tagged with the request
+// ID of their context (see logging.GormLogger).
+//
+// A memory:// URL has no database to connect to and is a fatal error here.
+func Connect(databaseURL string, logLevel logger.LogLevel) *gorm.DB {
+	dialect, dataSourceName := Parse(databaseURL)
+
+	// Queries slower than a second are logged at WARN level.
 	// https://gorm.io/docs/logger.html
-	newLogger := logger.New(
-		log.New(log.Writer(), "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold: time.Second,
-			LogLevel:      logger.Info,
-			Colorful:      true,
-		},
-	)
+	newLogger := logging.NewGormLogger(slog.Default(), logLevel, time.Second)
+
+	var dialector gorm.Dialector
+	var gooseDialect string
+	switch dialect {
+	case SQLite:
+		dialector, gooseDialect = sqlite.Open(dataSourceName), "sqlite3"
+	case Postgres:
+		dialector, gooseDialect = postgres.Open(dataSourceName), "postgres"
+	default:
+		log.Fatalf("%s has no SQL database to connect to", databaseURL)
+	}
 
 	// gorm.Open returns a *gorm.DB — a connection-pool handle, not a single
-	// connection.  The sqlite driver keeps the underlying file/memory handle
-	// open for the lifetime of the process.
+	// connection.  TranslateError maps each driver's unique-violation error to
+	// gorm.ErrDuplicatedKey, so callers detect conflicts the same way on
+	// every dialect.
 	// https://gorm.io/docs/connecting_to_the_database.html
-	db, err := gorm.Open(sqlite.Open(dataSourceName), &gorm.Config{
-		Logger: newLogger,
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         newLogger,
+		TranslateError: true,
 	})
 
 	if err != nil {
@@ -62,16 +112,19 @@ func Connect(dataSourceName string) *gorm.DB {
 
 	// SQLite does not support concurrent writes; a single open connection
 	// prevents "database is locked" errors under concurrent request load.
-	sqlDB.SetMaxOpenConns(1)
-	sqlDB.SetMaxIdleConns(1)
+	// PostgreSQL keeps database/sql's default pool.
+	if dialect == SQLite {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+	}
 
 	goose.SetBaseFS(migrations.FS)
 
-	if err := goose.SetDialect("sqlite3"); err != nil {
+	if err := goose.SetDialect(gooseDialect); err != nil {
 		log.Fatal(err)
 	}
 
-	if err := goose.Up(sqlDB, "."); err != nil {
+	if err := goose.Up(sqlDB, string(dialect)); err != nil {
 		log.Fatal(err)
 	}
 
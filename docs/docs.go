@@ -15,158 +15,421 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/players": {
+        "/admin/config": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "The configuration after defaults, environment variables, the config file\nand command-line flags have been applied, in that order. Credentials\nembedded in the database URL are redacted. Requires the admin role.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "players"
+                    "admin"
                 ],
-                "summary": "Retrieves all players",
+                "summary": "Retrieves the effective configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/config.Config"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/players/trash": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Permanently deletes every soft-deleted Player",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.PurgeResult"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Oldest first, without their secrets. Requires the admin role.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Retrieves every webhook subscription",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.Player"
+                                "$ref": "#/definitions/model.WebhookSubscription"
                             }
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     }
                 }
             },
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Player changes of the listed event types (all when events is empty) are\nPOSTed to url, signed with HMAC-SHA256. The generated secret is returned\nonly in this response. Requires the admin role.",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
                 "tags": [
-                    "players"
+                    "webhooks"
                 ],
-                "summary": "Creates a Player",
+                "summary": "Creates a webhook subscription",
                 "parameters": [
                     {
-                        "description": "Player",
-                        "name": "player",
+                        "description": "url and, optionally, events",
+                        "name": "subscription",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Player"
+                            "$ref": "#/definitions/model.WebhookSubscription"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created"
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookSubscription"
+                        }
                     },
                     "400": {
-                        "description": "Bad Request"
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
-                    "409": {
-                        "description": "Conflict"
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "422": {
-                        "description": "Unprocessable Entity"
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     }
                 }
             }
         },
-        "/players/squadnumber/{squadnumber}": {
+        "/admin/webhooks/dead-letters": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deliveries whose every attempt failed, most recent first, with the status\nand error of the last attempt. Requires the admin role.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "players"
+                    "webhooks"
                 ],
-                "summary": "Retrieves a Player by its Squad Number",
+                "summary": "Retrieves the webhook deliveries given up on",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "The delivery, typically a dead letter, is attempted again at once with a\nfresh budget of attempts. Requires the admin role.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Queues a webhook delivery again",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Player.SquadNumber",
-                        "name": "squadnumber",
+                        "type": "integer",
+                        "description": "WebhookDelivery.ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/model.Player"
+                            "$ref": "#/definitions/model.WebhookDelivery"
                         }
                     },
                     "400": {
-                        "description": "Bad Request"
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "404": {
-                        "description": "Not Found"
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     }
                 }
-            },
-            "put": {
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/admin/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Without its secret. Requires the admin role.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "players"
+                    "webhooks"
                 ],
-                "summary": "Updates (entirely) a Player by its Squad Number",
+                "summary": "Retrieves a webhook subscription by its ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Player.SquadNumber",
-                        "name": "squadnumber",
+                        "description": "WebhookSubscription.ID (UUID)",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Player",
-                        "name": "player",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.Player"
-                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookSubscription"
+                        }
                     },
-                    "400": {
-                        "description": "Bad Request"
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
-                    "404": {
-                        "description": "Not Found"
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity"
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     }
                 }
             },
             "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Its pending deliveries and dead letters are removed with it. Requires the\nadmin role.",
+                "produces": [
+                    "application/problem+json"
+                ],
                 "tags": [
-                    "players"
+                    "webhooks"
                 ],
-                "summary": "Deletes a Player by its Squad Number",
+                "summary": "Removes a webhook subscription by its ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Player.SquadNumber",
-                        "name": "squadnumber",
+                        "description": "WebhookSubscription.ID (UUID)",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -175,54 +438,1807 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request"
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "404": {
-                        "description": "Not Found"
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     }
                 }
             }
         },
-        "/players/{id}": {
+        "/audit": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Events are returned oldest first. from and to (RFC 3339) restrict\nthe result to events that occurred at or after from and before to.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/problem+json"
                 ],
                 "tags": [
-                    "players"
+                    "audit"
                 ],
-                "summary": "Retrieves a Player by its internal UUID",
+                "summary": "Retrieves the audit trail of all Players",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Player.ID (UUID)",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Earliest occurrence, inclusive (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest occurrence, exclusive (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Player"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.AuditEvent"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Not Found"
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
                     },
-                    "500": {
-                        "description": "Internal Server Error"
-                    }
-                }
-            }
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/live": {
+            "get": {
+                "description": "Always 200 while the server can answer at all; it checks no dependency, so\nan outage never gets a healthy instance restarted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Reports whether the process is running",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Checks the database connection, the schema migration version against the\nlatest embedded migration, and the response cache, and reports each with its\nlatency. Returns 503 with status \"unavailable\" when a check fails, or with\nstatus \"draining\" once the server has received SIGINT or SIGTERM.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Reports whether the server accepts new work",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/model.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/players": {
+            "get": {
+                "description": "Without query parameters every player is returned. Passing page,\npageSize or after returns a single page instead; the total number\nof matching players is reported in X-Total-Count and navigation\nlinks (first, prev, next, last) in the Link header (RFC 8288).",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Retrieves all players",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "1-based page number (offset pagination)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Players per page (1-100, default 20 when paginating)",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Player.ID of the last player on the previous page (cursor pagination)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields, prefix with - for descending (e.g. lastName,-squadNumber)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by team",
+                        "name": "team",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by league",
+                        "name": "league",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by abbreviated position",
+                        "name": "abbrPosition",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter by starting eleven membership",
+                        "name": "starting11",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Player"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "Pagination links"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of players matching the filters"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "With an Idempotency-Key, a retry with the same key and payload gets the\noriginal response again (Idempotent-Replayed: true) instead of creating\nthe player twice; the same key with another payload is refused with 422,\nand a retry while the first request runs with 409.\n\nThe created player is returned, with its URL in Location, unless the\nrequest is sent with Prefer: return=minimal (RFC 7240).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Creates a Player",
+                "parameters": [
+                    {
+                        "description": "Player",
+                        "name": "player",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client-chosen key (at most 255 characters) identifying retries of this request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "return=minimal to leave the created player out of the response",
+                        "name": "Prefer",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the created player"
+                            },
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "true when the response is replayed for an Idempotency-Key"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created player"
+                            },
+                            "Preference-Applied": {
+                                "type": "string",
+                                "description": "The return preference applied, when one was sent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Payload Too Large (with an Idempotency-Key)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/bulk": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Rows are read from a JSON array, NDJSON (one player per line) or CSV\n(header row of JSON field names), selected by Content-Type. Every row is\nvalidated like a POST body. In atomic mode (default) nothing is inserted\nunless every row succeeds; in partial mode every valid, non-conflicting\nrow is inserted. The body reports the outcome of each row. A body of more\nthan 1000 rows or 1 MiB is refused with 413.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Creates many Players at once",
+                "parameters": [
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "description": "atomic (default) or partial",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Players",
+                        "name": "players",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Player"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkImportResult"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status (partial mode, some rows failed)",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict (atomic mode, squad numbers taken)",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkImportResult"
+                        }
+                    },
+                    "413": {
+                        "description": "Payload Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity (atomic mode, invalid rows)",
+                        "schema": {
+                            "$ref": "#/definitions/model.BulkImportResult"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/events": {
+            "get": {
+                "description": "Each change is sent as an event of type player.created, player.updated or\nplayer.deleted, with an id and the player as JSON data (as it was before\nthe change for player.deleted). A client reconnecting with Last-Event-ID\nfirst receives the events it missed; when those are no longer known, a\nstream.reset event tells it to reload the players instead.",
+                "produces": [
+                    "text/event-stream",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Streams player changes as Server-Sent Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received, to resume after",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/export": {
+            "get": {
+                "description": "The format is taken from the format query parameter or, when it is\nabsent, negotiated from the Accept header (NDJSON by default). Rows are\nordered by squad number and written as they are read from the database.",
+                "produces": [
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Streams every Player as CSV or NDJSON",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "csv or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Player"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/squadnumber/{squadnumber}": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Retrieves a Player by its Squad Number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.SquadNumber",
+                        "name": "squadnumber",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response; a match returns 304",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the player's current version"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Nothing is returned, unless the request is sent with\nPrefer: return=representation (RFC 7240): the updated player is then returned with 200.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Updates (entirely) a Player by its Squad Number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.SquadNumber",
+                        "name": "squadnumber",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the update is conditional on",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "return=representation to get the updated player back",
+                        "name": "Prefer",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Player",
+                        "name": "player",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK (Prefer: return=representation)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the updated player"
+                            },
+                            "Preference-Applied": {
+                                "type": "string",
+                                "description": "return=representation"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the updated player"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "The player is soft-deleted: it moves to the trash (GET /players/trash),\nfrom where it can be restored until the trash is purged.",
+                "produces": [
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Deletes a Player by its Squad Number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.SquadNumber",
+                        "name": "squadnumber",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the deletion is conditional on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "The body is either an RFC 7396 JSON Merge Patch (a partial Player)\nor an RFC 6902 JSON Patch (an array of operations), selected by\nContent-Type. The patched player must pass the same validation as a PUT body.\nLike PUT, nothing is returned unless the request is sent with Prefer: return=representation.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Updates (partially) a Player by its Squad Number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.SquadNumber",
+                        "name": "squadnumber",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the update is conditional on",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "return=representation to get the updated player back",
+                        "name": "Prefer",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Merge patch or JSON Patch document",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK (Prefer: return=representation)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the updated player"
+                            },
+                            "Preference-Applied": {
+                                "type": "string",
+                                "description": "return=representation"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the updated player"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Payload Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/squadnumber/{squadnumber}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "When several deleted players had the squad number, the most recently\ndeleted one is restored. If the squad number has been given to another\nplayer since, the restore is refused with 409 Conflict.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Restores a soft-deleted Player by its Squad Number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.SquadNumber",
+                        "name": "squadnumber",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the restored player"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/trash": {
+            "get": {
+                "description": "Deleted players stay in the trash, most recently deleted first,\nuntil they are restored or purged.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Retrieves all soft-deleted players",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DeletedPlayer"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/{id}": {
+            "get": {
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "players"
+                ],
+                "summary": "Retrieves a Player by its internal UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response; a match returns 304",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Player"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Strong entity tag of the player's current version"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/players/{id}/history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Every create, update, delete, restore and purge of the player, oldest\nfirst, with the player before and after the change. Players from the\nseed data have an empty history until they are first changed.",
+                "produces": [
+                    "application/json",
+                    "application/problem+json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Retrieves the change history of a Player by its internal UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player.ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.AuditEvent"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized (when reads are protected)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden (when reads are protected)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProblemDetails"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "config.AuthConfig": {
+            "type": "object",
+            "properties": {
+                "apiKeys": {
+                    "description": "AUTH_API_KEYS, -auth-api-keys: comma-separated name:sha256-hex[:role] entries",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "jwksFile": {
+                    "description": "AUTH_JWKS_FILE, -auth-jwks-file: JSON Web Key Set of RS*/ES*/PS* bearer tokens",
+                    "type": "string"
+                },
+                "jwtAudience": {
+                    "description": "AUTH_JWT_AUDIENCE, -auth-jwt-audience: required aud claim, if set",
+                    "type": "string"
+                },
+                "jwtIssuer": {
+                    "description": "AUTH_JWT_ISSUER, -auth-jwt-issuer: required iss claim, if set",
+                    "type": "string"
+                },
+                "jwtRolesClaim": {
+                    "description": "AUTH_JWT_ROLES_CLAIM, -auth-jwt-roles-claim: claim listing the token's roles",
+                    "type": "string"
+                },
+                "jwtSecret": {
+                    "description": "AUTH_JWT_SECRET, -auth-jwt-secret: HMAC key of HS256/384/512 bearer tokens",
+                    "type": "string"
+                },
+                "protectReads": {
+                    "description": "AUTH_PROTECT_READS, -auth-protect-reads: require credentials on GET routes too",
+                    "type": "boolean"
+                }
+            }
+        },
+        "config.CacheConfig": {
+            "type": "object",
+            "properties": {
+                "backend": {
+                    "description": "CACHE_BACKEND, -cache-backend: memory, redis or none",
+                    "type": "string"
+                },
+                "defaultTTL": {
+                    "description": "CACHE_DEFAULT_TTL, -cache-default-ttl: store-wide default expiry",
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "pageTTL": {
+                    "description": "CACHE_PAGE_TTL, -cache-page-ttl: expiry of cached GET responses on routes without a TTL of their own",
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "redisURL": {
+                    "description": "CACHE_REDIS_URL, -cache-redis-url: redis://[:password@]host:port[/db] (redis only)",
+                    "type": "string"
+                },
+                "routes": {
+                    "$ref": "#/definitions/config.CacheRouteTTLs"
+                }
+            }
+        },
+        "config.CacheRouteTTLs": {
+            "type": "object",
+            "properties": {
+                "playerByID": {
+                    "description": "CACHE_TTL_PLAYER_BY_ID, -cache-ttl-player-by-id: GET /players/:id",
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "playerBySquadNumber": {
+                    "description": "CACHE_TTL_PLAYER_BY_SQUAD_NUMBER, -cache-ttl-player-by-squad-number: GET /players/squadnumber/:squadnumber",
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "players": {
+                    "description": "CACHE_TTL_PLAYERS, -cache-ttl-players: GET /players",
+                    "type": "string",
+                    "example": "5m0s"
+                }
+            }
+        },
+        "config.Config": {
+            "type": "object",
+            "properties": {
+                "auth": {
+                    "$ref": "#/definitions/config.AuthConfig"
+                },
+                "cache": {
+                    "$ref": "#/definitions/config.CacheConfig"
+                },
+                "database": {
+                    "$ref": "#/definitions/config.DatabaseConfig"
+                },
+                "events": {
+                    "$ref": "#/definitions/config.EventsConfig"
+                },
+                "idempotency": {
+                    "$ref": "#/definitions/config.IdempotencyConfig"
+                },
+                "outbox": {
+                    "$ref": "#/definitions/config.OutboxConfig"
+                },
+                "rateLimit": {
+                    "$ref": "#/definitions/config.RateLimitConfig"
+                },
+                "server": {
+                    "$ref": "#/definitions/config.ServerConfig"
+                },
+                "tracing": {
+                    "$ref": "#/definitions/config.TracingConfig"
+                },
+                "webhooks": {
+                    "$ref": "#/definitions/config.WebhooksConfig"
+                }
+            }
+        },
+        "config.DatabaseConfig": {
+            "type": "object",
+            "properties": {
+                "logLevel": {
+                    "description": "DATABASE_LOG_LEVEL, -database-log-level: silent, error, warn or info",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "DATABASE_URL (or legacy STORAGE_PATH), -database-url; see data.Parse",
+                    "type": "string"
+                }
+            }
+        },
+        "config.EventsConfig": {
+            "type": "object",
+            "properties": {
+                "bufferSize": {
+                    "description": "EVENTS_BUFFER_SIZE, -events-buffer-size: latest events kept for clients resuming with Last-Event-ID",
+                    "type": "integer",
+                    "example": 1000
+                }
+            }
+        },
+        "config.IdempotencyConfig": {
+            "type": "object",
+            "properties": {
+                "ttl": {
+                    "description": "IDEMPOTENCY_TTL, -idempotency-ttl: how long a response is replayed to retries",
+                    "type": "string",
+                    "example": "24h0m0s"
+                }
+            }
+        },
+        "config.OutboxConfig": {
+            "type": "object",
+            "properties": {
+                "batchSize": {
+                    "description": "OUTBOX_BATCH_SIZE, -outbox-batch-size: messages published per round",
+                    "type": "integer",
+                    "example": 100
+                },
+                "natsEmbedded": {
+                    "description": "OUTBOX_NATS_EMBEDDED, -outbox-nats-embedded: run the NATS server in the process, listening on the host:port of natsURL",
+                    "type": "boolean"
+                },
+                "natsStoreDir": {
+                    "description": "OUTBOX_NATS_STORE_DIR, -outbox-nats-store-dir: JetStream storage of the embedded NATS server",
+                    "type": "string"
+                },
+                "natsSubject": {
+                    "description": "OUTBOX_NATS_SUBJECT, -outbox-nats-subject: subject prefix, followed by the event type",
+                    "type": "string"
+                },
+                "natsURL": {
+                    "description": "OUTBOX_NATS_URL, -outbox-nats-url: NATS server of the nats publisher",
+                    "type": "string"
+                },
+                "pollInterval": {
+                    "description": "OUTBOX_POLL_INTERVAL, -outbox-poll-interval: how often the outbox is looked at without a change of this process",
+                    "type": "string",
+                    "example": "1s"
+                },
+                "publishers": {
+                    "description": "OUTBOX_PUBLISHERS, -outbox-publishers: comma-separated bus, log and nats",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "config.RateLimitConfig": {
+            "type": "object",
+            "properties": {
+                "failedAuth": {
+                    "description": "RATE_LIMIT_FAILED_AUTH, -rate-limit-failed-auth: rejected credentials per period, per IP address, or off",
+                    "type": "string",
+                    "example": "10/1m0s"
+                },
+                "reads": {
+                    "description": "RATE_LIMIT_READS, -rate-limit-reads: requests per period on GET routes, or off",
+                    "type": "string",
+                    "example": "300/1m0s"
+                },
+                "writes": {
+                    "description": "RATE_LIMIT_WRITES, -rate-limit-writes: requests per period on mutating routes, or off",
+                    "type": "string",
+                    "example": "60/1m0s"
+                }
+            }
+        },
+        "config.ServerConfig": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "SERVER_ADDRESS, -address: host:port to listen on",
+                    "type": "string"
+                },
+                "idleTimeout": {
+                    "description": "SERVER_IDLE_TIMEOUT, -idle-timeout: keep-alive connection lifetime between requests",
+                    "type": "string",
+                    "example": "2m0s"
+                },
+                "maxHeaderBytes": {
+                    "description": "SERVER_MAX_HEADER_BYTES, -max-header-bytes: largest accepted request header",
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "mode": {
+                    "description": "GIN_MODE, -mode: debug, release or test",
+                    "type": "string"
+                },
+                "readTimeout": {
+                    "description": "SERVER_READ_TIMEOUT, -read-timeout: limit for reading a whole request",
+                    "type": "string",
+                    "example": "15s"
+                },
+                "requestTimeout": {
+                    "description": "SERVER_REQUEST_TIMEOUT, -request-timeout: deadline for handling a request, database queries included",
+                    "type": "string",
+                    "example": "30s"
+                },
+                "shutdownReadinessDelay": {
+                    "description": "SERVER_SHUTDOWN_READINESS_DELAY, -shutdown-readiness-delay: time still served after readiness fails on SIGINT/SIGTERM, or 0",
+                    "type": "string",
+                    "example": "5s"
+                },
+                "shutdownTimeout": {
+                    "description": "SERVER_SHUTDOWN_TIMEOUT, -shutdown-timeout: grace period for in-flight requests on SIGINT/SIGTERM",
+                    "type": "string",
+                    "example": "10s"
+                },
+                "trustedProxies": {
+                    "description": "SERVER_TRUSTED_PROXIES, -trusted-proxies: comma-separated IPs or CIDRs whose X-Forwarded-For is believed",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "writeTimeout": {
+                    "description": "SERVER_WRITE_TIMEOUT, -write-timeout: limit for writing a response",
+                    "type": "string",
+                    "example": "1m0s"
+                }
+            }
+        },
+        "config.TracingConfig": {
+            "type": "object",
+            "properties": {
+                "endpoint": {
+                    "description": "TRACING_ENDPOINT, -tracing-endpoint: OTLP/HTTP collector URL (otlp only)",
+                    "type": "string"
+                },
+                "exporter": {
+                    "description": "TRACING_EXPORTER, -tracing-exporter: none, stdout or otlp",
+                    "type": "string"
+                },
+                "serviceName": {
+                    "description": "TRACING_SERVICE_NAME, -tracing-service-name: service.name of every span",
+                    "type": "string"
+                }
+            }
+        },
+        "config.WebhooksConfig": {
+            "type": "object",
+            "properties": {
+                "backoff": {
+                    "description": "WEBHOOK_BACKOFF, -webhook-backoff: delay before the first retry",
+                    "type": "string",
+                    "example": "1s"
+                },
+                "maxAttempts": {
+                    "description": "WEBHOOK_MAX_ATTEMPTS, -webhook-max-attempts: attempts before a delivery becomes a dead letter",
+                    "type": "integer",
+                    "example": 8
+                },
+                "maxBackoff": {
+                    "description": "WEBHOOK_MAX_BACKOFF, -webhook-max-backoff: longest delay between retries",
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "pollInterval": {
+                    "description": "WEBHOOK_POLL_INTERVAL, -webhook-poll-interval: how often retries that came due are looked for",
+                    "type": "string",
+                    "example": "5s"
+                },
+                "timeout": {
+                    "description": "WEBHOOK_TIMEOUT, -webhook-timeout: limit for one attempt",
+                    "type": "string",
+                    "example": "10s"
+                }
+            }
+        },
+        "model.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Who made the change",
+                    "type": "string",
+                    "example": "anonymous"
+                },
+                "after": {
+                    "description": "Player after the change, or null",
+                    "type": "object"
+                },
+                "before": {
+                    "description": "Player before the change, or null",
+                    "type": "object"
+                },
+                "id": {
+                    "description": "Sequential event number",
+                    "type": "integer",
+                    "example": 42
+                },
+                "occurredAt": {
+                    "description": "When the change was committed (UTC)",
+                    "type": "string",
+                    "example": "2022-12-18T18:00:00Z"
+                },
+                "operation": {
+                    "description": "create, update, delete, restore or purge",
+                    "type": "string",
+                    "example": "update"
+                },
+                "playerId": {
+                    "description": "Player.ID of the mutated Player",
+                    "type": "string",
+                    "example": "acc433bf-d505-51fe-831e-45eb44c4d43c"
+                }
+            }
+        },
+        "model.BulkImportResult": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "Number of players inserted",
+                    "type": "integer",
+                    "example": 24
+                },
+                "failed": {
+                    "description": "Number of rows rejected",
+                    "type": "integer",
+                    "example": 1
+                },
+                "mode": {
+                    "description": "Import mode: atomic or partial",
+                    "type": "string",
+                    "example": "partial"
+                },
+                "rows": {
+                    "description": "Outcome of every input row",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BulkRowResult"
+                    }
+                }
+            }
+        },
+        "model.BulkRowResult": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "description": "Human-readable explanation of a failure",
+                    "type": "string",
+                    "example": "A player with the same squad number exists."
+                },
+                "errors": {
+                    "description": "Field-level validation failures, if any",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.FieldError"
+                    }
+                },
+                "id": {
+                    "description": "Player.ID assigned to an inserted row",
+                    "type": "string",
+                    "example": "acc433bf-d505-51fe-831e-45eb44c4d43c"
+                },
+                "row": {
+                    "description": "1-based position of the row in the input",
+                    "type": "integer",
+                    "example": 3
+                },
+                "squadNumber": {
+                    "description": "Squad number of the row, when it could be parsed",
+                    "type": "integer",
+                    "example": 10
+                },
+                "status": {
+                    "description": "Outcome as an HTTP status code",
+                    "type": "integer",
+                    "example": 409
+                }
+            }
+        },
+        "model.DeletedPlayer": {
+            "type": "object",
+            "required": [
+                "abbrPosition",
+                "dateOfBirth",
+                "firstName",
+                "lastName",
+                "league",
+                "position",
+                "team"
+            ],
+            "properties": {
+                "abbrPosition": {
+                    "description": "The abbreviated form of the Player's position",
+                    "type": "string"
+                },
+                "dateOfBirth": {
+                    "description": "The date of birth of the Player",
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "When the Player was deleted",
+                    "type": "string",
+                    "example": "2022-12-18T18:00:00Z"
+                },
+                "firstName": {
+                    "description": "The first name of the Player",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Internal UUID (server-generated, opaque to clients)",
+                    "type": "string"
+                },
+                "lastName": {
+                    "description": "The last name of the Player",
+                    "type": "string"
+                },
+                "league": {
+                    "description": "The league where the team plays",
+                    "type": "string"
+                },
+                "middleName": {
+                    "description": "The middle name of the Player, if any",
+                    "type": "string"
+                },
+                "position": {
+                    "description": "The playing position of the Player",
+                    "type": "string"
+                },
+                "squadNumber": {
+                    "description": "User-facing identifier; unique among live Players (DB-enforced)",
+                    "type": "integer",
+                    "maximum": 99,
+                    "minimum": 1
+                },
+                "starting11": {
+                    "description": "Indicates whether the Player is in the starting 11",
+                    "type": "boolean"
+                },
+                "team": {
+                    "description": "The team to which the Player belongs",
+                    "type": "string"
+                }
+            }
+        },
+        "model.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "JSON name of the offending field",
+                    "type": "string",
+                    "example": "squadNumber"
+                },
+                "message": {
+                    "description": "Human-readable description of the failure",
+                    "type": "string",
+                    "example": "must be at most 99"
+                },
+                "rule": {
+                    "description": "Validation rule that failed (binding tag)",
+                    "type": "string",
+                    "example": "max"
+                }
+            }
+        },
+        "model.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "description": "Check-specific values, e.g. migration versions",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "error": {
+                    "description": "Why the check failed",
+                    "type": "string",
+                    "example": "sql: database is closed"
+                },
+                "latency": {
+                    "description": "Time the check took",
+                    "type": "string",
+                    "example": "1.2ms"
+                },
+                "status": {
+                    "description": "ok or failing",
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "model.HealthReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "Result of each dependency check",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.HealthCheck"
+                    }
+                },
+                "status": {
+                    "description": "ok, draining or unavailable",
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
         "model.Player": {
             "type": "object",
             "required": [
@@ -268,7 +2284,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "squadNumber": {
-                    "description": "User-facing unique identifier; DB-enforced uniqueness",
+                    "description": "User-facing identifier; unique among live Players (DB-enforced)",
                     "type": "integer",
                     "maximum": 99,
                     "minimum": 1
@@ -282,6 +2298,166 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "model.ProblemDetails": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "description": "Human-readable explanation specific to this occurrence",
+                    "type": "string",
+                    "example": "Validation failed."
+                },
+                "errors": {
+                    "description": "Field-level validation failures, if any",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "URI reference identifying this occurrence",
+                    "type": "string",
+                    "example": "/players"
+                },
+                "status": {
+                    "description": "HTTP status code generated by the server",
+                    "type": "integer",
+                    "example": 422
+                },
+                "title": {
+                    "description": "Short, human-readable summary of the problem type",
+                    "type": "string",
+                    "example": "Unprocessable Entity"
+                },
+                "type": {
+                    "description": "URI reference identifying the problem type",
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "model.PurgeResult": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "description": "Number of Players permanently deleted",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "model.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Attempts made so far",
+                    "type": "integer",
+                    "example": 8
+                },
+                "createdAt": {
+                    "description": "When the change was made (UTC)",
+                    "type": "string",
+                    "example": "2022-12-18T18:00:00Z"
+                },
+                "deliveredAt": {
+                    "description": "When the receiver accepted it, or null",
+                    "type": "string",
+                    "example": "2022-12-18T18:00:01Z"
+                },
+                "eventType": {
+                    "description": "player.created, player.updated or player.deleted",
+                    "type": "string",
+                    "example": "player.updated"
+                },
+                "id": {
+                    "description": "Sequential delivery number",
+                    "type": "integer",
+                    "example": 42
+                },
+                "lastError": {
+                    "description": "Why the last attempt failed, if it did",
+                    "type": "string",
+                    "example": "unexpected status 503"
+                },
+                "lastStatus": {
+                    "description": "HTTP status of the last attempt; 0 when it got none",
+                    "type": "integer",
+                    "example": 503
+                },
+                "nextAttemptAt": {
+                    "description": "When a pending delivery is next attempted (UTC)",
+                    "type": "string",
+                    "example": "2022-12-18T18:00:00Z"
+                },
+                "payload": {
+                    "description": "Body of every attempt",
+                    "type": "object"
+                },
+                "state": {
+                    "description": "pending, delivered or dead",
+                    "type": "string",
+                    "example": "dead"
+                },
+                "subscriptionId": {
+                    "description": "WebhookSubscription.ID of the receiver",
+                    "type": "string",
+                    "example": "0f8fad5b-d9cb-469f-a165-70867728950e"
+                }
+            }
+        },
+        "model.WebhookSubscription": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "createdAt": {
+                    "description": "When the subscription was created (UTC)",
+                    "type": "string",
+                    "example": "2022-12-18T18:00:00Z"
+                },
+                "events": {
+                    "description": "Event types delivered; empty for all",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "player.created",
+                        "player.deleted"
+                    ]
+                },
+                "id": {
+                    "description": "Server-generated UUID",
+                    "type": "string",
+                    "example": "0f8fad5b-d9cb-469f-a165-70867728950e"
+                },
+                "secret": {
+                    "description": "HMAC-SHA256 key of the signatures, on creation only",
+                    "type": "string",
+                    "example": "9b1d6e3c0a4f..."
+                },
+                "url": {
+                    "description": "Receiver of the deliveries",
+                    "type": "string",
+                    "maxLength": 2048,
+                    "example": "https://example.com/hooks/players"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "A static API key, configured by its SHA-256 in AUTH_API_KEYS.",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "\"Bearer \" followed by a JWT signed with AUTH_JWT_SECRET or a key of AUTH_JWKS_FILE.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,200 @@
+// Package events publishes player changes to in-process subscribers, such as
+// the Server-Sent Events stream of GET /players/events.
+//
+// # Resuming
+//
+// Every event gets an ID, increasing by one from 1 for the life of the
+// process.  The Broker keeps the most recent events in a ring buffer, so a
+// subscriber that went away can resume after the last event it saw
+// (Last-Event-ID).  When that event has already left the buffer, or comes
+// from before a restart, the subscriber cannot catch up and is told to
+// reset, i.e. reload the players, instead.
+//
+// # Slow subscribers
+//
+// Publishing never waits for a subscriber.  A subscriber falling more than
+// subscriberBuffer events behind is dropped: its Events channel is closed,
+// and it can subscribe again from its last event, which the ring buffer
+// still holds unless it has fallen very far behind.
+//
+// # Publishers
+//
+// The Broker is one Publisher of player changes.  The player services hand
+// it every change as soon as it is committed, in the process that made it
+// (see service.NewAuditedPlayerService), so with several replicas each
+// Broker streams the changes of its own replica only: a client following
+// every change must be served by a single replica, or subscribe to
+// webhooks.  The outbox relay, which runs on one replica at a time, hands
+// the changes to the shared sinks instead, such as the webhook dispatcher
+// (see outbox.Bus).
+package events
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+// Event types.
+const (
+	PlayerCreated = "player.created"
+	PlayerUpdated = "player.updated"
+	PlayerDeleted = "player.deleted"
+	PlayerPurged  = "player.purged"
+	// StreamReset is not published: streams send it to a resuming subscriber
+	// whose Subscription.Reset is set.
+	StreamReset = "stream.reset"
+)
+
+// Event is a change to a player.
+type Event struct {
+	ID     uint64       // Position in the stream, from 1
+	Type   string       // PlayerCreated, PlayerUpdated, PlayerDeleted or PlayerPurged
+	Player model.Player // The player after the change; before it for PlayerDeleted and PlayerPurged
+}
+
+// Publisher receives the changes to players, once they are committed.
+// Publish must not block for long: the relay publishes the changes one
+// after the other.
+type Publisher interface {
+	Publish(eventType string, player model.Player)
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped.
+const subscriberBuffer = 64
+
+// Broker fans events out to subscribers and remembers the most recent ones.
+// It is safe for concurrent use, and a nil Broker drops every event.
+type Broker struct {
+	mutex       sync.Mutex
+	ring        []Event // The most recent events, oldest at ring[start]
+	start       int
+	last        uint64 // ID of the latest event; 0 before the first
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker returns a Broker remembering the latest capacity events.
+func NewBroker(capacity int) *Broker {
+	return &Broker{
+		ring:        make([]Event, 0, capacity),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records a change of type eventType to player and sends it to every
+// subscriber.
+func (b *Broker) Publish(eventType string, player model.Player) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return
+	}
+	b.last++
+	event := Event{ID: b.last, Type: eventType, Player: player}
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, event)
+	} else if len(b.ring) > 0 {
+		b.ring[b.start] = event
+		b.start = (b.start + 1) % len(b.ring)
+	}
+	for subscription := range b.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			b.drop(subscription)
+		}
+	}
+}
+
+// Subscription is a subscriber's view of a Broker.
+type Subscription struct {
+	// Replay holds the events published after lastEventID, when the
+	// subscriber resumed; Events delivers those published afterwards.
+	Replay []Event
+	// Reset tells that the events after lastEventID are no longer known: the
+	// subscriber must reload what it knows of the players.
+	Reset bool
+	// Current is the ID of the latest event when subscribing, from which the
+	// subscriber resumes after a reset.
+	Current uint64
+	// Events delivers the events as they are published.  It is closed when
+	// the subscriber falls behind, closes the Subscription, or the Broker is
+	// closed.
+	Events <-chan Event
+
+	broker *Broker
+	events chan Event
+}
+
+// Subscribe returns a Subscription to the events published from now on,
+// preceded by those published after lastEventID when it is not empty.
+func (b *Broker) Subscribe(lastEventID string) *Subscription {
+	events := make(chan Event, subscriberBuffer)
+	subscription := &Subscription{Events: events, broker: b, events: events}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		close(events)
+		return subscription
+	}
+	subscription.Current = b.last
+	if lastEventID != "" {
+		subscription.Replay, subscription.Reset = b.since(lastEventID)
+	}
+	b.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// since returns the buffered events after lastEventID, or reset when some
+// of them are no longer buffered (or lastEventID is not one of this
+// Broker's IDs).
+func (b *Broker) since(lastEventID string) (events []Event, reset bool) {
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || id > b.last {
+		return nil, true
+	}
+	first := b.last - uint64(len(b.ring)) + 1 // ID of the oldest buffered event
+	if id+1 < first {
+		return nil, true
+	}
+	for i := range b.ring {
+		event := b.ring[(b.start+i)%len(b.ring)]
+		if event.ID > id {
+			events = append(events, event)
+		}
+	}
+	return events, false
+}
+
+// Close ends the subscription.  It may be called more than once.
+func (s *Subscription) Close() {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+	s.broker.drop(s)
+}
+
+// drop closes the Events channel of subscription, if still subscribed.  The
+// caller holds the mutex.
+func (b *Broker) drop(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; ok {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// Close ends every subscription and drops the events published from then
+// on, so that open streams end when the server shuts down.
+func (b *Broker) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	for subscription := range b.subscribers {
+		b.drop(subscription)
+	}
+}
@@ -0,0 +1,113 @@
+// Package health defines the dependency checks behind the readiness probe.
+//
+// A Check probes one dependency and returns optional details; the probe
+// runs all of them concurrently with Run and reports each result, with its
+// latency, in a model.HealthReport.  Liveness deliberately runs no check: a
+// dependency outage must make the instance unready (taken out of rotation),
+// not dead (restarted).
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+)
+
+// Check probes one dependency.  Run returns an error when the dependency is
+// unusable, and optionally details worth reporting either way.  It must
+// return promptly once ctx is done.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (map[string]any, error)
+}
+
+// Run runs checks concurrently, each cancelled after timeout, and returns
+// their results, keyed by name, and whether every check passed.
+func Run(ctx context.Context, timeout time.Duration, checks []Check) (map[string]model.HealthCheck, bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	results := make(map[string]model.HealthCheck, len(checks))
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	for _, check := range checks {
+		wait.Go(func() {
+			start := time.Now()
+			details, err := check.Run(ctx)
+			result := model.HealthCheck{
+				Status:  model.HealthOK,
+				Latency: time.Since(start).String(),
+				Details: details,
+			}
+			if err != nil {
+				result.Status = model.HealthFailing
+				result.Error = err.Error()
+			}
+			mutex.Lock()
+			results[check.Name] = result
+			mutex.Unlock()
+		})
+	}
+	wait.Wait()
+	healthy := true
+	for _, result := range results {
+		healthy = healthy && result.Status == model.HealthOK
+	}
+	return results, healthy
+}
+
+// Database checks that a connection to db can be obtained and answers a
+// ping, which fails when, for instance, a PostgreSQL server is down.
+func Database(db *gorm.DB) Check {
+	return Check{Name: "database", Run: func(ctx context.Context) (map[string]any, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"dialect": db.Name()}, sqlDB.PingContext(ctx)
+	}}
+}
+
+// Migrations checks that the schema of db is at the latest migration this
+// binary embeds (see data.MigrationVersions), and reports both versions.
+func Migrations(db *gorm.DB) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) (map[string]any, error) {
+		current, latest, err := data.MigrationVersions(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{"current": current, "latest": latest}
+		if current != latest {
+			return details, fmt.Errorf("schema is at version %d, expected %d", current, latest)
+		}
+		return details, nil
+	}}
+}
+
+// Cache checks that store accepts a write and returns it on a read.  Every
+// probe uses its own key, so concurrent probes cannot read each other's
+// value; the "health.probe." prefix cannot collide with the keys of
+// cache.CachePage, which carry their own.
+func Cache(store persistence.CacheStore) Check {
+	return Check{Name: "cache", Run: func(ctx context.Context) (map[string]any, error) {
+		written := time.Now().UnixNano()
+		key := fmt.Sprintf("health.probe.%d", written)
+		if err := store.Set(key, written, time.Minute); err != nil {
+			return nil, err
+		}
+		defer func() { _ = store.Delete(key) }()
+		var read int64
+		if err := store.Get(key, &read); err != nil {
+			return nil, err
+		}
+		if read != written {
+			return nil, fmt.Errorf("read back %d, wrote %d", read, written)
+		}
+		return nil, nil
+	}}
+}
@@ -0,0 +1,244 @@
+// Package logging writes the access log and GORM's SQL log as JSON lines
+// with log/slog, each tagged with the ID of the request it belongs to.
+//
+// # Request IDs
+//
+// Middleware takes the request ID from the X-Request-ID header, or generates
+// a UUID when the client sends none (or one that is not a short run of
+// printable ASCII, which could forge log lines), echoes it in the response
+// and stores it in the request's context.  GormLogger reads it back from the
+// context of each statement, so the SQL lines of a request carry its ID as
+// long as the statement runs with the request's context (see
+// the context parameter of every PlayerService method).
+//
+// # Lines
+//
+//	{"time":"…","level":"INFO","msg":"request","request_id":"…","method":"GET","path":"/players/…","route":"/players/:id","status":200,"latency_ms":1.2,…}
+//	{"time":"…","level":"INFO","msg":"sql","request_id":"…","sql":"SELECT …","rows":1,"latency_ms":0.3}
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// RequestIDHeader names the request and response header carrying the
+// request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds a request ID accepted from a client.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// New returns a logger writing JSON lines to w.
+func New(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// validRequestID reports whether id, as sent by a client, is safe to log and
+// echo: 1 to maxRequestIDLength printable ASCII characters, without spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware returns the Gin middleware that assigns every request its ID
+// and, once the request has been served, writes its access log line to
+// logger: at INFO level, WARN for 4xx and ERROR for 5xx responses.  It must
+// be the first middleware installed, so the ID is in place for every other
+// one and the line covers the whole request.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		id := context.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		context.Header(RequestIDHeader, id)
+		context.Request = context.Request.WithContext(WithRequestID(context.Request.Context(), id))
+
+		context.Next()
+
+		status := context.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attributes := []slog.Attr{
+			slog.String("request_id", id),
+			slog.String("method", context.Request.Method),
+			slog.String("path", context.Request.URL.Path),
+			slog.String("route", context.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", milliseconds(time.Since(start))),
+			slog.Int("bytes", context.Writer.Size()),
+			slog.String("client_ip", context.ClientIP()),
+			slog.String("user_agent", context.Request.UserAgent()),
+		}
+		if query := context.Request.URL.RawQuery; query != "" {
+			attributes = append(attributes, slog.String("query", query))
+		}
+		if len(context.Errors) > 0 {
+			attributes = append(attributes, slog.String("errors", context.Errors.String()))
+		}
+		logger.LogAttrs(context.Request.Context(), level, "request", attributes...)
+	}
+}
+
+// Recovery returns the Gin middleware that turns a panic in a handler into a
+// 500 response and an ERROR line on logger with the panic value and stack,
+// in place of gin.Recovery's text output.  It must be installed after
+// Middleware, so the line carries the request ID and the 500 is logged.
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(context *gin.Context, err any) {
+		logger.LogAttrs(context.Request.Context(), slog.LevelError, "panic",
+			slog.String("request_id", RequestID(context.Request.Context())),
+			slog.String("error", fmt.Sprint(err)),
+			slog.String("stack", string(debug.Stack())))
+		context.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+// GinDebug returns a gin.DebugPrintFunc writing Gin's debug-mode messages
+// (registered routes, warnings) to logger instead of as text to stdout.
+func GinDebug(logger *slog.Logger) func(format string, values ...any) {
+	return func(format string, values ...any) {
+		logger.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), slog.String("component", "gin"))
+	}
+}
+
+// milliseconds returns d in (fractional) milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// GormLogger is a GORM logger writing to a slog.Logger.  Each statement is a
+// "sql" line tagged with the request ID of its context: at ERROR level when
+// it failed (other than with gorm.ErrRecordNotFound, an expected outcome of
+// lookups), WARN when it took longer than the slow threshold, INFO
+// otherwise.
+type GormLogger struct {
+	logger        *slog.Logger
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewGormLogger returns a GormLogger writing to logger what level lets
+// through: gormlogger.Info every statement, gormlogger.Warn slow and failed
+// ones, gormlogger.Error failed ones, gormlogger.Silent nothing.
+func NewGormLogger(logger *slog.Logger, level gormlogger.LogLevel, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{logger: logger, level: level, slowThreshold: slowThreshold}
+}
+
+// LogMode returns a copy of the logger at level.
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info logs a GORM message at INFO level.
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...any) {
+	if l.level >= gormlogger.Info {
+		l.message(ctx, slog.LevelInfo, msg, data)
+	}
+}
+
+// Warn logs a GORM message at WARN level.
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...any) {
+	if l.level >= gormlogger.Warn {
+		l.message(ctx, slog.LevelWarn, msg, data)
+	}
+}
+
+// Error logs a GORM message at ERROR level.
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...any) {
+	if l.level >= gormlogger.Error {
+		l.message(ctx, slog.LevelError, msg, data)
+	}
+}
+
+// message logs a printf-style GORM message.
+func (l *GormLogger) message(ctx context.Context, level slog.Level, msg string, data []any) {
+	l.logger.LogAttrs(ctx, level, strings.TrimSpace(fmt.Sprintf(msg, data...)), l.requestID(ctx)...)
+}
+
+// Trace logs a finished statement.
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+
+	var level slog.Level
+	switch {
+	case failed && l.level >= gormlogger.Error:
+		level = slog.LevelError
+	case slow && l.level >= gormlogger.Warn:
+		level = slog.LevelWarn
+	case l.level >= gormlogger.Info:
+		level = slog.LevelInfo
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	attributes := append(l.requestID(ctx),
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("latency_ms", milliseconds(elapsed)),
+	)
+	if failed {
+		attributes = append(attributes, slog.String("error", err.Error()))
+	}
+	if slow {
+		attributes = append(attributes, slog.Bool("slow", true))
+	}
+	l.logger.LogAttrs(ctx, level, "sql", attributes...)
+}
+
+// requestID returns the request_id attribute of ctx, or none outside a
+// request.
+func (l *GormLogger) requestID(ctx context.Context) []slog.Attr {
+	if id := RequestID(ctx); id != "" {
+		return []slog.Attr{slog.String("request_id", id)}
+	}
+	return nil
+}
@@ -1,70 +1,325 @@
 // Package main initializes and runs the RESTful API server.
 //
-// It connects to the SQLite3 database, configures routes, and starts the
-// Gin HTTP server with Swagger docs enabled.
+// It loads the configuration, connects to the configured storage backend
+// (SQLite, PostgreSQL or memory), configures routes, and starts the Gin HTTP
+// server with Swagger docs enabled, shutting it down gracefully on SIGINT or
+// SIGTERM.
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/cachestore"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
 	"github.com/nanotaboada/go-samples-gin-restful/controller"
 	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/health"
+	"github.com/nanotaboada/go-samples-gin-restful/logging"
+	"github.com/nanotaboada/go-samples-gin-restful/metrics"
+	"github.com/nanotaboada/go-samples-gin-restful/outbox"
+	"github.com/nanotaboada/go-samples-gin-restful/ratelimit"
 	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/server"
 	"github.com/nanotaboada/go-samples-gin-restful/service"
 	"github.com/nanotaboada/go-samples-gin-restful/swagger"
+	"github.com/nanotaboada/go-samples-gin-restful/tracing"
+	"github.com/nanotaboada/go-samples-gin-restful/webhook"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
 )
 
+// The security schemes of the routes that require credentials (see the auth
+// package); the other API metadata is set at runtime by swagger.Setup.
+//
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description A static API key, configured by its SHA-256 in AUTH_API_KEYS.
+//
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by a JWT signed with AUTH_JWT_SECRET or a key of AUTH_JWKS_FILE.
 func main() {
-	// STORAGE_PATH is injected by Docker Compose (see compose.yaml).
-	// When running locally without Docker the variable is empty, so we fall
-	// back to the pre-seeded file in the repository.
-	dsn := os.Getenv("STORAGE_PATH")
-	if dsn == "" {
-		// Running locally in debug mode; use the bundled SQLite file.
-		dsn = "./storage/players-sqlite3.db"
+	// Everything is logged as JSON lines on stdout: the access log, GORM's
+	// SQL (see data.Connect), Gin's debug messages and, through slog's
+	// default logger, the standard log package used below and by goose.
+	logger := logging.New(os.Stdout)
+	slog.SetDefault(logger)
+	gin.DebugPrintFunc = logging.GinDebug(logger)
+
+	// The configuration comes from defaults, environment variables, an
+	// optional config file and command-line flags, in increasing order of
+	// precedence (see the config package).  An invalid value stops the
+	// process here, before anything is started.  STORAGE_PATH, injected by
+	// Docker Compose (see compose.yaml), is still honoured as the older
+	// spelling of DATABASE_URL.
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
 	}
+	gin.SetMode(cfg.Server.Mode)
 
 	// Dependency injection chain: data → service → controller.
 	// Each layer depends only on the abstraction of the layer below it:
 	//   data.Connect  returns *gorm.DB   (concrete, no interface needed at this level)
-	//   NewPlayerService wraps *gorm.DB  and exposes PlayerService (interface)
+	//   NewAuditedPlayerService wraps *gorm.DB and exposes PlayerService (interface)
 	//   NewPlayerController wraps PlayerService (interface) — easy to mock in tests
-	db := data.Connect(dsn)
-	playerService := service.NewPlayerService(db)
-	playerController := controller.NewPlayerController(playerService)
+	// The memory backend has no database and provides the services itself.
+	// Prometheus metrics are collected from the HTTP layer, GORM and the
+	// response cache, and served at /metrics.  Every request is traced, with
+	// its GORM statements as child spans, and exported as tracing.exporter
+	// says (see the tracing package).
+	appMetrics := metrics.New()
+	appTracing, err := tracing.Setup(context.Background(), cfg.Tracing, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var db *gorm.DB
+	var playerService service.PlayerService
+	var auditService service.AuditService
+	var outboxService service.OutboxService
+	var webhookService service.WebhookService
+	var idempotencyService service.IdempotencyService
+	// Every replica streams the changes it commits to the clients of its own
+	// GET /players/events as soon as they are committed; the outbox relay
+	// below runs on one replica at a time, so it cannot feed them.
+	broker := events.NewBroker(cfg.Events.BufferSize)
+	if dialect, _ := data.Parse(cfg.Database.URL); dialect == data.Memory {
+		playerService, auditService, outboxService = service.NewMemoryServices(nil, broker)
+		webhookService = service.NewMemoryWebhookService()
+		idempotencyService = service.NewMemoryIdempotencyService()
+	} else {
+		db = data.Connect(cfg.Database.URL, cfg.Database.GormLogLevel())
+		if err := appMetrics.InstrumentDB(db); err != nil {
+			log.Fatal(err)
+		}
+		if err := appTracing.InstrumentDB(db); err != nil {
+			log.Fatal(err)
+		}
+		// The audited service records every mutation in the audit_events
+		// and outbox tables, in the same transaction as the mutation itself.
+		playerService = service.NewAuditedPlayerService(db, broker)
+		auditService = service.NewAuditService(db)
+		outboxService = service.NewOutboxService(db)
+		webhookService = service.NewWebhookService(db)
+		idempotencyService = service.NewIdempotencyService(db)
+	}
+	// The outbox relay publishes the committed changes with each configured
+	// publisher (see the outbox package): bus queues them for the webhook
+	// subscriptions (see the webhook package), whose queue all replicas
+	// share.  The NATS server must be reachable at startup, unless it is
+	// embedded: it then runs in this process, and the publisher reaches it
+	// without going through the network.
+	dispatcher := webhook.NewDispatcher(webhookService, cfg.Webhooks)
+	var publishers []outbox.Publisher
+	var natsPublisher *outbox.NATS
+	var natsServer *outbox.EmbeddedNATS
+	for _, name := range cfg.Outbox.Publishers {
+		switch name {
+		case "bus":
+			publishers = append(publishers, outbox.Bus(dispatcher))
+		case "log":
+			publishers = append(publishers, outbox.Log(logger))
+		case "nats":
+			if cfg.Outbox.NATSEmbedded {
+				if natsServer, err = outbox.StartNATS(cfg.Outbox.NATSURL, cfg.Outbox.NATSStoreDir, cfg.Outbox.NATSSubject); err != nil {
+					log.Fatalf("outbox: embedded NATS server error: %v", err)
+				}
+				log.Printf("embedded NATS server listening on %s", natsServer.ClientURL())
+				natsPublisher, err = natsServer.Connect(cfg.Outbox.NATSSubject)
+			} else {
+				natsPublisher, err = outbox.ConnectNATS(cfg.Outbox.NATSURL, cfg.Outbox.NATSSubject)
+			}
+			if err != nil {
+				log.Fatalf("outbox: NATS connection error: %v", err)
+			}
+			publishers = append(publishers, natsPublisher)
+		}
+	}
+	relay := outbox.NewRelay(outboxService, outbox.Multi(publishers...), cfg.Outbox)
+	playerController := controller.NewPlayerController(playerService).WithNotify(relay.Notify)
+	auditController := controller.NewAuditController(auditService)
+	configController := controller.NewConfigController(cfg)
+	webhookController := controller.NewWebhookController(webhookService, dispatcher)
 
-	// InMemoryStore is the in-process cache used by gin-contrib/cache.
-	// The TTL passed here is the default; individual routes may override it.
-	store := persistence.NewInMemoryStore(time.Hour)
+	// Mutating routes require an API key or a bearer JWT (see the auth
+	// package); without any configured, they refuse every request.
+	authenticator, err := auth.New(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !authenticator.Configured() {
+		slog.Warn("no API key or JWT key configured: every request to a protected route will be refused")
+	}
+
+	// The response cache lives in the process, in Redis (shared by every
+	// replica) or nowhere, as configured; see the cachestore package.  The
+	// store's TTL is the default; the cached routes override it with theirs.
+	store, cacheKeys, err := cachestore.Open(cfg.Cache)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Readiness checks every dependency this backend has: the memory backend
+	// has no database (and no migrations) to check, and a disabled cache no
+	// cache.  The cache probe uses the bare store, so it does not count as
+	// cache hits.
+	var checks []health.Check
+	if store != nil {
+		checks = append(checks, health.Cache(store))
+	}
+	if db != nil {
+		checks = append(checks, health.Database(db), health.Migrations(db))
+	}
+	healthController := controller.NewHealthController(checks...)
 
-	// gin.Default() creates a router pre-configured with two middleware:
-	//   Logger  — logs every request (method, path, status, latency) to stdout
-	//   Recovery — catches panics, logs the stack trace, and returns 500
-	// Use gin.New() if you want a bare router with no middleware.
-	app := gin.Default()
+	// gin.New() creates a bare router; the middleware gin.Default() would
+	// add (Logger and Recovery) log as text, so their JSON counterparts are
+	// installed instead:
+	//   logging.Middleware — assigns the X-Request-ID and logs every request
+	//   logging.Recovery   — catches panics, logs the stack trace, and returns 500
+	app := gin.New()
+	// Only the configured reverse proxies are believed about the client's
+	// address (X-Forwarded-For), so a client cannot forge one to dodge its
+	// rate limit.
+	if err := app.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
+	app.Use(logging.Middleware(logger), logging.Recovery(logger))
+	app.Use(appMetrics.Middleware())
+	app.Use(appTracing.Middleware()...)
+	// Every request, and the queries it runs, must be done within
+	// server.requestTimeout; the change stream lifts the deadline.
+	app.Use(controller.Deadline(time.Duration(cfg.Server.RequestTimeout)))
 
-	route.RegisterPlayerRoutes(app, playerController, store)
+	// Rejected credentials are counted per IP address before they reach the
+	// identity-based limits below, so they cannot be guessed at leisure.
+	authenticate := controller.LimitFailedAuthentication(ratelimit.New(cfg.RateLimit.FailedAuth), controller.Authenticate(authenticator))
+	limitReads := controller.RateLimit(ratelimit.New(cfg.RateLimit.Reads))
+	limitWrites := controller.RateLimit(ratelimit.New(cfg.RateLimit.Writes))
+	caching := route.Caching{
+		Store: appMetrics.InstrumentCache(store),
+		Keys:  cacheKeys,
+		TTL:   time.Duration(cfg.Cache.PageTTL),
+		TTLs: map[string]time.Duration{
+			route.GetAllPath:        cfg.Cache.TTL(cfg.Cache.Routes.Players),
+			route.GetByIDPath:       cfg.Cache.TTL(cfg.Cache.Routes.PlayerByID),
+			route.BySquadNumberPath: cfg.Cache.TTL(cfg.Cache.Routes.PlayerBySquadNumber),
+		},
+	}
+	// A retried POST /players with the same Idempotency-Key gets the stored
+	// response for idempotency.ttl instead of creating the player again.
+	idempotent := controller.Idempotency(idempotencyService, time.Duration(cfg.Idempotency.TTL))
+	route.RegisterPlayerRoutes(app, playerController, caching,
+		authenticate, cfg.Auth.ProtectReads, limitReads, limitWrites, idempotent)
+	route.RegisterEventRoutes(app, controller.NewEventsController(broker), authenticate, cfg.Auth.ProtectReads, limitReads)
+	route.RegisterAuditRoutes(app, auditController, authenticate, cfg.Auth.ProtectReads, limitReads)
+	route.RegisterConfigRoutes(app, configController, authenticate)
+	route.RegisterWebhookRoutes(app, webhookController, authenticate)
+	route.RegisterHealthRoutes(app, healthController)
+	route.RegisterMetricsRoutes(app, appMetrics.Handler())
 
 	// The Swagger UI is served at /swagger/index.html.
 	// ginSwagger.WrapHandler adapts the swaggerFiles.Handler (an http.Handler)
 	// to Gin's handler type.
 	app.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Minimal liveness probe — returns {"status":"ok"} with no DB dependency.
-	app.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
-
 	swagger.Setup()
-	// app.Run blocks until the process exits.  The port is fixed at 9000 to
-	// match the Docker EXPOSE directive and the compose.yaml port mapping.
-	if err := app.Run(":9000"); err != nil {
+
+	// ctx is cancelled by the first SIGINT (Ctrl+C) or SIGTERM (docker stop);
+	// a second signal kills the process as usual.  The default address,
+	// :9000, matches the Docker EXPOSE directive and the compose.yaml port
+	// mapping.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	listener, err := net.Listen("tcp", cfg.Server.Address)
+	if err != nil {
 		log.Fatalf("server error: %v", err)
 	}
+	log.Printf("listening on %s", listener.Addr())
+
+	// The webhook worker delivers queued changes until ctx is cancelled; a
+	// delivery it was attempting then is retried once its claim expires.
+	// So does the outbox relay, whose unpublished messages wait in the
+	// outbox for the next start (or another replica).
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		dispatcher.Run(ctx)
+	}()
+	relayed := make(chan struct{})
+	go func() {
+		defer close(relayed)
+		relay.Run(ctx)
+	}()
+
+	// closeNATS waits for the relay, which stop has cancelled, to finish its
+	// round, then closes the NATS connection and the embedded server, if any.
+	// It runs once: as a draining hook, or after a failure to serve.
+	closeNATS := sync.OnceFunc(func() {
+		<-relayed
+		if natsPublisher != nil {
+			if natsErr := natsPublisher.Close(); natsErr != nil {
+				log.Printf("NATS close error: %v", natsErr)
+			}
+		}
+		if natsServer != nil {
+			natsServer.Shutdown()
+		}
+	})
+
+	// server.Run blocks until the signal, then stops the workers, flips
+	// readiness to draining, ends the open event streams (which would
+	// otherwise never finish) and closes NATS, keeps serving for the
+	// readiness delay, so load balancers see the failing probe before the
+	// listener closes, and waits up to the shutdown timeout for in-flight
+	// requests.
+	httpServer := server.New(cfg.Server, app)
+	err = server.Run(ctx, httpServer, listener, time.Duration(cfg.Server.ShutdownReadinessDelay), time.Duration(cfg.Server.ShutdownTimeout), stop, healthController.Drain, broker.Close, closeNATS)
+	if err != nil {
+		log.Printf("server error: %v", err)
+	}
+	stop()
+	<-dispatched
+	closeNATS()
+
+	// No handler, nor the webhook worker or the outbox relay, is running any
+	// more: release the connection pool.
+	if db != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			if dbErr = sqlDB.Close(); dbErr != nil {
+				log.Printf("database close error: %v", dbErr)
+			}
+		}
+	}
+
+	// Export the spans still buffered, including those of the last requests.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	if traceErr := appTracing.Shutdown(shutdownCtx); traceErr != nil {
+		log.Printf("tracing shutdown error: %v", traceErr)
+	}
+	cancel()
+	if err != nil {
+		os.Exit(1)
+	}
+	log.Print("server stopped")
 }
@@ -0,0 +1,224 @@
+// Package metrics collects Prometheus metrics for HTTP requests, GORM
+// queries and the response cache, and serves them for scraping.
+//
+// # Metrics
+//
+//	http_requests_total{method,route,status}             counter
+//	http_request_duration_seconds{method,route,status}   histogram
+//	gorm_query_duration_seconds{operation,table}         histogram
+//	gorm_query_rows{operation,table}                     histogram of rows returned or affected
+//	gorm_query_errors_total{operation,table}             counter
+//	cache_hits_total, cache_misses_total                 counters
+//	cache_evictions_total                                counter
+//
+// plus the standard go_* and process_* collectors.
+//
+// # Label cardinality
+//
+// The route label is the route template (e.g. "/players/:id", see
+// route.GetByIDPath), never the raw path, so one series covers every player;
+// requests matching no route share the "unmatched" label.  The table label
+// is bounded by the schema.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// unmatchedRoute labels requests that matched no route, such as 404s.
+const unmatchedRoute = "unmatched"
+
+// Metrics holds the collectors and the registry they are registered with.
+//
+// Each Metrics has its own registry rather than Prometheus' global one, so
+// tests can create as many as they need without duplicate registrations.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	queryDuration *prometheus.HistogramVec
+	queryRows     *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+
+	cacheHits      prometheus.Counter
+	cacheMisses    prometheus.Counter
+	cacheEvictions prometheus.Counter
+}
+
+// New returns a Metrics with every collector registered.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time to serve an HTTP request, by method, route template and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gorm_query_duration_seconds",
+			Help:    "Time to run a GORM statement, by operation and table.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation", "table"}),
+		queryRows: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gorm_query_rows",
+			Help:    "Rows returned or affected by a GORM statement, by operation and table.",
+			Buckets: []float64{0, 1, 10, 100, 1000},
+		}, []string{"operation", "table"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gorm_query_errors_total",
+			Help: "GORM statements that failed, other than with gorm.ErrRecordNotFound, by operation and table.",
+		}, []string{"operation", "table"}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "Response cache lookups answered from the cache.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_misses_total",
+			Help: "Response cache lookups that found no entry, or an expired one.",
+		}),
+		cacheEvictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cache_evictions_total",
+			Help: "Response cache entries removed before they expired, by invalidation after a mutation.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration,
+		m.queryDuration, m.queryRows, m.queryErrors,
+		m.cacheHits, m.cacheMisses, m.cacheEvictions,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware returns a Gin middleware that counts and times every request,
+// and counts the cache hits and misses recorded by route.CachePage.  It must
+// be installed with router.Use before the routes it measures.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		template := context.FullPath()
+		if template == "" {
+			template = unmatchedRoute
+		}
+		status := strconv.Itoa(context.Writer.Status())
+		m.requests.WithLabelValues(context.Request.Method, template, status).Inc()
+		m.requestDuration.WithLabelValues(context.Request.Method, template, status).Observe(time.Since(start).Seconds())
+
+		switch context.GetString(route.CacheStatusKey) {
+		case route.CacheHit:
+			m.cacheHits.Inc()
+		case route.CacheMiss:
+			m.cacheMisses.Inc()
+		}
+	}
+}
+
+// startKey is the statement instance key holding a statement's start time.
+const startKey = "metrics:start"
+
+// InstrumentDB registers GORM callbacks that time every statement run
+// through db (and the sessions and transactions derived from it) and record
+// its row count and failure.  Migrations run by goose bypass GORM and are not
+// measured.
+func (m *Metrics) InstrumentDB(db *gorm.DB) error {
+	callbacks := db.Callback()
+	processors := []struct {
+		operation     string
+		before, after callbackRegistrar
+	}{
+		{"create", callbacks.Create().Before("*"), callbacks.Create().After("*")},
+		{"query", callbacks.Query().Before("*"), callbacks.Query().After("*")},
+		{"update", callbacks.Update().Before("*"), callbacks.Update().After("*")},
+		{"delete", callbacks.Delete().Before("*"), callbacks.Delete().After("*")},
+		{"row", callbacks.Row().Before("*"), callbacks.Row().After("*")},
+		{"raw", callbacks.Raw().Before("*"), callbacks.Raw().After("*")},
+	}
+	for _, p := range processors {
+		if err := p.before.Register("metrics:before_"+p.operation, start); err != nil {
+			return err
+		}
+		if err := p.after.Register("metrics:after_"+p.operation, m.observe(p.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callbackRegistrar is the part of GORM's (unexported) callback type used by
+// InstrumentDB.
+type callbackRegistrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// start records the start time of a statement.
+func start(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+// observe returns the callback recording a finished statement of operation.
+func (m *Metrics) observe(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		started, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		m.queryDuration.WithLabelValues(operation, table).Observe(time.Since(started.(time.Time)).Seconds())
+		m.queryRows.WithLabelValues(operation, table).Observe(float64(db.Statement.RowsAffected))
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			m.queryErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
+
+// InstrumentCache returns store wrapped so that every entry deleted from it
+// is counted as an eviction.  Hits and misses are counted by Middleware
+// instead (see route.CachePage).  A nil store, for a disabled cache, stays
+// nil.
+func (m *Metrics) InstrumentCache(store persistence.CacheStore) persistence.CacheStore {
+	if store == nil {
+		return nil
+	}
+	return &instrumentedStore{CacheStore: store, metrics: m}
+}
+
+// instrumentedStore is the persistence.CacheStore returned by
+// InstrumentCache; methods it does not override go straight to the store.
+type instrumentedStore struct {
+	persistence.CacheStore
+	metrics *Metrics
+}
+
+// Delete counts the entry as evicted if it was present.
+func (s *instrumentedStore) Delete(key string) error {
+	err := s.CacheStore.Delete(key)
+	if err == nil {
+		s.metrics.cacheEvictions.Inc()
+	}
+	return err
+}
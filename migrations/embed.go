@@ -2,9 +2,14 @@
 // The embedded FS is consumed by data.Connect to apply schema and seed
 // migrations at startup without requiring migration files on the filesystem
 // at runtime.
+//
+// Each supported SQL dialect has its own directory (sqlite, postgres) holding
+// the same sequence of versions, so a schema change is made by adding one
+// file with the same number to every directory.  The Postgres files quote the
+// camelCase column names, which Postgres would otherwise fold to lower case.
 package migrations
 
 import "embed"
 
-//go:embed *.sql
+//go:embed sqlite/*.sql postgres/*.sql
 var FS embed.FS
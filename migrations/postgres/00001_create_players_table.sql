@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS players (
+    id             TEXT         PRIMARY KEY,
+    "firstName"    VARCHAR(100),
+    "middleName"   VARCHAR(100),
+    "lastName"     VARCHAR(100),
+    "dateOfBirth"  TEXT,
+    "squadNumber"  INTEGER      UNIQUE NOT NULL,
+    position       VARCHAR(50),
+    "abbrPosition" VARCHAR(10),
+    team           VARCHAR(100),
+    league         VARCHAR(100),
+    starting11     BOOLEAN
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS players;
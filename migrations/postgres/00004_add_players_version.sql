@@ -0,0 +1,7 @@
+-- +goose Up
+-- version backs optimistic concurrency control: every UPDATE increments it,
+-- and the API exposes it as the player's ETag.  Existing rows start at 1.
+ALTER TABLE players ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE players DROP COLUMN version;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Soft delete: DELETE sets deletedAt instead of removing the row, and every
+-- query filters on deletedAt IS NULL.  A deleted player must not keep its
+-- squad number, so uniqueness is moved from the column constraint to a
+-- partial index over live rows.
+ALTER TABLE players ADD COLUMN "deletedAt" TIMESTAMPTZ;
+ALTER TABLE players DROP CONSTRAINT "players_squadNumber_key";
+
+CREATE UNIQUE INDEX idx_players_squad_number ON players ("squadNumber") WHERE "deletedAt" IS NULL;
+CREATE INDEX idx_players_deleted_at ON players ("deletedAt");
+
+-- +goose Down
+-- Soft-deleted rows cannot coexist with a column-level UNIQUE constraint,
+-- so they are purged before the original table shape is restored.
+DELETE FROM players WHERE "deletedAt" IS NOT NULL;
+
+DROP INDEX idx_players_deleted_at;
+DROP INDEX idx_players_squad_number;
+ALTER TABLE players ADD CONSTRAINT "players_squadNumber_key" UNIQUE ("squadNumber");
+ALTER TABLE players DROP COLUMN "deletedAt";
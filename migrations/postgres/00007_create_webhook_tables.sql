@@ -0,0 +1,34 @@
+-- +goose Up
+-- Webhook subscriptions: where to POST player changes, which changes, and
+-- the secret their HMAC-SHA256 signature is computed with.  events holds a
+-- JSON array of event types; an empty array subscribes to every type.
+CREATE TABLE webhook_subscriptions (
+    id          TEXT          PRIMARY KEY,
+    url         VARCHAR(2048) NOT NULL,
+    events      TEXT          NOT NULL,
+    secret      VARCHAR(128)  NOT NULL,
+    "createdAt" TIMESTAMPTZ   NOT NULL
+);
+
+-- Webhook deliveries: one row per change and subscription, from the moment
+-- the change is made until it is delivered or given up on (the dead
+-- letters).  Rows of deleted subscriptions go with them.
+CREATE TABLE webhook_deliveries (
+    id               BIGSERIAL    PRIMARY KEY,
+    "subscriptionId" TEXT         NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    "eventType"      VARCHAR(20)  NOT NULL,
+    payload          TEXT         NOT NULL,
+    state            VARCHAR(10)  NOT NULL,
+    attempts         INTEGER      NOT NULL DEFAULT 0,
+    "nextAttemptAt"  TIMESTAMPTZ  NOT NULL,
+    "lastStatus"     INTEGER      NOT NULL DEFAULT 0,
+    "lastError"      TEXT         NOT NULL DEFAULT '',
+    "createdAt"      TIMESTAMPTZ  NOT NULL,
+    "deliveredAt"    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (state, "nextAttemptAt");
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
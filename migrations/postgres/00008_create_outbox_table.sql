@@ -0,0 +1,27 @@
+-- +goose Up
+-- Transactional outbox: every player mutation inserts its change here in
+-- the same transaction, so a change is published if and only if it was
+-- committed.  The relay publishes the rows in id order and deletes them once
+-- published; payload is the player's JSON representation (after the change,
+-- or before it for a delete or purge).
+CREATE TABLE outbox (
+    id          BIGSERIAL   PRIMARY KEY,
+    "playerId"  TEXT        NOT NULL,
+    "eventType" VARCHAR(20) NOT NULL,
+    payload     TEXT        NOT NULL,
+    "createdAt" TIMESTAMPTZ NOT NULL
+);
+
+-- The relay lease: only the replica holding it publishes, so the changes to
+-- a player are published in the order they were made.
+CREATE TABLE outbox_leases (
+    name        VARCHAR(20) PRIMARY KEY,
+    holder      TEXT        NOT NULL,
+    "expiresAt" TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO outbox_leases (name, holder, "expiresAt") VALUES ('relay', '', '1970-01-01 00:00:00+00');
+
+-- +goose Down
+DROP TABLE outbox_leases;
+DROP TABLE outbox;
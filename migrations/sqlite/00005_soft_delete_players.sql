@@ -0,0 +1,60 @@
+-- +goose Up
+-- Soft delete: DELETE sets deletedAt instead of removing the row, and every
+-- query filters on deletedAt IS NULL.  A deleted player must not keep its
+-- squad number, so uniqueness is moved from the column constraint to a
+-- partial index over live rows.  SQLite cannot drop a column constraint, so
+-- the table is rebuilt.
+CREATE TABLE players_soft_delete (
+    id           TEXT        PRIMARY KEY,
+    firstName    VARCHAR(100),
+    middleName   VARCHAR(100),
+    lastName     VARCHAR(100),
+    dateOfBirth  TEXT,
+    squadNumber  INTEGER     NOT NULL,
+    position     VARCHAR(50),
+    abbrPosition VARCHAR(10),
+    team         VARCHAR(100),
+    league       VARCHAR(100),
+    starting11   BOOLEAN,
+    version      INTEGER     NOT NULL DEFAULT 1,
+    deletedAt    DATETIME
+);
+
+INSERT INTO players_soft_delete (id, firstName, middleName, lastName, dateOfBirth, squadNumber, position, abbrPosition, team, league, starting11, version)
+SELECT id, firstName, middleName, lastName, dateOfBirth, squadNumber, position, abbrPosition, team, league, starting11, version
+FROM players;
+
+DROP TABLE players;
+ALTER TABLE players_soft_delete RENAME TO players;
+
+CREATE UNIQUE INDEX idx_players_squad_number ON players (squadNumber) WHERE deletedAt IS NULL;
+CREATE INDEX idx_players_deleted_at ON players (deletedAt);
+
+-- +goose Down
+-- Soft-deleted rows cannot coexist with a column-level UNIQUE constraint,
+-- so they are purged before the original table shape is restored.
+DELETE FROM players WHERE deletedAt IS NOT NULL;
+
+CREATE TABLE players_hard_delete (
+    id           TEXT        PRIMARY KEY,
+    firstName    VARCHAR(100),
+    middleName   VARCHAR(100),
+    lastName     VARCHAR(100),
+    dateOfBirth  TEXT,
+    squadNumber  INTEGER     UNIQUE NOT NULL,
+    position     VARCHAR(50),
+    abbrPosition VARCHAR(10),
+    team         VARCHAR(100),
+    league       VARCHAR(100),
+    starting11   BOOLEAN,
+    version      INTEGER     NOT NULL DEFAULT 1
+);
+
+INSERT INTO players_hard_delete (id, firstName, middleName, lastName, dateOfBirth, squadNumber, position, abbrPosition, team, league, starting11, version)
+SELECT id, firstName, middleName, lastName, dateOfBirth, squadNumber, position, abbrPosition, team, league, starting11, version
+FROM players;
+
+DROP TABLE players;
+ALTER TABLE players_hard_delete RENAME TO players;
+
+CREATE UNIQUE INDEX idx_players_squad_number ON players (squadNumber);
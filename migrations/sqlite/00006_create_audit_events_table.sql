@@ -0,0 +1,20 @@
+-- +goose Up
+-- Audit trail of player mutations.  Each row records one operation on one
+-- player together with its JSON representation before and after, and is
+-- written in the same transaction as the mutation it describes.  Rows are
+-- never updated or deleted by the API, and they outlive purged players.
+CREATE TABLE audit_events (
+    id          INTEGER      PRIMARY KEY AUTOINCREMENT,
+    playerId    TEXT         NOT NULL,
+    operation   VARCHAR(10)  NOT NULL,
+    actor       VARCHAR(100) NOT NULL,
+    occurredAt  DATETIME     NOT NULL,
+    beforeState TEXT,
+    afterState  TEXT
+);
+
+CREATE INDEX idx_audit_events_player_id ON audit_events (playerId, occurredAt);
+CREATE INDEX idx_audit_events_occurred_at ON audit_events (occurredAt);
+
+-- +goose Down
+DROP TABLE audit_events;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Responses to requests sent with an Idempotency-Key header, replayed when
+-- the client retries with the same key.  A key belongs to the client that
+-- sent it (owner); fingerprint tells a retry from another request reusing
+-- the key.  A row with status 0 is a request still in progress.  Rows are
+-- removed once expired.
+CREATE TABLE idempotency_keys (
+    owner          TEXT     NOT NULL,
+    idempotencyKey TEXT     NOT NULL,
+    fingerprint    TEXT     NOT NULL,
+    status         INTEGER  NOT NULL,
+    header         TEXT     NOT NULL,
+    body           BLOB     NOT NULL,
+    createdAt      DATETIME NOT NULL,
+    expiresAt      DATETIME NOT NULL,
+    PRIMARY KEY (owner, idempotencyKey)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expiresAt);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Operations recorded in AuditEvent.Operation.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// AuditEvent is one entry of the audit trail: a single mutation of a single
+// Player, who made it and when.
+//
+// Before and After hold the Player's JSON representation, exactly as GET
+// returns it, on either side of the mutation; Before is null for a create
+// and After is null for a delete or purge.  They are stored as JSON text
+// rather than as columns so the trail survives later changes to Player.
+type AuditEvent struct {
+	ID         int64           `json:"id" gorm:"column:id;primaryKey" example:"42"`                                    // Sequential event number
+	PlayerID   string          `json:"playerId" gorm:"column:playerId" example:"acc433bf-d505-51fe-831e-45eb44c4d43c"` // Player.ID of the mutated Player
+	Operation  string          `json:"operation" gorm:"column:operation" example:"update"`                             // create, update, delete, restore or purge
+	Actor      string          `json:"actor" gorm:"column:actor" example:"anonymous"`                                  // Who made the change
+	OccurredAt time.Time       `json:"occurredAt" gorm:"column:occurredAt" example:"2022-12-18T18:00:00Z"`             // When the change was committed (UTC)
+	Before     json.RawMessage `json:"before" gorm:"column:beforeState" swaggertype:"object"`                          // Player before the change, or null
+	After      json.RawMessage `json:"after" gorm:"column:afterState" swaggertype:"object"`                            // Player after the change, or null
+}
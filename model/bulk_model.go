@@ -0,0 +1,29 @@
+package model
+
+// BulkImportResult is the body returned by POST /players/bulk.
+//
+// Mode echoes the import mode: "atomic" (all-or-nothing) or "partial"
+// (every valid, non-conflicting row is inserted).  Rows holds one entry per
+// input row, in input order, so clients can match outcomes to their
+// spreadsheet lines.
+type BulkImportResult struct {
+	Mode    string          `json:"mode" example:"partial"` // Import mode: atomic or partial
+	Created int             `json:"created" example:"24"`   // Number of players inserted
+	Failed  int             `json:"failed" example:"1"`     // Number of rows rejected
+	Rows    []BulkRowResult `json:"rows"`                   // Outcome of every input row
+}
+
+// BulkRowResult is the outcome of a single row of a bulk import.
+//
+// Status uses HTTP status codes as a vocabulary: 201 (inserted), 400 (the
+// row could not be parsed), 409 (squad number already taken), 422 (failed
+// validation; see Errors) and 424 Failed Dependency (the row was valid but
+// was not inserted because another row failed an atomic import).
+type BulkRowResult struct {
+	Row         int          `json:"row" example:"3"`                                                        // 1-based position of the row in the input
+	Status      int          `json:"status" example:"409"`                                                   // Outcome as an HTTP status code
+	ID          string       `json:"id,omitempty" example:"acc433bf-d505-51fe-831e-45eb44c4d43c"`            // Player.ID assigned to an inserted row
+	SquadNumber int          `json:"squadNumber,omitempty" example:"10"`                                     // Squad number of the row, when it could be parsed
+	Detail      string       `json:"detail,omitempty" example:"A player with the same squad number exists."` // Human-readable explanation of a failure
+	Errors      []FieldError `json:"errors,omitempty"`                                                       // Field-level validation failures, if any
+}
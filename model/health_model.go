@@ -0,0 +1,25 @@
+package model
+
+// Health statuses reported by HealthReport.Status and HealthCheck.Status.
+const (
+	HealthOK          = "ok"          // Healthy: the server accepts new work
+	HealthDraining    = "draining"    // Shutting down: finishing in-flight requests only
+	HealthUnavailable = "unavailable" // At least one dependency check failed
+	HealthFailing     = "failing"     // This dependency check failed
+)
+
+// HealthReport is the body of the health probes.  Readiness lists every
+// dependency check under Checks, keyed by name (e.g. "database"), so a
+// failing probe says which dependency is at fault.
+type HealthReport struct {
+	Status string                 `json:"status" example:"ok"` // ok, draining or unavailable
+	Checks map[string]HealthCheck `json:"checks,omitempty"`    // Result of each dependency check
+}
+
+// HealthCheck is the result of one dependency check.
+type HealthCheck struct {
+	Status  string         `json:"status" example:"ok"`                               // ok or failing
+	Latency string         `json:"latency" example:"1.2ms"`                           // Time the check took
+	Error   string         `json:"error,omitempty" example:"sql: database is closed"` // Why the check failed
+	Details map[string]any `json:"details,omitempty"`                                 // Check-specific values, e.g. migration versions
+}
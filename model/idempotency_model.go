@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is the response to a request sent with an
+// Idempotency-Key header, replayed when the client retries with the same
+// key (see controller.Idempotency).  Status is 0 while the request is in
+// progress.
+type IdempotencyRecord struct {
+	Owner       string            `gorm:"column:owner;primaryKey"`          // The client the key belongs to
+	Key         string            `gorm:"column:idempotencyKey;primaryKey"` // The Idempotency-Key header
+	Fingerprint string            `gorm:"column:fingerprint"`               // Hash of the method, path and body of the request
+	Status      int               `gorm:"column:status"`                    // Status of the response; 0 while in progress
+	Header      map[string]string `gorm:"column:header;serializer:json"`    // Headers of the response that are replayed
+	Body        []byte            `gorm:"column:body"`                      // Body of the response
+	CreatedAt   time.Time         `gorm:"column:createdAt"`                 // When the request was first received (UTC)
+	ExpiresAt   time.Time         `gorm:"column:expiresAt"`                 // When the key may be used again for another request (UTC)
+}
+
+// TableName is the idempotency_keys table of the migrations, rather than
+// GORM's default "idempotency_records".
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxMessage is a committed change to a Player waiting to be published
+// by the outbox relay (see the outbox package).  It is written in the same
+// transaction as the change, so it exists if and only if the change was
+// committed.
+type OutboxMessage struct {
+	ID        int64           `json:"id" gorm:"column:id;primaryKey"`    // Sequential message number: the publication order
+	PlayerID  string          `json:"playerId" gorm:"column:playerId"`   // Player.ID of the changed Player
+	EventType string          `json:"eventType" gorm:"column:eventType"` // player.created, player.updated, player.deleted or player.purged
+	Payload   json.RawMessage `json:"payload" gorm:"column:payload"`     // The Player after the change; before it for a delete or purge
+	CreatedAt time.Time       `json:"createdAt" gorm:"column:createdAt"` // When the change was committed (UTC)
+}
+
+// TableName is the outbox table of the migrations, rather than GORM's
+// default "outbox_messages".
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
@@ -2,6 +2,12 @@
 // including Player.
 package model
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 // Player is a footballer, a sportsperson who plays football.
 //
 // # Struct tags
@@ -30,16 +36,44 @@ package model
 // generated server-side on POST. This keeps the internal key opaque and stable
 // across environments.  Clients use squadNumber to identify players in PUT and
 // DELETE requests; the UUID is available via the UUID lookup endpoint.
+//
+// # Version
+//
+// Version is an optimistic-concurrency counter incremented on every update.
+// It is not part of the JSON representation (`json:"-"`); the API exposes it
+// through the ETag header instead, and clients send it back in If-Match to
+// make PUT and DELETE conditional.
+//
+// # Soft delete
+//
+// DeletedAt is a gorm.DeletedAt, which GORM treats specially: Delete sets it
+// instead of removing the row, and every query adds "deletedAt IS NULL"
+// unless it is built with Unscoped.  Deleted players are listed and restored
+// through the trash endpoints, which expose the timestamp via DeletedPlayer.
 type Player struct {
-	ID           string `json:"id" gorm:"column:id;primaryKey" binding:"-"`                               // Internal UUID (server-generated, opaque to clients)
-	FirstName    string `json:"firstName" gorm:"column:firstName" binding:"required"`                     // The first name of the Player
-	MiddleName   string `json:"middleName" gorm:"column:middleName" binding:"omitempty"`                  // The middle name of the Player, if any
-	LastName     string `json:"lastName" gorm:"column:lastName" binding:"required"`                       // The last name of the Player
-	DateOfBirth  string `json:"dateOfBirth" gorm:"column:dateOfBirth" binding:"required"`                 // The date of birth of the Player
-	SquadNumber  int    `json:"squadNumber" gorm:"column:squadNumber;uniqueIndex" binding:"min=1,max=99"` // User-facing unique identifier; DB-enforced uniqueness
-	Position     string `json:"position" gorm:"column:position" binding:"required"`                       // The playing position of the Player
-	AbbrPosition string `json:"abbrPosition" gorm:"column:abbrPosition" binding:"required"`               // The abbreviated form of the Player's position
-	Team         string `json:"team" gorm:"column:team" binding:"required"`                               // The team to which the Player belongs
-	League       string `json:"league" gorm:"column:league" binding:"required"`                           // The league where the team plays
-	Starting11   bool   `json:"starting11" gorm:"column:starting11"`                                      // Indicates whether the Player is in the starting 11
+	ID           string         `json:"id" gorm:"column:id;primaryKey" binding:"-"`                               // Internal UUID (server-generated, opaque to clients)
+	FirstName    string         `json:"firstName" gorm:"column:firstName" binding:"required"`                     // The first name of the Player
+	MiddleName   string         `json:"middleName" gorm:"column:middleName" binding:"omitempty"`                  // The middle name of the Player, if any
+	LastName     string         `json:"lastName" gorm:"column:lastName" binding:"required"`                       // The last name of the Player
+	DateOfBirth  string         `json:"dateOfBirth" gorm:"column:dateOfBirth" binding:"required"`                 // The date of birth of the Player
+	SquadNumber  int            `json:"squadNumber" gorm:"column:squadNumber;uniqueIndex" binding:"min=1,max=99"` // User-facing identifier; unique among live Players (DB-enforced)
+	Position     string         `json:"position" gorm:"column:position" binding:"required"`                       // The playing position of the Player
+	AbbrPosition string         `json:"abbrPosition" gorm:"column:abbrPosition" binding:"required"`               // The abbreviated form of the Player's position
+	Team         string         `json:"team" gorm:"column:team" binding:"required"`                               // The team to which the Player belongs
+	League       string         `json:"league" gorm:"column:league" binding:"required"`                           // The league where the team plays
+	Starting11   bool           `json:"starting11" gorm:"column:starting11"`                                      // Indicates whether the Player is in the starting 11
+	Version      int            `json:"-" gorm:"column:version;default:1" binding:"-"`                            // Optimistic-concurrency counter, exposed as the ETag
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"column:deletedAt;index" binding:"-"`                              // Soft-delete timestamp; NULL while the Player is live
+}
+
+// DeletedPlayer is a soft-deleted Player as listed in the trash: the full
+// Player representation plus the time it was deleted.
+type DeletedPlayer struct {
+	Player
+	DeletedAt time.Time `json:"deletedAt" example:"2022-12-18T18:00:00Z"` // When the Player was deleted
+}
+
+// PurgeResult reports how many soft-deleted Players a purge removed for good.
+type PurgeResult struct {
+	Purged int64 `json:"purged" example:"3"` // Number of Players permanently deleted
 }
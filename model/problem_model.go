@@ -0,0 +1,29 @@
+package model
+
+// ProblemDetails is an RFC 7807 (Problem Details for HTTP APIs) error body,
+// served with the "application/problem+json" media type.
+//
+// Type is a URI reference identifying the problem type; "about:blank" means
+// the problem has no additional semantics beyond the HTTP status code, in
+// which case Title is the standard status text (e.g. "Not Found").
+// Instance identifies this specific occurrence — here, the request path.
+//
+// Errors is an extension member (RFC 7807 §3.2) listing the individual
+// field-level failures when a request body fails binding validation.
+type ProblemDetails struct {
+	Type     string       `json:"type" example:"about:blank"`                    // URI reference identifying the problem type
+	Title    string       `json:"title" example:"Unprocessable Entity"`          // Short, human-readable summary of the problem type
+	Status   int          `json:"status" example:"422"`                          // HTTP status code generated by the server
+	Detail   string       `json:"detail,omitempty" example:"Validation failed."` // Human-readable explanation specific to this occurrence
+	Instance string       `json:"instance,omitempty" example:"/players"`         // URI reference identifying this occurrence
+	Errors   []FieldError `json:"errors,omitempty"`                              // Field-level validation failures, if any
+}
+
+// FieldError describes a single request field that failed validation.
+// Field is the JSON name (e.g. "squadNumber"), not the Go struct field name,
+// so clients can map it back to their payload directly.
+type FieldError struct {
+	Field   string `json:"field" example:"squadNumber"`          // JSON name of the offending field
+	Rule    string `json:"rule" example:"max"`                   // Validation rule that failed (binding tag)
+	Message string `json:"message" example:"must be at most 99"` // Human-readable description of the failure
+}
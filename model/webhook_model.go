@@ -0,0 +1,63 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// States of a WebhookDelivery.
+const (
+	DeliveryPending   = "pending"   // Waiting for its next attempt
+	DeliveryDelivered = "delivered" // Accepted by the receiver with a 2xx status
+	DeliveryDead      = "dead"      // Given up on after the last attempt: a dead letter
+)
+
+// WebhookSubscription asks for player changes to be POSTed to URL.
+//
+// Secret is generated by the server when the subscription is created, and
+// returned only then: receivers verify the signature of every delivery with
+// it (see the webhook package).
+type WebhookSubscription struct {
+	ID        string    `json:"id" gorm:"column:id;primaryKey" example:"0f8fad5b-d9cb-469f-a165-70867728950e"`                                                                                       // Server-generated UUID
+	URL       string    `json:"url" gorm:"column:url" binding:"required,http_url,max=2048" example:"https://example.com/hooks/players"`                                                              // Receiver of the deliveries
+	Events    []string  `json:"events" gorm:"column:events;serializer:json" binding:"dive,oneof=player.created player.updated player.deleted player.purged" example:"player.created,player.deleted"` // Event types delivered; empty for all
+	Secret    string    `json:"secret,omitempty" gorm:"column:secret" example:"9b1d6e3c0a4f..."`                                                                                                     // HMAC-SHA256 key of the signatures, on creation only
+	CreatedAt time.Time `json:"createdAt" gorm:"column:createdAt" example:"2022-12-18T18:00:00Z"`                                                                                                    // When the subscription was created (UTC)
+}
+
+// Wants reports whether the subscription is for events of eventType.
+func (s WebhookSubscription) Wants(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, wanted := range s.Events {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event on its way to one subscription, from the
+// moment the change is made until the receiver accepts it or it is given up
+// on.  Payload is the JSON body POSTed on every attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id" gorm:"column:id;primaryKey" example:"42"`                                                // Sequential delivery number
+	SubscriptionID string          `json:"subscriptionId" gorm:"column:subscriptionId" example:"0f8fad5b-d9cb-469f-a165-70867728950e"` // WebhookSubscription.ID of the receiver
+	EventType      string          `json:"eventType" gorm:"column:eventType" example:"player.updated"`                                 // player.created, player.updated or player.deleted
+	Payload        json.RawMessage `json:"payload" gorm:"column:payload" swaggertype:"object"`                                         // Body of every attempt
+	State          string          `json:"state" gorm:"column:state" example:"dead"`                                                   // pending, delivered or dead
+	Attempts       int             `json:"attempts" gorm:"column:attempts" example:"8"`                                                // Attempts made so far
+	NextAttemptAt  time.Time       `json:"nextAttemptAt" gorm:"column:nextAttemptAt" example:"2022-12-18T18:00:00Z"`                   // When a pending delivery is next attempted (UTC)
+	LastStatus     int             `json:"lastStatus" gorm:"column:lastStatus" example:"503"`                                          // HTTP status of the last attempt; 0 when it got none
+	LastError      string          `json:"lastError" gorm:"column:lastError" example:"unexpected status 503"`                          // Why the last attempt failed, if it did
+	CreatedAt      time.Time       `json:"createdAt" gorm:"column:createdAt" example:"2022-12-18T18:00:00Z"`                           // When the change was made (UTC)
+	DeliveredAt    *time.Time      `json:"deliveredAt" gorm:"column:deliveredAt" example:"2022-12-18T18:00:01Z"`                       // When the receiver accepted it, or null
+}
+
+// WebhookEvent is the JSON body of a delivery.
+type WebhookEvent struct {
+	Type       string    `json:"type" example:"player.updated"`             // player.created, player.updated or player.deleted
+	OccurredAt time.Time `json:"occurredAt" example:"2022-12-18T18:00:00Z"` // When the change was made (UTC)
+	Player     Player    `json:"player"`                                    // The player after the change; before it for player.deleted
+}
@@ -0,0 +1,114 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsStartTimeout bounds the wait for the embedded NATS server to accept
+// connections.
+const natsStartTimeout = 10 * time.Second
+
+// natsDuplicateWindow is how long the stream of the embedded NATS server
+// remembers the Nats-Msg-Id of the messages it stored: a message published
+// again within it, after its acknowledgement was lost, is dropped.
+const natsDuplicateWindow = 2 * time.Minute
+
+// EmbeddedNATS is a NATS server running in the process, so the nats
+// publisher needs no server of its own.  JetStream is enabled, with a stream
+// keeping every message published on the player change subjects: consumers
+// can replay the changes they missed, and a message published twice is
+// stored once (see natsDuplicateWindow).
+type EmbeddedNATS struct {
+	server *natsserver.Server
+}
+
+// StartNATS starts a NATS server listening on the host and port of url,
+// storing its JetStream data in storeDir, and creates or updates the stream
+// of the subjects subject.> (e.g. players.player.updated), named after
+// subject in upper case (e.g. PLAYERS).
+func StartNATS(rawURL string, storeDir string, subject string) (*EmbeddedNATS, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host, portText, err := net.SplitHostPort(parsed.Host)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("NATS port %q: %w", portText, err)
+	}
+	server, err := natsserver.NewServer(&natsserver.Options{
+		ServerName: "go-samples-gin-restful",
+		Host:       host,
+		Port:       port,
+		JetStream:  true,
+		StoreDir:   storeDir,
+		NoSigs:     true, // Signals are main's to handle
+	})
+	if err != nil {
+		return nil, err
+	}
+	server.Start()
+	if !server.ReadyForConnections(natsStartTimeout) {
+		server.Shutdown()
+		return nil, errors.New("the embedded NATS server did not start in time")
+	}
+	embedded := &EmbeddedNATS{server: server}
+	if err := embedded.createStream(subject); err != nil {
+		embedded.Shutdown()
+		return nil, fmt.Errorf("NATS stream: %w", err)
+	}
+	return embedded, nil
+}
+
+// createStream creates or updates the stream of the subjects subject.>.
+func (e *EmbeddedNATS) createStream(subject string) error {
+	conn, err := nats.Connect(e.server.ClientURL(), nats.InProcessServer(e.server))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), natsStartTimeout)
+	defer cancel()
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       strings.ToUpper(strings.ReplaceAll(subject, ".", "_")),
+		Subjects:   []string{subject + ".>"},
+		Duplicates: natsDuplicateWindow,
+	})
+	return err
+}
+
+// ClientURL returns the URL clients outside the process connect to.
+func (e *EmbeddedNATS) ClientURL() string {
+	return e.server.ClientURL()
+}
+
+// Connect returns a NATS publisher (see ConnectNATS) connected to the server
+// in process, without going through the network.
+func (e *EmbeddedNATS) Connect(subject string) (*NATS, error) {
+	return ConnectNATS(e.server.ClientURL(), subject, nats.InProcessServer(e.server))
+}
+
+// Shutdown closes the client connections and stops the server, once the
+// publishers connected to it are closed.
+func (e *EmbeddedNATS) Shutdown() {
+	e.server.Shutdown()
+	e.server.WaitForShutdown()
+}
@@ -0,0 +1,156 @@
+// Package outbox publishes the player changes written to the outbox.
+//
+// # Transactional outbox
+//
+// Every mutation of service.PlayerService writes an OutboxMessage in the
+// same transaction as the change itself (see service.OutboxService), so a
+// change is never committed without its message, nor a message without its
+// change.  The Relay then drains the outbox to a Publisher: Bus hands the
+// changes to in-process publishers (the webhook dispatcher, whose queue the
+// replicas share), Log logs them, and NATS sends them to a NATS server,
+// external or running in the process (see EmbeddedNATS).
+//
+// The change stream of GET /players/events is not fed by the Relay: only one
+// replica relays at a time, so the player services hand every change to the
+// events.Broker of the replica that committed it instead.
+//
+// # Delivery
+//
+// A message is deleted only once published, so delivery is at least once: a
+// message whose publication failed, or whose acknowledgement was lost to a
+// crash, is published again.  Consumers should ignore the message IDs they
+// have already processed (NATS sends them as the Nats-Msg-Id header, which
+// JetStream deduplicates on).
+//
+// Messages are published in the order they were written.  When publishing
+// one fails, the later messages of the same player wait for it, so the
+// changes to a player are never published out of order; other players'
+// messages are not held back.  Only the replica holding the relay lease
+// drains the outbox, so replicas do not reorder them either.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher publishes outbox messages for the Relay.
+type Publisher interface {
+	// Publish returns once message is published, or an error, in which case
+	// the Relay publishes it again later.
+	Publish(ctx context.Context, message model.OutboxMessage) error
+}
+
+// multi publishes to several publishers in turn.
+type multi []Publisher
+
+// Multi returns a Publisher publishing every message to each of publishers,
+// in order.  When one fails, the message is published again to all of them,
+// so each must tolerate duplicates.
+func Multi(publishers ...Publisher) Publisher {
+	return multi(publishers)
+}
+
+// Publish publishes message to each publisher, stopping at the first error.
+func (m multi) Publish(ctx context.Context, message model.OutboxMessage) error {
+	for _, publisher := range m {
+		if err := publisher.Publish(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bus hands messages to in-process publishers.
+type bus []events.Publisher
+
+// Bus returns a Publisher handing every message, as the player change it
+// describes, to each of publishers, such as a webhook.Dispatcher.
+func Bus(publishers ...events.Publisher) Publisher {
+	return bus(publishers)
+}
+
+// Publish decodes the player of message and hands it to each publisher.
+func (b bus) Publish(ctx context.Context, message model.OutboxMessage) error {
+	var player model.Player
+	if err := json.Unmarshal(message.Payload, &player); err != nil {
+		return err
+	}
+	for _, publisher := range b {
+		publisher.Publish(message.EventType, player)
+	}
+	return nil
+}
+
+// logPublisher logs messages.
+type logPublisher struct {
+	logger *slog.Logger
+}
+
+// Log returns a Publisher logging every message to logger, at info level.
+func Log(logger *slog.Logger) Publisher {
+	return logPublisher{logger: logger}
+}
+
+// Publish logs message.
+func (p logPublisher) Publish(ctx context.Context, message model.OutboxMessage) error {
+	p.logger.InfoContext(ctx, "outbox: player change",
+		"message", message.ID,
+		"event", message.EventType,
+		"player", message.PlayerID,
+		"payload", message.Payload,
+	)
+	return nil
+}
+
+// natsFlushTimeout bounds the wait for the NATS server to take a message.
+const natsFlushTimeout = 5 * time.Second
+
+// NATS publishes messages to a NATS server.
+type NATS struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// ConnectNATS connects to the NATS server at url, and returns a NATS
+// publisher sending every message to the subject made of subject, a dot and
+// the event type (e.g. players.player.updated), with the player as its data
+// and the message ID as its Nats-Msg-Id header.  The connection is
+// re-established as needed until Close.  options are applied after the
+// defaults, e.g. nats.InProcessServer for an EmbeddedNATS.
+func ConnectNATS(url string, subject string, options ...nats.Option) (*NATS, error) {
+	conn, err := nats.Connect(url, append([]nats.Option{nats.Name("go-samples-gin-restful"), nats.MaxReconnects(-1)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{conn: conn, subject: subject}, nil
+}
+
+// Publish sends message and waits for the server to take it.
+func (n *NATS) Publish(ctx context.Context, message model.OutboxMessage) error {
+	msg := nats.NewMsg(n.subject + "." + message.EventType)
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(message.ID, 10))
+	msg.Data = message.Payload
+	if err := n.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, natsFlushTimeout)
+	defer cancel()
+	return n.conn.FlushWithContext(ctx)
+}
+
+// Close sends what is buffered and closes the connection.
+func (n *NATS) Close() error {
+	if err := n.conn.Drain(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+)
+
+// minLease is the shortest relay lease: long enough for a round to finish
+// before another replica may take over.
+const minLease = 30 * time.Second
+
+// Relay drains the outbox to a Publisher.
+type Relay struct {
+	service   service.OutboxService
+	publisher Publisher
+	config    config.OutboxConfig
+	holder    string        // Identifies this relay in the lease
+	wake      chan struct{} // Signalled when messages are written
+}
+
+// NewRelay returns a Relay publishing the messages of service with
+// publisher, as cfg says.  It only runs once Run is called.
+func NewRelay(service service.OutboxService, publisher Publisher, cfg config.OutboxConfig) *Relay {
+	return &Relay{
+		service:   service,
+		publisher: publisher,
+		config:    cfg,
+		holder:    uuid.NewString(),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the relay, unless it is already due to wake.  It never
+// blocks, so it is called after every change (see
+// controller.PlayerController.WithNotify).
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run drains the outbox until ctx is done: whenever Notify is called, and
+// every PollInterval for the changes of other replicas and the messages
+// whose publication failed.  It then gives up the lease, so that the relay
+// of a restarted process need not wait for it to expire.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.config.PollInterval))
+	defer ticker.Stop()
+	for {
+		if _, err := r.Drain(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("outbox: draining failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			// A lease renewed to expire now is free for anyone to take.
+			if _, err := r.service.Lease(context.Background(), r.holder, time.Now(), 0); err != nil {
+				slog.Error("outbox: releasing the lease failed", "error", err)
+			}
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain publishes the pending messages, oldest first, and returns how many
+// it published.  It publishes nothing while another relay holds the lease,
+// and stops after a round in which a publication failed: the messages left
+// are published again by a later Drain.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	lease := max(minLease, 3*time.Duration(r.config.PollInterval))
+	published := 0
+	for {
+		if held, err := r.service.Lease(ctx, r.holder, time.Now(), lease); err != nil || !held {
+			return published, err
+		}
+		messages, err := r.service.Pending(ctx, r.config.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		// A player whose message failed has its later messages held back,
+		// to keep its changes in order.
+		failed := make(map[string]bool)
+		acknowledged := make([]int64, 0, len(messages))
+		for _, message := range messages {
+			if ctx.Err() != nil {
+				break
+			}
+			if failed[message.PlayerID] {
+				continue
+			}
+			if err := r.publisher.Publish(ctx, message); err != nil {
+				slog.Warn("outbox: publishing failed", "message", message.ID, "event", message.EventType, "player", message.PlayerID, "error", err)
+				failed[message.PlayerID] = true
+				continue
+			}
+			acknowledged = append(acknowledged, message.ID)
+		}
+		if err := r.service.Acknowledge(ctx, acknowledged...); err != nil {
+			return published, err
+		}
+		published += len(acknowledged)
+		if ctx.Err() != nil {
+			return published, ctx.Err()
+		}
+		if len(failed) > 0 || len(messages) < r.config.BatchSize {
+			return published, nil
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package ratelimit limits how often each client may call the API, with a
+// token bucket per client.
+//
+// # Token buckets
+//
+// A Limiter for a config.Rate of N requests per period gives every client a
+// bucket of N tokens, refilled continuously at N per period.  Each request
+// takes a token; a request finding the bucket empty is refused until the
+// next token comes in.  A client can thus send N requests in a burst, but no
+// more than N per period on average.
+//
+// Buckets are created on a client's first request.  A bucket that has
+// refilled completely is indistinguishable from a new one, so full buckets
+// are dropped from time to time, keeping memory bounded by the clients
+// active within about one period.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+)
+
+// Decision is the outcome of Limiter.Allow and Limiter.Check.
+type Decision struct {
+	Allowed    bool          // Whether the request may proceed
+	Limit      int           // Capacity of the bucket: requests allowed per period
+	Remaining  int           // Whole tokens left in the bucket after the request
+	RetryAfter time.Duration // Wait until the next token, when not Allowed
+}
+
+// bucket is the state of one client's token bucket.
+type bucket struct {
+	tokens float64   // Tokens at update
+	update time.Time // When tokens was last computed
+}
+
+// Limiter holds the token buckets of every client for one rate.  It is safe
+// for concurrent use.
+type Limiter struct {
+	rate     config.Rate
+	perToken time.Duration // Time to refill one token
+	mutex    sync.Mutex
+	buckets  map[string]*bucket
+	swept    time.Time // When full buckets were last dropped
+}
+
+// New returns a Limiter allowing rate to every client, or nil when rate is
+// off; a nil Limiter allows everything.
+func New(rate config.Rate) *Limiter {
+	if rate.Off() {
+		return nil
+	}
+	return &Limiter{
+		rate:     rate,
+		perToken: rate.Period / time.Duration(rate.Requests),
+		buckets:  make(map[string]*bucket),
+		swept:    time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of the client key, and tells whether
+// there was one.
+func (l *Limiter) Allow(key string) Decision {
+	return l.decide(key, true)
+}
+
+// Check tells whether the bucket of the client key holds a token, like
+// Allow, but leaves it there: the caller takes it later with Allow, if the
+// request turns out to count.
+func (l *Limiter) Check(key string) Decision {
+	return l.decide(key, false)
+}
+
+// decide refills the bucket of the client key, and takes a token from it
+// when take is set and there is one.
+func (l *Limiter) decide(key string, take bool) Decision {
+	if l == nil {
+		return Decision{Allowed: true}
+	}
+	now := time.Now()
+	capacity := float64(l.rate.Requests)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if now.Sub(l.swept) >= l.rate.Period {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, update: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.update = now
+
+	decision := Decision{Limit: l.rate.Requests}
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) * float64(l.perToken))
+	}
+	decision.Remaining = int(math.Floor(b.tokens))
+	return decision
+}
+
+// refill returns the tokens of b at now: those it had, plus those that came
+// in since, up to the capacity.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + float64(now.Sub(b.update))/float64(l.perToken)
+	return math.Min(tokens, float64(l.rate.Requests))
+}
+
+// sweep drops the buckets that are full at now.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.rate.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,26 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+)
+
+// RegisterAuditRoutes wires the audit trail endpoints to the router.
+//
+// Unlike the player reads, these responses are not cached: every mutation
+// appends to the trail, and ClearCache does not know about these keys.  They
+// run limitReads, the rate limit of the player reads (nil for no limit).
+//
+// The trail of every player, with the actor of every change, requires
+// authenticate and auth.RoleAdmin, like the /admin routes.  The history of a
+// single player is a player read: like the others, it requires authenticate
+// and auth.RoleViewer only when protectReads is set.
+func RegisterAuditRoutes(router *gin.Engine, auditController *controller.AuditController, authenticate gin.HandlerFunc, protectReads bool, limitReads gin.HandlerFunc) {
+	if protectReads {
+		router.GET(HistoryPath, chain(authenticate, limitReads, controller.Authorize(auth.RoleViewer), auditController.GetHistory)...)
+	} else {
+		router.GET(HistoryPath, chain(limitReads, auditController.GetHistory)...)
+	}
+	router.GET(AuditPath, chain(authenticate, limitReads, controller.Authorize(auth.RoleAdmin), auditController.GetEvents)...)
+}
@@ -0,0 +1,16 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+)
+
+// RegisterConfigRoutes wires the configuration endpoint to the router.  The
+// configuration cannot change while the process runs, but the response is
+// small and rarely requested, so it is not cached.  Like every /admin route
+// it requires authenticate (controller.Authenticate in main.go) to succeed
+// and the principal to hold auth.RoleAdmin.
+func RegisterConfigRoutes(router *gin.Engine, configController *controller.ConfigController, authenticate gin.HandlerFunc) {
+	router.GET(ConfigPath, authenticate, controller.Authorize(auth.RoleAdmin), configController.GetConfig)
+}
@@ -0,0 +1,20 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+)
+
+// RegisterEventRoutes wires the player change stream to the router.
+//
+// The stream is never cached, and counts as one request against limitReads
+// however long it stays open.  Like the player reads, it requires
+// authenticate and auth.RoleViewer when protectReads is set.
+func RegisterEventRoutes(router *gin.Engine, eventsController *controller.EventsController, authenticate gin.HandlerFunc, protectReads bool, limitReads gin.HandlerFunc) {
+	if protectReads {
+		router.GET(EventsPath, chain(authenticate, limitReads, controller.Authorize(auth.RoleViewer), eventsController.Stream)...)
+		return
+	}
+	router.GET(EventsPath, chain(limitReads, eventsController.Stream)...)
+}
@@ -0,0 +1,15 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+)
+
+// RegisterHealthRoutes wires the liveness and readiness probes to the
+// router.  They are never cached: a cached "ok" would hide a failing
+// dependency, or the switch to draining.
+func RegisterHealthRoutes(router *gin.Engine, healthController *controller.HealthController) {
+	router.GET(LivePath, healthController.GetLive)
+	router.GET(ReadyPath, healthController.GetReady)
+	router.GET(HealthPath, healthController.GetReady)
+}
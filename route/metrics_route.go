@@ -0,0 +1,13 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterMetricsRoutes wires the Prometheus scrape endpoint to the router.
+// handler is usually metrics.Metrics.Handler; gin.WrapH adapts it to Gin.
+func RegisterMetricsRoutes(router *gin.Engine, handler http.Handler) {
+	router.GET(MetricsPath, gin.WrapH(handler))
+}
@@ -44,14 +44,72 @@ const (
 	// GetByIDPath retrieves a player by its internal UUID (surrogate key).
 	GetByIDPath = PlayersPath + "/:" + IDParam
 
-	// BySquadNumberPath is used for GET, PUT, and DELETE; all squad-number
+	// BySquadNumberPath is used for GET, PUT, PATCH, and DELETE; all squad-number
 	// routes share the "/squadnumber/:" + SquadNumberParam pattern.
 	BySquadNumberPath = PlayersPath + "/squadnumber/:" + SquadNumberParam
 
+	// BulkPath imports many players in one request (POST).
+	BulkPath = PlayersPath + "/bulk"
+
+	// ExportPath streams every player as CSV or NDJSON (GET).  Being a static
+	// segment, it takes priority over GetByIDPath.
+	ExportPath = PlayersPath + "/export"
+
+	// EventsPath streams player changes as Server-Sent Events (GET).  Being a
+	// static segment, it takes priority over GetByIDPath.
+	EventsPath = PlayersPath + "/events"
+
+	// TrashPath lists soft-deleted players (GET).
+	TrashPath = PlayersPath + "/trash"
+
+	// RestorePath restores a soft-deleted player by squad number (POST).
+	RestorePath = BySquadNumberPath + "/restore"
+
+	// AdminPath is the base path of administrative endpoints.
+	AdminPath = "/admin"
+
+	// PurgePath permanently removes every soft-deleted player (DELETE).
+	PurgePath = AdminPath + TrashPath
+
+	// ConfigPath exposes the effective, redacted configuration (GET).
+	ConfigPath = AdminPath + "/config"
+
+	// WebhooksPath lists and creates webhook subscriptions (GET, POST).
+	WebhooksPath = AdminPath + "/webhooks"
+
+	// WebhookPath retrieves or removes a webhook subscription by ID (GET,
+	// DELETE).
+	WebhookPath = WebhooksPath + "/:" + IDParam
+
+	// DeadLettersPath lists the webhook deliveries given up on (GET).  Being
+	// a static segment, it takes priority over WebhookPath.
+	DeadLettersPath = WebhooksPath + "/dead-letters"
+
+	// RedeliverPath queues a webhook delivery again by ID (POST).
+	RedeliverPath = WebhooksPath + "/deliveries/:" + IDParam + "/redeliver"
+
+	// HistoryPath lists the audit events of a player by its internal UUID (GET).
+	HistoryPath = GetByIDPath + "/history"
+
+	// AuditPath lists the audit events of all players, optionally within a
+	// time range (GET).
+	AuditPath = "/audit"
+
 	// SwaggerPath uses the "*any" wildcard so the Swagger UI handler receives
 	// any sub-path under /swagger/ (static assets, index, JSON spec, etc.).
 	SwaggerPath = "/swagger/*any"
 
-	// HealthPath is the liveness probe endpoint for Docker / load balancers.
+	// MetricsPath exposes Prometheus metrics for scraping (GET).
+	MetricsPath = "/metrics"
+
+	// HealthPath predates the split into liveness and readiness; it answers
+	// like ReadyPath so existing probes keep their meaning.
 	HealthPath = "/health"
+
+	// LivePath is the liveness probe: the process is up (GET).
+	LivePath = HealthPath + "/live"
+
+	// ReadyPath is the readiness probe: the process and its database,
+	// migrations and cache can serve requests (GET).
+	ReadyPath = HealthPath + "/ready"
 )
@@ -3,12 +3,16 @@ package route
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/gin-contrib/cache"
 	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/cachestore"
 	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
 )
 
 // RegisterPlayerRoutes wires all player endpoints to the router.
@@ -21,66 +25,300 @@ import (
 //
 // # Caching strategy
 //
-// Read endpoints (GET) are wrapped with cache.CachePage, which:
+// Read endpoints (GET) are wrapped with CachePage, which delegates to
+// cache.CachePage:
 //  1. Computes a cache key from the full request URL.
-//  2. On first hit, calls the real handler and stores the response in the
-//     InMemoryStore with the given TTL (1 hour here).
+//  2. On first hit, calls the real handler and stores the response in
+//     caching.Store for the route's TTL (caching.TTLs, or caching.TTL).
 //  3. On subsequent hits within the TTL, replays the cached response without
 //     calling the handler — no DB round-trip.
 //
-// Write endpoints (POST, PUT, DELETE) are wrapped with ClearCache, which
-// deletes the affected cache keys before delegating to the real handler, so
-// the next GET always fetches fresh data.
-func RegisterPlayerRoutes(router *gin.Engine, controller *controller.PlayerController, store *persistence.InMemoryStore) {
+// Successful responses tell clients to keep them for as long as the server
+// does (Cache-Control: private, max-age=<TTL>).  A nil caching.Store caches
+// nothing, and tells clients to revalidate every time (Cache-Control:
+// no-cache).
+//
+// The cache key includes the query string, so every page, filter and sort
+// combination of GET /players (and any query string sent to a single-player
+// route) is cached separately.  Those keys cannot be derived from the mutated
+// player, so the cached routes record each key they serve in caching.Keys,
+// by path, for ClearCache to evict.
+//
+// # Conditional requests
+//
+// Single-player GETs carry an ETag and are additionally wrapped with
+// controller.NotModified, outside cache.CachePage, so If-None-Match is
+// honoured for cached and fresh responses alike (304 Not Modified).  PUT and
+// PATCH and DELETE evaluate If-Match themselves (412 Precondition Failed).
+//
+// Write endpoints (POST, PUT, PATCH, DELETE, restore) are wrapped with
+// ClearCache which, once the handler has changed players, evicts every
+// cached representation of them and of the collection, so the next GET
+// fetches fresh data.
+//
+// # Authentication
+//
+// Write endpoints run authenticate (controller.Authenticate in main.go)
+// first, so unauthenticated requests are refused before they touch the cache
+// or the database.  Read endpoints are public unless protectReads is set;
+// authenticate then runs outside CachePage, so cached responses are not
+// served without credentials either.
+//
+// # Authorization
+//
+// Each authenticated endpoint then runs controller.Authorize with the role it
+// requires (403 Forbidden otherwise):
+//   - auth.RoleViewer: reads, when protectReads is set.
+//   - auth.RoleEditor: creating, updating and restoring a player.
+//   - auth.RoleAdmin: deleting a player, the bulk import and purging the
+//     trash.
+//
+// # Rate limiting
+//
+// Reads run limitReads and writes limitWrites (controller.RateLimit in
+// main.go, nil for no limit), after authenticate, so authenticated clients
+// are limited by identity rather than by address, and before CachePage, so
+// cached responses count too: 429 Too Many Requests past the limit.
+//
+// # Idempotency
+//
+// POST /players runs idempotent (controller.Idempotency in main.go, nil for
+// none) right before the handler, so a retry sent with the same
+// Idempotency-Key gets the stored response instead of creating the player
+// again.  Being a replay, it evicts nothing from the cache.
+func RegisterPlayerRoutes(router *gin.Engine, playerController *controller.PlayerController, caching Caching, authenticate gin.HandlerFunc, protectReads bool, limitReads, limitWrites, idempotent gin.HandlerFunc) {
+	cached := func(path string, handler gin.HandlerFunc) gin.HandlerFunc {
+		return Track(caching.Keys, CachePage(caching.Store, caching.ttl(path), handler))
+	}
+	evicting := func(handler gin.HandlerFunc) gin.HandlerFunc {
+		return ClearCache(caching.Store, caching.Keys, handler)
+	}
+	read := func(handler gin.HandlerFunc) gin.HandlersChain {
+		if protectReads {
+			return chain(authenticate, limitReads, controller.Authorize(auth.RoleViewer), handler)
+		}
+		return chain(limitReads, handler)
+	}
+	write := func(role auth.Role, handlers ...gin.HandlerFunc) gin.HandlersChain {
+		return chain(append([]gin.HandlerFunc{authenticate, limitWrites, controller.Authorize(role)}, handlers...)...)
+	}
+
 	// Register routes for /players (without trailing slash)
-	router.GET(GetAllPath, cache.CachePage(store, time.Hour, controller.GetAll))
-	router.POST(GetAllPath, ClearCache(store, controller.Post))
+	router.GET(GetAllPath, read(cached(GetAllPath, playerController.GetAll))...)
+	router.POST(GetAllPath, write(auth.RoleEditor, idempotent, evicting(playerController.Post))...)
 
 	// Register alias routes for /players/ (with trailing slash).
 	// Gin does not automatically redirect trailing-slash variants; registering
 	// them explicitly avoids 301 redirects that some clients don't follow.
-	router.GET(GetAllPathTrailingSlash, cache.CachePage(store, time.Hour, controller.GetAll))
-	router.POST(GetAllPathTrailingSlash, ClearCache(store, controller.Post))
+	router.GET(GetAllPathTrailingSlash, read(cached(GetAllPath, playerController.GetAll))...)
+	router.POST(GetAllPathTrailingSlash, write(auth.RoleEditor, idempotent, evicting(playerController.Post))...)
 
 	// GET by squad number (user-facing identifier)
-	router.GET(BySquadNumberPath, cache.CachePage(store, time.Hour, controller.GetBySquadNumber))
+	router.GET(BySquadNumberPath, read(controller.NotModified(cached(BySquadNumberPath, playerController.GetBySquadNumber)))...)
 
 	// GET by internal UUID (surrogate key)
-	router.GET(GetByIDPath, cache.CachePage(store, time.Hour, controller.GetByID))
+	router.GET(GetByIDPath, read(controller.NotModified(cached(GetByIDPath, playerController.GetByID)))...)
+
+	// Bulk import and export.  The export is streamed straight from the
+	// database and is deliberately not cached.
+	router.POST(BulkPath, write(auth.RoleAdmin, evicting(playerController.BulkCreate))...)
+	router.GET(ExportPath, read(playerController.Export)...)
+
+	// PUT, PATCH and DELETE use squad number as the mutable resource identifier
+	router.PUT(BySquadNumberPath, write(auth.RoleEditor, evicting(playerController.Put))...)
+	router.PATCH(BySquadNumberPath, write(auth.RoleEditor, evicting(playerController.Patch))...)
+	router.DELETE(BySquadNumberPath, write(auth.RoleAdmin, evicting(playerController.Delete))...)
+
+	// Trash of soft-deleted players.  Only a restore makes a player visible
+	// again, so it is the only trash operation that clears the cache.
+	router.GET(TrashPath, read(playerController.GetTrash)...)
+	router.POST(RestorePath, write(auth.RoleEditor, evicting(playerController.Restore))...)
+	router.DELETE(PurgePath, write(auth.RoleAdmin, playerController.Purge)...)
+}
+
+// chain returns handlers as a gin.HandlersChain, leaving out the nil ones.
+func chain(handlers ...gin.HandlerFunc) gin.HandlersChain {
+	var result gin.HandlersChain
+	for _, handler := range handlers {
+		if handler != nil {
+			result = append(result, handler)
+		}
+	}
+	return result
+}
+
+// CacheStatusKey is the gin.Context key under which CachePage records
+// whether a response came from the cache: CacheHit or CacheMiss.  Requests
+// to uncached routes do not have it.
+const CacheStatusKey = "route.cacheStatus"
+
+// Values of CacheStatusKey.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// CachePage is cache.CachePage recording the outcome of the lookup under
+// CacheStatusKey, for metrics.  The lookup is a miss exactly when the handler
+// runs; the store alone cannot tell, as cache.CachePage also reads it while
+// writing a fresh response.
+//
+// A cached response is replayed with the headers it was stored with, which
+// would replace those the middleware set for the current request (its
+// X-Request-ID and rate limit headers); those are restored before the
+// replayed response is written.  So is Cache-Control, which follows expire
+// even when the stored response was cached under another TTL.
+//
+// A nil store caches nothing: handler runs every time, and successful
+// responses get Cache-Control: no-cache.
+func CachePage(store persistence.CacheStore, expire time.Duration, handler gin.HandlerFunc) gin.HandlerFunc {
+	if store == nil {
+		return func(context *gin.Context) {
+			writer := &replayWriter{ResponseWriter: context.Writer, context: context, cacheControl: "no-cache"}
+			context.Writer = writer
+			handler(context)
+			context.Writer = writer.ResponseWriter
+		}
+	}
+	cached := cache.CachePage(store, expire, func(context *gin.Context) {
+		context.Set(CacheStatusKey, CacheMiss)
+		handler(context)
+	})
+	cacheControl := fmt.Sprintf("private, max-age=%d", int(expire.Seconds()))
+	return func(context *gin.Context) {
+		context.Set(CacheStatusKey, CacheHit)
+		writer := &replayWriter{ResponseWriter: context.Writer, context: context, current: context.Writer.Header().Clone(), cacheControl: cacheControl}
+		context.Writer = writer
+		cached(context)
+		context.Writer = writer.ResponseWriter
+	}
+}
+
+// replayWriter restores the headers set for the current request over those
+// of a replayed cached response, and sets Cache-Control on successful
+// responses, right before they are written.
+type replayWriter struct {
+	gin.ResponseWriter
+	context      *gin.Context
+	current      http.Header // Headers set before CachePage ran
+	cacheControl string      // Cache-Control of successful responses
+}
+
+// restore puts back the current request's headers on a cache hit, and sets
+// Cache-Control on a response cache.CachePage would store (below 300).
+func (w *replayWriter) restore() {
+	if w.context.GetString(CacheStatusKey) == CacheHit {
+		for name, values := range w.current {
+			w.Header()[name] = values
+		}
+	}
+	if w.Status() < http.StatusMultipleChoices {
+		w.Header().Set("Cache-Control", w.cacheControl)
+	}
+}
+
+// Write restores the current request's headers and writes data.
+func (w *replayWriter) Write(data []byte) (int, error) {
+	w.restore()
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteHeaderNow restores the current request's headers and writes them.
+func (w *replayWriter) WriteHeaderNow() {
+	w.restore()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Caching configures the response cache of RegisterPlayerRoutes.
+type Caching struct {
+	Store persistence.CacheStore   // Cached responses; nil caches nothing
+	Keys  cachestore.KeyIndex      // Keys of the query-string variants; nil with a nil Store
+	TTL   time.Duration            // Expiry of cached responses
+	TTLs  map[string]time.Duration // Expiry by route path (e.g. GetByIDPath), overriding TTL
+}
 
-	// PUT and DELETE use squad number as the mutable resource identifier
-	router.PUT(BySquadNumberPath, ClearCache(store, controller.Put))
-	router.DELETE(BySquadNumberPath, ClearCache(store, controller.Delete))
+// ttl returns the expiry of responses to the route path.
+func (c Caching) ttl(path string) time.Duration {
+	if ttl, ok := c.TTLs[path]; ok {
+		return ttl
+	}
+	return c.TTL
 }
 
-// ClearCache is a middleware factory that invalidates cached responses before
-// a mutating handler (POST, PUT, DELETE) runs.
+// Track wraps a cached handler and records in keys the cache key of every
+// request that carries a query string, under its path.  The key is computed
+// exactly as cache.CachePage computes it, from the full request URI.  A nil
+// keys records nothing.
+func Track(keys cachestore.KeyIndex, handler gin.HandlerFunc) gin.HandlerFunc {
+	if keys == nil {
+		return handler
+	}
+	return func(context *gin.Context) {
+		if context.Request.URL.RawQuery != "" {
+			key := cache.CreateKey(context.Request.URL.RequestURI())
+			if err := keys.Add(context.Request.URL.Path, key); err != nil {
+				_ = context.Error(fmt.Errorf("cache: recording %s: %w", key, err))
+			}
+		}
+		handler(context)
+	}
+}
+
+// playerPaths returns the paths of every cached representation of player:
+// by ID and by squad number.
+func playerPaths(player model.Player) []string {
+	return []string{
+		PlayersPath + "/" + player.ID,
+		fmt.Sprintf("%s/squadnumber/%d", PlayersPath, player.SquadNumber),
+	}
+}
+
+// ClearCache is a middleware factory that invalidates cached responses once
+// a mutating handler (POST, PUT, PATCH, DELETE, restore) has changed players.
 //
 // It returns a gin.HandlerFunc — Gin's standard handler type, which is just
 // func(*gin.Context).  The closure captures `store` and `handler`, so each
 // call to ClearCache produces an independent middleware instance.
 //
-// Cache keys are derived from URL paths using cache.CreateKey (the same
-// function cache.CachePage uses internally), ensuring the keys match exactly.
-// The squad-number-specific key is only added when the route has a
-// :squadnumber parameter (PUT / DELETE), not for collection-level mutations.
-func ClearCache(store persistence.CacheStore, handler gin.HandlerFunc) gin.HandlerFunc {
+// The handler records the players it changed, as they were before and after
+// the change (see controller.ChangedPlayers), so a player whose squad number
+// changed loses the cached pages of both numbers.  Every representation of
+// each of them is evicted, by ID and by squad number, along with the
+// collection, query-string variants included (taken from keys).  Keys are
+// derived with cache.CreateKey, the function cache.CachePage uses
+// internally, so they match exactly.  A nil store evicts nothing.
+//
+// Eviction happens after the handler, once the change is committed: evicting
+// before would let a concurrent GET cache the old data again in between.  A
+// GET that read the player just before the commit can still store it just
+// after the eviction; pageTTL bounds how long that page lingers.  A handler
+// that changed nothing (a failed request) evicts nothing.
+func ClearCache(store persistence.CacheStore, keys cachestore.KeyIndex, handler gin.HandlerFunc) gin.HandlerFunc {
+	if store == nil {
+		return handler
+	}
 	return func(context *gin.Context) {
-		squadNumber := context.Param(SquadNumberParam)
+		handler(context)
 
-		// Always bust the collection-level cache (the GET /players response).
-		keys := []string{
-			cache.CreateKey(PlayersPath),
-			cache.CreateKey(PlayersPathTrailingSlash),
+		changed := controller.ChangedPlayers(context)
+		if len(changed) == 0 {
+			return
+		}
+		paths := []string{PlayersPath, PlayersPathTrailingSlash}
+		for _, player := range changed {
+			paths = append(paths, playerPaths(player)...)
 		}
-		// Also bust the individual resource cache when operating on a specific player.
-		if squadNumber != "" {
-			keys = append(keys, cache.CreateKey(fmt.Sprintf("%s/squadnumber/%s", PlayersPath, squadNumber)))
+		evict := make([]string, 0, len(paths))
+		for _, path := range paths {
+			evict = append(evict, cache.CreateKey(path))
 		}
-		for _, key := range keys {
+		variants, err := keys.Take(paths...)
+		if err != nil {
+			_ = context.Error(fmt.Errorf("cache: taking the keys of %v: %w", paths, err))
+		}
+		for _, key := range append(evict, variants...) {
 			// Ignore delete errors: a cache-miss on delete is harmless.
 			_ = store.Delete(key)
 		}
-		handler(context)
 	}
 }
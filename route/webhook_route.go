@@ -0,0 +1,23 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+)
+
+// RegisterWebhookRoutes wires the webhook administration endpoints to the
+// router.  Subscriptions decide where player data is sent, so like every
+// /admin route they require authenticate and auth.RoleAdmin.  Nothing is
+// cached: deliveries change state without a request.
+func RegisterWebhookRoutes(router *gin.Engine, webhookController *controller.WebhookController, authenticate gin.HandlerFunc) {
+	admin := func(handler gin.HandlerFunc) gin.HandlersChain {
+		return chain(authenticate, controller.Authorize(auth.RoleAdmin), handler)
+	}
+	router.POST(WebhooksPath, admin(webhookController.PostSubscription)...)
+	router.GET(WebhooksPath, admin(webhookController.GetSubscriptions)...)
+	router.GET(WebhookPath, admin(webhookController.GetSubscription)...)
+	router.DELETE(WebhookPath, admin(webhookController.DeleteSubscription)...)
+	router.GET(DeadLettersPath, admin(webhookController.GetDeadLetters)...)
+	router.POST(RedeliverPath, admin(webhookController.PostRedelivery)...)
+}
@@ -0,0 +1,94 @@
+// Package server runs the HTTP server and shuts it down gracefully.
+//
+// # Graceful shutdown
+//
+// Docker stops a container by sending SIGTERM and, after a grace period,
+// SIGKILL.  Run turns the first signal into an orderly shutdown:
+//
+//  1. The draining hooks run, so the health probe starts failing and no new
+//     work is routed to this instance.
+//  2. The server keeps serving for the readiness delay: load balancers only
+//     notice the failing probe at their next check, and until then keep
+//     routing requests here, which would be refused if the listener were
+//     already closed.
+//  3. http.Server.Shutdown closes the listener and idle connections, then
+//     waits for in-flight requests (e.g. a PUT halfway through its
+//     transaction) to complete.
+//  4. If they have not completed within the grace period, the remaining
+//     connections are closed and Run reports it.
+//
+// Closing resources the handlers use, such as the database pool, is left to
+// the caller, after Run has returned and no handler can still be running.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+)
+
+// New returns an http.Server for handler with the address, timeouts and
+// header limit of config.  Unlike gin's Engine.Run, which starts a server
+// without any timeout, it bounds how long a slow or idle client can hold a
+// connection.
+func New(config config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              config.Address,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(config.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(config.ReadTimeout),
+		WriteTimeout:      time.Duration(config.WriteTimeout),
+		IdleTimeout:       time.Duration(config.IdleTimeout),
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+}
+
+// Run serves connections accepted on listener until ctx is done, then shuts
+// server down, allowing in-flight requests up to gracePeriod to complete.
+// Each draining hook is called once, readinessDelay before the shutdown
+// begins.
+//
+// Run returns nil after a clean shutdown, an error wrapping
+// context.DeadlineExceeded if requests were still running when the grace
+// period ended, or the error that stopped the server from serving.
+func Run(ctx context.Context, server *http.Server, listener net.Listener, readinessDelay, gracePeriod time.Duration, draining ...func()) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		// Serve only returns early on a listener failure.
+		return err
+	case <-ctx.Done():
+	}
+
+	for _, drain := range draining {
+		drain()
+	}
+	delay := time.NewTimer(readinessDelay)
+	defer delay.Stop()
+	select {
+	case err := <-served:
+		return err
+	case <-delay.C:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Abort the requests that outlived the grace period.
+		_ = server.Close()
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnonymousActor is recorded as the actor of mutations made through a
+// service that was not given an authenticated principal with WithPrincipal.
+const AnonymousActor = "anonymous"
+
+// maxActorLength bounds the actor recorded in the audit trail (the width of
+// the audit_events.actor column).
+const maxActorLength = 100
+
+// actorOf returns the actor recorded for mutations made by principal: its
+// subject, truncated to maxActorLength, or AnonymousActor when it has none.
+func actorOf(principal auth.Principal) string {
+	name := strings.TrimSpace(principal.Subject)
+	if name == "" {
+		return AnonymousActor
+	}
+	if len(name) > maxActorLength {
+		name = name[:maxActorLength]
+	}
+	return name
+}
+
+// auditedPlayerService is a PlayerService decorator that appends an
+// AuditEvent, and an OutboxMessage, for every mutation it performs.
+//
+// Reads are delegated unchanged to the embedded PlayerService.  Each
+// mutation instead opens a transaction, runs the plain GORM service bound to
+// that transaction, and writes the audit event and the outbox message
+// through the same handle, so the mutation and its records are committed
+// (or rolled back) together: there is never a change without an event, nor
+// an event without a change, and every committed change is eventually
+// published by the outbox relay.  Once committed, the changes are also
+// handed to the local publishers, straight away (see publishLocally).
+type auditedPlayerService struct {
+	PlayerService                    // Plain service on db, used for reads
+	db            *gorm.DB           // Database handle the transactions are opened on
+	principal     auth.Principal     // Recorded as AuditEvent.Actor
+	local         []events.Publisher // Told of every committed change
+}
+
+// NewAuditedPlayerService returns a PlayerService backed by the given
+// *gorm.DB that records an audit trail of every mutation in the
+// audit_events table, and queues its publication in the outbox table.
+// Mutations are attributed to AnonymousActor until WithPrincipal names
+// someone else.  Every committed change is also handed to each of local.
+func NewAuditedPlayerService(db *gorm.DB, local ...events.Publisher) PlayerService {
+	return &auditedPlayerService{PlayerService: NewPlayerService(db), db: db, local: local}
+}
+
+// WithPrincipal returns a copy of the service that attributes its mutations
+// to principal.  The receiver is left untouched, so a per-request copy can be
+// taken from a service shared by all requests.
+func (s *auditedPlayerService) WithPrincipal(principal auth.Principal) PlayerService {
+	audited := *s
+	audited.principal = principal
+	return &audited
+}
+
+// Create inserts the player and records a create event.
+func (s *auditedPlayerService) Create(ctx context.Context, player *model.Player) error {
+	return s.transaction(ctx, func(tx *gorm.DB, changes *[]change) error {
+		if err := NewPlayerService(tx).Create(ctx, player); err != nil {
+			return err
+		}
+		return s.record(tx, changes, model.AuditCreate, player.ID, nil, player)
+	})
+}
+
+// CreateBatch inserts the players like PlayerService.CreateBatch and records
+// a create event for every row that was committed: none when an atomic batch
+// is rejected, otherwise one per row without an error.
+func (s *auditedPlayerService) CreateBatch(ctx context.Context, players []model.Player, atomic bool) ([]error, error) {
+	var rowErrors []error
+	err := s.transaction(ctx, func(tx *gorm.DB, changes *[]change) error {
+		var err error
+		rowErrors, err = NewPlayerService(tx).CreateBatch(ctx, players, atomic)
+		if err != nil {
+			return err
+		}
+		rejected := false
+		for _, rowError := range rowErrors {
+			rejected = rejected || (atomic && rowError != nil)
+		}
+		for i := range players {
+			if rejected || rowErrors[i] != nil {
+				continue
+			}
+			if err := s.record(tx, changes, model.AuditCreate, players[i].ID, nil, &players[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return rowErrors, err
+}
+
+// Update replaces the player like PlayerService.Update and records an update
+// event.  The before snapshot is read inside the transaction, so it is the
+// exact state the update replaced.
+func (s *auditedPlayerService) Update(ctx context.Context, player *model.Player) error {
+	return s.transaction(ctx, func(tx *gorm.DB, changes *[]change) error {
+		players := NewPlayerService(tx)
+		before, err := players.RetrieveByID(ctx, player.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted since the caller read it: the versioned UPDATE would
+			// not match either.
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+		if err := players.Update(ctx, player); err != nil {
+			return err
+		}
+		return s.record(tx, changes, model.AuditUpdate, player.ID, &before, player)
+	})
+}
+
+// Delete soft-deletes the player like PlayerService.Delete and records a
+// delete event.  The version check guarantees player is the state deleted.
+func (s *auditedPlayerService) Delete(ctx context.Context, player *model.Player) error {
+	return s.transaction(ctx, func(tx *gorm.DB, changes *[]change) error {
+		if err := NewPlayerService(tx).Delete(ctx, player); err != nil {
+			return err
+		}
+		return s.record(tx, changes, model.AuditDelete, player.ID, player, nil)
+	})
+}
+
+// Restore restores the player like PlayerService.Restore and records a
+// restore event.
+func (s *auditedPlayerService) Restore(ctx context.Context, squadNumber int) (model.Player, error) {
+	var player model.Player
+	err := s.transaction(ctx, func(tx *gorm.DB, changes *[]change) error {
+		var err error
+		player, err = NewPlayerService(tx).Restore(ctx, squadNumber)
+		if err != nil {
+			return err
+		}
+		return s.record(tx, changes, model.AuditRestore, player.ID, nil, &player)
+	})
+	return player, err
+}
+
+// Purge empties the trash like PlayerService.Purge and records a purge event
+// for every player removed.
+func (s *auditedPlayerService) Purge(ctx context.Context) (int64, error) {
+	var purged int64
+	err := s.transaction(ctx, func(tx *gorm.DB, changes *[]change) error {
+		players := NewPlayerService(tx)
+		deleted, err := players.RetrieveDeleted(ctx)
+		if err != nil {
+			return err
+		}
+		if purged, err = players.Purge(ctx); err != nil {
+			return err
+		}
+		for i := range deleted {
+			if err := s.record(tx, changes, model.AuditPurge, deleted[i].ID, &deleted[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// transaction runs fn in a transaction on s.db, with ctx, and, once it is
+// committed, hands the changes fn recorded to the local publishers.
+func (s *auditedPlayerService) transaction(ctx context.Context, fn func(tx *gorm.DB, changes *[]change) error) error {
+	var changes []change
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error { return fn(tx, &changes) }); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		publishLocally(s.local, change.eventType, change.player)
+	}
+	return nil
+}
+
+// record inserts one audit event, and the outbox message publishing the
+// change, through tx, and adds the change to changes.  A nil snapshot is
+// stored as NULL.
+func (s *auditedPlayerService) record(tx *gorm.DB, changes *[]change, operation string, playerID string, before *model.Player, after *model.Player) error {
+	now := time.Now().UTC()
+	event := model.AuditEvent{
+		PlayerID:   playerID,
+		Operation:  operation,
+		Actor:      actorOf(s.principal),
+		OccurredAt: now,
+	}
+	var err error
+	if event.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if event.After, err = snapshot(after); err != nil {
+		return err
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	message, err := outboxMessage(operation, playerID, before, after, now)
+	if err != nil {
+		return err
+	}
+	*changes = append(*changes, changeOf(operation, before, after))
+	return tx.Create(&message).Error
+}
+
+// snapshot returns the JSON representation of player, or nil for no player.
+func snapshot(player *model.Player) (json.RawMessage, error) {
+	if player == nil {
+		return nil, nil
+	}
+	return json.Marshal(player)
+}
+
+// AuditQuery selects audit events by the time they occurred.  From is
+// inclusive and To exclusive; a zero value leaves that side unbounded.
+type AuditQuery struct {
+	From time.Time
+	To   time.Time
+}
+
+// AuditService defines the contract for reading the audit trail written by
+// NewAuditedPlayerService.  The trail is append-only, so there are no
+// mutating methods.
+type AuditService interface {
+	History(ctx context.Context, playerID string) ([]model.AuditEvent, error)
+	Events(ctx context.Context, query AuditQuery) ([]model.AuditEvent, error)
+}
+
+// Columns and ordering of audit_events queries (see squadNumberColumn).
+var (
+	playerIDColumn   = clause.Column{Name: "playerId"}
+	occurredAtColumn = clause.Column{Name: "occurredAt"}
+	auditOrder       = clause.OrderBy{Columns: []clause.OrderByColumn{{Column: occurredAtColumn}, {Column: clause.Column{Name: "id"}}}}
+)
+
+// auditService implements AuditService using GORM.
+type auditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService returns an AuditService backed by the given *gorm.DB.
+func NewAuditService(db *gorm.DB) AuditService {
+	return &auditService{db: db}
+}
+
+// History fetches every event of the player with the given Player.ID, oldest
+// first.  Players loaded by the seed migrations have no events until they
+// are first changed, so an empty history is returned for any player still
+// in the table (live or in the trash), and gorm.ErrRecordNotFound only when
+// the ID is unknown both to the trail and to the players table.
+func (s *auditService) History(ctx context.Context, playerID string) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+	if err := s.db.WithContext(ctx).Where("? = ?", playerIDColumn, playerID).Order(auditOrder).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		return events, nil
+	}
+	var player model.Player
+	if err := s.db.WithContext(ctx).Unscoped().Where("id = ?", playerID).First(&player).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Events fetches the events that occurred within query's time range, oldest
+// first.
+func (s *auditService) Events(ctx context.Context, query AuditQuery) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+	tx := s.db.WithContext(ctx).Model(&model.AuditEvent{})
+	if !query.From.IsZero() {
+		tx = tx.Where("? >= ?", occurredAtColumn, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("? < ?", occurredAtColumn, query.To.UTC())
+	}
+	err := tx.Order(auditOrder).Find(&events).Error
+	return events, err
+}
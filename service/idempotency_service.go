@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyService defines the contract for storing the responses of
+// requests sent with an Idempotency-Key header (see controller.Idempotency).
+type IdempotencyService interface {
+	// Reserve stores record, a request in progress, unless its owner already
+	// has an unexpired record with the same key: that record is then
+	// returned, with gorm.ErrDuplicatedKey.  Expired records are removed
+	// first, so their keys can be used again.
+	Reserve(ctx context.Context, record model.IdempotencyRecord, now time.Time) (model.IdempotencyRecord, error)
+	// Complete stores the status, header, body and expiry of a reserved
+	// record.
+	Complete(ctx context.Context, record model.IdempotencyRecord) error
+	// Release removes the record of owner's key, so that the request can be
+	// retried.
+	Release(ctx context.Context, owner string, key string) error
+}
+
+// Columns of idempotency_keys queries (see squadNumberColumn).
+var idempotencyKeyColumn = clause.Column{Name: "idempotencyKey"}
+
+// idempotencyService implements IdempotencyService using GORM.
+type idempotencyService struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyService returns an IdempotencyService backed by the given
+// *gorm.DB.
+func NewIdempotencyService(db *gorm.DB) IdempotencyService {
+	return &idempotencyService{db: db}
+}
+
+// Reserve inserts record, relying on the primary key to refuse a key its
+// owner already has; of concurrent requests with the same key, exactly one
+// succeeds.
+func (s *idempotencyService) Reserve(ctx context.Context, record model.IdempotencyRecord, now time.Time) (model.IdempotencyRecord, error) {
+	if err := s.db.WithContext(ctx).Where("? <= ?", expiresAtColumn, now.UTC()).Delete(&model.IdempotencyRecord{}).Error; err != nil {
+		return model.IdempotencyRecord{}, err
+	}
+	err := s.db.WithContext(ctx).Create(&record).Error
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return record, err
+	}
+	var existing model.IdempotencyRecord
+	if err := s.db.WithContext(ctx).Where("owner = ? AND ? = ?", record.Owner, idempotencyKeyColumn, record.Key).Take(&existing).Error; err != nil {
+		return existing, err
+	}
+	return existing, gorm.ErrDuplicatedKey
+}
+
+// Complete updates the response columns of record.
+func (s *idempotencyService) Complete(ctx context.Context, record model.IdempotencyRecord) error {
+	return s.db.WithContext(ctx).Model(&record).Select("status", "header", "body", "expiresAt").Updates(&record).Error
+}
+
+// Release deletes the record of owner's key.
+func (s *idempotencyService) Release(ctx context.Context, owner string, key string) error {
+	return s.db.WithContext(ctx).Where("owner = ? AND ? = ?", owner, idempotencyKeyColumn, key).Delete(&model.IdempotencyRecord{}).Error
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+)
+
+// idempotencyKey identifies an IdempotencyRecord.
+type idempotencyKey struct {
+	owner string
+	key   string
+}
+
+// memoryIdempotencyService implements IdempotencyService in process memory.
+type memoryIdempotencyService struct {
+	mutex   sync.Mutex
+	records map[idempotencyKey]model.IdempotencyRecord
+}
+
+// NewMemoryIdempotencyService returns an empty IdempotencyService that keeps
+// the records in process memory, for the memory backend.  Nothing survives
+// a restart.  It returns the same errors as the GORM implementation.
+func NewMemoryIdempotencyService() IdempotencyService {
+	return &memoryIdempotencyService{records: make(map[idempotencyKey]model.IdempotencyRecord)}
+}
+
+// Reserve stores record unless its key is taken.
+func (s *memoryIdempotencyService) Reserve(ctx context.Context, record model.IdempotencyRecord, now time.Time) (model.IdempotencyRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, existing := range s.records {
+		if !existing.ExpiresAt.After(now) {
+			delete(s.records, key)
+		}
+	}
+	key := idempotencyKey{owner: record.Owner, key: record.Key}
+	if existing, ok := s.records[key]; ok {
+		return existing, gorm.ErrDuplicatedKey
+	}
+	s.records[key] = record
+	return record, nil
+}
+
+// Complete replaces the record.
+func (s *memoryIdempotencyService) Complete(ctx context.Context, record model.IdempotencyRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records[idempotencyKey{owner: record.Owner, key: record.Key}] = record
+	return nil
+}
+
+// Release removes the record.
+func (s *memoryIdempotencyService) Release(ctx context.Context, owner string, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.records, idempotencyKey{owner: owner, key: key})
+	return nil
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+)
+
+// memoryStore holds the players, the audit trail and the outbox of the
+// in-memory backend.  Soft-deleted players stay in players with DeletedAt
+// set, exactly as they stay in the SQL table.  A single mutex serialises
+// writers, which makes every mutation, together with its audit event and
+// outbox message, atomic.
+type memoryStore struct {
+	mutex         sync.RWMutex
+	players       map[string]model.Player // Keyed by Player.ID
+	events        []model.AuditEvent      // In the order they were recorded
+	outbox        []model.OutboxMessage   // Not yet acknowledged, in the order they were written
+	lastMessageID int64                   // ID of the latest outbox message
+	local         []events.Publisher      // Told of every change, once made
+}
+
+// memoryPlayerService implements PlayerService on a memoryStore.
+type memoryPlayerService struct {
+	store     *memoryStore
+	principal auth.Principal // Recorded as AuditEvent.Actor
+}
+
+// memoryAuditService implements AuditService on a memoryStore.
+type memoryAuditService struct {
+	store *memoryStore
+}
+
+// memoryOutboxService implements OutboxService on a memoryStore.
+type memoryOutboxService struct {
+	store *memoryStore
+}
+
+// NewMemoryPlayerService returns a PlayerService that keeps players in
+// process memory, initially holding players, and the AuditService reading
+// the trail it records.  Nothing survives a restart.
+//
+// It honours the same contract as the GORM implementation, errors included:
+// gorm.ErrRecordNotFound for misses, gorm.ErrDuplicatedKey for squad number
+// or ID conflicts, ErrVersionConflict and ErrInvalidQuery, so the controller
+// cannot tell the two apart.  Both services are returned together because
+// they share the store.
+func NewMemoryPlayerService(players []model.Player) (PlayerService, AuditService) {
+	playerService, auditService, _ := NewMemoryServices(players)
+	return playerService, auditService
+}
+
+// NewMemoryServices returns the services of NewMemoryPlayerService, and the
+// OutboxService the relay drains the changes of the PlayerService from.  Like
+// NewAuditedPlayerService, the PlayerService also hands every change to each
+// of local.
+func NewMemoryServices(players []model.Player, local ...events.Publisher) (PlayerService, AuditService, OutboxService) {
+	store := &memoryStore{players: make(map[string]model.Player, len(players)), local: local}
+	for _, player := range players {
+		if player.Version == 0 {
+			player.Version = 1
+		}
+		store.players[player.ID] = player
+	}
+	return &memoryPlayerService{store: store}, &memoryAuditService{store: store}, &memoryOutboxService{store: store}
+}
+
+// WithPrincipal returns a copy of the service that attributes its mutations
+// to principal.
+func (s *memoryPlayerService) WithPrincipal(principal auth.Principal) PlayerService {
+	return &memoryPlayerService{store: s.store, principal: principal}
+}
+
+// Create stores a copy of player.  Like the version column, Version starts at
+// 1.
+func (s *memoryPlayerService) Create(ctx context.Context, player *model.Player) error {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	if err := s.store.insert(player); err != nil {
+		return err
+	}
+	return s.record(model.AuditCreate, player.ID, nil, player)
+}
+
+// CreateBatch stores the players and returns one entry per player, like the
+// GORM implementation: nil for an inserted player, or the error that rejected
+// it.  When atomic is true and any player fails, nothing is stored.
+func (s *memoryPlayerService) CreateBatch(ctx context.Context, players []model.Player, atomic bool) ([]error, error) {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	rowErrors := make([]error, len(players))
+	inserted := make([]int, 0, len(players))
+	for i := range players {
+		if rowErrors[i] = s.store.insert(&players[i]); rowErrors[i] == nil {
+			inserted = append(inserted, i)
+		}
+	}
+	if atomic && len(inserted) < len(players) {
+		for _, i := range inserted {
+			delete(s.store.players, players[i].ID)
+		}
+		return rowErrors, nil
+	}
+	for _, i := range inserted {
+		if err := s.record(model.AuditCreate, players[i].ID, nil, &players[i]); err != nil {
+			return rowErrors, err
+		}
+	}
+	return rowErrors, nil
+}
+
+// RetrieveAll returns every live player, ordered by squad number.
+func (s *memoryPlayerService) RetrieveAll(ctx context.Context) ([]model.Player, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	return s.store.live(nil), nil
+}
+
+// StreamAll calls visit for every live player, ordered by squad number.  It
+// iterates over a snapshot, so visit may take its time without blocking
+// writers.
+func (s *memoryPlayerService) StreamAll(ctx context.Context, visit func(player model.Player) error) error {
+	s.store.mutex.RLock()
+	players := s.store.live(nil)
+	s.store.mutex.RUnlock()
+	for _, player := range players {
+		if err := visit(player); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetrievePage returns the slice of live players selected by query, with the
+// same filtering, ordering, offset and keyset semantics as the GORM
+// implementation.
+func (s *memoryPlayerService) RetrievePage(ctx context.Context, query PlayerQuery) (PlayerPage, error) {
+	var page PlayerPage
+	orders, err := query.orderColumns()
+	if err != nil {
+		return page, err
+	}
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	players := s.store.live(query.matches)
+	compare := func(a, b model.Player) int {
+		for _, order := range orders {
+			if c := compareColumn(a, b, order.column); c != 0 {
+				if order.desc {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	}
+	slices.SortFunc(players, compare)
+	page.Total = int64(len(players))
+	if query.After != "" {
+		anchor, ok := s.store.players[query.After]
+		if !ok || anchor.DeletedAt.Valid {
+			return page, fmt.Errorf("%w: unknown cursor %q", ErrInvalidQuery, query.After)
+		}
+		start, _ := slices.BinarySearchFunc(players, anchor, compare)
+		for start < len(players) && compare(players[start], anchor) <= 0 {
+			start++
+		}
+		players = players[start:]
+	}
+	if query.Paginated() {
+		if query.After == "" && query.Page > 1 {
+			players = players[min((query.Page-1)*query.PageSize, len(players)):]
+		}
+		players = players[:min(query.PageSize, len(players))]
+	}
+	page.Players = players
+	return page, nil
+}
+
+// matches reports whether player passes the equality filters of q.
+func (q PlayerQuery) matches(player model.Player) bool {
+	return (q.Team == "" || player.Team == q.Team) &&
+		(q.League == "" || player.League == q.League) &&
+		(q.AbbrPosition == "" || player.AbbrPosition == q.AbbrPosition) &&
+		(q.Starting11 == nil || player.Starting11 == *q.Starting11)
+}
+
+// compareColumn compares two players on one column of sortColumns, or id.
+// Strings compare byte-wise, like SQLite's default BINARY collation.
+func compareColumn(a, b model.Player, column string) int {
+	switch column {
+	case "firstName":
+		return cmp.Compare(a.FirstName, b.FirstName)
+	case "lastName":
+		return cmp.Compare(a.LastName, b.LastName)
+	case "dateOfBirth":
+		return cmp.Compare(a.DateOfBirth, b.DateOfBirth)
+	case "squadNumber":
+		return cmp.Compare(a.SquadNumber, b.SquadNumber)
+	case "position":
+		return cmp.Compare(a.Position, b.Position)
+	case "abbrPosition":
+		return cmp.Compare(a.AbbrPosition, b.AbbrPosition)
+	case "team":
+		return cmp.Compare(a.Team, b.Team)
+	case "league":
+		return cmp.Compare(a.League, b.League)
+	default:
+		return cmp.Compare(a.ID, b.ID)
+	}
+}
+
+// RetrieveByID returns the live player with the given ID, or
+// gorm.ErrRecordNotFound.
+func (s *memoryPlayerService) RetrieveByID(ctx context.Context, id string) (model.Player, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	player, ok := s.store.players[id]
+	if !ok || player.DeletedAt.Valid {
+		return model.Player{}, gorm.ErrRecordNotFound
+	}
+	return player, nil
+}
+
+// RetrieveBySquadNumber returns the live player with the given squad number,
+// or gorm.ErrRecordNotFound.
+func (s *memoryPlayerService) RetrieveBySquadNumber(ctx context.Context, squadNumber int) (model.Player, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	player, ok := s.store.bySquadNumber(squadNumber)
+	if !ok {
+		return model.Player{}, gorm.ErrRecordNotFound
+	}
+	return player, nil
+}
+
+// Update replaces the live player with player.ID, provided it still carries
+// player.Version; otherwise ErrVersionConflict is returned.  On success
+// player.Version holds the new version.
+func (s *memoryPlayerService) Update(ctx context.Context, player *model.Player) error {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	before, ok := s.store.players[player.ID]
+	if !ok || before.DeletedAt.Valid || before.Version != player.Version {
+		return ErrVersionConflict
+	}
+	if other, ok := s.store.bySquadNumber(player.SquadNumber); ok && other.ID != player.ID {
+		return gorm.ErrDuplicatedKey
+	}
+	player.Version++
+	player.DeletedAt = gorm.DeletedAt{}
+	s.store.players[player.ID] = *player
+	return s.record(model.AuditUpdate, player.ID, &before, player)
+}
+
+// Delete soft-deletes the live player with player.ID, provided it still
+// carries player.Version; otherwise ErrVersionConflict is returned.
+func (s *memoryPlayerService) Delete(ctx context.Context, player *model.Player) error {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	current, ok := s.store.players[player.ID]
+	if !ok || current.DeletedAt.Valid || current.Version != player.Version {
+		return ErrVersionConflict
+	}
+	current.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.store.players[player.ID] = current
+	return s.record(model.AuditDelete, player.ID, player, nil)
+}
+
+// RetrieveDeleted returns every soft-deleted player, most recently deleted
+// first.
+func (s *memoryPlayerService) RetrieveDeleted(ctx context.Context) ([]model.Player, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	return s.store.deleted(), nil
+}
+
+// Restore brings back the most recently deleted player with the given squad
+// number.  It returns gorm.ErrRecordNotFound when the trash holds no such
+// player and gorm.ErrDuplicatedKey when the squad number has been reassigned.
+func (s *memoryPlayerService) Restore(ctx context.Context, squadNumber int) (model.Player, error) {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	deleted := s.store.deleted()
+	index := slices.IndexFunc(deleted, func(player model.Player) bool {
+		return player.SquadNumber == squadNumber
+	})
+	if index < 0 {
+		return model.Player{}, gorm.ErrRecordNotFound
+	}
+	player := deleted[index]
+	if _, taken := s.store.bySquadNumber(squadNumber); taken {
+		return model.Player{}, gorm.ErrDuplicatedKey
+	}
+	player.DeletedAt = gorm.DeletedAt{}
+	s.store.players[player.ID] = player
+	return player, s.record(model.AuditRestore, player.ID, nil, &player)
+}
+
+// Purge permanently removes every soft-deleted player and returns how many
+// were removed.
+func (s *memoryPlayerService) Purge(ctx context.Context) (int64, error) {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	deleted := s.store.deleted()
+	for i := range deleted {
+		delete(s.store.players, deleted[i].ID)
+		if err := s.record(model.AuditPurge, deleted[i].ID, &deleted[i], nil); err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(len(deleted)), nil
+}
+
+// record appends an audit event and an outbox message.  The caller holds the
+// write lock.
+func (s *memoryPlayerService) record(operation string, playerID string, before *model.Player, after *model.Player) error {
+	now := time.Now().UTC()
+	event := model.AuditEvent{
+		ID:         int64(len(s.store.events) + 1),
+		PlayerID:   playerID,
+		Operation:  operation,
+		Actor:      actorOf(s.principal),
+		OccurredAt: now,
+	}
+	var err error
+	if event.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if event.After, err = snapshot(after); err != nil {
+		return err
+	}
+	message, err := outboxMessage(operation, playerID, before, after, now)
+	if err != nil {
+		return err
+	}
+	s.store.lastMessageID++
+	message.ID = s.store.lastMessageID
+	s.store.events = append(s.store.events, event)
+	s.store.outbox = append(s.store.outbox, message)
+	// The change is already made: there is no transaction to commit.
+	change := changeOf(operation, before, after)
+	publishLocally(s.store.local, change.eventType, change.player)
+	return nil
+}
+
+// insert stores a copy of player unless its ID is taken (by any player,
+// deleted or not) or its squad number is taken by a live player, in which
+// case gorm.ErrDuplicatedKey is returned.  The caller holds the write lock.
+func (m *memoryStore) insert(player *model.Player) error {
+	if _, taken := m.players[player.ID]; taken {
+		return gorm.ErrDuplicatedKey
+	}
+	if _, taken := m.bySquadNumber(player.SquadNumber); taken {
+		return gorm.ErrDuplicatedKey
+	}
+	if player.Version == 0 {
+		player.Version = 1
+	}
+	m.players[player.ID] = *player
+	return nil
+}
+
+// bySquadNumber returns the live player with the given squad number.
+func (m *memoryStore) bySquadNumber(squadNumber int) (model.Player, bool) {
+	for _, player := range m.players {
+		if player.SquadNumber == squadNumber && !player.DeletedAt.Valid {
+			return player, true
+		}
+	}
+	return model.Player{}, false
+}
+
+// live returns the live players accepted by keep (all when nil), ordered by
+// squad number.
+func (m *memoryStore) live(keep func(player model.Player) bool) []model.Player {
+	players := []model.Player{}
+	for _, player := range m.players {
+		if !player.DeletedAt.Valid && (keep == nil || keep(player)) {
+			players = append(players, player)
+		}
+	}
+	slices.SortFunc(players, func(a, b model.Player) int { return cmp.Compare(a.SquadNumber, b.SquadNumber) })
+	return players
+}
+
+// deleted returns the soft-deleted players, most recently deleted first.
+func (m *memoryStore) deleted() []model.Player {
+	players := []model.Player{}
+	for _, player := range m.players {
+		if player.DeletedAt.Valid {
+			players = append(players, player)
+		}
+	}
+	slices.SortFunc(players, func(a, b model.Player) int { return b.DeletedAt.Time.Compare(a.DeletedAt.Time) })
+	return players
+}
+
+// History returns every event of the player with the given ID, oldest first,
+// or gorm.ErrRecordNotFound when the ID is unknown both to the trail and to
+// the store (see auditService.History).
+func (s *memoryAuditService) History(ctx context.Context, playerID string) ([]model.AuditEvent, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	events := []model.AuditEvent{}
+	for _, event := range s.store.events {
+		if event.PlayerID == playerID {
+			events = append(events, event)
+		}
+	}
+	if _, ok := s.store.players[playerID]; !ok && len(events) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return events, nil
+}
+
+// Events returns the events that occurred within query's time range, oldest
+// first.
+func (s *memoryAuditService) Events(ctx context.Context, query AuditQuery) ([]model.AuditEvent, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	events := []model.AuditEvent{}
+	for _, event := range s.store.events {
+		if (query.From.IsZero() || !event.OccurredAt.Before(query.From)) &&
+			(query.To.IsZero() || event.OccurredAt.Before(query.To)) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Pending returns the oldest messages.
+func (s *memoryOutboxService) Pending(ctx context.Context, limit int) ([]model.OutboxMessage, error) {
+	s.store.mutex.RLock()
+	defer s.store.mutex.RUnlock()
+	return append([]model.OutboxMessage{}, s.store.outbox[:min(limit, len(s.store.outbox))]...), nil
+}
+
+// Acknowledge removes the messages.
+func (s *memoryOutboxService) Acknowledge(ctx context.Context, ids ...int64) error {
+	s.store.mutex.Lock()
+	defer s.store.mutex.Unlock()
+	s.store.outbox = slices.DeleteFunc(s.store.outbox, func(message model.OutboxMessage) bool {
+		return slices.Contains(ids, message.ID)
+	})
+	return nil
+}
+
+// Lease always grants the lease: only this process can reach the store.
+func (s *memoryOutboxService) Lease(ctx context.Context, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+)
+
+// memoryWebhookService implements WebhookService in process memory.  A
+// single mutex serialises every operation, which makes Claim atomic.
+type memoryWebhookService struct {
+	mutex         sync.Mutex
+	subscriptions []model.WebhookSubscription // In the order they were created
+	deliveries    []model.WebhookDelivery     // In the order they were queued
+	lastID        int64                       // ID of the latest delivery
+}
+
+// NewMemoryWebhookService returns an empty WebhookService that keeps
+// subscriptions and deliveries in process memory, for the memory backend.
+// Nothing survives a restart, and only the process's own dispatcher can
+// work the queue.  It returns the same errors as the GORM implementation.
+func NewMemoryWebhookService() WebhookService {
+	return &memoryWebhookService{}
+}
+
+// Subscribe stores a copy of subscription with a new UUID and secret.
+func (s *memoryWebhookService) Subscribe(ctx context.Context, subscription *model.WebhookSubscription) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := prepareSubscription(subscription); err != nil {
+		return err
+	}
+	s.subscriptions = append(s.subscriptions, *subscription)
+	return nil
+}
+
+// Subscriptions returns every subscription, oldest first.
+func (s *memoryWebhookService) Subscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]model.WebhookSubscription{}, s.subscriptions...), nil
+}
+
+// Subscription returns the subscription with the given ID.
+func (s *memoryWebhookService) Subscription(ctx context.Context, id string) (model.WebhookSubscription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, subscription := range s.subscriptions {
+		if subscription.ID == id {
+			return subscription, nil
+		}
+	}
+	return model.WebhookSubscription{}, gorm.ErrRecordNotFound
+}
+
+// Unsubscribe removes the subscription and its deliveries.
+func (s *memoryWebhookService) Unsubscribe(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	index := slices.IndexFunc(s.subscriptions, func(subscription model.WebhookSubscription) bool {
+		return subscription.ID == id
+	})
+	if index < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	s.subscriptions = slices.Delete(s.subscriptions, index, index+1)
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(delivery model.WebhookDelivery) bool {
+		return delivery.SubscriptionID == id
+	})
+	return nil
+}
+
+// Enqueue appends one pending delivery per subscription wanting eventType.
+func (s *memoryWebhookService) Enqueue(ctx context.Context, eventType string, payload json.RawMessage) ([]model.WebhookDelivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deliveries := newDeliveries(s.subscriptions, eventType, payload, time.Now().UTC())
+	for i := range deliveries {
+		s.lastID++
+		deliveries[i].ID = s.lastID
+		s.deliveries = append(s.deliveries, deliveries[i])
+	}
+	return deliveries, nil
+}
+
+// Claim returns up to limit due deliveries, oldest first, and postpones them
+// by lease.
+func (s *memoryWebhookService) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var due []*model.WebhookDelivery
+	for i := range s.deliveries {
+		delivery := &s.deliveries[i]
+		if delivery.State == model.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *model.WebhookDelivery) int { return a.NextAttemptAt.Compare(b.NextAttemptAt) })
+	claimed := []model.WebhookDelivery{}
+	for _, delivery := range due[:min(limit, len(due))] {
+		delivery.NextAttemptAt = now.UTC().Add(lease)
+		claimed = append(claimed, *delivery)
+	}
+	return claimed, nil
+}
+
+// Record stores the outcome of an attempt, unless the delivery was removed
+// meanwhile.
+func (s *memoryWebhookService) Record(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stored := s.find(delivery.ID); stored != nil {
+		stored.State = delivery.State
+		stored.Attempts = delivery.Attempts
+		stored.NextAttemptAt = delivery.NextAttemptAt
+		stored.LastStatus = delivery.LastStatus
+		stored.LastError = delivery.LastError
+		stored.DeliveredAt = delivery.DeliveredAt
+	}
+	return nil
+}
+
+// DeadLetters returns the dead deliveries, most recent first.
+func (s *memoryWebhookService) DeadLetters(ctx context.Context) ([]model.WebhookDelivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deliveries := []model.WebhookDelivery{}
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		if s.deliveries[i].State == model.DeliveryDead {
+			deliveries = append(deliveries, s.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+// Redeliver resets the delivery with the given ID.
+func (s *memoryWebhookService) Redeliver(ctx context.Context, id int64, now time.Time) (model.WebhookDelivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delivery := s.find(id)
+	if delivery == nil {
+		return model.WebhookDelivery{}, gorm.ErrRecordNotFound
+	}
+	redeliver(delivery, now)
+	return *delivery, nil
+}
+
+// find returns the stored delivery with the given ID, or nil.  The caller
+// holds the mutex.
+func (s *memoryWebhookService) find(id int64) *model.WebhookDelivery {
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == id {
+			return &s.deliveries[i]
+		}
+	}
+	return nil
+}
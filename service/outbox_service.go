@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxEventTypes maps the operations of the audit trail to the event type
+// of their outbox message.  A restored player is back among the live ones,
+// so it is published as created.
+var outboxEventTypes = map[string]string{
+	model.AuditCreate:  events.PlayerCreated,
+	model.AuditUpdate:  events.PlayerUpdated,
+	model.AuditDelete:  events.PlayerDeleted,
+	model.AuditRestore: events.PlayerCreated,
+	model.AuditPurge:   events.PlayerPurged,
+}
+
+// outboxMessage returns the outbox message of an operation on the player
+// with playerID: its payload is after, or before when there is no after.
+func outboxMessage(operation string, playerID string, before *model.Player, after *model.Player, now time.Time) (model.OutboxMessage, error) {
+	player := after
+	if player == nil {
+		player = before
+	}
+	payload, err := snapshot(player)
+	return model.OutboxMessage{
+		PlayerID:  playerID,
+		EventType: outboxEventTypes[operation],
+		Payload:   payload,
+		CreatedAt: now,
+	}, err
+}
+
+// change is a committed change, as handed to the local publishers.
+type change struct {
+	eventType string
+	player    model.Player
+}
+
+// changeOf returns the change made by an operation: its player is after, or
+// before when there is no after, like the payload of its outbox message.
+func changeOf(operation string, before *model.Player, after *model.Player) change {
+	player := after
+	if player == nil {
+		player = before
+	}
+	return change{eventType: outboxEventTypes[operation], player: *player}
+}
+
+// publishLocally hands a committed change to each of publishers, in the
+// process that made it.
+//
+// The outbox relay runs on a single replica at a time (the one holding its
+// lease), so what must reach the clients of every replica, such as the
+// events.Broker behind GET /players/events, cannot wait for it: the player
+// services publish each change locally as soon as it is committed, and the
+// relay only publishes to the shared sinks.
+func publishLocally(publishers []events.Publisher, eventType string, player model.Player) {
+	for _, publisher := range publishers {
+		publisher.Publish(eventType, player)
+	}
+}
+
+// OutboxService defines the contract for reading the outbox written by
+// NewAuditedPlayerService (and the memory backend), for the outbox relay.
+type OutboxService interface {
+	// Pending returns up to limit messages, in the order they were written.
+	Pending(ctx context.Context, limit int) ([]model.OutboxMessage, error)
+	// Acknowledge deletes the messages with the given IDs, once published.
+	Acknowledge(ctx context.Context, ids ...int64) error
+	// Lease takes or renews the relay lease for holder until now+ttl, and
+	// reports whether holder has it: false while another holder's lease
+	// runs.
+	Lease(ctx context.Context, holder string, now time.Time, ttl time.Duration) (bool, error)
+}
+
+// relayLease is the name of the relay's row in outbox_leases.
+const relayLease = "relay"
+
+// Columns of outbox_leases (and idempotency_keys) queries (see
+// squadNumberColumn).
+var expiresAtColumn = clause.Column{Name: "expiresAt"}
+
+// outboxService implements OutboxService using GORM.
+type outboxService struct {
+	db *gorm.DB
+}
+
+// NewOutboxService returns an OutboxService backed by the given *gorm.DB.
+func NewOutboxService(db *gorm.DB) OutboxService {
+	return &outboxService{db: db}
+}
+
+// Pending fetches the oldest messages.
+func (s *outboxService) Pending(ctx context.Context, limit int) ([]model.OutboxMessage, error) {
+	messages := []model.OutboxMessage{}
+	err := s.db.WithContext(ctx).Order("id").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// Acknowledge deletes the messages.
+func (s *outboxService) Acknowledge(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.OutboxMessage{}).Error
+}
+
+// Lease updates the lease row only when holder already has it or it has
+// expired, so of several replicas racing for it exactly one wins.
+func (s *outboxService) Lease(ctx context.Context, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	now = now.UTC()
+	result := s.db.WithContext(ctx).Table("outbox_leases").
+		Where("name = ? AND (holder = ? OR ? < ?)", relayLease, holder, expiresAtColumn, now).
+		Updates(map[string]any{"holder": holder, "expiresAt": now.Add(ttl)})
+	return result.RowsAffected == 1, result.Error
+}
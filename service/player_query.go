@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+)
+
+// ErrInvalidQuery is returned by RetrievePage when the PlayerQuery refers to
+// an unknown sort field or to a cursor that does not match any player.
+// The controller translates it into a 400 response via errors.Is.
+var ErrInvalidQuery = errors.New("invalid player query")
+
+// MaxPageSize caps PlayerQuery.PageSize so a single request cannot pull an
+// arbitrarily large slice of the table.
+const MaxPageSize = 100
+
+// DefaultPageSize is used when a client paginates (page or after) without
+// choosing a page size.
+const DefaultPageSize = 20
+
+// sortColumns maps the JSON field names clients may sort by to the column
+// names used in the players table.  Only whitelisted fields are accepted so
+// client input never reaches an ORDER BY clause verbatim.
+var sortColumns = map[string]string{
+	"firstName":    "firstName",
+	"lastName":     "lastName",
+	"dateOfBirth":  "dateOfBirth",
+	"squadNumber":  "squadNumber",
+	"position":     "position",
+	"abbrPosition": "abbrPosition",
+	"team":         "team",
+	"league":       "league",
+}
+
+// SortField is one component of a multi-field sort, e.g. "-squadNumber" is
+// SortField{Field: "squadNumber", Descending: true}.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// PlayerQuery describes which slice of the players collection to return.
+//
+// The zero value selects every player ordered by squad number, which is
+// what GET /players returns when no query parameters are given.
+//
+// Two pagination styles are supported and are mutually exclusive:
+//
+//   - Offset-based: Page (1-based) and PageSize.
+//   - Cursor-based: After (the ID of the last player on the previous page)
+//     and PageSize.  Cursor pages are stable under concurrent inserts because
+//     they continue from a row instead of skipping a fixed number of rows.
+//
+// Filter fields are exact matches; an empty string (or nil for Starting11)
+// means "do not filter on this field".
+type PlayerQuery struct {
+	Page     int
+	PageSize int
+	After    string
+	Sort     []SortField
+
+	Team         string
+	League       string
+	AbbrPosition string
+	Starting11   *bool
+}
+
+// PlayerPage is the result of RetrievePage: the requested slice of players
+// plus the number of players matching the filters across all pages.
+type PlayerPage struct {
+	Players []model.Player
+	Total   int64
+}
+
+// Paginated reports whether the query asks for a single page rather than the
+// whole (filtered) collection.
+func (q PlayerQuery) Paginated() bool {
+	return q.PageSize > 0
+}
+
+// orderColumn is a resolved SortField: the table column plus its direction.
+type orderColumn struct {
+	column string
+	desc   bool
+}
+
+// orderColumns resolves Sort against the whitelist and appends the primary
+// key as a final tiebreaker so the ordering is total — a requirement for
+// both stable offset pages and keyset (cursor) pagination.
+func (q PlayerQuery) orderColumns() ([]orderColumn, error) {
+	sort := q.Sort
+	if len(sort) == 0 {
+		sort = []SortField{{Field: "squadNumber"}}
+	}
+	columns := make([]orderColumn, 0, len(sort)+1)
+	for _, field := range sort {
+		column, ok := sortColumns[field.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, field.Field)
+		}
+		columns = append(columns, orderColumn{column: column, desc: field.Descending})
+	}
+	return append(columns, orderColumn{column: "id"}), nil
+}
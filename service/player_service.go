@@ -3,8 +3,29 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
 	"github.com/nanotaboada/go-samples-gin-restful/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVersionConflict is returned by Update and Delete when the row no longer
+// carries the Version the caller read, i.e. another request modified or
+// removed the player in the meantime.  The controller translates it into a
+// 412 response via errors.Is.
+var ErrVersionConflict = errors.New("player version conflict")
+
+// Columns referenced in hand-written conditions.  GORM quotes the column
+// names it generates itself, but not those inside a condition string, and
+// PostgreSQL folds unquoted identifiers to lower case; passing a clause.Column
+// as the argument of a "?" placeholder has GORM quote it for every dialect.
+var (
+	squadNumberColumn = clause.Column{Name: "squadNumber"}
+	deletedAtColumn   = clause.Column{Name: "deletedAt"}
 )
 
 // PlayerService defines the contract for player business logic.
@@ -13,13 +34,25 @@ import (
 // these methods automatically satisfies PlayerService — no "implements"
 // keyword is needed. This makes it easy to swap the real implementation for a
 // mock in tests without modifying any production code.
+//
+// Every method takes the context of the caller's request first, and runs its
+// statements with it: they are cancelled with it, at its deadline or when the
+// client goes away, and traced as its children.
+// https://gorm.io/docs/context.html
 type PlayerService interface {
-	Create(player *model.Player) error
-	RetrieveAll() ([]model.Player, error)
-	RetrieveByID(id string) (model.Player, error)
-	RetrieveBySquadNumber(squadNumber int) (model.Player, error)
-	Update(player *model.Player) error
-	Delete(player *model.Player) error
+	Create(ctx context.Context, player *model.Player) error
+	CreateBatch(ctx context.Context, players []model.Player, atomic bool) ([]error, error)
+	RetrieveAll(ctx context.Context) ([]model.Player, error)
+	StreamAll(ctx context.Context, visit func(player model.Player) error) error
+	RetrievePage(ctx context.Context, query PlayerQuery) (PlayerPage, error)
+	RetrieveByID(ctx context.Context, id string) (model.Player, error)
+	RetrieveBySquadNumber(ctx context.Context, squadNumber int) (model.Player, error)
+	Update(ctx context.Context, player *model.Player) error
+	Delete(ctx context.Context, player *model.Player) error
+	RetrieveDeleted(ctx context.Context) ([]model.Player, error)
+	Restore(ctx context.Context, squadNumber int) (model.Player, error)
+	Purge(ctx context.Context) (int64, error)
+	WithPrincipal(principal auth.Principal) PlayerService
 }
 
 // playerService implements PlayerService using GORM.
@@ -39,28 +72,185 @@ func NewPlayerService(db *gorm.DB) PlayerService {
 // Create inserts a new Player row into the database.
 // GORM uses the struct's field values and tags to build the INSERT statement.
 // https://gorm.io/docs/create.html
-func (s *playerService) Create(player *model.Player) error {
-	return s.db.Create(player).Error
+func (s *playerService) Create(ctx context.Context, player *model.Player) error {
+	return s.db.WithContext(ctx).Create(player).Error
+}
+
+// CreateBatch inserts players in a single transaction and returns one entry
+// per player: nil when the row was inserted, or the error that rejected it
+// (typically a unique constraint violation on squadNumber, including
+// duplicates within the batch itself).
+//
+// Each INSERT runs inside its own savepoint, so a failed row is rolled back
+// on its own and the remaining rows are still attempted; the caller gets the
+// outcome of every row, not only the first failure.  When atomic is true and
+// any row fails, the whole transaction is rolled back and nothing is
+// inserted (all-or-nothing); otherwise the rows that succeeded are committed.
+//
+// The second return value is reserved for failures of the transaction
+// itself (BEGIN, SAVEPOINT, COMMIT), in which case nothing was inserted.
+// https://gorm.io/docs/transactions.html
+func (s *playerService) CreateBatch(ctx context.Context, players []model.Player, atomic bool) ([]error, error) {
+	rowErrors := make([]error, len(players))
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		failed := false
+		for i := range players {
+			if err := tx.SavePoint("row").Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&players[i]).Error; err != nil {
+				rowErrors[i] = err
+				failed = true
+				if err := tx.RollbackTo("row").Error; err != nil {
+					return err
+				}
+			}
+		}
+		if atomic && failed {
+			return errBatchRejected
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchRejected) {
+		return rowErrors, err
+	}
+	return rowErrors, nil
 }
 
+// errBatchRejected rolls back an atomic CreateBatch transaction in which at
+// least one row failed.  It never leaves CreateBatch: the per-row errors
+// already tell the caller what happened.
+var errBatchRejected = errors.New("batch rejected")
+
 // RetrieveAll fetches every row from the players table.
 // Find populates the slice and never returns gorm.ErrRecordNotFound (it
 // returns an empty slice instead), so callers don't need to check for that
 // specific error here.
 // https://gorm.io/docs/query.html
-func (s *playerService) RetrieveAll() ([]model.Player, error) {
+func (s *playerService) RetrieveAll(ctx context.Context) ([]model.Player, error) {
 	var players []model.Player
-	result := s.db.Find(&players)
+	result := s.db.WithContext(ctx).Find(&players)
 	return players, result.Error
 }
 
+// StreamAll calls visit for every player, ordered by squad number, reading
+// rows one at a time from the database cursor instead of loading the whole
+// table into memory.  Iteration stops at the first error, from the database
+// or from visit, which is returned.
+// https://gorm.io/docs/advanced_query.html#Iteration
+func (s *playerService) StreamAll(ctx context.Context, visit func(player model.Player) error) error {
+	rows, err := s.db.WithContext(ctx).Model(&model.Player{}).Order(clause.OrderByColumn{Column: squadNumberColumn}).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var player model.Player
+		if err := s.db.ScanRows(rows, &player); err != nil {
+			return err
+		}
+		if err := visit(player); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RetrievePage fetches the slice of players selected by query, together with
+// the total number of players matching its filters.
+//
+// Filtering and ordering are applied in SQL.  Offset pages use LIMIT/OFFSET;
+// cursor pages use keyset pagination: the row identified by query.After is
+// loaded first and its sort-column values become the lower bound of the
+// next page, so rows inserted before the cursor never shift later pages.
+// https://gorm.io/docs/query.html
+func (s *playerService) RetrievePage(ctx context.Context, query PlayerQuery) (PlayerPage, error) {
+	var page PlayerPage
+	orders, err := query.orderColumns()
+	if err != nil {
+		return page, err
+	}
+	if err := s.filtered(ctx, query).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+	tx := s.filtered(ctx, query)
+	if query.After != "" {
+		anchor := map[string]any{}
+		err := s.db.WithContext(ctx).Model(&model.Player{}).Where("id = ?", query.After).Take(&anchor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return page, fmt.Errorf("%w: unknown cursor %q", ErrInvalidQuery, query.After)
+		}
+		if err != nil {
+			return page, err
+		}
+		tx = tx.Where(afterCursor(orders, anchor))
+	}
+	for _, order := range orders {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: order.column}, Desc: order.desc})
+	}
+	if query.Paginated() {
+		tx = tx.Limit(query.PageSize)
+		if query.After == "" && query.Page > 1 {
+			tx = tx.Offset((query.Page - 1) * query.PageSize)
+		}
+	}
+	page.Players = []model.Player{}
+	err = tx.Find(&page.Players).Error
+	return page, err
+}
+
+// filtered returns a fresh query on the players table with the equality
+// filters from query applied.  A fresh chain is needed per statement because
+// GORM statements accumulate clauses and cannot be reused after execution.
+func (s *playerService) filtered(ctx context.Context, query PlayerQuery) *gorm.DB {
+	tx := s.db.WithContext(ctx).Model(&model.Player{})
+	filters := []struct{ column, value string }{
+		{"team", query.Team},
+		{"league", query.League},
+		{"abbrPosition", query.AbbrPosition},
+	}
+	for _, filter := range filters {
+		if filter.value != "" {
+			tx = tx.Where(clause.Eq{Column: clause.Column{Name: filter.column}, Value: filter.value})
+		}
+	}
+	if query.Starting11 != nil {
+		tx = tx.Where(clause.Eq{Column: clause.Column{Name: "starting11"}, Value: *query.Starting11})
+	}
+	return tx
+}
+
+// afterCursor builds the keyset predicate selecting rows that sort strictly
+// after anchor.  For orders (a, b, id) it expands to
+//
+//	a > A OR (a = A AND b > B) OR (a = A AND b = B AND id > ID)
+//
+// with ">" flipped to "<" for descending columns.
+func afterCursor(orders []orderColumn, anchor map[string]any) clause.Expression {
+	branches := make([]clause.Expression, 0, len(orders))
+	for i, order := range orders {
+		terms := make([]clause.Expression, 0, i+1)
+		for _, previous := range orders[:i] {
+			terms = append(terms, clause.Eq{Column: clause.Column{Name: previous.column}, Value: anchor[previous.column]})
+		}
+		column := clause.Column{Name: order.column}
+		if order.desc {
+			terms = append(terms, clause.Lt{Column: column, Value: anchor[order.column]})
+		} else {
+			terms = append(terms, clause.Gt{Column: column, Value: anchor[order.column]})
+		}
+		branches = append(branches, clause.And(terms...))
+	}
+	return clause.Or(branches...)
+}
+
 // RetrieveByID fetches a single Player by its internal UUID.
 // First adds "LIMIT 1" and returns gorm.ErrRecordNotFound when no row matches,
 // which the controller translates into a 404 response.
 // https://gorm.io/docs/query.html
-func (s *playerService) RetrieveByID(id string) (model.Player, error) {
+func (s *playerService) RetrieveByID(ctx context.Context, id string) (model.Player, error) {
 	var player model.Player
-	result := s.db.Where("id = ?", id).First(&player)
+	result := s.db.WithContext(ctx).Where("id = ?", id).First(&player)
 	return player, result.Error
 }
 
@@ -68,25 +258,93 @@ func (s *playerService) RetrieveByID(id string) (model.Player, error) {
 // Like RetrieveByID, First returns gorm.ErrRecordNotFound on miss; the
 // controller uses errors.Is to distinguish "not found" from other DB errors.
 // https://gorm.io/docs/query.html
-func (s *playerService) RetrieveBySquadNumber(squadNumber int) (model.Player, error) {
+func (s *playerService) RetrieveBySquadNumber(ctx context.Context, squadNumber int) (model.Player, error) {
 	var player model.Player
-	result := s.db.Where("squadNumber = ?", squadNumber).First(&player)
+	result := s.db.WithContext(ctx).Where("? = ?", squadNumberColumn, squadNumber).First(&player)
 	return player, result.Error
 }
 
 // Update replaces a Player record entirely (full update / HTTP PUT semantics).
-// Save issues an UPDATE covering all columns, not just the changed ones.
-// Using Save instead of Updates avoids accidentally zeroing fields that the
-// caller omitted — the caller must always pass the complete player struct.
+//
+// The UPDATE covers all columns, not just the changed ones (Select("*")), so
+// the caller must always pass the complete player struct.  It is guarded by
+// the version the caller read: the WHERE clause matches only if player.Version
+// is still current, and the statement increments it.  When no row matches,
+// another request got there first and ErrVersionConflict is returned.  On
+// success player.Version holds the new version.
 // https://gorm.io/docs/update.html
-func (s *playerService) Update(player *model.Player) error {
-	return s.db.Save(player).Error
+func (s *playerService) Update(ctx context.Context, player *model.Player) error {
+	expected := player.Version
+	player.Version = expected + 1
+	result := s.db.WithContext(ctx).Model(player).Where("version = ?", expected).Select("*").Updates(player)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		player.Version = expected
+	}
+	return result.Error
+}
+
+// Delete soft-deletes a Player, provided it still carries player.Version
+// (see Update); otherwise ErrVersionConflict is returned.
+// Because the Player struct has a gorm.DeletedAt field, GORM issues an
+// UPDATE setting deletedAt rather than a DELETE statement; the row stays in
+// the table (see RetrieveDeleted, Restore and Purge) but disappears from
+// every other query.
+// https://gorm.io/docs/delete.html#Soft-Delete
+func (s *playerService) Delete(ctx context.Context, player *model.Player) error {
+	result := s.db.WithContext(ctx).Where("version = ?", player.Version).Delete(player)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return result.Error
+}
+
+// RetrieveDeleted fetches every soft-deleted Player, most recently deleted
+// first.  Unscoped lifts GORM's implicit "deletedAt IS NULL" filter.
+// https://gorm.io/docs/delete.html#Find-soft-deleted-records
+func (s *playerService) RetrieveDeleted(ctx context.Context) ([]model.Player, error) {
+	players := []model.Player{}
+	result := s.db.WithContext(ctx).Unscoped().Where("? IS NOT NULL", deletedAtColumn).Order(clause.OrderByColumn{Column: deletedAtColumn, Desc: true}).Find(&players)
+	return players, result.Error
+}
+
+// Restore brings back the most recently deleted Player with the given squad
+// number and returns it.  It returns gorm.ErrRecordNotFound when the trash
+// holds no such Player.
+//
+// If the squad number was reassigned to another Player after the deletion,
+// clearing deletedAt violates the partial unique index on live squad numbers
+// and the unique constraint error is returned unchanged, leaving both rows
+// as they were.
+func (s *playerService) Restore(ctx context.Context, squadNumber int) (model.Player, error) {
+	var player model.Player
+	err := s.db.WithContext(ctx).Unscoped().
+		Where("? = ? AND ? IS NOT NULL", squadNumberColumn, squadNumber, deletedAtColumn).
+		Order(clause.OrderByColumn{Column: deletedAtColumn, Desc: true}).
+		First(&player).Error
+	if err != nil {
+		return player, err
+	}
+	if err := s.db.WithContext(ctx).Unscoped().Model(&player).Update("deletedAt", nil).Error; err != nil {
+		return player, err
+	}
+	player.DeletedAt = gorm.DeletedAt{}
+	return player, nil
+}
+
+// Purge permanently removes every soft-deleted Player and returns how many
+// rows were removed.  Unscoped turns GORM's soft delete back into a DELETE.
+// https://gorm.io/docs/delete.html#Delete-permanently
+func (s *playerService) Purge(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Unscoped().Where("? IS NOT NULL", deletedAtColumn).Delete(&model.Player{})
+	return result.RowsAffected, result.Error
 }
 
-// Delete removes a Player from the database permanently.
-// Because the Player struct has no gorm.DeletedAt (soft-delete) field, GORM
-// issues a hard DELETE statement rather than setting a deleted_at timestamp.
-// https://gorm.io/docs/delete.html
-func (s *playerService) Delete(player *model.Player) error {
-	return s.db.Delete(player).Error
+// WithPrincipal returns the service itself: the plain GORM service keeps no
+// audit trail, so there is nothing to attribute mutations to.  See
+// NewAuditedPlayerService for the implementation that records the principal.
+func (s *playerService) WithPrincipal(principal auth.Principal) PlayerService {
+	return s
 }
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookService defines the contract for storing webhook subscriptions and
+// the queue of their deliveries.  The webhook package's Dispatcher works the
+// queue; both implementations let several dispatchers share it.
+type WebhookService interface {
+	// Subscribe stores subscription with a new ID, Secret and CreatedAt.
+	Subscribe(ctx context.Context, subscription *model.WebhookSubscription) error
+	// Subscriptions returns every subscription, oldest first.
+	Subscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	// Subscription returns the subscription with the given ID, or
+	// gorm.ErrRecordNotFound.
+	Subscription(ctx context.Context, id string) (model.WebhookSubscription, error)
+	// Unsubscribe removes the subscription with the given ID and its
+	// deliveries, or returns gorm.ErrRecordNotFound.
+	Unsubscribe(ctx context.Context, id string) error
+	// Enqueue queues payload for every subscription wanting eventType, due
+	// at once, and returns the new deliveries.
+	Enqueue(ctx context.Context, eventType string, payload json.RawMessage) ([]model.WebhookDelivery, error)
+	// Claim returns up to limit pending deliveries due at now, oldest first,
+	// and postpones each by lease, so that no other dispatcher attempts it
+	// meanwhile; one that is not recorded in time is attempted again.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	// Record stores the outcome of an attempt: the state, attempts, next
+	// attempt, last status and error, and delivery time of delivery.
+	Record(ctx context.Context, delivery *model.WebhookDelivery) error
+	// DeadLetters returns the deliveries given up on, most recent first.
+	DeadLetters(ctx context.Context) ([]model.WebhookDelivery, error)
+	// Redeliver makes the delivery with the given ID pending and due at now,
+	// with a fresh budget of attempts, or returns gorm.ErrRecordNotFound.
+	Redeliver(ctx context.Context, id int64, now time.Time) (model.WebhookDelivery, error)
+}
+
+// Columns of webhook_deliveries queries (see squadNumberColumn).
+var (
+	subscriptionIDColumn = clause.Column{Name: "subscriptionId"}
+	nextAttemptAtColumn  = clause.Column{Name: "nextAttemptAt"}
+	createdAtColumn      = clause.Column{Name: "createdAt"}
+)
+
+// webhookService implements WebhookService using GORM.
+type webhookService struct {
+	db *gorm.DB
+}
+
+// NewWebhookService returns a WebhookService backed by the given *gorm.DB.
+func NewWebhookService(db *gorm.DB) WebhookService {
+	return &webhookService{db: db}
+}
+
+// Subscribe inserts subscription with a new UUID and secret.
+func (s *webhookService) Subscribe(ctx context.Context, subscription *model.WebhookSubscription) error {
+	if err := prepareSubscription(subscription); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Create(subscription).Error
+}
+
+// Subscriptions fetches every subscription, oldest first.
+func (s *webhookService) Subscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	subscriptions := []model.WebhookSubscription{}
+	err := s.db.WithContext(ctx).Order(clause.OrderByColumn{Column: createdAtColumn}).Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// Subscription fetches the subscription with the given ID.
+func (s *webhookService) Subscription(ctx context.Context, id string) (model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&subscription).Error
+	return subscription, err
+}
+
+// Unsubscribe deletes the subscription and its deliveries in one
+// transaction.  The deliveries are deleted explicitly because SQLite does
+// not enforce the foreign key's ON DELETE CASCADE unless told to.
+func (s *webhookService) Unsubscribe(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("? = ?", subscriptionIDColumn, id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&model.WebhookSubscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Enqueue inserts one pending delivery per subscription wanting eventType.
+func (s *webhookService) Enqueue(ctx context.Context, eventType string, payload json.RawMessage) ([]model.WebhookDelivery, error) {
+	subscriptions, err := s.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := newDeliveries(subscriptions, eventType, payload, time.Now().UTC())
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+	return deliveries, s.db.WithContext(ctx).Create(&deliveries).Error
+}
+
+// Claim selects the due deliveries, then postpones each with an UPDATE that
+// only matches while it is still due: of several dispatchers selecting the
+// same delivery, exactly one postpones, and so claims, it.
+func (s *webhookService) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	now = now.UTC()
+	var due []model.WebhookDelivery
+	err := s.db.WithContext(ctx).Where("state = ? AND ? <= ?", model.DeliveryPending, nextAttemptAtColumn, now).
+		Order(clause.OrderByColumn{Column: nextAttemptAtColumn}).Order("id").Limit(limit).Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]model.WebhookDelivery, 0, len(due))
+	for _, delivery := range due {
+		leased := now.Add(lease)
+		result := s.db.WithContext(ctx).Model(&model.WebhookDelivery{}).
+			Where("id = ? AND state = ? AND ? <= ?", delivery.ID, model.DeliveryPending, nextAttemptAtColumn, now).
+			Update("nextAttemptAt", leased)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			delivery.NextAttemptAt = leased
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
+
+// Record updates the outcome columns of delivery.  It never inserts, so the
+// outcome of a delivery whose subscription was removed meanwhile is dropped.
+func (s *webhookService) Record(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.db.WithContext(ctx).Model(&model.WebhookDelivery{}).Where("id = ?", delivery.ID).
+		Select("state", "attempts", "nextAttemptAt", "lastStatus", "lastError", "deliveredAt").
+		Updates(delivery).Error
+}
+
+// DeadLetters fetches the dead deliveries, most recent first.
+func (s *webhookService) DeadLetters(ctx context.Context) ([]model.WebhookDelivery, error) {
+	deliveries := []model.WebhookDelivery{}
+	err := s.db.WithContext(ctx).Where("state = ?", model.DeliveryDead).
+		Order(clause.OrderByColumn{Column: createdAtColumn, Desc: true}).Order("id DESC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Redeliver resets the delivery with the given ID.
+func (s *webhookService) Redeliver(ctx context.Context, id int64, now time.Time) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).First(&delivery).Error; err != nil {
+			return err
+		}
+		redeliver(&delivery, now)
+		return NewWebhookService(tx).Record(ctx, &delivery)
+	})
+	return delivery, err
+}
+
+// secretBytes is the length of a generated subscription secret, before hex
+// encoding: that of the SHA-256 key it is used as.
+const secretBytes = 32
+
+// prepareSubscription gives subscription a new UUID, a random secret and the
+// current time, whatever the client sent.
+func prepareSubscription(subscription *model.WebhookSubscription) error {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	subscription.ID = uuid.NewString()
+	subscription.Secret = hex.EncodeToString(secret)
+	subscription.CreatedAt = time.Now().UTC()
+	if subscription.Events == nil {
+		subscription.Events = []string{}
+	}
+	return nil
+}
+
+// newDeliveries returns a pending delivery of payload, due at now, for every
+// subscription wanting eventType.
+func newDeliveries(subscriptions []model.WebhookSubscription, eventType string, payload json.RawMessage, now time.Time) []model.WebhookDelivery {
+	deliveries := []model.WebhookDelivery{}
+	for _, subscription := range subscriptions {
+		if subscription.Wants(eventType) {
+			deliveries = append(deliveries, model.WebhookDelivery{
+				SubscriptionID: subscription.ID,
+				EventType:      eventType,
+				Payload:        payload,
+				State:          model.DeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+			})
+		}
+	}
+	return deliveries
+}
+
+// redeliver makes delivery pending and due at now, with no attempts made.
+// The last status and error are kept until the next attempt replaces them.
+func redeliver(delivery *model.WebhookDelivery, now time.Time) {
+	delivery.State = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now.UTC()
+	delivery.DeliveredAt = nil
+}
@@ -0,0 +1,477 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Credentials accepted by authenticate.
+const (
+	TestAPIKey     = "test-api-key"
+	TestAPIKeyName = "tests"
+	TestJWTSecret  = "a-test-secret-of-at-least-32-bytes"
+)
+
+// testAuthConfig accepts TestAPIKey, which grants the admin role, and HS256
+// tokens signed with TestJWTSecret.
+func testAuthConfig() config.AuthConfig {
+	return config.AuthConfig{
+		APIKeys:       []string{TestAPIKeyName + ":" + hashAPIKey(TestAPIKey) + ":" + string(auth.RoleAdmin)},
+		JWTSecret:     TestJWTSecret,
+		JWTRolesClaim: "roles",
+	}
+}
+
+// hashAPIKey returns the hex SHA-256 of key, as configured in AUTH_API_KEYS.
+func hashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// roleClaims are the claims of test tokens: the registered ones and the
+// roles claim of testAuthConfig.
+type roleClaims struct {
+	jwt.RegisteredClaims
+	Roles []auth.Role `json:"roles,omitempty"`
+}
+
+// signToken returns an HS256 token signed with TestJWTSecret granting roles,
+// valid for an hour unless claims say otherwise.
+func signToken(test *testing.T, claims jwt.RegisteredClaims, roles ...auth.Role) string {
+	test.Helper()
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, roleClaims{claims, roles}).SignedString([]byte(TestJWTSecret))
+	require.NoError(test, err)
+	return token
+}
+
+// withTestCredentials authenticates every request without credentials of its
+// own with TestAPIKey.
+func withTestCredentials(context *gin.Context) {
+	if context.GetHeader(XAPIKey) == "" && context.GetHeader(Authorization) == "" {
+		context.Request.Header.Set(XAPIKey, TestAPIKey)
+	}
+}
+
+// base64URL encodes b as JWK members are.
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJWKS writes a JWKS file holding the public keys of rsaKey (kid
+// "rsa-1") and ecKey (kid "ec-1") and returns its path.
+func writeJWKS(test *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	test.Helper()
+	ecPublic, err := ecKey.PublicKey.Bytes() // 0x04 || X || Y
+	require.NoError(test, err)
+	size := (len(ecPublic) - 1) / 2
+	set := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256",
+			"n": base64URL(rsaKey.N.Bytes()), "e": base64URL(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256",
+			"x": base64URL(ecPublic[1 : 1+size]), "y": base64URL(ecPublic[1+size:])},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "", "e": ""},
+	}}
+	content, err := json.Marshal(set)
+	require.NoError(test, err)
+	path := filepath.Join(test.TempDir(), "jwks.json")
+	require.NoError(test, os.WriteFile(path, content, 0o600))
+	return path
+}
+
+// setupAuthRouter returns a router whose player and audit routes require
+// credentials accepted by cfg, without withTestCredentials.
+func setupAuthRouter(test *testing.T, cfg config.AuthConfig) *gin.Engine {
+	test.Helper()
+	authenticator, err := auth.New(cfg)
+	require.NoError(test, err)
+	router := gin.New()
+	route.RegisterPlayerRoutes(router, playerController, inMemoryCaching(persistence.NewInMemoryStore(time.Hour)),
+		controller.Authenticate(authenticator), cfg.ProtectReads, nil, nil, nil)
+	route.RegisterAuditRoutes(router, auditController, controller.Authenticate(authenticator), cfg.ProtectReads, nil)
+	return router
+}
+
+// postInvalidPlayer sends a POST /players whose body fails validation, with
+// the given credential headers.  Authentication runs first, so 422 means the
+// credentials were accepted and nothing was created.
+func postInvalidPlayer(test *testing.T, router *gin.Engine, headers map[string]string) *httptest.ResponseRecorder {
+	test.Helper()
+	request, err := http.NewRequest(http.MethodPost, route.GetAllPath, strings.NewReader(`{}`))
+	require.NoError(test, err)
+	request.Header.Set(ContentType, ApplicationJSON)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+/* POST /players (authentication) ------------------------------------------ */
+
+// TestRequestPOSTPlayersNoCredentialsResponseUnauthorized tests that a
+// POST request to /players
+// without credentials returns a 401 Unauthorized problem with a bearer
+// challenge.
+func TestRequestPOSTPlayersNoCredentialsResponseUnauthorized(test *testing.T) {
+
+	// Arrange
+	router := setupAuthRouter(test, testAuthConfig())
+
+	// Act
+	recorder := postInvalidPlayer(test, router, nil)
+
+	// Assert
+	var problem model.ProblemDetails
+	require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(test, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(test, ApplicationProblemJSON, recorder.Header().Get(ContentType))
+	assert.Equal(test, `Bearer realm="go-samples-gin-restful"`, recorder.Header().Get(WWWAuthenticate))
+	assert.Equal(test, http.StatusUnauthorized, problem.Status)
+	assert.Equal(test, route.GetAllPath, problem.Instance)
+}
+
+// TestRequestPOSTPlayersCredentialsResponseByValidity tests that a POST
+// request to /players
+// is let through with a valid API key or bearer token, and refused with 401
+// Unauthorized and an invalid_token challenge otherwise.
+func TestRequestPOSTPlayersCredentialsResponseByValidity(test *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(test, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(test, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(test, err)
+	cfg := testAuthConfig()
+	cfg.JWKSFile = writeJWKS(test, rsaKey, ecKey)
+	cfg.JWTIssuer = "https://issuer.example"
+	cfg.JWTAudience = "players-api"
+	router := setupAuthRouter(test, cfg)
+
+	valid := func() roleClaims {
+		return roleClaims{jwt.RegisteredClaims{
+			Subject:   "scout",
+			Issuer:    cfg.JWTIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JWTAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}, []auth.Role{auth.RoleEditor}}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(test, err)
+		return signed
+	}
+	expired := valid()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongAudience := valid()
+	wrongAudience.Audience = jwt.ClaimStrings{"another-api"}
+	noSubject := valid()
+	noSubject.Subject = ""
+	unsigned := sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, valid())
+
+	cases := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"APIKey", map[string]string{XAPIKey: TestAPIKey}, http.StatusUnprocessableEntity},
+		{"UnknownAPIKey", map[string]string{XAPIKey: "guess"}, http.StatusUnauthorized},
+		{"HS256", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodHS256, "", []byte(TestJWTSecret), valid())}, http.StatusUnprocessableEntity},
+		{"HS256WrongSecret", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodHS256, "", []byte(strings.Repeat("x", 32)), valid())}, http.StatusUnauthorized},
+		{"RS256FromJWKS", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, valid())}, http.StatusUnprocessableEntity},
+		{"ES256FromJWKSWithoutKid", map[string]string{Authorization: "bearer " + sign(jwt.SigningMethodES256, "", ecKey, valid())}, http.StatusUnprocessableEntity},
+		{"RS256UnknownKey", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodRS256, "rsa-1", otherKey, valid())}, http.StatusUnauthorized},
+		{"RS512NotAllowedForKey", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodRS512, "rsa-1", rsaKey, valid())}, http.StatusUnauthorized},
+		{"AlgNone", map[string]string{Authorization: "Bearer " + unsigned}, http.StatusUnauthorized},
+		{"Expired", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodHS256, "", []byte(TestJWTSecret), expired)}, http.StatusUnauthorized},
+		{"WrongAudience", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodHS256, "", []byte(TestJWTSecret), wrongAudience)}, http.StatusUnauthorized},
+		{"NoSubject", map[string]string{Authorization: "Bearer " + sign(jwt.SigningMethodHS256, "", []byte(TestJWTSecret), noSubject)}, http.StatusUnauthorized},
+		{"Malformed", map[string]string{Authorization: "Bearer not.a.token"}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := postInvalidPlayer(t, router, tc.headers)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			if tc.wantStatus == http.StatusUnauthorized {
+				assert.Contains(t, recorder.Header().Get(WWWAuthenticate), `error="invalid_token"`)
+			}
+		})
+	}
+}
+
+// TestRequestWriteRoutesNoCredentialsResponseUnauthorized tests that every
+// mutating route refuses requests without credentials, before the handler
+// runs.
+func TestRequestWriteRoutesNoCredentialsResponseUnauthorized(test *testing.T) {
+	router := setupAuthRouter(test, testAuthConfig())
+	cases := []struct{ method, path string }{
+		{http.MethodPost, route.GetAllPathTrailingSlash},
+		{http.MethodPost, route.BulkPath},
+		{http.MethodPut, buildSquadNumberPath("23")},
+		{http.MethodPatch, buildSquadNumberPath("23")},
+		{http.MethodDelete, buildSquadNumberPath("23")},
+		{http.MethodPost, buildSquadNumberPath("23") + "/restore"},
+		{http.MethodDelete, route.PurgePath},
+	}
+	for _, tc := range cases {
+		test.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			request, err := http.NewRequest(tc.method, tc.path, nil)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		})
+	}
+}
+
+/* Player routes (authorization) ------------------------------------------- */
+
+// TestRequestWriteRoutesRoleResponseByAuthorization tests that every mutating
+// route lets through the roles it requires, and refuses the others with 403
+// Forbidden before the handler runs.  Requests that get through fail
+// validation or find no player, so nothing is changed.
+func TestRequestWriteRoutesRoleResponseByAuthorization(test *testing.T) {
+	router := setupAuthRouter(test, testAuthConfig())
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		role    auth.Role
+		allowed bool
+	}{
+		{"ViewerPOST", http.MethodPost, route.GetAllPath, auth.RoleViewer, false},
+		{"EditorPOST", http.MethodPost, route.GetAllPath, auth.RoleEditor, true},
+		{"EditorPUT", http.MethodPut, buildSquadNumberPath("99"), auth.RoleEditor, true},
+		{"ViewerPATCH", http.MethodPatch, buildSquadNumberPath("99"), auth.RoleViewer, false},
+		{"EditorPATCH", http.MethodPatch, buildSquadNumberPath("99"), auth.RoleEditor, true},
+		{"EditorRestore", http.MethodPost, buildSquadNumberPath("99") + "/restore", auth.RoleEditor, true},
+		{"EditorDELETE", http.MethodDelete, buildSquadNumberPath("99"), auth.RoleEditor, false},
+		{"AdminDELETE", http.MethodDelete, buildSquadNumberPath("99"), auth.RoleAdmin, true},
+		{"EditorBulk", http.MethodPost, route.BulkPath, auth.RoleEditor, false},
+		{"AdminBulk", http.MethodPost, route.BulkPath, auth.RoleAdmin, true},
+		{"EditorPurge", http.MethodDelete, route.PurgePath, auth.RoleEditor, false},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+			require.NoError(t, err)
+			request.Header.Set(ContentType, ApplicationJSON)
+			request.Header.Set(Authorization, "Bearer "+signToken(t, jwt.RegisteredClaims{Subject: "scout"}, tc.role))
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			if tc.allowed {
+				assert.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, recorder.Code)
+			} else {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			}
+		})
+	}
+}
+
+// TestRequestPOSTPlayersViewerResponseForbidden tests that a POST request to
+// /players
+// with the credentials of a viewer returns a 403 Forbidden problem naming
+// the role required and the role held.
+func TestRequestPOSTPlayersViewerResponseForbidden(test *testing.T) {
+
+	// Arrange
+	cfg := testAuthConfig()
+	cfg.APIKeys = append(cfg.APIKeys, "reader:"+hashAPIKey("reader-key"))
+	router := setupAuthRouter(test, cfg)
+
+	// Act
+	recorder := postInvalidPlayer(test, router, map[string]string{XAPIKey: "reader-key"})
+
+	// Assert
+	var problem model.ProblemDetails
+	require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(test, http.StatusForbidden, recorder.Code)
+	assert.Equal(test, ApplicationProblemJSON, recorder.Header().Get(ContentType))
+	assert.Equal(test, http.StatusForbidden, problem.Status)
+	assert.Contains(test, problem.Detail, "requires the editor role")
+	assert.Contains(test, problem.Detail, "viewer")
+}
+
+// TestRequestPOSTPlayersRolesClaimResponseByRoles tests that the roles of a
+// bearer token are read from the configured claim, as an array or a
+// space-separated string, ignoring unknown roles.
+func TestRequestPOSTPlayersRolesClaimResponseByRoles(test *testing.T) {
+	cases := []struct {
+		name       string
+		claim      string
+		roles      any
+		wantStatus int
+	}{
+		{"Array", "roles", []string{"viewer", "editor"}, http.StatusUnprocessableEntity},
+		{"SpaceSeparated", "roles", "viewer editor", http.StatusUnprocessableEntity},
+		{"CustomClaim", "groups", []string{"admin"}, http.StatusUnprocessableEntity},
+		{"UnknownRole", "roles", []string{"owner"}, http.StatusForbidden},
+		{"NoRoles", "roles", nil, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg := testAuthConfig()
+			cfg.JWTRolesClaim = tc.claim
+			router := setupAuthRouter(t, cfg)
+			claims := jwt.MapClaims{"sub": "scout", "exp": time.Now().Add(time.Hour).Unix()}
+			if tc.roles != nil {
+				claims[tc.claim] = tc.roles
+			}
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(TestJWTSecret))
+			require.NoError(t, err)
+
+			recorder := postInvalidPlayer(t, router, map[string]string{Authorization: "Bearer " + token})
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+		})
+	}
+}
+
+/* GET /players (authentication) ------------------------------------------- */
+
+// TestRequestGETPlayersNoCredentialsResponseByProtectReads tests that a GET
+// request to /players
+// without credentials is served by default, and refused once reads are
+// protected.
+func TestRequestGETPlayersNoCredentialsResponseByProtectReads(test *testing.T) {
+	cases := []struct {
+		name         string
+		protectReads bool
+		headers      map[string]string
+		wantStatus   int
+	}{
+		{"Public", false, nil, http.StatusOK},
+		{"Protected", true, nil, http.StatusUnauthorized},
+		{"ProtectedWithAPIKey", true, map[string]string{XAPIKey: TestAPIKey}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg := testAuthConfig()
+			cfg.ProtectReads = tc.protectReads
+			router := setupAuthRouter(t, cfg)
+			request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+			require.NoError(t, err)
+			for name, value := range tc.headers {
+				request.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+		})
+	}
+}
+
+/* Audit routes (authentication and authorization) ------------------------ */
+
+// TestRequestGETAuditRoutesResponseByAuthorization tests that GET requests to
+// /audit require an admin whether or not reads are protected, and that GET
+// requests to /players/:id/history require a viewer once they are.
+func TestRequestGETAuditRoutesResponseByAuthorization(test *testing.T) {
+	history := route.PlayersPath + "/" + MakeExistingPlayer().ID + "/history"
+	cases := []struct {
+		name         string
+		path         string
+		protectReads bool
+		roles        []auth.Role // nil for no credentials
+		wantStatus   int
+	}{
+		{"AuditNoCredentialsResponseUnauthorized", route.AuditPath, false, nil, http.StatusUnauthorized},
+		{"AuditEditorResponseForbidden", route.AuditPath, false, []auth.Role{auth.RoleEditor}, http.StatusForbidden},
+		{"AuditAdminResponseOK", route.AuditPath, false, []auth.Role{auth.RoleAdmin}, http.StatusOK},
+		{"AuditProtectedViewerResponseForbidden", route.AuditPath, true, []auth.Role{auth.RoleViewer}, http.StatusForbidden},
+		{"HistoryNoCredentialsResponseOK", history, false, nil, http.StatusOK},
+		{"HistoryProtectedNoCredentialsResponseUnauthorized", history, true, nil, http.StatusUnauthorized},
+		{"HistoryProtectedNoRoleResponseForbidden", history, true, []auth.Role{}, http.StatusForbidden},
+		{"HistoryProtectedViewerResponseOK", history, true, []auth.Role{auth.RoleViewer}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg := testAuthConfig()
+			cfg.ProtectReads = tc.protectReads
+			router := setupAuthRouter(t, cfg)
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			if tc.roles != nil {
+				request.Header.Set(Authorization, "Bearer "+signToken(t, jwt.RegisteredClaims{Subject: "auditor"}, tc.roles...))
+			}
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+		})
+	}
+}
+
+// TestAuthNewInvalidJWKSReturnsError tests that a JWKS file that cannot be
+// used is reported when the authenticator is built, not on the first
+// request.
+func TestAuthNewInvalidJWKSReturnsError(test *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{"NotJSON", "keys"},
+		{"NoSignatureKey", `{"keys":[{"kty":"RSA","use":"enc"}]}`},
+		{"UnsupportedKeyType", `{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`},
+		{"AlgorithmMismatch", `{"keys":[{"kty":"EC","crv":"P-256","alg":"RS256","x":"AQ","y":"AQ"}]}`},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jwks.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			_, err := auth.New(config.AuthConfig{JWKSFile: path})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestAuthNewUnknownAPIKeyRoleReturnsError tests that an API key granting a
+// role that does not exist is reported when the authenticator is built.
+func TestAuthNewUnknownAPIKeyRoleReturnsError(test *testing.T) {
+	_, err := auth.New(config.AuthConfig{APIKeys: []string{"ci:" + hashAPIKey(TestAPIKey) + ":owner"}})
+
+	require.Error(test, err)
+	assert.Contains(test, err.Error(), "owner")
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/cachestore"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve sends a request with an optional JSON body to router.
+func serve(test *testing.T, router *gin.Engine, method string, path string, contentType string, body string) *httptest.ResponseRecorder {
+	test.Helper()
+	request, err := http.NewRequest(method, path, strings.NewReader(body))
+	require.NoError(test, err)
+	if contentType != "" {
+		request.Header.Set(ContentType, contentType)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// restoreMartinez puts Martínez (squad 23) back as seeded once test ends.
+func restoreMartinez(test *testing.T) {
+	test.Cleanup(func() {
+		original := MakeExistingPlayer()
+		if err := testDB.Save(&original).Error; err != nil {
+			test.Logf("cleanup: failed to restore Martínez: %v", err)
+		}
+	})
+}
+
+/* Cache invalidation ------------------------------------------------------- */
+
+// TestRequestGETPlayerByIDAfterPUTResponseFreshPlayer tests that a
+// GET request to /players/:id, with or without a query string, issued after
+// a PUT returns the updated player instead of the previously cached page.
+func TestRequestGETPlayerByIDAfterPUTResponseFreshPlayer(test *testing.T) {
+
+	// Arrange
+	restoreMartinez(test)
+	router := setupRouter(playerController)
+	path := route.PlayersPath + "/" + MakeExistingPlayer().ID
+	paths := []string{path, path + "?fields=all"}
+	for _, p := range paths {
+		require.Equal(test, http.StatusOK, serve(test, router, http.MethodGet, p, "", "").Code)
+	}
+	body, err := json.Marshal(MakeUpdatePlayer())
+	require.NoError(test, err)
+
+	// Act
+	put := serve(test, router, http.MethodPut, buildSquadNumberPath("23"), ApplicationJSON, string(body))
+
+	// Assert
+	assert.Equal(test, http.StatusNoContent, put.Code)
+	for _, p := range paths {
+		var player model.Player
+		recorder := serve(test, router, http.MethodGet, p, "", "")
+		require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &player))
+		assert.Equal(test, "Emiliano", player.FirstName, p)
+	}
+}
+
+// TestRequestGETPlayerBySquadNumberAfterPATCHResponseByNewNumber tests that a
+// PATCH changing a player's squad number evicts the cached page of the old
+// number as well as the new one.
+func TestRequestGETPlayerBySquadNumberAfterPATCHResponseByNewNumber(test *testing.T) {
+
+	// Arrange
+	restoreMartinez(test)
+	router := setupRouter(playerController)
+	require.Equal(test, http.StatusOK, serve(test, router, http.MethodGet, buildSquadNumberPath("23"), "", "").Code)
+	require.Equal(test, http.StatusNotFound, serve(test, router, http.MethodGet, buildSquadNumberPath("27"), "", "").Code)
+
+	// Act
+	patch := serve(test, router, http.MethodPatch, buildSquadNumberPath("23"), MergePatchJSON, `{"squadNumber":27}`)
+
+	// Assert
+	assert.Equal(test, http.StatusNoContent, patch.Code)
+	assert.Equal(test, http.StatusNotFound, serve(test, router, http.MethodGet, buildSquadNumberPath("23"), "", "").Code)
+	assert.Equal(test, http.StatusOK, serve(test, router, http.MethodGet, buildSquadNumberPath("27"), "", "").Code)
+}
+
+// TestRequestGETPlayerByIDAfterFailedPUTResponseCachedPlayer tests that a
+// PUT the handler refuses evicts nothing: the cached page keeps being served
+// even though the database changed behind the API's back.
+func TestRequestGETPlayerByIDAfterFailedPUTResponseCachedPlayer(test *testing.T) {
+
+	// Arrange
+	restoreMartinez(test)
+	router := setupRouter(playerController)
+	path := route.PlayersPath + "/" + MakeExistingPlayer().ID
+	require.Equal(test, http.StatusOK, serve(test, router, http.MethodGet, path, "", "").Code)
+	require.NoError(test, testDB.Model(&model.Player{}).Where("squadNumber = ?", 23).Update("firstName", "Emiliano").Error)
+
+	// Act
+	put := serve(test, router, http.MethodPut, buildSquadNumberPath("23"), ApplicationJSON, `{}`)
+
+	// Assert
+	assert.Equal(test, http.StatusUnprocessableEntity, put.Code)
+	var player model.Player
+	recorder := serve(test, router, http.MethodGet, path, "", "")
+	require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &player))
+	assert.Equal(test, "Damián", player.FirstName)
+}
+
+/* Cache backends ----------------------------------------------------------- */
+
+// setupCachedRouter returns a router serving the player routes with the
+// response cache of the given configuration.
+func setupCachedRouter(test *testing.T, cfg config.CacheConfig, ttls map[string]time.Duration) *gin.Engine {
+	test.Helper()
+	store, keys, err := cachestore.Open(cfg)
+	require.NoError(test, err)
+	router := gin.New()
+	router.Use(withTestCredentials)
+	caching := route.Caching{Store: store, Keys: keys, TTL: time.Duration(cfg.PageTTL), TTLs: ttls}
+	route.RegisterPlayerRoutes(router, playerController, caching, authenticate, false, nil, nil, nil)
+	return router
+}
+
+// redisCacheConfig returns the configuration of a redis cache backed by a
+// fresh in-process Redis server.
+func redisCacheConfig(test *testing.T) config.CacheConfig {
+	test.Helper()
+	server := miniredis.RunT(test)
+	cfg := config.Default().Cache
+	cfg.Backend = "redis"
+	cfg.RedisURL = "redis://" + server.Addr()
+	return cfg
+}
+
+// TestRequestGETPlayerByIDRedisCacheResponseSharedByReplicas tests that
+// replicas sharing a Redis cache serve each other's cached responses, and
+// that a PUT on one replica evicts them, query-string variants included,
+// from all.
+func TestRequestGETPlayerByIDRedisCacheResponseSharedByReplicas(test *testing.T) {
+
+	// Arrange
+	restoreMartinez(test)
+	cfg := redisCacheConfig(test)
+	first := setupCachedRouter(test, cfg, nil)
+	second := setupCachedRouter(test, cfg, nil)
+	path := route.PlayersPath + "/" + MakeExistingPlayer().ID + "?fields=all"
+	require.Equal(test, http.StatusOK, serve(test, first, http.MethodGet, path, "", "").Code)
+	require.NoError(test, testDB.Model(&model.Player{}).Where("squadNumber = ?", 23).Update("team", "Club Atlético Independiente").Error)
+	var cached model.Player
+	require.NoError(test, json.Unmarshal(serve(test, second, http.MethodGet, path, "", "").Body.Bytes(), &cached))
+	body, err := json.Marshal(MakeUpdatePlayer())
+	require.NoError(test, err)
+
+	// Act
+	put := serve(test, second, http.MethodPut, buildSquadNumberPath("23"), ApplicationJSON, string(body))
+
+	// Assert
+	assert.Equal(test, "Aston Villa FC", cached.Team)
+	assert.Equal(test, http.StatusNoContent, put.Code)
+	var player model.Player
+	require.NoError(test, json.Unmarshal(serve(test, first, http.MethodGet, path, "", "").Body.Bytes(), &player))
+	assert.Equal(test, "Emiliano", player.FirstName)
+}
+
+// TestRequestGETPlayersCacheControlResponseByRouteTTL tests that successful
+// responses carry a Cache-Control max-age matching their route's TTL, that
+// failures carry none, and that a disabled cache asks clients to revalidate.
+func TestRequestGETPlayersCacheControlResponseByRouteTTL(test *testing.T) {
+	memory := config.Default().Cache
+	disabled := config.Default().Cache
+	disabled.Backend = "none"
+	ttls := map[string]time.Duration{route.GetByIDPath: 5 * time.Minute}
+	cases := []struct {
+		name string
+		cfg  config.CacheConfig
+		path string
+		want string
+	}{
+		{"RouteTTL", memory, route.PlayersPath + "/" + MakeExistingPlayer().ID, "private, max-age=300"},
+		{"PageTTL", memory, route.GetAllPath, "private, max-age=3600"},
+		{"NotFound", memory, route.PlayersPath + "/" + MakeUnknownPlayer().ID, ""},
+		{"Redis", redisCacheConfig(test), buildSquadNumberPath("23"), "private, max-age=3600"},
+		{"Disabled", disabled, route.GetAllPath, "no-cache"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupCachedRouter(t, tc.cfg, ttls)
+
+			fresh := serve(t, router, http.MethodGet, tc.path, "", "")
+			replayed := serve(t, router, http.MethodGet, tc.path, "", "")
+
+			assert.Equal(t, tc.want, fresh.Header().Get(CacheControl))
+			assert.Equal(t, tc.want, replayed.Header().Get(CacheControl))
+		})
+	}
+}
+
+// TestRequestGETPlayerByIDDisabledCacheResponseFreshPlayer tests that with
+// the none backend every GET reaches the database.
+func TestRequestGETPlayerByIDDisabledCacheResponseFreshPlayer(test *testing.T) {
+
+	// Arrange
+	restoreMartinez(test)
+	cfg := config.Default().Cache
+	cfg.Backend = "none"
+	router := setupCachedRouter(test, cfg, nil)
+	path := route.PlayersPath + "/" + MakeExistingPlayer().ID
+	require.Equal(test, http.StatusOK, serve(test, router, http.MethodGet, path, "", "").Code)
+	require.NoError(test, testDB.Model(&model.Player{}).Where("squadNumber = ?", 23).Update("firstName", "Emiliano").Error)
+
+	// Act
+	recorder := serve(test, router, http.MethodGet, path, "", "")
+
+	// Assert
+	var player model.Player
+	require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &player))
+	assert.Equal(test, "Emiliano", player.FirstName)
+}
+
+// TestCacheStoreOpenUnreachableRedisReturnsError tests that the redis
+// backend is refused at startup when its server does not answer.
+func TestCacheStoreOpenUnreachableRedisReturnsError(test *testing.T) {
+	cfg := config.Default().Cache
+	cfg.Backend = "redis"
+	server := miniredis.RunT(test)
+	cfg.RedisURL = "redis://" + server.Addr()
+	server.Close()
+
+	store, keys, err := cachestore.Open(cfg)
+
+	assert.Error(test, err)
+	assert.Nil(test, store)
+	assert.Nil(test, keys)
+}
@@ -0,0 +1,334 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// environment returns a getenv function reading from variables instead of
+// the process environment.
+func environment(variables map[string]string) func(string) string {
+	return func(name string) string { return variables[name] }
+}
+
+// writeConfigFile writes content to a file with the given name in a
+// temporary directory and returns its path.
+func writeConfigFile(test *testing.T, name string, content string) string {
+	path := filepath.Join(test.TempDir(), name)
+	require.NoError(test, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// TestConfigLoadNoSourcesReturnsDefault tests that without any environment
+// variable, file or flag the configuration is the default one.
+func TestConfigLoadNoSourcesReturnsDefault(test *testing.T) {
+	cfg, err := config.Load(nil, environment(nil))
+
+	require.NoError(test, err)
+	assert.Equal(test, config.Default(), cfg)
+}
+
+// TestConfigLoadPrecedence tests that the file overrides the environment and
+// that flags override both, value by value.
+func TestConfigLoadPrecedence(test *testing.T) {
+	file := writeConfigFile(test, "config.yaml", `
+server:
+  address: ":8081"
+cache:
+  pageTTL: 5m
+`)
+	env := environment(map[string]string{
+		"SERVER_ADDRESS":     ":8080",
+		"DATABASE_LOG_LEVEL": "warn",
+		"CACHE_PAGE_TTL":     "1m",
+		"CONFIG_FILE":        file,
+	})
+
+	cfg, err := config.Load([]string{"-cache-page-ttl", "10m"}, env)
+
+	require.NoError(test, err)
+	assert.Equal(test, ":8081", cfg.Server.Address)                        // file over env
+	assert.Equal(test, "warn", cfg.Database.LogLevel)                      // env over default
+	assert.Equal(test, config.Duration(10*time.Minute), cfg.Cache.PageTTL) // flag over file
+	assert.Equal(test, config.Default().Cache.DefaultTTL, cfg.Cache.DefaultTTL)
+}
+
+// TestConfigLoadDatabaseURL tests that DATABASE_URL takes precedence over the
+// legacy STORAGE_PATH, which is still honoured on its own.
+func TestConfigLoadDatabaseURL(test *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"StoragePath", map[string]string{"STORAGE_PATH": "/storage/players.db"}, "/storage/players.db"},
+		{"DatabaseURLOverStoragePath", map[string]string{"STORAGE_PATH": "/storage/players.db", "DATABASE_URL": "memory://"}, "memory://"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg, err := config.Load(nil, environment(tc.env))
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, cfg.Database.URL)
+		})
+	}
+}
+
+// TestConfigLoadFile tests that YAML and TOML files are decoded, chosen by
+// extension, and that the -config flag takes precedence over CONFIG_FILE.
+func TestConfigLoadFile(test *testing.T) {
+	yamlFile := writeConfigFile(test, "config.yml", "database:\n  logLevel: error\n")
+	tomlFile := writeConfigFile(test, "config.toml", "[database]\nlogLevel = \"silent\"\n")
+	cases := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"YAML", nil, map[string]string{"CONFIG_FILE": yamlFile}, "error"},
+		{"TOML", nil, map[string]string{"CONFIG_FILE": tomlFile}, "silent"},
+		{"FlagOverConfigFile", []string{"-config", tomlFile}, map[string]string{"CONFIG_FILE": yamlFile}, "silent"},
+		{"Empty", nil, map[string]string{"CONFIG_FILE": writeConfigFile(test, "empty.yaml", "")}, "info"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg, err := config.Load(tc.args, environment(tc.env))
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, cfg.Database.LogLevel)
+		})
+	}
+}
+
+// TestConfigLoadRateLimit tests that rate limits are read as requests/period
+// from any source, and that "off" disables one.
+func TestConfigLoadRateLimit(test *testing.T) {
+	file := writeConfigFile(test, "config.yaml", "rateLimit:\n  writes: \"off\"\n")
+
+	cfg, err := config.Load(nil, environment(map[string]string{"RATE_LIMIT_READS": "100/30s", "CONFIG_FILE": file}))
+
+	require.NoError(test, err)
+	assert.Equal(test, config.Rate{Requests: 100, Period: 30 * time.Second}, cfg.RateLimit.Reads)
+	assert.True(test, cfg.RateLimit.Writes.Off())
+}
+
+// TestConfigLoadCacheRouteTTLs tests that a route without a TTL of its own
+// expires after the page TTL, and one with a TTL after that TTL.
+func TestConfigLoadCacheRouteTTLs(test *testing.T) {
+	file := writeConfigFile(test, "config.yaml", "cache:\n  backend: redis\n  routes:\n    playerByID: 10m\n")
+
+	cfg, err := config.Load([]string{"-cache-page-ttl", "2m"}, environment(map[string]string{"CACHE_TTL_PLAYERS": "30s", "CONFIG_FILE": file}))
+
+	require.NoError(test, err)
+	assert.Equal(test, "redis", cfg.Cache.Backend)
+	assert.Equal(test, 30*time.Second, cfg.Cache.TTL(cfg.Cache.Routes.Players))
+	assert.Equal(test, 10*time.Minute, cfg.Cache.TTL(cfg.Cache.Routes.PlayerByID))
+	assert.Equal(test, 2*time.Minute, cfg.Cache.TTL(cfg.Cache.Routes.PlayerBySquadNumber))
+}
+
+// TestConfigLoadInvalidReturnsError tests that an invalid value from any
+// source is rejected at load time, naming the offending setting.
+func TestConfigLoadInvalidReturnsError(test *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"Address", nil, map[string]string{"SERVER_ADDRESS": "9000"}, "server.address"},
+		{"Mode", []string{"-mode", "verbose"}, nil, "server.mode"},
+		{"LogLevel", nil, map[string]string{"DATABASE_LOG_LEVEL": "debug"}, "database.logLevel"},
+		{"UnparsableTTL", nil, map[string]string{"CACHE_DEFAULT_TTL": "forever"}, "CACHE_DEFAULT_TTL"},
+		{"NonPositiveTTL", []string{"-cache-page-ttl", "0s"}, nil, "cache.pageTTL"},
+		{"NonPositiveTimeout", nil, map[string]string{"SERVER_SHUTDOWN_TIMEOUT": "-1s"}, "server.shutdownTimeout"},
+		{"NegativeShutdownReadinessDelay", nil, map[string]string{"SERVER_SHUTDOWN_READINESS_DELAY": "-1s"}, "server.shutdownReadinessDelay"},
+		{"EmbeddedNATSWithoutPublisher", nil, map[string]string{"OUTBOX_NATS_EMBEDDED": "true"}, "outbox.natsEmbedded"},
+		{"EmbeddedNATSWithTLS", nil, map[string]string{"OUTBOX_PUBLISHERS": "nats", "OUTBOX_NATS_EMBEDDED": "true", "OUTBOX_NATS_URL": "tls://localhost:4222"}, "outbox.natsURL"},
+		{"RequestTimeoutAboveWriteTimeout", []string{"-request-timeout", "2m"}, nil, "server.requestTimeout"},
+		{"TracingExporter", []string{"-tracing-exporter", "jaeger"}, nil, "tracing.exporter"},
+		{"TracingEndpoint", nil, map[string]string{"TRACING_EXPORTER": "otlp", "TRACING_ENDPOINT": "localhost:4318"}, "tracing.endpoint"},
+		{"APIKeyWithoutName", nil, map[string]string{"AUTH_API_KEYS": ":" + hashAPIKey(TestAPIKey)}, "auth.apiKeys[0]"},
+		{"APIKeyNotHashed", []string{"-auth-api-keys", "ci:" + TestAPIKey}, nil, "auth.apiKeys[0]"},
+		{"APIKeyEmptyRole", nil, map[string]string{"AUTH_API_KEYS": "ci:" + hashAPIKey(TestAPIKey) + ":"}, "auth.apiKeys[0]"},
+		{"EmptyJWTRolesClaim", []string{"-auth-jwt-roles-claim", ""}, nil, "auth.jwtRolesClaim"},
+		{"ShortJWTSecret", nil, map[string]string{"AUTH_JWT_SECRET": "secret"}, "auth.jwtSecret"},
+		{"UnparsableRateLimit", nil, map[string]string{"RATE_LIMIT_READS": "fast"}, "RATE_LIMIT_READS"},
+		{"NonPositiveRateLimit", []string{"-rate-limit-writes", "0/1m"}, nil, "rateLimit.writes"},
+		{"CacheBackend", nil, map[string]string{"CACHE_BACKEND": "memcached"}, "cache.backend"},
+		{"CacheRedisURL", []string{"-cache-backend", "redis", "-cache-redis-url", "localhost:6379"}, nil, "cache.redisURL"},
+		{"NegativeCacheRouteTTL", []string{"-cache-ttl-player-by-id", "-1m"}, nil, "cache.routes.playerByID"},
+		{"NonPositiveEventsBufferSize", []string{"-events-buffer-size", "0"}, nil, "events.bufferSize"},
+		{"NonPositiveWebhookMaxAttempts", nil, map[string]string{"WEBHOOK_MAX_ATTEMPTS": "0"}, "webhooks.maxAttempts"},
+		{"WebhookMaxBackoffBelowBackoff", []string{"-webhook-backoff", "1m", "-webhook-max-backoff", "30s"}, nil, "webhooks.maxBackoff"},
+		{"UnknownOutboxPublisher", nil, map[string]string{"OUTBOX_PUBLISHERS": "bus,kafka"}, "outbox.publishers[1]"},
+		{"NonNATSOutboxURL", []string{"-outbox-publishers", "nats", "-outbox-nats-url", "http://localhost:4222"}, nil, "outbox.natsURL"},
+		{"NonPositiveOutboxBatchSize", []string{"-outbox-batch-size", "0"}, nil, "outbox.batchSize"},
+		{"NonPositiveIdempotencyTTL", nil, map[string]string{"IDEMPOTENCY_TTL": "0s"}, "idempotency.ttl"},
+		{"MalformedFailedAuthRate", nil, map[string]string{"RATE_LIMIT_FAILED_AUTH": "10 per minute"}, "RATE_LIMIT_FAILED_AUTH"},
+		{"TrustedProxy", nil, map[string]string{"SERVER_TRUSTED_PROXIES": "10.0.0.0/8,proxy"}, "server.trustedProxies[1]"},
+		{"UnparsableProtectReads", []string{"-auth-protect-reads", "sometimes"}, nil, "-auth-protect-reads"},
+		{"UnparsableMaxHeaderBytes", []string{"-max-header-bytes", "1MB"}, nil, "-max-header-bytes"},
+		{"UnknownFileKey", nil, map[string]string{"CONFIG_FILE": writeConfigFile(test, "typo.yaml", "server:\n  adress: \":9000\"\n")}, "adress"},
+		{"FileExtension", nil, map[string]string{"CONFIG_FILE": writeConfigFile(test, "config.json", "{}")}, "extension"},
+		{"MissingFile", []string{"-config", "does-not-exist.yaml"}, nil, "does-not-exist.yaml"},
+		{"UnknownFlag", []string{"-port", "9000"}, nil, "port"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			_, err := config.Load(tc.args, environment(tc.env))
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+// TestConfigLoadReportsEveryInvalidValue tests that all invalid values are
+// reported together rather than only the first one.
+func TestConfigLoadReportsEveryInvalidValue(test *testing.T) {
+	_, err := config.Load([]string{"-address", "nowhere", "-mode", "verbose"}, environment(nil))
+
+	require.Error(test, err)
+	assert.Contains(test, err.Error(), "server.address")
+	assert.Contains(test, err.Error(), "server.mode")
+}
+
+// TestConfigRedacted tests that the password of a database URL, in its
+// userinfo or its query parameters, is masked and that other values, such as
+// SQLite paths, are left as they are.
+func TestConfigRedacted(test *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"PostgresPassword", "postgres://players:s3cr3t@db:5432/players", "postgres://players:xxxxx@db:5432/players"},
+		{"PostgresWithoutPassword", "postgres://players@db:5432/players", "postgres://players@db:5432/players"},
+		{"PostgresPasswordParameter", "postgres://players@db:5432/players?sslmode=require&password=s3cr3t", "postgres://players@db:5432/players?sslmode=require&password=xxxxx"},
+		{"PostgresSSLPasswordParameter", "postgres://players:s3cr3t@db/players?sslpassword=k3y&sslmode=verify-full", "postgres://players:xxxxx@db/players?sslpassword=xxxxx&sslmode=verify-full"},
+		{"PostgresParameterCase", "postgresql://db/players?PassWord=s3cr3t", "postgresql://db/players?PassWord=xxxxx"},
+		{"SQLitePath", "./storage/players-sqlite3.db", "./storage/players-sqlite3.db"},
+		{"SQLiteSharedMemory", "file::memory:?cache=shared", "file::memory:?cache=shared"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Database.URL = tc.url
+
+			redacted := cfg.Redacted()
+
+			assert.Equal(t, tc.want, redacted.Database.URL)
+			assert.Equal(t, tc.url, cfg.Database.URL)
+		})
+	}
+}
+
+// TestConfigRedactedCacheRedisURL tests that the password of the Redis URL,
+// in its userinfo or its query parameters, is masked.
+func TestConfigRedactedCacheRedisURL(test *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"Userinfo", "redis://:s3cr3t@cache:6379/0", "redis://:xxxxx@cache:6379/0"},
+		{"PasswordParameter", "rediss://cache:6379/0?password=s3cr3t&dial_timeout=5s", "rediss://cache:6379/0?password=xxxxx&dial_timeout=5s"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Cache.RedisURL = tc.url
+
+			redacted := cfg.Redacted()
+
+			assert.Equal(t, tc.want, redacted.Cache.RedisURL)
+		})
+	}
+}
+
+// TestConfigRedactedAuth tests that the JWT secret and the API key hashes are
+// masked, and the API key names and roles kept.
+func TestConfigRedactedAuth(test *testing.T) {
+	cfg, err := config.Load([]string{"-auth-api-keys", "ci:" + hashAPIKey("ci-key") + ",deploy:" + hashAPIKey("deploy-key") + ":admin"},
+		environment(map[string]string{"AUTH_JWT_SECRET": TestJWTSecret}))
+	require.NoError(test, err)
+
+	redacted := cfg.Redacted()
+
+	assert.Equal(test, []string{"ci:xxxxx", "deploy:xxxxx:admin"}, redacted.Auth.APIKeys)
+	assert.Equal(test, "xxxxx", redacted.Auth.JWTSecret)
+	assert.Equal(test, TestJWTSecret, cfg.Auth.JWTSecret)
+}
+
+/* GET /admin/config -------------------------------------------------------- */
+
+// TestRequestGETAdminConfigResponseRedactedConfig tests that a
+// GET request to /admin/config
+// returns a 200 OK status with the effective configuration, its database
+// password redacted.
+func TestRequestGETAdminConfigResponseRedactedConfig(test *testing.T) {
+
+	// Arrange
+	cfg := config.Default()
+	cfg.Database.URL = "postgres://players:s3cr3t@db:5432/players"
+	cfg.Cache.PageTTL = config.Duration(90 * time.Second)
+	router := gin.New()
+	router.Use(withTestCredentials)
+	route.RegisterConfigRoutes(router, controller.NewConfigController(cfg), authenticate)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.ConfigPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var response map[string]map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.NotContains(test, recorder.Body.String(), "s3cr3t")
+	assert.Equal(test, "postgres://players:xxxxx@db:5432/players", response["database"]["url"])
+	assert.Equal(test, "1m30s", response["cache"]["pageTTL"])
+	assert.Equal(test, ":9000", response["server"]["address"])
+}
+
+// TestRequestGETAdminConfigNotAdminResponseForbidden tests that a
+// GET request to /admin/config
+// with the credentials of an editor returns a 403 Forbidden problem.
+func TestRequestGETAdminConfigNotAdminResponseForbidden(test *testing.T) {
+
+	// Arrange
+	router := gin.New()
+	route.RegisterConfigRoutes(router, controller.NewConfigController(config.Default()), authenticate)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.ConfigPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(Authorization, "Bearer "+signToken(test, jwt.RegisteredClaims{Subject: "scout"}, auth.RoleEditor))
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusForbidden, recorder.Code)
+	assert.Equal(test, ApplicationProblemJSON, recorder.Header().Get(ContentType))
+}
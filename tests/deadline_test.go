@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDeadlineRouter returns a router serving the player routes of
+// playerService, with every request given timeout.
+func setupDeadlineRouter(playerService service.PlayerService, timeout time.Duration) *gin.Engine {
+	router := gin.New()
+	router.Use(withTestCredentials, controller.Deadline(timeout))
+	route.RegisterPlayerRoutes(router, controller.NewPlayerController(playerService), inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, nil)
+	return router
+}
+
+// blockingPlayerService returns a MockPlayerService whose RetrievePage waits
+// until the context it is called with is done, and reports why.
+func blockingPlayerService() *MockPlayerService {
+	return &MockPlayerService{
+		RetrievePageFunc: func(ctx context.Context, query service.PlayerQuery) (service.PlayerPage, error) {
+			<-ctx.Done()
+			return service.PlayerPage{}, ctx.Err()
+		},
+	}
+}
+
+// requestMarker is the type of the context key under which
+// TestRequestGETPlayersContextReachesService marks its request's context.
+type requestMarker struct{}
+
+/* Request deadline --------------------------------------------------------- */
+
+// TestRequestGETPlayersContextDoneResponseStatus tests that a
+// GET request to /players
+// whose query is still running at the request deadline returns 503 Service
+// Unavailable, and one whose client went away is logged as 499.
+func TestRequestGETPlayersContextDoneResponseStatus(test *testing.T) {
+	cases := []struct {
+		name     string
+		timeout  time.Duration
+		cancel   bool
+		wantCode int
+	}{
+		{"DeadlineExceededResponseStatusServiceUnavailable", 20 * time.Millisecond, false, http.StatusServiceUnavailable},
+		{"ClientGoneResponseStatusClientClosedRequest", time.Minute, true, 499},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupDeadlineRouter(blockingPlayerService(), tc.timeout)
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+			defer cancel()
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, route.GetAllPath, nil)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				router.ServeHTTP(recorder, request)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the request was not cancelled")
+			}
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, ApplicationProblemJSON, recorder.Header().Get(ContentType))
+		})
+	}
+}
+
+// TestRequestGETPlayersContextReachesService tests that a
+// GET request to /players
+// hands the service the request's context, with the request deadline.
+func TestRequestGETPlayersContextReachesService(test *testing.T) {
+	var received context.Context
+	playerService := &MockPlayerService{
+		RetrievePageFunc: func(ctx context.Context, query service.PlayerQuery) (service.PlayerPage, error) {
+			received = ctx
+			return service.PlayerPage{Players: []model.Player{}}, nil
+		},
+	}
+	router := setupDeadlineRouter(playerService, time.Minute)
+	request, err := http.NewRequestWithContext(context.WithValue(context.Background(), requestMarker{}, "marked"), http.MethodGet, route.GetAllPath, nil)
+	require.NoError(test, err)
+	recorder := httptest.NewRecorder()
+	sent := time.Now()
+
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	require.NotNil(test, received)
+	assert.Equal(test, "marked", received.Value(requestMarker{}))
+	deadline, ok := received.Deadline()
+	require.True(test, ok, "the service was not given the request deadline")
+	assert.WithinDuration(test, sent.Add(time.Minute), deadline, 5*time.Second)
+}
+
+// TestRequestGETPlayersDeadlineReachesDatabase tests that a
+// GET request to /players
+// runs its queries with the request's deadline: on SQLite, a request out of
+// time is refused by the database with 503, instead of reading the players.
+func TestRequestGETPlayersDeadlineReachesDatabase(test *testing.T) {
+	playerService := service.NewAuditedPlayerService(openSQLiteDB(test))
+
+	recorder := serve(test, setupDeadlineRouter(playerService, time.Nanosecond), http.MethodGet, route.GetAllPath, "", "")
+
+	assert.Equal(test, http.StatusServiceUnavailable, recorder.Code)
+}
+
+// TestRequestGETPlayerEventsOutlivesDeadline tests that a
+// GET request to /players/events
+// keeps streaming past the request deadline.
+func TestRequestGETPlayerEventsOutlivesDeadline(test *testing.T) {
+	broker := events.NewBroker(10)
+	router := gin.New()
+	router.Use(controller.Deadline(20 * time.Millisecond))
+	route.RegisterEventRoutes(router, controller.NewEventsController(broker), authenticate, false, nil)
+	server := httptest.NewServer(router)
+	test.Cleanup(server.Close)
+
+	_, received := readEvents(test, server, "", 1, func() {
+		time.Sleep(100 * time.Millisecond)
+		broker.Publish(events.PlayerUpdated, MakeExistingPlayer())
+	})
+
+	assert.Equal(test, events.PlayerUpdated, received[0].Type)
+}
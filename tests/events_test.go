@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LastEventID is the header an EventSource resumes a stream with.
+const LastEventID = "Last-Event-ID"
+
+// streamedEvent is an event read from a text/event-stream response.
+type streamedEvent struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// readEvents opens the stream of server with the given Last-Event-ID (none
+// when empty), calls act once it is open, and returns the first count
+// events it receives.
+func readEvents(test *testing.T, server *httptest.Server, lastEventID string, count int, act func()) (*http.Response, []streamedEvent) {
+	test.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+route.EventsPath, nil)
+	require.NoError(test, err)
+	if lastEventID != "" {
+		request.Header.Set(LastEventID, lastEventID)
+	}
+	response, err := server.Client().Do(request)
+	require.NoError(test, err)
+	defer response.Body.Close()
+	act()
+
+	var received []streamedEvent
+	var event streamedEvent
+	scanner := bufio.NewScanner(response.Body)
+	for len(received) < count && scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Type = value
+		case "data":
+			event.Data = value
+		case "":
+			received = append(received, event)
+			event = streamedEvent{}
+		}
+	}
+	require.Len(test, received, count, "stream ended: %v", scanner.Err())
+	return response, received
+}
+
+// setupEventsServer returns a server streaming the events of broker, and
+// serving the player routes of a controller on an in-memory backend holding
+// players, which hands its changes to broker.  No relay runs: the stream
+// does not depend on it.
+func setupEventsServer(test *testing.T, broker *events.Broker, players ...model.Player) *httptest.Server {
+	test.Helper()
+	playerService, _, _ := service.NewMemoryServices(players, broker)
+	router := gin.New()
+	router.Use(withTestCredentials)
+	playerController := controller.NewPlayerController(playerService)
+	route.RegisterPlayerRoutes(router, playerController, inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, nil)
+	route.RegisterEventRoutes(router, controller.NewEventsController(broker), authenticate, false, nil)
+	server := httptest.NewServer(router)
+	test.Cleanup(server.Close)
+	return server
+}
+
+/* GET /players/events ------------------------------------------------------ */
+
+// TestRequestGETPlayerEventsAfterMutationResponseEvent tests that a
+// GET request to /players/events
+// streams an event with the player for every successful POST, PUT and DELETE.
+func TestRequestGETPlayerEventsAfterMutationResponseEvent(test *testing.T) {
+	created, err := json.Marshal(MakeNonexistentPlayer())
+	require.NoError(test, err)
+	updated, err := json.Marshal(MakeUpdatePlayer())
+	require.NoError(test, err)
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantType  string
+		wantFirst string
+	}{
+		{"POST", http.MethodPost, route.GetAllPath, string(created), events.PlayerCreated, "Giovani"},
+		{"PUT", http.MethodPut, buildSquadNumberPath("23"), string(updated), events.PlayerUpdated, "Emiliano"},
+		{"DELETE", http.MethodDelete, buildSquadNumberPath("23"), "", events.PlayerDeleted, "Damián"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			server := setupEventsServer(t, events.NewBroker(10), MakeExistingPlayer())
+
+			response, received := readEvents(t, server, "", 1, func() {
+				request, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+				require.NoError(t, err)
+				request.Header.Set(ContentType, ApplicationJSON)
+				mutation, err := server.Client().Do(request)
+				require.NoError(t, err)
+				mutation.Body.Close()
+			})
+
+			assert.Equal(t, "text/event-stream", response.Header.Get(ContentType))
+			assert.Equal(t, "1", received[0].ID)
+			assert.Equal(t, tc.wantType, received[0].Type)
+			var player model.Player
+			require.NoError(t, json.Unmarshal([]byte(received[0].Data), &player))
+			assert.Equal(t, tc.wantFirst, player.FirstName)
+		})
+	}
+}
+
+// TestRequestGETPlayerEventsLastEventIDResponseMissedEvents tests that a
+// GET request to /players/events with a Last-Event-ID header
+// first streams the buffered events after it, or a stream.reset event when
+// they are no longer buffered.
+func TestRequestGETPlayerEventsLastEventIDResponseMissedEvents(test *testing.T) {
+	broker := events.NewBroker(3)
+	for squadNumber := 1; squadNumber <= 5; squadNumber++ {
+		broker.Publish(events.PlayerCreated, model.Player{SquadNumber: squadNumber})
+	}
+	server := setupEventsServer(test, broker)
+	cases := []struct {
+		name        string
+		lastEventID string
+		wantIDs     []string
+		wantType    string
+	}{
+		{"Buffered", "3", []string{"4", "5"}, events.PlayerCreated},
+		{"Evicted", "1", []string{"5"}, events.StreamReset},
+		{"FromAnotherProcess", "42", []string{"5"}, events.StreamReset},
+		{"Unparsable", "latest", []string{"5"}, events.StreamReset},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			_, received := readEvents(t, server, tc.lastEventID, len(tc.wantIDs), func() {})
+
+			for i, event := range received {
+				assert.Equal(t, tc.wantIDs[i], event.ID)
+				assert.Equal(t, tc.wantType, event.Type)
+			}
+		})
+	}
+}
+
+// TestEventsBrokerSlowSubscriberIsDropped tests that a subscriber that does
+// not keep up has its channel closed, without holding up Publish, and can
+// resume from the ring buffer.
+func TestEventsBrokerSlowSubscriberIsDropped(test *testing.T) {
+	broker := events.NewBroker(1000)
+	subscription := broker.Subscribe("")
+
+	for squadNumber := range 100 {
+		broker.Publish(events.PlayerUpdated, model.Player{SquadNumber: squadNumber})
+	}
+
+	var last events.Event
+	for event := range subscription.Events {
+		last = event
+	}
+	resumed := broker.Subscribe(fmt.Sprint(last.ID))
+	assert.Less(test, last.ID, uint64(100))
+	assert.False(test, resumed.Reset)
+	require.NotEmpty(test, resumed.Replay)
+	assert.Equal(test, last.ID+1, resumed.Replay[0].ID)
+	assert.Equal(test, uint64(100), resumed.Replay[len(resumed.Replay)-1].ID)
+}
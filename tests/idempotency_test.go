@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// idempotencyBackend opens an empty IdempotencyService on one storage
+// backend.
+type idempotencyBackend struct {
+	name string
+	open func(test *testing.T) service.IdempotencyService
+}
+
+// idempotencyBackends lists the backends the idempotency tests run against.
+func idempotencyBackends() []idempotencyBackend {
+	return []idempotencyBackend{
+		{"Memory", func(test *testing.T) service.IdempotencyService {
+			return service.NewMemoryIdempotencyService()
+		}},
+		{"SQLite", func(test *testing.T) service.IdempotencyService {
+			return service.NewIdempotencyService(openSQLiteDB(test))
+		}},
+	}
+}
+
+// setupIdempotencyRouter returns a router serving the player routes of
+// playerService, POST /players storing its responses in idempotencyService.
+func setupIdempotencyRouter(playerService service.PlayerService, idempotencyService service.IdempotencyService) *gin.Engine {
+	router := gin.New()
+	router.Use(withTestCredentials)
+	idempotent := controller.Idempotency(idempotencyService, time.Hour)
+	route.RegisterPlayerRoutes(router, controller.NewPlayerController(playerService), inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, idempotent)
+	return router
+}
+
+// postIdempotent sends POST /players with body and the Idempotency-Key key,
+// plus headers.
+func postIdempotent(test *testing.T, router *gin.Engine, key string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	test.Helper()
+	request, err := http.NewRequest(http.MethodPost, route.GetAllPath, strings.NewReader(body))
+	require.NoError(test, err)
+	request.Header.Set(ContentType, ApplicationJSON)
+	request.Header.Set(controller.IdempotencyKeyHeader, key)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+/* POST /players with Idempotency-Key -------------------------------------- */
+
+// TestRequestPOSTPlayersIdempotencyKeyRetryResponseReplayed tests that a
+// POST request to /players
+// retried with the same Idempotency-Key and payload, even reformatted, gets
+// the original response without creating the player again, and that the
+// key of another client does not collide with it.
+func TestRequestPOSTPlayersIdempotencyKeyRetryResponseReplayed(test *testing.T) {
+	for _, backend := range idempotencyBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			playerService, _ := service.NewMemoryPlayerService(nil)
+			router := setupIdempotencyRouter(playerService, backend.open(t))
+			body, err := json.Marshal(MakeNonexistentPlayer())
+			require.NoError(t, err)
+			var fields map[string]any
+			require.NoError(t, json.Unmarshal(body, &fields))
+			reformatted, err := json.MarshalIndent(fields, "", "  ")
+			require.NoError(t, err)
+			coach := map[string]string{Authorization: "Bearer " + signToken(t, jwt.RegisteredClaims{Subject: "coach"}, auth.RoleEditor)}
+
+			first := postIdempotent(t, router, "retry-1", string(body), nil)
+			retry := postIdempotent(t, router, "retry-1", string(reformatted), nil)
+			other := postIdempotent(t, router, "retry-1", string(body), coach)
+			players, err := playerService.RetrieveAll(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusCreated, first.Code)
+			assert.Empty(t, first.Header().Get(controller.IdempotentReplayedHeader))
+			assert.Equal(t, http.StatusCreated, retry.Code)
+			assert.Equal(t, "true", retry.Header().Get(controller.IdempotentReplayedHeader))
+			assert.Equal(t, first.Header().Get("Location"), retry.Header().Get("Location"))
+			assert.Equal(t, first.Body.String(), retry.Body.String())
+			assert.Equal(t, http.StatusConflict, other.Code, "the coach's request runs, and finds the squad number taken")
+			assert.Len(t, players, 1)
+		})
+	}
+}
+
+// TestRequestPOSTPlayersIdempotencyKeyReusedResponseStatus tests that a
+// POST request to /players
+// reusing an Idempotency-Key for another payload returns 422, that an
+// overlong key returns 400, that a body over 4 KiB returns 413, and that a
+// stored client error is replayed.
+func TestRequestPOSTPlayersIdempotencyKeyReusedResponseStatus(test *testing.T) {
+	playerService, _ := service.NewMemoryPlayerService(nil)
+	router := setupIdempotencyRouter(playerService, service.NewMemoryIdempotencyService())
+	created, err := json.Marshal(MakeNonexistentPlayer())
+	require.NoError(test, err)
+	updated, err := json.Marshal(MakeUpdatePlayer())
+	require.NoError(test, err)
+	require.Equal(test, http.StatusCreated, postIdempotent(test, router, "reused", string(created), nil).Code)
+	require.Equal(test, http.StatusBadRequest, postIdempotent(test, router, "malformed", "{", nil).Code)
+	cases := []struct {
+		name         string
+		key          string
+		body         string
+		want         int
+		wantReplayed string
+	}{
+		{"DifferentPayload", "reused", string(updated), http.StatusUnprocessableEntity, ""},
+		{"OverlongKey", strings.Repeat("k", 256), string(created), http.StatusBadRequest, ""},
+		{"OversizedBody", "oversized", strings.Repeat(" ", 4<<10) + string(created), http.StatusRequestEntityTooLarge, ""},
+		{"StoredClientError", "malformed", "{", http.StatusBadRequest, "true"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := postIdempotent(t, router, tc.key, tc.body, nil)
+
+			assert.Equal(t, tc.want, recorder.Code)
+			assert.Equal(t, tc.wantReplayed, recorder.Header().Get(controller.IdempotentReplayedHeader))
+		})
+	}
+}
+
+// TestRequestPOSTPlayersIdempotencyKeyInProgressResponseStatusConflict
+// tests that a POST request to /players
+// retried while the first is still running returns 409 with Retry-After,
+// and that a retry after a server error runs the handler again.
+func TestRequestPOSTPlayersIdempotencyKeyInProgressResponseStatusConflict(test *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var mutex sync.Mutex
+	attempts := 0
+	playerService := &MockPlayerService{
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
+			return model.Player{}, gorm.ErrRecordNotFound
+		},
+		CreateFunc: func(_ context.Context, player *model.Player) error {
+			mutex.Lock()
+			attempts++
+			attempt := attempts
+			mutex.Unlock()
+			if attempt == 1 {
+				close(started)
+				<-release
+				return errors.New("database unavailable")
+			}
+			return nil
+		},
+	}
+	router := setupIdempotencyRouter(playerService, service.NewMemoryIdempotencyService())
+	body, err := json.Marshal(MakeNonexistentPlayer())
+	require.NoError(test, err)
+
+	failed := make(chan *httptest.ResponseRecorder)
+	go func() { failed <- postIdempotent(test, router, "slow", string(body), nil) }()
+	<-started
+	inProgress := postIdempotent(test, router, "slow", string(body), nil)
+	close(release)
+	first := <-failed
+	retried := postIdempotent(test, router, "slow", string(body), nil)
+
+	assert.Equal(test, http.StatusConflict, inProgress.Code)
+	assert.Equal(test, "1", inProgress.Header().Get(controller.RetryAfterHeader))
+	assert.Equal(test, http.StatusInternalServerError, first.Code)
+	assert.Equal(test, http.StatusCreated, retried.Code)
+	assert.Empty(test, retried.Header().Get(controller.IdempotentReplayedHeader))
+	assert.Equal(test, 2, attempts)
+}
+
+// TestIdempotencyServiceReserveIsExclusive tests that a key is reserved by
+// one request at a time, until it expires or is released.
+func TestIdempotencyServiceReserveIsExclusive(test *testing.T) {
+	for _, backend := range idempotencyBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			idempotencyService := backend.open(t)
+			now := time.Now().UTC()
+			record := model.IdempotencyRecord{Owner: "api-key:tests", Key: "k", Fingerprint: "f", Header: map[string]string{}, Body: []byte{}, CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+			_, err := idempotencyService.Reserve(context.Background(), record, now)
+			require.NoError(t, err)
+			record.Status, record.Body = http.StatusCreated, []byte(`{}`)
+			require.NoError(t, idempotencyService.Complete(context.Background(), record))
+
+			existing, errTaken := idempotencyService.Reserve(context.Background(), record, now.Add(30*time.Second))
+			_, errExpired := idempotencyService.Reserve(context.Background(), record, now.Add(2*time.Minute))
+			require.NoError(t, idempotencyService.Release(context.Background(), record.Owner, record.Key))
+			_, errReleased := idempotencyService.Reserve(context.Background(), record, now.Add(2*time.Minute))
+
+			assert.ErrorIs(t, errTaken, gorm.ErrDuplicatedKey)
+			assert.Equal(t, http.StatusCreated, existing.Status)
+			assert.Equal(t, `{}`, string(existing.Body))
+			assert.NoError(t, errExpired)
+			assert.NoError(t, errReleased)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/logging"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupLoggedRouter returns a router serving the player routes with every
+// request and SQL statement logged as JSON to the returned buffer.
+func setupLoggedRouter() (*gin.Engine, *bytes.Buffer) {
+	output := &bytes.Buffer{}
+	appLogger := logging.New(output)
+	db := testDB.Session(&gorm.Session{Logger: logging.NewGormLogger(appLogger, logger.Info, time.Second)})
+
+	router := gin.New()
+	router.Use(logging.Middleware(appLogger), logging.Recovery(appLogger))
+	route.RegisterPlayerRoutes(router, controller.NewPlayerController(service.NewPlayerService(db)), inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, nil)
+	router.GET("/panic", func(context *gin.Context) { panic("boom") })
+	return router, output
+}
+
+// logLines decodes every JSON line of output, keyed by its "msg".
+func logLines(test *testing.T, output *bytes.Buffer) map[string][]map[string]any {
+	lines := map[string][]map[string]any{}
+	for _, raw := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		line := map[string]any{}
+		require.NoError(test, json.Unmarshal([]byte(raw), &line), raw)
+		msg, _ := line["msg"].(string)
+		lines[msg] = append(lines[msg], line)
+	}
+	return lines
+}
+
+/* GET /players/squadnumber/:squadnumber ------------------------------------ */
+
+// TestRequestGETPlayerBySquadNumberRequestIDResponseRequestIDLogged tests
+// that a GET request to /players/squadnumber/:squadnumber
+// echoes the request ID it is sent, and tags its JSON access line and every
+// SQL line it causes with it.
+func TestRequestGETPlayerBySquadNumberRequestIDResponseRequestIDLogged(test *testing.T) {
+
+	// Arrange
+	router, output := setupLoggedRouter()
+	request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/squadnumber/10", nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(XRequestID, "client-supplied-42")
+	recorder := httptest.NewRecorder()
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Equal(test, "client-supplied-42", recorder.Header().Get(XRequestID))
+	lines := logLines(test, output)
+	require.Len(test, lines["request"], 1)
+	access := lines["request"][0]
+	assert.Equal(test, "client-supplied-42", access["request_id"])
+	assert.Equal(test, "INFO", access["level"])
+	assert.Equal(test, route.BySquadNumberPath, access["route"])
+	assert.EqualValues(test, http.StatusOK, access["status"])
+	require.NotEmpty(test, lines["sql"])
+	for _, line := range lines["sql"] {
+		assert.Equal(test, "client-supplied-42", line["request_id"])
+		assert.Contains(test, line["sql"], "players")
+	}
+}
+
+// TestRequestGETPlayerBySquadNumberCachedResponseOwnRequestID tests that a
+// GET request to /players/squadnumber/:squadnumber
+// answered from the cache echoes its own request ID, not the one the cached
+// response was stored with.
+func TestRequestGETPlayerBySquadNumberCachedResponseOwnRequestID(test *testing.T) {
+
+	// Arrange
+	router, _ := setupLoggedRouter()
+	ids := []string{"first-request", "second-request"}
+	recorders := make([]*httptest.ResponseRecorder, len(ids))
+
+	// Act
+	for i, id := range ids {
+		request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/squadnumber/10", nil)
+		if err != nil {
+			test.Fatalf(ErrNewRequest, err)
+		}
+		request.Header.Set(XRequestID, id)
+		recorders[i] = httptest.NewRecorder()
+		router.ServeHTTP(recorders[i], request)
+	}
+
+	// Assert
+	for i, id := range ids {
+		assert.Equal(test, http.StatusOK, recorders[i].Code)
+		assert.Equal(test, id, recorders[i].Header().Get(XRequestID))
+	}
+	assert.Equal(test, recorders[0].Body.String(), recorders[1].Body.String())
+}
+
+// TestRequestGETPlayerByIDWithoutRequestIDResponseGeneratedRequestID tests
+// that a GET request to /players/:id
+// without a usable request ID gets a generated one, echoed in the response
+// and logged, and that the unknown player is logged as a warning rather than
+// a failed statement.
+func TestRequestGETPlayerByIDWithoutRequestIDResponseGeneratedRequestID(test *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+	}{
+		{"Absent", ""},
+		{"ForgedLogLine", "x\n{\"level\":\"ERROR\"}"},
+		{"TooLong", strings.Repeat("x", 129)},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router, output := setupLoggedRouter()
+			request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+InvalidID, nil)
+			require.NoError(t, err)
+			if tc.header != "" {
+				request.Header.Set(XRequestID, tc.header)
+			}
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			id := recorder.Header().Get(XRequestID)
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+			assert.Len(t, id, 36)
+			lines := logLines(t, output)
+			require.Len(t, lines["request"], 1)
+			assert.Equal(t, id, lines["request"][0]["request_id"])
+			assert.Equal(t, "WARN", lines["request"][0]["level"])
+			require.Len(t, lines["sql"], 1)
+			assert.Equal(t, id, lines["sql"][0]["request_id"])
+			assert.Equal(t, "INFO", lines["sql"][0]["level"])
+			assert.NotContains(t, lines["sql"][0], "error")
+		})
+	}
+}
+
+// TestRequestGETPanicResponseInternalServerErrorLogged tests that a panic in
+// a handler is answered with 500 and logged as JSON with the request ID.
+func TestRequestGETPanicResponseInternalServerErrorLogged(test *testing.T) {
+	router, output := setupLoggedRouter()
+
+	status := get(test, router, "/panic")
+
+	assert.Equal(test, http.StatusInternalServerError, status)
+	lines := logLines(test, output)
+	require.Len(test, lines["panic"], 1)
+	assert.Equal(test, "boom", lines["panic"][0]["error"])
+	assert.Equal(test, lines["request"][0]["request_id"], lines["panic"][0]["request_id"])
+	assert.Equal(test, "ERROR", lines["request"][0]["level"])
+}
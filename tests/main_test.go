@@ -40,25 +40,33 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/cachestore"
 	"github.com/nanotaboada/go-samples-gin-restful/controller"
 	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/health"
 	"github.com/nanotaboada/go-samples-gin-restful/model"
 	"github.com/nanotaboada/go-samples-gin-restful/route"
 	"github.com/nanotaboada/go-samples-gin-restful/service"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var (
@@ -66,38 +74,81 @@ var (
 	testDB *gorm.DB
 	// playerController is global because integration tests use it via setupRouter()
 	playerController *controller.PlayerController
+	// auditController is global because setupRouter registers the audit routes
+	// next to the player routes, so tests can read back the trail they produce
+	auditController *controller.AuditController
+	// healthController is global for the same reason: setupRouter registers
+	// the health probe too
+	healthController *controller.HealthController
+	// authenticate is global because setupRouter and the routers of other
+	// test files guard their player routes with it; it accepts TestAPIKey
+	// and tokens from signToken
+	authenticate gin.HandlerFunc
 	// Note: playerService is local in TestMain since it's only needed to construct
 	// the controller and is never referenced directly by tests
 )
 
 func TestMain(main *testing.M) {
 	gin.SetMode(gin.TestMode)
-	testDB = data.Connect("file::memory:?cache=shared")
+	testDB = data.Connect("file::memory:?cache=shared", logger.Info)
 	// playerService is local - only used to initialize playerController,
 	// then garbage collected
-	playerService := service.NewPlayerService(testDB)
+	playerService := service.NewAuditedPlayerService(testDB)
 	playerController = controller.NewPlayerController(playerService)
+	auditController = controller.NewAuditController(service.NewAuditService(testDB))
+	healthController = controller.NewHealthController(
+		health.Database(testDB),
+		health.Migrations(testDB),
+		health.Cache(persistence.NewInMemoryStore(time.Hour)),
+	)
+	authenticator, err := auth.New(testAuthConfig())
+	if err != nil {
+		panic(err)
+	}
+	authenticate = controller.Authenticate(authenticator)
 	os.Exit(main.Run())
 }
 
 func setupRouter(controller *controller.PlayerController) *gin.Engine {
 	store := persistence.NewInMemoryStore(time.Hour)
 	app := gin.Default()
-	route.RegisterPlayerRoutes(app, controller, store)
-	app.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Requests are authenticated as TestAPIKeyName unless they carry
+	// credentials of their own; tests/auth_test.go covers the refusals.
+	app.Use(withTestCredentials)
+	route.RegisterPlayerRoutes(app, controller, inMemoryCaching(store), authenticate, false, nil, nil, nil)
+	route.RegisterAuditRoutes(app, auditController, authenticate, false, nil)
+	route.RegisterHealthRoutes(app, healthController)
 	return app
 }
 
+// inMemoryCaching returns the response cache of the tests: store, with an
+// in-process key index and a one-hour TTL on every route.
+func inMemoryCaching(store persistence.CacheStore) route.Caching {
+	return route.Caching{Store: store, Keys: cachestore.NewMemoryKeyIndex(), TTL: time.Hour}
+}
+
 const (
-	ContentType        = "Content-Type"
-	ApplicationJSON    = "application/json"
-	InvalidID          = "invalid-id"
-	InvalidSquadNumber = "invalid-squadnumber"
-	ErrNewRequest      = "failed to create request: %v"
-	ErrMarshal         = "failed to marshal player: %v"
-	ErrUnmarshal       = "failed to unmarshal response body: %v"
+	ContentType            = "Content-Type"
+	TotalCount             = "X-Total-Count"
+	ApplicationJSON        = "application/json"
+	ApplicationProblemJSON = "application/problem+json"
+	MergePatchJSON         = "application/merge-patch+json"
+	JSONPatchJSON          = "application/json-patch+json"
+	NDJSON                 = "application/x-ndjson"
+	CSV                    = "text/csv"
+	ETag                   = "ETag"
+	CacheControl           = "Cache-Control"
+	IfMatch                = "If-Match"
+	IfNoneMatch            = "If-None-Match"
+	XAPIKey                = "X-API-Key"
+	Authorization          = "Authorization"
+	WWWAuthenticate        = "WWW-Authenticate"
+	XRequestID             = "X-Request-ID"
+	InvalidID              = "invalid-id"
+	InvalidSquadNumber     = "invalid-squadnumber"
+	ErrNewRequest          = "failed to create request: %v"
+	ErrMarshal             = "failed to marshal player: %v"
+	ErrUnmarshal           = "failed to unmarshal response body: %v"
 )
 
 // buildSquadNumberPath returns the request path for squad-number routes by
@@ -129,6 +180,149 @@ func TestRequestGETHealthResponseStatusOK(test *testing.T) {
 	assert.Equal(test, http.StatusOK, recorder.Code)
 }
 
+// TestRequestGETHealthReadyResponseChecks tests that a
+// GET request to /health/ready
+// returns a 200 OK status with a passing database, migrations and cache
+// check, each with its latency, and the schema at the latest migration.
+func TestRequestGETHealthReadyResponseChecks(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.ReadyPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var report model.HealthReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Equal(test, model.HealthOK, report.Status)
+	for _, name := range []string{"database", "migrations", "cache"} {
+		check, ok := report.Checks[name]
+		if assert.True(test, ok, "missing %s check", name) {
+			assert.Equal(test, model.HealthOK, check.Status, name)
+			assert.NotEmpty(test, check.Latency, name)
+		}
+	}
+	migrations := report.Checks["migrations"].Details
+	assert.Equal(test, migrations["latest"], migrations["current"])
+	assert.NotZero(test, migrations["latest"])
+}
+
+// TestRequestGETHealthReadyFailingDependencyResponseStatusServiceUnavailable
+// tests that a GET request to /health/ready returns a 503 Service Unavailable
+// status, marking only the check of the dependency at fault as failing, when
+// the database is closed, the schema is behind the embedded migrations, or
+// the cache rejects writes.
+func TestRequestGETHealthReadyFailingDependencyResponseStatusServiceUnavailable(test *testing.T) {
+	cache := health.Cache(persistence.NewInMemoryStore(time.Hour))
+	cases := []struct {
+		name        string
+		checks      func(t *testing.T) []health.Check
+		wantFailing []string
+	}{
+		{"DatabaseClosed", func(t *testing.T) []health.Check {
+			db := data.Connect("file:health-closed?mode=memory&cache=shared", logger.Silent)
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sqlDB.Close(); err != nil {
+				t.Fatal(err)
+			}
+			return []health.Check{health.Database(db), health.Migrations(db), cache}
+		}, []string{"database", "migrations"}},
+		{"MigrationPending", func(t *testing.T) []health.Check {
+			db := data.Connect("file:health-pending?mode=memory&cache=shared", logger.Silent)
+			t.Cleanup(func() {
+				if sqlDB, err := db.DB(); err == nil {
+					_ = sqlDB.Close()
+				}
+			})
+			// Forget the latest migration, as "goose down" would.
+			if err := db.Exec("DELETE FROM goose_db_version WHERE version_id = (SELECT MAX(version_id) FROM goose_db_version)").Error; err != nil {
+				t.Fatal(err)
+			}
+			return []health.Check{health.Database(db), health.Migrations(db), cache}
+		}, []string{"migrations"}},
+		{"CacheUnavailable", func(t *testing.T) []health.Check {
+			store := &MockCacheStore{
+				CacheStore: persistence.NewInMemoryStore(time.Hour),
+				SetFunc:    func(key string, value any, expire time.Duration) error { return ErrGenericError },
+			}
+			return []health.Check{health.Database(testDB), health.Migrations(testDB), health.Cache(store)}
+		}, []string{"cache"}},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			route.RegisterHealthRoutes(router, controller.NewHealthController(tc.checks(t)...))
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, route.ReadyPath, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+
+			router.ServeHTTP(recorder, request)
+
+			var report model.HealthReport
+			if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+				t.Fatalf(ErrUnmarshal, err)
+			}
+			assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			assert.Equal(t, model.HealthUnavailable, report.Status)
+			for name, check := range report.Checks {
+				if slices.Contains(tc.wantFailing, name) {
+					assert.Equal(t, model.HealthFailing, check.Status, name)
+					assert.NotEmpty(t, check.Error, name)
+				} else {
+					assert.Equal(t, model.HealthOK, check.Status, name)
+				}
+			}
+		})
+	}
+}
+
+// TestRequestGETHealthDrainingResponse tests that once the server has started
+// draining, GET requests to /health/ready and /health return a 503 Service
+// Unavailable status, while /health/live still returns 200 OK.
+func TestRequestGETHealthDrainingResponse(test *testing.T) {
+	draining := controller.NewHealthController()
+	router := gin.New()
+	route.RegisterHealthRoutes(router, draining)
+	draining.Drain()
+	cases := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{route.ReadyPath, http.StatusServiceUnavailable, `{"status":"draining"}`},
+		{route.HealthPath, http.StatusServiceUnavailable, `{"status":"draining"}`},
+		{route.LivePath, http.StatusOK, `{"status":"ok"}`},
+	}
+	for _, tc := range cases {
+		test.Run(tc.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
 /* POST /players/ ----------------------------------------------------------- */
 
 // TestRequestPOSTPlayersEmptyBodyResponseStatusBadRequest tests that a
@@ -204,6 +398,67 @@ func TestRequestPOSTPlayersNonExistingResponseStatusCreated(test *testing.T) {
 	assert.Equal(test, http.StatusCreated, recorder.Code)
 }
 
+// TestRequestPOSTPlayersNonExistingPreferResponseBody tests that a
+// POST request to /players with a non-existing player
+// returns the created player with its URL in Location, unless the request
+// prefers a minimal response, and that the URL serves the player.
+func TestRequestPOSTPlayersNonExistingPreferResponseBody(test *testing.T) {
+	cases := []struct {
+		name        string
+		prefer      string
+		wantBody    bool
+		wantApplied string
+	}{
+		{"NoPreferenceResponseBodyPlayer", "", true, ""},
+		{"RepresentationResponseBodyPlayer", "respond-async, return=representation; charset=utf-8", true, "return=representation"},
+		{"MinimalResponseBodyEmpty", `return="minimal"`, false, "return=minimal"},
+		{"UnknownReturnResponseBodyPlayer", "return=everything", true, ""},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			playerService, _ := service.NewMemoryPlayerService(nil)
+			router := setupRouter(controller.NewPlayerController(playerService))
+			body, err := json.Marshal(MakeNonexistentPlayer())
+			if err != nil {
+				t.Fatalf(ErrMarshal, err)
+			}
+			request, err := http.NewRequest(http.MethodPost, route.GetAllPath, bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			request.Header.Set(ContentType, ApplicationJSON)
+			if tc.prefer != "" {
+				request.Header.Set(controller.PreferHeader, tc.prefer)
+			}
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+			created, err := playerService.RetrieveBySquadNumber(context.Background(), MakeNonexistentPlayer().SquadNumber)
+			if err != nil {
+				t.Fatalf("player not created: %v", err)
+			}
+			location := recorder.Header().Get("Location")
+			fetched := serve(t, router, http.MethodGet, location, "", "")
+
+			assert.Equal(t, http.StatusCreated, recorder.Code)
+			assert.Equal(t, route.GetAllPath+"/"+created.ID, location)
+			assert.Equal(t, tc.wantApplied, recorder.Header().Get(controller.PreferenceAppliedHeader))
+			assert.Equal(t, http.StatusOK, fetched.Code)
+			assert.Equal(t, fetched.Header().Get("ETag"), recorder.Header().Get("ETag"))
+			if !tc.wantBody {
+				assert.Empty(t, recorder.Body.String())
+				return
+			}
+			var player model.Player
+			if err := json.Unmarshal(recorder.Body.Bytes(), &player); err != nil {
+				t.Fatalf(ErrUnmarshal, err)
+			}
+			assert.Equal(t, created.ID, player.ID)
+			assert.Equal(t, MakeNonexistentPlayer().LastName, player.LastName)
+		})
+	}
+}
+
 // TestRequestPOSTPlayersValidationResponseStatusUnprocessableEntity tests that a
 // POST request to /players with an invalid payload (missing required fields or
 // out-of-range squadNumber) returns a 422 Unprocessable Entity status.
@@ -262,6 +517,79 @@ func TestRequestPOSTPlayersValidationResponseStatusUnprocessableEntity(test *tes
 	}
 }
 
+// TestRequestPOSTPlayersValidationResponseProblemDetails tests that a
+// POST request to /players with an invalid payload
+// returns an application/problem+json body listing every failed field by its
+// JSON name.
+func TestRequestPOSTPlayersValidationResponseProblemDetails(test *testing.T) {
+
+	// Arrange
+	player := MakeNonexistentPlayer()
+	player.FirstName = ""
+	player.SquadNumber = 100
+	body, err := json.Marshal(player)
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, route.GetAllPath, bytes.NewBuffer(body))
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(ContentType, ApplicationJSON)
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var problem model.ProblemDetails
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Equal(test, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Contains(test, recorder.Header().Get(ContentType), ApplicationProblemJSON)
+	assert.Equal(test, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(test, "Unprocessable Entity", problem.Title)
+	assert.Equal(test, route.GetAllPath, problem.Instance)
+	assert.ElementsMatch(test, []model.FieldError{
+		{Field: "firstName", Rule: "required", Message: "is required"},
+		{Field: "squadNumber", Rule: "max", Message: "must be at most 99"},
+	}, problem.Errors)
+}
+
+// TestRequestPOSTPlayersExistingResponseProblemDetails tests that a
+// POST request to /players with an existing player
+// returns an application/problem+json body with a 409 status.
+func TestRequestPOSTPlayersExistingResponseProblemDetails(test *testing.T) {
+
+	// Arrange
+	body, err := json.Marshal(MakeExistingPlayer())
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, route.GetAllPath, bytes.NewBuffer(body))
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(ContentType, ApplicationJSON)
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var problem model.ProblemDetails
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Contains(test, recorder.Header().Get(ContentType), ApplicationProblemJSON)
+	assert.Equal(test, http.StatusConflict, problem.Status)
+	assert.Equal(test, "Conflict", problem.Title)
+	assert.Empty(test, problem.Errors)
+}
+
 // TestRequestPOSTPlayersTrailingSlashEmptyBodyResponseStatusBadRequest tests that a
 // POST request to /players/ (with trailing slash) and an empty body
 // returns a 400 Bad Request status.
@@ -290,7 +618,7 @@ func TestRequestPOSTPlayersRetrieveErrorResponseStatusInternalServerError(test *
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return model.Player{}, ErrGenericError
 		},
 	}
@@ -322,10 +650,10 @@ func TestRequestPOSTPlayersCreateErrorResponseStatusInternalServerError(test *te
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return model.Player{}, gorm.ErrRecordNotFound
 		},
-		CreateFunc: func(player *model.Player) error {
+		CreateFunc: func(_ context.Context, player *model.Player) error {
 			return ErrDatabaseFailure
 		},
 	}
@@ -357,14 +685,14 @@ func TestRequestPOSTPlayersCreateErrorResponseStatusConflict(test *testing.T) {
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			// Preflight check passes (squad number not found), simulating the
 			// window between the read and the write where a concurrent request
 			// inserts the same squadNumber, causing the subsequent Create to
 			// violate the UNIQUE constraint.
 			return model.Player{}, gorm.ErrRecordNotFound
 		},
-		CreateFunc: func(player *model.Player) error {
+		CreateFunc: func(_ context.Context, player *model.Player) error {
 			return errors.New("UNIQUE constraint failed: players.squad_number")
 		},
 	}
@@ -389,129 +717,605 @@ func TestRequestPOSTPlayersCreateErrorResponseStatusConflict(test *testing.T) {
 	assert.Equal(test, http.StatusConflict, recorder.Code)
 }
 
-/* GET /players/ ------------------------------------------------------------ */
+/* POST /players/bulk ------------------------------------------------------- */
 
-// TestRequestGETPlayersTrailingSlashResponseStatusOK tests that a
-// GET request to /players/ (with trailing slash)
-// returns a 200 OK status.
-func TestRequestGETPlayersTrailingSlashResponseStatusOK(test *testing.T) {
+// makeBulkPlayers returns two players absent from the seeded squad: Lo Celso
+// (squad 27) and a copy of him on squad 28, so bulk tests never touch seeded
+// rows.
+func makeBulkPlayers() []model.Player {
+	first := MakeNonexistentPlayer()
+	second := MakeNonexistentPlayer()
+	second.SquadNumber = 28
+	return []model.Player{first, second}
+}
 
-	// Arrange
-	router := setupRouter(playerController)
+// resetBulkPlayers removes the players of makeBulkPlayers before the test
+// (an earlier POST test may have left Lo Celso behind) and after it.
+func resetBulkPlayers(test *testing.T) {
+	reset := func() {
+		testDB.Unscoped().Where("squadNumber IN ?", []int{27, 28}).Delete(&model.Player{})
+	}
+	reset()
+	test.Cleanup(reset)
+}
+
+// postBulk sends body to /players/bulk with the given mode and Content-Type
+// and decodes the BulkImportResult, if any.
+func postBulk(test *testing.T, router *gin.Engine, mode string, contentType string, body string) (*httptest.ResponseRecorder, model.BulkImportResult) {
 	recorder := httptest.NewRecorder()
-	request, err := http.NewRequest(http.MethodGet, route.GetAllPathTrailingSlash, nil)
+	request, err := http.NewRequest(http.MethodPost, route.BulkPath+"?mode="+mode, strings.NewReader(body))
 	if err != nil {
 		test.Fatalf(ErrNewRequest, err)
 	}
-
-	// Act
+	request.Header.Set(ContentType, contentType)
 	router.ServeHTTP(recorder, request)
+	var result model.BulkImportResult
+	if strings.HasPrefix(recorder.Header().Get(ContentType), ApplicationJSON) {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+			test.Fatalf(ErrUnmarshal, err)
+		}
+	}
+	return recorder, result
+}
 
-	// Assert
-	assert.Equal(test, http.StatusOK, recorder.Code)
+// TestRequestPOSTPlayersBulkFormatsResponseStatusCreated tests that a
+// POST request to /players/bulk with valid, non-conflicting rows in each
+// supported format returns a 201 Created status and inserts every row.
+func TestRequestPOSTPlayersBulkFormatsResponseStatusCreated(test *testing.T) {
+	players := makeBulkPlayers()
+	array, err := json.Marshal(players)
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	var ndjson strings.Builder
+	for _, player := range players {
+		line, err := json.Marshal(player)
+		if err != nil {
+			test.Fatalf(ErrMarshal, err)
+		}
+		ndjson.Write(line)
+		ndjson.WriteString("\n")
+	}
+	csv := "squadNumber,firstName,lastName,dateOfBirth,position,abbrPosition,team,league,starting11\n" +
+		"27,Giovani,Lo Celso,1996-04-09T00:00:00.000Z,Central Midfield,CM,Villarreal CF,La Liga,false\n" +
+		"28,Giovani,Lo Celso,1996-04-09T00:00:00.000Z,Central Midfield,CM,Villarreal CF,La Liga,\n"
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"JSONResponseStatusCreated", ApplicationJSON, string(array)},
+		{"NDJSONResponseStatusCreated", NDJSON, ndjson.String()},
+		{"CSVResponseStatusCreated", CSV, csv},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			resetBulkPlayers(t)
+			recorder, result := postBulk(t, setupRouter(playerController), "atomic", tc.contentType, tc.body)
+			var count int64
+			testDB.Model(&model.Player{}).Where("squadNumber IN ?", []int{27, 28}).Count(&count)
+			assert.Equal(t, http.StatusCreated, recorder.Code)
+			assert.Equal(t, 2, result.Created)
+			assert.Equal(t, int64(2), count)
+			for _, row := range result.Rows {
+				assert.Equal(t, http.StatusCreated, row.Status)
+				assert.NotEmpty(t, row.ID)
+			}
+		})
+	}
 }
 
-// TestRequestGETPlayersResponseStatusOK tests that a
-// GET request to /players
-// returns a 200 OK status.
-func TestRequestGETPlayersResponseStatusOK(test *testing.T) {
+// TestRequestPOSTPlayersBulkAtomicConflictResponseStatusConflict tests that a
+// POST request to /players/bulk in atomic mode where one row reuses a seeded
+// squad number returns a 409 Conflict status and inserts nothing.
+func TestRequestPOSTPlayersBulkAtomicConflictResponseStatusConflict(test *testing.T) {
 
 	// Arrange
-	router := setupRouter(playerController)
-	recorder := httptest.NewRecorder()
-	request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+	resetBulkPlayers(test)
+	players := append(makeBulkPlayers(), MakeExistingPlayer())
+	body, err := json.Marshal(players)
 	if err != nil {
-		test.Fatalf(ErrNewRequest, err)
+		test.Fatalf(ErrMarshal, err)
 	}
 
 	// Act
-	router.ServeHTTP(recorder, request)
+	recorder, result := postBulk(test, setupRouter(playerController), "atomic", ApplicationJSON, string(body))
 
 	// Assert
-	assert.Equal(test, http.StatusOK, recorder.Code)
+	var count int64
+	testDB.Model(&model.Player{}).Where("squadNumber IN ?", []int{27, 28}).Count(&count)
+	assert.Equal(test, http.StatusConflict, recorder.Code)
+	assert.Equal(test, int64(0), count)
+	assert.Equal(test, 0, result.Created)
+	assert.Equal(test, []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusConflict},
+		[]int{result.Rows[0].Status, result.Rows[1].Status, result.Rows[2].Status})
 }
 
-// TestRequestGETPlayersResponsePlayers tests that a
-// GET request to /players
-// returns a collection of Players.
-func TestRequestGETPlayersResponsePlayers(test *testing.T) {
+// TestRequestPOSTPlayersBulkPartialResponseStatusMultiStatus tests that a
+// POST request to /players/bulk in partial mode with a valid row, an invalid
+// row, a row conflicting with the database and a row duplicating another row
+// of the batch returns a 207 Multi-Status status and inserts only the valid row.
+func TestRequestPOSTPlayersBulkPartialResponseStatusMultiStatus(test *testing.T) {
 
 	// Arrange
-	router := setupRouter(playerController)
-	recorder := httptest.NewRecorder()
-	request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+	resetBulkPlayers(test)
+	valid := MakeNonexistentPlayer()
+	invalid := MakeNonexistentPlayer()
+	invalid.SquadNumber = 28
+	invalid.LastName = ""
+	body, err := json.Marshal([]model.Player{valid, invalid, MakeExistingPlayer(), valid})
 	if err != nil {
-		test.Fatalf(ErrNewRequest, err)
+		test.Fatalf(ErrMarshal, err)
 	}
 
 	// Act
-	router.ServeHTTP(recorder, request)
-	var players []model.Player
-	if err := json.Unmarshal(recorder.Body.Bytes(), &players); err != nil {
-		test.Fatalf(ErrUnmarshal, err)
-	}
+	recorder, result := postBulk(test, setupRouter(playerController), "partial", ApplicationJSON, string(body))
 
 	// Assert
-	assert.NotEmpty(test, players)
+	var count int64
+	testDB.Model(&model.Player{}).Where("squadNumber IN ?", []int{27, 28}).Count(&count)
+	assert.Equal(test, http.StatusMultiStatus, recorder.Code)
+	assert.Equal(test, int64(1), count)
+	assert.Equal(test, 1, result.Created)
+	assert.Equal(test, 3, result.Failed)
+	assert.Equal(test, []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusConflict, http.StatusConflict},
+		[]int{result.Rows[0].Status, result.Rows[1].Status, result.Rows[2].Status, result.Rows[3].Status})
+	assert.Equal(test, "lastName", result.Rows[1].Errors[0].Field)
 }
 
-// TestRequestGETPlayersRetrieveErrorResponseStatusInternalServerError tests that a
-// GET request to /players when service.RetrieveAll() returns an unexpected error
+// TestRequestPOSTPlayersBulkInvalidRequestResponseStatus tests that a
+// POST request to /players/bulk whose body, media type or mode is unusable as a
+// whole returns the expected error status code.
+func TestRequestPOSTPlayersBulkInvalidRequestResponseStatus(test *testing.T) {
+	cases := []struct {
+		name        string
+		mode        string
+		contentType string
+		body        string
+		wantCode    int
+	}{
+		{"NotAnArrayResponseStatusBadRequest", "atomic", ApplicationJSON, `{"squadNumber":27}`, http.StatusBadRequest},
+		{"EmptyArrayResponseStatusBadRequest", "atomic", ApplicationJSON, `[]`, http.StatusBadRequest},
+		{"UnknownCSVColumnResponseStatusBadRequest", "atomic", CSV, "shoeSize\n42\n", http.StatusBadRequest},
+		{"UnknownModeResponseStatusBadRequest", "sometimes", ApplicationJSON, `[]`, http.StatusBadRequest},
+		{"XMLResponseStatusUnsupportedMediaType", "atomic", "application/xml", `<players/>`, http.StatusUnsupportedMediaType},
+		{"TooManyRowsResponseStatusRequestEntityTooLarge", "atomic", NDJSON, strings.Repeat("{}\n", 1001), http.StatusRequestEntityTooLarge},
+		{"TooManyJSONRowsResponseStatusRequestEntityTooLarge", "atomic", ApplicationJSON, "[" + strings.Repeat("{},", 1000) + "{}]", http.StatusRequestEntityTooLarge},
+		{"TooLargeBodyResponseStatusRequestEntityTooLarge", "atomic", ApplicationJSON, "[" + strings.Repeat(" ", controller.MaxBulkBytes) + "{}]", http.StatusRequestEntityTooLarge},
+		{"TooLargeCSVBodyResponseStatusRequestEntityTooLarge", "atomic", CSV, "firstName\n" + strings.Repeat("a", controller.MaxBulkBytes) + "\n", http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder, _ := postBulk(t, setupRouter(playerController), tc.mode, tc.contentType, tc.body)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Header().Get(ContentType), ApplicationProblemJSON)
+		})
+	}
+}
+
+// TestRequestPOSTPlayersBulkCreateBatchErrorResponseStatusInternalServerError tests that a
+// POST request to /players/bulk when service.CreateBatch() returns an error
 // returns a 500 Internal Server Error status.
-func TestRequestGETPlayersRetrieveErrorResponseStatusInternalServerError(test *testing.T) {
+func TestRequestPOSTPlayersBulkCreateBatchErrorResponseStatusInternalServerError(test *testing.T) {
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveAllFunc: func() ([]model.Player, error) {
+		CreateBatchFunc: func(_ context.Context, players []model.Player, atomic bool) ([]error, error) {
 			return nil, ErrDatabaseFailure
 		},
 	}
-	controller := controller.NewPlayerController(mockService)
-	router := setupRouter(controller)
-	recorder := httptest.NewRecorder()
-	request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+	body, err := json.Marshal(makeBulkPlayers())
 	if err != nil {
-		test.Fatalf(ErrNewRequest, err)
+		test.Fatalf(ErrMarshal, err)
 	}
 
 	// Act
-	router.ServeHTTP(recorder, request)
+	recorder, _ := postBulk(test, setupRouter(controller.NewPlayerController(mockService)), "atomic", ApplicationJSON, string(body))
 
 	// Assert
 	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
 }
 
-/* GET /players/:id --------------------------------------------------------- */
-
-// TestRequestGETPlayerByIDUnknownResponseStatusNotFound tests that a
-// GET request to /players/:id when the UUID is absent from the database
-// returns a 404 Not Found status.
-func TestRequestGETPlayerByIDUnknownResponseStatusNotFound(test *testing.T) {
+/* GET /players/export ------------------------------------------------------ */
 
-	// Arrange
-	player := MakeUnknownPlayer()
-	router := setupRouter(playerController)
-	recorder := httptest.NewRecorder()
-	request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+player.ID, nil)
-	if err != nil {
-		test.Fatalf(ErrNewRequest, err)
+// TestRequestGETPlayersExportResponsePlayers tests that a
+// GET request to /players/export returns every player, as CSV or NDJSON
+// depending on the format parameter or the Accept header.
+func TestRequestGETPlayersExportResponsePlayers(test *testing.T) {
+	var total int64
+	testDB.Model(&model.Player{}).Count(&total)
+	cases := []struct {
+		name      string
+		query     string
+		accept    string
+		wantType  string
+		wantLines int
+	}{
+		{"FormatCSVResponseCSV", "?format=csv", "", CSV, int(total) + 1},
+		{"FormatNDJSONResponseNDJSON", "?format=ndjson", "", NDJSON, int(total)},
+		{"AcceptCSVResponseCSV", "", CSV, CSV, int(total) + 1},
+		{"DefaultResponseNDJSON", "", "", NDJSON, int(total)},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(playerController)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, route.ExportPath+tc.query, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			if tc.accept != "" {
+				request.Header.Set("Accept", tc.accept)
+			}
+			router.ServeHTTP(recorder, request)
+			lines := strings.Split(strings.TrimRight(recorder.Body.String(), "\n"), "\n")
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Contains(t, recorder.Header().Get(ContentType), tc.wantType)
+			assert.Len(t, lines, tc.wantLines)
+		})
 	}
-
-	// Act
-	router.ServeHTTP(recorder, request)
-
-	// Assert
-	assert.Equal(test, http.StatusNotFound, recorder.Code)
 }
 
-// TestRequestGETPlayerByIDExistingResponseStatusOK tests that a
-// GET request to /players/:id when the UUID exists
-// returns a 200 OK status.
-func TestRequestGETPlayerByIDExistingResponseStatusOK(test *testing.T) {
-
-	// Arrange
-	// Squad #10 = Lionel Messi → UUID v5 derived from "Lionel-Messi" using canonical namespace
-	id := "acc433bf-d505-51fe-831e-45eb44c4d43c"
+// TestRequestGETPlayersExportInvalidResponseStatus tests that a
+// GET request to /players/export with an unknown format or an unsatisfiable
+// Accept header returns the expected error status code.
+func TestRequestGETPlayersExportInvalidResponseStatus(test *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		accept   string
+		wantCode int
+	}{
+		{"UnknownFormatResponseStatusBadRequest", "?format=xlsx", "", http.StatusBadRequest},
+		{"AcceptXMLResponseStatusNotAcceptable", "", "application/xml", http.StatusNotAcceptable},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(playerController)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, route.ExportPath+tc.query, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			if tc.accept != "" {
+				request.Header.Set("Accept", tc.accept)
+			}
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
+
+// TestRequestGETPlayersExportStreamErrorResponseStatusInternalServerError tests that a
+// GET request to /players/export when service.StreamAll() fails before any row is written
+// returns a 500 Internal Server Error status.
+func TestRequestGETPlayersExportStreamErrorResponseStatusInternalServerError(test *testing.T) {
+
+	// Arrange
+	mockService := &MockPlayerService{
+		StreamAllFunc: func(_ context.Context, visit func(player model.Player) error) error {
+			return ErrDatabaseFailure
+		},
+	}
+	router := setupRouter(controller.NewPlayerController(mockService))
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.ExportPath+"?format=csv", nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
+	assert.Contains(test, recorder.Header().Get(ContentType), ApplicationProblemJSON)
+}
+
+/* GET /players/ ------------------------------------------------------------ */
+
+// TestRequestGETPlayersTrailingSlashResponseStatusOK tests that a
+// GET request to /players/ (with trailing slash)
+// returns a 200 OK status.
+func TestRequestGETPlayersTrailingSlashResponseStatusOK(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPathTrailingSlash, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+}
+
+// TestRequestGETPlayersResponseStatusOK tests that a
+// GET request to /players
+// returns a 200 OK status.
+func TestRequestGETPlayersResponseStatusOK(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+}
+
+// TestRequestGETPlayersResponsePlayers tests that a
+// GET request to /players
+// returns a collection of Players.
+func TestRequestGETPlayersResponsePlayers(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+	var players []model.Player
+	if err := json.Unmarshal(recorder.Body.Bytes(), &players); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+
+	// Assert
+	assert.NotEmpty(test, players)
+}
+
+// TestRequestGETPlayersRetrieveErrorResponseStatusInternalServerError tests that a
+// GET request to /players when service.RetrievePage() returns an unexpected error
+// returns a 500 Internal Server Error status.
+func TestRequestGETPlayersRetrieveErrorResponseStatusInternalServerError(test *testing.T) {
+
+	// Arrange
+	mockService := &MockPlayerService{
+		RetrievePageFunc: func(_ context.Context, query service.PlayerQuery) (service.PlayerPage, error) {
+			return service.PlayerPage{}, ErrDatabaseFailure
+		},
+	}
+	controller := controller.NewPlayerController(mockService)
+	router := setupRouter(controller)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
+}
+
+// TestRequestGETPlayersPaginatedResponsePageAndHeaders tests that a
+// GET request to /players?page=2&pageSize=5
+// returns five Players, the total count and prev/next links.
+func TestRequestGETPlayersPaginatedResponsePageAndHeaders(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPath+"?page=2&pageSize=5", nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+	var players []model.Player
+	if err := json.Unmarshal(recorder.Body.Bytes(), &players); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Len(test, players, 5)
+	total, err := strconv.Atoi(recorder.Header().Get(TotalCount))
+	assert.NoError(test, err)
+	assert.Greater(test, total, 10)
+	link := recorder.Header().Get("Link")
+	assert.Contains(test, link, `rel="prev"`)
+	assert.Contains(test, link, `rel="next"`)
+	assert.Contains(test, link, "page=3")
+}
+
+// TestRequestGETPlayersCursorResponseNextPage tests that a
+// GET request to /players?after={id}&pageSize=5
+// returns the Players that follow the cursor in sort order.
+func TestRequestGETPlayersCursorResponseNextPage(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	first := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPath+"?pageSize=5&sort=lastName", nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	router.ServeHTTP(first, request)
+	var firstPage []model.Player
+	if err := json.Unmarshal(first.Body.Bytes(), &firstPage); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	cursor := firstPage[len(firstPage)-1]
+	recorder := httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, route.GetAllPath+"?pageSize=5&sort=lastName&after="+cursor.ID, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+	var players []model.Player
+	if err := json.Unmarshal(recorder.Body.Bytes(), &players); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Len(test, players, 5)
+	for _, player := range players {
+		assert.Greater(test, player.LastName, cursor.LastName)
+	}
+	assert.Contains(test, recorder.Header().Get("Link"), "after="+players[4].ID)
+}
+
+// TestRequestGETPlayersFilteredSortedResponseMatchingPlayers tests that a
+// GET request to /players filtered by league and starting11 and sorted by
+// descending squad number returns only matching Players in that order.
+func TestRequestGETPlayersFilteredSortedResponseMatchingPlayers(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.GetAllPath+"?league=Premier+League&starting11=true&sort=-squadNumber", nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+	var players []model.Player
+	if err := json.Unmarshal(recorder.Body.Bytes(), &players); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.NotEmpty(test, players)
+	assert.Equal(test, strconv.Itoa(len(players)), recorder.Header().Get(TotalCount))
+	for i, player := range players {
+		assert.Equal(test, "Premier League", player.League)
+		assert.True(test, player.Starting11)
+		if i > 0 {
+			assert.Less(test, player.SquadNumber, players[i-1].SquadNumber)
+		}
+	}
+}
+
+// TestRequestGETPlayersInvalidQueryResponseStatusBadRequest tests that a
+// GET request to /players with malformed or unknown query parameters
+// returns a 400 Bad Request status.
+func TestRequestGETPlayersInvalidQueryResponseStatusBadRequest(test *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+	}{
+		{"NonNumericPageResponseStatusBadRequest", "page=abc"},
+		{"PageSizeBelowMinResponseStatusBadRequest", "pageSize=0"},
+		{"PageSizeAboveMaxResponseStatusBadRequest", "pageSize=101"},
+		{"PageWithCursorResponseStatusBadRequest", "page=1&after=" + MakeExistingPlayer().ID},
+		{"UnknownCursorResponseStatusBadRequest", "after=" + MakeUnknownPlayer().ID},
+		{"UnknownSortFieldResponseStatusBadRequest", "sort=shoeSize"},
+		{"InvalidBooleanFilterResponseStatusBadRequest", "starting11=maybe"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(playerController)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, route.GetAllPath+"?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		})
+	}
+}
+
+// TestRequestGETPlayersQueryAfterPOSTResponseFreshPage tests that a
+// GET request to /players with a query string issued after a POST
+// returns fresh data instead of the previously cached page.
+func TestRequestGETPlayersQueryAfterPOSTResponseFreshPage(test *testing.T) {
+
+	// Arrange
+	player := MakeNonexistentPlayer()
+	testDB.Unscoped().Where("squadNumber = ?", player.SquadNumber).Delete(&model.Player{})
+	test.Cleanup(func() {
+		testDB.Unscoped().Where("squadNumber = ?", player.SquadNumber).Delete(&model.Player{})
+	})
+	body, err := json.Marshal(player)
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	router := setupRouter(playerController)
+	path := route.GetAllPath + "?league=La+Liga"
+	before := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	router.ServeHTTP(before, request)
+	postRecorder := httptest.NewRecorder()
+	postRequest, err := http.NewRequest(http.MethodPost, route.GetAllPath, bytes.NewBuffer(body))
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	postRequest.Header.Set(ContentType, ApplicationJSON)
+	router.ServeHTTP(postRecorder, postRequest)
+	recorder := httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusCreated, postRecorder.Code)
+	countBefore, _ := strconv.Atoi(before.Header().Get(TotalCount))
+	countAfter, _ := strconv.Atoi(recorder.Header().Get(TotalCount))
+	assert.Equal(test, countBefore+1, countAfter)
+}
+
+/* GET /players/:id --------------------------------------------------------- */
+
+// TestRequestGETPlayerByIDUnknownResponseStatusNotFound tests that a
+// GET request to /players/:id when the UUID is absent from the database
+// returns a 404 Not Found status.
+func TestRequestGETPlayerByIDUnknownResponseStatusNotFound(test *testing.T) {
+
+	// Arrange
+	player := MakeUnknownPlayer()
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+player.ID, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusNotFound, recorder.Code)
+}
+
+// TestRequestGETPlayerByIDExistingResponseStatusOK tests that a
+// GET request to /players/:id when the UUID exists
+// returns a 200 OK status.
+func TestRequestGETPlayerByIDExistingResponseStatusOK(test *testing.T) {
+
+	// Arrange
+	// Squad #10 = Lionel Messi → UUID v5 derived from "Lionel-Messi" using canonical namespace
+	id := "acc433bf-d505-51fe-831e-45eb44c4d43c"
 	router := setupRouter(playerController)
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+id, nil)
@@ -562,7 +1366,7 @@ func TestRequestGETPlayerByIDRetrieveErrorResponseStatusInternalServerError(test
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveByIDFunc: func(id string) (model.Player, error) {
+		RetrieveByIDFunc: func(_ context.Context, id string) (model.Player, error) {
 			return model.Player{}, ErrGenericError
 		},
 	}
@@ -648,7 +1452,7 @@ func TestRequestGETPlayerBySquadNumberRetrieveErrorResponseStatusInternalServerE
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return model.Player{}, ErrGenericError
 		},
 	}
@@ -667,6 +1471,113 @@ func TestRequestGETPlayerBySquadNumberRetrieveErrorResponseStatusInternalServerE
 	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
 }
 
+// TestRequestGETPlayerBySquadNumberResponseProblemDetails tests that a
+// GET request to /players/squadnumber/:squadnumber for unknown and invalid
+// squad numbers returns an application/problem+json body whose status and
+// instance match the response.
+func TestRequestGETPlayerBySquadNumberResponseProblemDetails(test *testing.T) {
+	cases := []struct {
+		name        string
+		squadNumber string
+		wantCode    int
+	}{
+		{"UnknownResponseProblemNotFound", fmt.Sprintf("%d", MakeUnknownPlayer().SquadNumber), http.StatusNotFound},
+		{"InvalidParamResponseProblemBadRequest", InvalidSquadNumber, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(playerController)
+			recorder := httptest.NewRecorder()
+			path := buildSquadNumberPath(tc.squadNumber)
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			var problem model.ProblemDetails
+			if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+				t.Fatalf(ErrUnmarshal, err)
+			}
+			assert.Contains(t, recorder.Header().Get(ContentType), ApplicationProblemJSON)
+			assert.Equal(t, tc.wantCode, problem.Status)
+			assert.Equal(t, "about:blank", problem.Type)
+			assert.Equal(t, path, problem.Instance)
+			assert.NotEmpty(t, problem.Detail)
+		})
+	}
+}
+
+// TestRequestGETPlayerBySquadNumberRetrieveErrorResponseProblemDetails tests that a
+// GET request to /players/squadnumber/:squadnumber when service.RetrieveBySquadNumber() returns an unexpected error
+// returns a 500 problem body that does not expose the underlying error.
+func TestRequestGETPlayerBySquadNumberRetrieveErrorResponseProblemDetails(test *testing.T) {
+
+	// Arrange
+	mockService := &MockPlayerService{
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
+			return model.Player{}, ErrDatabaseFailure
+		},
+	}
+	controller := controller.NewPlayerController(mockService)
+	router := setupRouter(controller)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, buildSquadNumberPath("10"), nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var problem model.ProblemDetails
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Equal(test, http.StatusInternalServerError, problem.Status)
+	assert.NotContains(test, problem.Detail, ErrDatabaseFailure.Error())
+}
+
+// TestRequestGETPlayerBySquadNumberIfNoneMatchResponseStatusNotModified tests that a
+// GET request to /players/squadnumber/:squadnumber carrying the ETag of a previous
+// response in If-None-Match returns a 304 Not Modified status with no body, both
+// when the response is produced by the handler and when it is replayed from cache.
+func TestRequestGETPlayerBySquadNumberIfNoneMatchResponseStatusNotModified(test *testing.T) {
+
+	// Arrange
+	path := buildSquadNumberPath("10")
+	first := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	setupRouter(playerController).ServeHTTP(first, request)
+	etag := first.Header().Get(ETag)
+	// A fresh router has an empty cache, so its first request reaches the handler.
+	router := setupRouter(playerController)
+
+	for _, name := range []string{"FreshResponseStatusNotModified", "CachedResponseStatusNotModified"} {
+		test.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			request.Header.Set(IfNoneMatch, etag)
+
+			// Act
+			router.ServeHTTP(recorder, request)
+
+			// Assert
+			assert.Equal(t, http.StatusNotModified, recorder.Code)
+			assert.Empty(t, recorder.Body.String())
+			assert.Equal(t, etag, recorder.Header().Get(ETag))
+		})
+	}
+	assert.Equal(test, http.StatusOK, first.Code)
+	assert.NotEmpty(test, etag)
+}
+
 /* PUT /players/squadnumber/:squadnumber ------------------------------------ */
 
 // TestRequestPUTPlayerBySquadNumberEmptyBodyResponseStatusBadRequest tests that a
@@ -877,7 +1788,7 @@ func TestRequestPUTPlayerBySquadNumberRetrieveErrorResponseStatusInternalServerE
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return model.Player{}, ErrGenericError
 		},
 	}
@@ -899,36 +1810,258 @@ func TestRequestPUTPlayerBySquadNumberRetrieveErrorResponseStatusInternalServerE
 	router.ServeHTTP(recorder, request)
 
 	// Assert
-	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
+}
+
+// TestRequestPUTPlayerBySquadNumberUpdateErrorResponseStatusInternalServerError tests that a
+// PUT request to /players/squadnumber/:squadnumber when service.Update() returns an error
+// returns a 500 Internal Server Error status.
+func TestRequestPUTPlayerBySquadNumberUpdateErrorResponseStatusInternalServerError(test *testing.T) {
+
+	// Arrange
+	mockService := &MockPlayerService{
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
+			return MakeExistingPlayer(), nil
+		},
+		UpdateFunc: func(_ context.Context, player *model.Player) error {
+			return ErrDatabaseFailure
+		},
+	}
+	controller := controller.NewPlayerController(mockService)
+	router := setupRouter(controller)
+	player := MakeExistingPlayer()
+	body, err := json.Marshal(player)
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPut, buildSquadNumberPath("23"), bytes.NewBuffer(body))
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(ContentType, ApplicationJSON)
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
+}
+
+// TestRequestPUTPlayerBySquadNumberIfMatch tests that a
+// PUT request to /players/squadnumber/:squadnumber with an If-Match header
+// applies the update only when the header matches the player's current ETag,
+// and returns a new ETag when it does.
+func TestRequestPUTPlayerBySquadNumberIfMatch(test *testing.T) {
+
+	// Arrange
+	path := buildSquadNumberPath("23")
+	router := setupRouter(playerController)
+	getRecorder := httptest.NewRecorder()
+	getRequest, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	router.ServeHTTP(getRecorder, getRequest)
+	etag := getRecorder.Header().Get(ETag)
+	body, err := json.Marshal(MakeUpdatePlayer())
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	test.Cleanup(func() {
+		original := MakeExistingPlayer()
+		if err := testDB.Save(&original).Error; err != nil {
+			test.Logf("cleanup: failed to restore Martínez: %v", err)
+		}
+	})
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPut, path, bytes.NewBuffer(body))
+		if err != nil {
+			test.Fatalf(ErrNewRequest, err)
+		}
+		request.Header.Set(ContentType, ApplicationJSON)
+		request.Header.Set(IfMatch, ifMatch)
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Act
+	current := put(etag)
+	stale := put(etag)
+
+	// Assert
+	assert.Equal(test, http.StatusNoContent, current.Code)
+	assert.NotEmpty(test, current.Header().Get(ETag))
+	assert.NotEqual(test, etag, current.Header().Get(ETag))
+	assert.Equal(test, http.StatusPreconditionFailed, stale.Code)
+}
+
+// TestRequestPUTPlayerBySquadNumberVersionConflictResponseStatusPreconditionFailed tests that a
+// PUT request to /players/squadnumber/:squadnumber when service.Update() reports that
+// another request changed the player in the meantime returns a 412 Precondition Failed status.
+func TestRequestPUTPlayerBySquadNumberVersionConflictResponseStatusPreconditionFailed(test *testing.T) {
+
+	// Arrange
+	mockService := &MockPlayerService{
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
+			return MakeExistingPlayer(), nil
+		},
+		UpdateFunc: func(_ context.Context, player *model.Player) error {
+			return service.ErrVersionConflict
+		},
+	}
+	controller := controller.NewPlayerController(mockService)
+	router := setupRouter(controller)
+	body, err := json.Marshal(MakeUpdatePlayer())
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPut, buildSquadNumberPath("23"), bytes.NewBuffer(body))
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(ContentType, ApplicationJSON)
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusPreconditionFailed, recorder.Code)
 }
 
-// TestRequestPUTPlayerBySquadNumberUpdateErrorResponseStatusInternalServerError tests that a
-// PUT request to /players/squadnumber/:squadnumber when service.Update() returns an error
+/* PATCH /players/squadnumber/:squadnumber ---------------------------------- */
+
+// TestRequestPATCHPlayerBySquadNumber tests that a
+// PATCH request to /players/squadnumber/:squadnumber applies merge patches and
+// JSON Patches to the stored player and returns the expected status code.
+// Martínez (squad 23) is patched and restored after every case.
+func TestRequestPATCHPlayerBySquadNumber(test *testing.T) {
+	cases := []struct {
+		name        string
+		squadNumber string
+		contentType string
+		body        string
+		wantCode    int
+		wantTeam    string
+	}{
+		{"MergePatchResponseStatusNoContent", "23", MergePatchJSON, `{"team":"Club Atlético River Plate"}`, http.StatusNoContent, "Club Atlético River Plate"},
+		{"JSONPatchResponseStatusNoContent", "23", JSONPatchJSON, `[{"op":"test","path":"/team","value":"Aston Villa FC"},{"op":"replace","path":"/team","value":"Club Atlético Independiente"}]`, http.StatusNoContent, "Club Atlético Independiente"},
+		{"JSONPatchFailedTestResponseStatusConflict", "23", JSONPatchJSON, `[{"op":"test","path":"/team","value":"Boca Juniors"}]`, http.StatusConflict, "Aston Villa FC"},
+		{"JSONPatchUnknownOpResponseStatusBadRequest", "23", JSONPatchJSON, `[{"op":"rename","path":"/team"}]`, http.StatusBadRequest, "Aston Villa FC"},
+		{"MalformedResponseStatusBadRequest", "23", MergePatchJSON, `{"team":`, http.StatusBadRequest, "Aston Villa FC"},
+		{"TooLargeResponseStatusRequestEntityTooLarge", "23", MergePatchJSON, `{"team":"` + strings.Repeat("a", controller.MaxPatchBytes) + `"}`, http.StatusRequestEntityTooLarge, "Aston Villa FC"},
+		{"ValidationResponseStatusUnprocessableEntity", "23", MergePatchJSON, `{"squadNumber":100}`, http.StatusUnprocessableEntity, "Aston Villa FC"},
+		{"PlainJSONResponseStatusUnsupportedMediaType", "23", ApplicationJSON, `{"team":"Club Atlético River Plate"}`, http.StatusUnsupportedMediaType, "Aston Villa FC"},
+		{"UnknownResponseStatusNotFound", fmt.Sprintf("%d", MakeUnknownPlayer().SquadNumber), MergePatchJSON, `{"team":"Club Atlético River Plate"}`, http.StatusNotFound, "Aston Villa FC"},
+		{"InvalidParamResponseStatusBadRequest", InvalidSquadNumber, MergePatchJSON, `{"team":"Club Atlético River Plate"}`, http.StatusBadRequest, "Aston Villa FC"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				original := MakeExistingPlayer()
+				if err := testDB.Save(&original).Error; err != nil {
+					t.Logf("cleanup: failed to restore Martínez: %v", err)
+				}
+			})
+			router := setupRouter(playerController)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPatch, buildSquadNumberPath(tc.squadNumber), strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			request.Header.Set(ContentType, tc.contentType)
+			router.ServeHTTP(recorder, request)
+			var stored model.Player
+			testDB.Where("squadNumber = ?", 23).First(&stored)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantTeam, stored.Team)
+			assert.Equal(t, MakeExistingPlayer().ID, stored.ID)
+		})
+	}
+}
+
+// TestRequestPUTAndPATCHPlayerBySquadNumberPreferResponseBody tests that
+// PUT and PATCH requests to /players/squadnumber/:squadnumber
+// return nothing, unless the request prefers the updated player back.
+func TestRequestPUTAndPATCHPlayerBySquadNumberPreferResponseBody(test *testing.T) {
+	put, err := json.Marshal(MakeUpdatePlayer())
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	cases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		prefer      string
+		wantCode    int
+		wantApplied string
+	}{
+		{"PUTNoPreferenceResponseStatusNoContent", http.MethodPut, ApplicationJSON, string(put), "", http.StatusNoContent, ""},
+		{"PUTRepresentationResponseStatusOK", http.MethodPut, ApplicationJSON, string(put), "return=representation", http.StatusOK, "return=representation"},
+		{"PUTMinimalResponseStatusNoContent", http.MethodPut, ApplicationJSON, string(put), "return=minimal", http.StatusNoContent, "return=minimal"},
+		{"PATCHRepresentationResponseStatusOK", http.MethodPatch, MergePatchJSON, `{"team":"Club Atlético River Plate"}`, "return=representation", http.StatusOK, "return=representation"},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			playerService, _ := service.NewMemoryPlayerService([]model.Player{MakeExistingPlayer()})
+			router := setupRouter(controller.NewPlayerController(playerService))
+			request, err := http.NewRequest(tc.method, buildSquadNumberPath("23"), strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			request.Header.Set(ContentType, tc.contentType)
+			request.Header.Set(controller.PreferHeader, tc.prefer)
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+			stored, err := playerService.RetrieveBySquadNumber(context.Background(), 23)
+			if err != nil {
+				t.Fatalf("player not found: %v", err)
+			}
+			fetched := serve(t, router, http.MethodGet, buildSquadNumberPath("23"), "", "")
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantApplied, recorder.Header().Get(controller.PreferenceAppliedHeader))
+			assert.Equal(t, fetched.Header().Get("ETag"), recorder.Header().Get("ETag"))
+			if tc.wantCode == http.StatusNoContent {
+				assert.Empty(t, recorder.Body.String())
+				return
+			}
+			var player model.Player
+			if err := json.Unmarshal(recorder.Body.Bytes(), &player); err != nil {
+				t.Fatalf(ErrUnmarshal, err)
+			}
+			assert.Equal(t, stored.Team, player.Team)
+			assert.Equal(t, stored.ID, player.ID)
+		})
+	}
+}
+
+// TestRequestPATCHPlayerBySquadNumberUpdateErrorResponseStatusInternalServerError tests that a
+// PATCH request to /players/squadnumber/:squadnumber when service.Update() returns an error
 // returns a 500 Internal Server Error status.
-func TestRequestPUTPlayerBySquadNumberUpdateErrorResponseStatusInternalServerError(test *testing.T) {
+func TestRequestPATCHPlayerBySquadNumberUpdateErrorResponseStatusInternalServerError(test *testing.T) {
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return MakeExistingPlayer(), nil
 		},
-		UpdateFunc: func(player *model.Player) error {
+		UpdateFunc: func(_ context.Context, player *model.Player) error {
 			return ErrDatabaseFailure
 		},
 	}
 	controller := controller.NewPlayerController(mockService)
 	router := setupRouter(controller)
-	player := MakeExistingPlayer()
-	body, err := json.Marshal(player)
-	if err != nil {
-		test.Fatalf(ErrMarshal, err)
-	}
 	recorder := httptest.NewRecorder()
-	request, err := http.NewRequest(http.MethodPut, buildSquadNumberPath("23"), bytes.NewBuffer(body))
+	request, err := http.NewRequest(http.MethodPatch, buildSquadNumberPath("23"), strings.NewReader(`{"team":"Club Atlético River Plate"}`))
 	if err != nil {
 		test.Fatalf(ErrNewRequest, err)
 	}
-	request.Header.Set(ContentType, ApplicationJSON)
+	request.Header.Set(ContentType, MergePatchJSON)
 
 	// Act
 	router.ServeHTTP(recorder, request)
@@ -1011,7 +2144,7 @@ func TestRequestDELETEPlayerBySquadNumberRetrieveErrorResponseStatusInternalServ
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return model.Player{}, ErrGenericError
 		},
 	}
@@ -1037,10 +2170,10 @@ func TestRequestDELETEPlayerBySquadNumberDeleteErrorResponseStatusInternalServer
 
 	// Arrange
 	mockService := &MockPlayerService{
-		RetrieveBySquadNumberFunc: func(squadNumber int) (model.Player, error) {
+		RetrieveBySquadNumberFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
 			return MakeExistingPlayer(), nil
 		},
-		DeleteFunc: func(player *model.Player) error {
+		DeleteFunc: func(_ context.Context, player *model.Player) error {
 			return ErrDatabaseFailure
 		},
 	}
@@ -1058,3 +2191,384 @@ func TestRequestDELETEPlayerBySquadNumberDeleteErrorResponseStatusInternalServer
 	// Assert
 	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
 }
+
+// TestRequestDELETEPlayerBySquadNumberStaleIfMatchResponseStatusPreconditionFailed tests that a
+// DELETE request to /players/squadnumber/:squadnumber whose If-Match header does not match
+// the player's current ETag returns a 412 Precondition Failed status and keeps the player.
+func TestRequestDELETEPlayerBySquadNumberStaleIfMatchResponseStatusPreconditionFailed(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodDelete, buildSquadNumberPath("10"), nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(IfMatch, `"stale"`)
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var count int64
+	testDB.Model(&model.Player{}).Where("squadNumber = ?", 10).Count(&count)
+	assert.Equal(test, http.StatusPreconditionFailed, recorder.Code)
+	assert.Equal(test, int64(1), count)
+}
+
+/* Trash: GET /players/trash, POST .../restore, DELETE /admin/players/trash - */
+
+// deleteLoCelso creates Lo Celso (squad 27) from scratch and soft-deletes him
+// through the API, so trash tests start from a known trash entry.  Every row
+// with squad 27, live or deleted, is removed for good after the test.
+func deleteLoCelso(test *testing.T, router *gin.Engine) {
+	reset := func() {
+		testDB.Unscoped().Where("squadNumber = ?", 27).Delete(&model.Player{})
+	}
+	reset()
+	test.Cleanup(reset)
+	body, err := json.Marshal(MakeNonexistentPlayer())
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	for _, request := range []struct{ method, path string }{
+		{http.MethodPost, route.GetAllPath},
+		{http.MethodDelete, buildSquadNumberPath("27")},
+	} {
+		recorder := httptest.NewRecorder()
+		req, err := http.NewRequest(request.method, request.path, bytes.NewBuffer(body))
+		if err != nil {
+			test.Fatalf(ErrNewRequest, err)
+		}
+		req.Header.Set(ContentType, ApplicationJSON)
+		router.ServeHTTP(recorder, req)
+		if recorder.Code >= 300 {
+			test.Fatalf("%s %s: got %d", request.method, request.path, recorder.Code)
+		}
+	}
+}
+
+// TestRequestDELETEPlayerBySquadNumberExistingResponsePlayerInTrash tests that a
+// DELETE request to /players/squadnumber/:squadnumber soft-deletes the player:
+// it disappears from GET but is listed by GET /players/trash with its deletion time.
+func TestRequestDELETEPlayerBySquadNumberExistingResponsePlayerInTrash(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	deleteLoCelso(test, router)
+	getRecorder := httptest.NewRecorder()
+	getRequest, err := http.NewRequest(http.MethodGet, buildSquadNumberPath("27"), nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.TrashPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(getRecorder, getRequest)
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var trash []model.DeletedPlayer
+	if err := json.Unmarshal(recorder.Body.Bytes(), &trash); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Equal(test, http.StatusNotFound, getRecorder.Code)
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	if assert.NotEmpty(test, trash) {
+		assert.Equal(test, 27, trash[0].SquadNumber)
+		assert.False(test, trash[0].DeletedAt.IsZero())
+	}
+}
+
+// TestRequestPOSTPlayerBySquadNumberRestore tests that a
+// POST request to /players/squadnumber/:squadnumber/restore returns the expected
+// status code: 200 when the deleted player can come back, 409 when the squad
+// number has been reassigned in the meantime and 404 when the trash has no such player.
+func TestRequestPOSTPlayerBySquadNumberRestore(test *testing.T) {
+	cases := []struct {
+		name        string
+		squadNumber string
+		reassign    bool
+		wantCode    int
+		wantLive    int64
+	}{
+		{"DeletedResponseStatusOK", "27", false, http.StatusOK, 1},
+		{"ReassignedResponseStatusConflict", "27", true, http.StatusConflict, 1},
+		{"UnknownResponseStatusNotFound", "28", false, http.StatusNotFound, 0},
+		{"InvalidParamResponseStatusBadRequest", InvalidSquadNumber, false, http.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(playerController)
+			deleteLoCelso(t, router)
+			if tc.reassign {
+				replacement := MakeNonexistentPlayer()
+				replacement.ID = MakeUnknownPlayer().ID
+				if err := testDB.Create(&replacement).Error; err != nil {
+					t.Fatalf("failed to reassign squad 27: %v", err)
+				}
+			}
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, buildSquadNumberPath(tc.squadNumber)+"/restore", nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			var live int64
+			testDB.Model(&model.Player{}).Where("squadNumber = ?", 27).Count(&live)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			assert.Equal(t, tc.wantLive, live)
+		})
+	}
+}
+
+// TestRequestDELETEAdminPlayersTrashResponsePurged tests that a
+// DELETE request to /admin/players/trash permanently removes soft-deleted
+// players and reports how many were removed.
+func TestRequestDELETEAdminPlayersTrashResponsePurged(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	deleteLoCelso(test, router)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodDelete, route.PurgePath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var result model.PurgeResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	var remaining int64
+	testDB.Unscoped().Model(&model.Player{}).Where("deletedAt IS NOT NULL").Count(&remaining)
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.GreaterOrEqual(test, result.Purged, int64(1))
+	assert.Equal(test, int64(0), remaining)
+}
+
+// TestRequestTrashServiceErrorResponseStatusInternalServerError tests that the
+// trash endpoints return a 500 Internal Server Error status when the service
+// returns an unexpected error.
+func TestRequestTrashServiceErrorResponseStatusInternalServerError(test *testing.T) {
+	mockService := &MockPlayerService{
+		RetrieveDeletedFunc: func(_ context.Context) ([]model.Player, error) { return nil, ErrDatabaseFailure },
+		RestoreFunc: func(_ context.Context, squadNumber int) (model.Player, error) {
+			return model.Player{}, ErrDatabaseFailure
+		},
+		PurgeFunc: func(_ context.Context) (int64, error) { return 0, ErrDatabaseFailure },
+	}
+	router := setupRouter(controller.NewPlayerController(mockService))
+	cases := []struct{ name, method, path string }{
+		{"GetTrashResponseStatusInternalServerError", http.MethodGet, route.TrashPath},
+		{"RestoreResponseStatusInternalServerError", http.MethodPost, buildSquadNumberPath("27") + "/restore"},
+		{"PurgeResponseStatusInternalServerError", http.MethodDelete, route.PurgePath},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		})
+	}
+}
+
+/* Audit trail -------------------------------------------------------------- */
+
+// putMartinez sends MakeUpdatePlayer as a PUT of squad number 23 with a
+// bearer token for actor and restores the seeded Martínez when the test ends.
+func putMartinez(test *testing.T, router *gin.Engine, actor string) *httptest.ResponseRecorder {
+	test.Helper()
+	body, err := json.Marshal(MakeUpdatePlayer())
+	if err != nil {
+		test.Fatalf(ErrMarshal, err)
+	}
+	test.Cleanup(func() {
+		original := MakeExistingPlayer()
+		if err := testDB.Save(&original).Error; err != nil {
+			test.Logf("cleanup: failed to restore Martínez: %v", err)
+		}
+	})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPut, buildSquadNumberPath("23"), bytes.NewBuffer(body))
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(ContentType, ApplicationJSON)
+	request.Header.Set(Authorization, "Bearer "+signToken(test, jwt.RegisteredClaims{Subject: actor}, auth.RoleEditor))
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// TestRequestGETPlayerHistoryAfterPUTResponseUpdateEvent tests that a
+// GET request to /players/:id/history after a PUT returns an update event
+// carrying the actor and the player before and after the change.
+func TestRequestGETPlayerHistoryAfterPUTResponseUpdateEvent(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	putRecorder := putMartinez(test, router, "coach")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+MakeExistingPlayer().ID+"/history", nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	var events []model.AuditEvent
+	if err := json.Unmarshal(recorder.Body.Bytes(), &events); err != nil {
+		test.Fatalf(ErrUnmarshal, err)
+	}
+	assert.Equal(test, http.StatusNoContent, putRecorder.Code)
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	if assert.NotEmpty(test, events) {
+		last := events[len(events)-1]
+		var before, after model.Player
+		assert.NoError(test, json.Unmarshal(last.Before, &before))
+		assert.NoError(test, json.Unmarshal(last.After, &after))
+		assert.Equal(test, model.AuditUpdate, last.Operation)
+		assert.Equal(test, "coach", last.Actor)
+		assert.Equal(test, MakeExistingPlayer().FirstName, before.FirstName)
+		assert.Equal(test, MakeUpdatePlayer().FirstName, after.FirstName)
+	}
+}
+
+// TestRequestGETPlayerHistory tests that a
+// GET request to /players/:id/history returns 200 for a player in the table,
+// even a seeded one with no events yet, and 404 for an ID it has never held.
+func TestRequestGETPlayerHistory(test *testing.T) {
+	cases := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{"ExistingResponseStatusOK", MakeExistingPlayer().ID, http.StatusOK},
+		{"UnknownResponseStatusNotFound", MakeUnknownPlayer().ID, http.StatusNotFound},
+	}
+	router := setupRouter(playerController)
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+tc.id+"/history", nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
+
+// TestRequestGETAuditTimeRange tests that a
+// GET request to /audit filters events by the from and to query parameters
+// and rejects malformed or inverted ranges with 400 Bad Request.
+func TestRequestGETAuditTimeRange(test *testing.T) {
+	router := setupRouter(playerController)
+	start := time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+	putMartinez(test, router, "coach")
+	future := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	cases := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantEmpty bool
+	}{
+		{"SinceStartResponseEvents", "?from=" + start, http.StatusOK, false},
+		{"FromFutureResponseNoEvents", "?from=" + future, http.StatusOK, true},
+		{"MalformedResponseStatusBadRequest", "?from=yesterday", http.StatusBadRequest, true},
+		{"InvertedResponseStatusBadRequest", "?from=" + future + "&to=" + start, http.StatusBadRequest, true},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, route.AuditPath+tc.query, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if recorder.Code != http.StatusOK {
+				return
+			}
+			var events []model.AuditEvent
+			if err := json.Unmarshal(recorder.Body.Bytes(), &events); err != nil {
+				t.Fatalf(ErrUnmarshal, err)
+			}
+			assert.Equal(t, tc.wantEmpty, len(events) == 0)
+		})
+	}
+}
+
+// TestRequestPUTPlayerBySquadNumberAuditFailureResponseUnchanged tests that a
+// PUT request to /players/squadnumber/:squadnumber whose audit event cannot be
+// written returns 500 and leaves the player unchanged, because the update and
+// its event share a transaction.
+func TestRequestPUTPlayerBySquadNumberAuditFailureResponseUnchanged(test *testing.T) {
+
+	// Arrange
+	router := setupRouter(playerController)
+	if err := testDB.Exec("ALTER TABLE audit_events RENAME TO audit_events_offline").Error; err != nil {
+		test.Fatalf("failed to take the audit table offline: %v", err)
+	}
+	test.Cleanup(func() {
+		if err := testDB.Exec("ALTER TABLE audit_events_offline RENAME TO audit_events").Error; err != nil {
+			test.Logf("cleanup: failed to bring the audit table back: %v", err)
+		}
+	})
+
+	// Act
+	recorder := putMartinez(test, router, "coach")
+
+	// Assert
+	var player model.Player
+	if err := testDB.Where("squadNumber = ?", 23).First(&player).Error; err != nil {
+		test.Fatalf("failed to read Martínez: %v", err)
+	}
+	assert.Equal(test, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(test, MakeExistingPlayer().FirstName, player.FirstName)
+}
+
+// TestRequestAuditServiceErrorResponseStatusInternalServerError tests that the
+// audit endpoints return a 500 Internal Server Error status when the service
+// returns an unexpected error.
+func TestRequestAuditServiceErrorResponseStatusInternalServerError(test *testing.T) {
+	mockService := &MockAuditService{
+		HistoryFunc: func(_ context.Context, _ string) ([]model.AuditEvent, error) { return nil, ErrDatabaseFailure },
+		EventsFunc: func(_ context.Context, _ service.AuditQuery) ([]model.AuditEvent, error) {
+			return nil, ErrDatabaseFailure
+		},
+	}
+	router := gin.New()
+	router.Use(withTestCredentials)
+	route.RegisterAuditRoutes(router, controller.NewAuditController(mockService), authenticate, false, nil)
+	cases := []struct{ name, path string }{
+		{"HistoryResponseStatusInternalServerError", route.PlayersPath + "/" + MakeExistingPlayer().ID + "/history"},
+		{"EventsResponseStatusInternalServerError", route.AuditPath},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatalf(ErrNewRequest, err)
+			}
+			router.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/metrics"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+// scrape returns the text exposition of m, as Prometheus would scrape it.
+func scrape(test *testing.T, m *metrics.Metrics) string {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.MetricsPath, nil)
+	require.NoError(test, err)
+	m.Handler().ServeHTTP(recorder, request)
+	require.Equal(test, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+// get serves a GET request for path through router and returns its status.
+func get(test *testing.T, router *gin.Engine, path string) int {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(test, err)
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+/* GET /metrics ------------------------------------------------------------- */
+
+// TestRequestGETMetricsResponseRequestsByRouteTemplate tests that a
+// GET request to /metrics
+// returns a 200 OK status with request counts and durations labelled by the
+// route template rather than the raw path, and cache hits and misses of the
+// cached routes.
+func TestRequestGETMetricsResponseRequestsByRouteTemplate(test *testing.T) {
+
+	// Arrange
+	appMetrics := metrics.New()
+	router := gin.New()
+	router.Use(appMetrics.Middleware())
+	store := appMetrics.InstrumentCache(persistence.NewInMemoryStore(time.Hour))
+	route.RegisterPlayerRoutes(router, playerController, inMemoryCaching(store), authenticate, false, nil, nil, nil)
+	route.RegisterMetricsRoutes(router, appMetrics.Handler())
+	byID := route.PlayersPath + "/" + MakeExistingPlayer().ID
+	get(test, router, byID)                            // cache miss
+	get(test, router, byID)                            // cache hit
+	get(test, router, route.PlayersPath+"/"+InvalidID) // cache miss (a 404 is not cached)
+	get(test, router, "/nowhere")
+
+	// Act
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, route.MetricsPath, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	body := recorder.Body.String()
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, body, `http_requests_total{method="GET",route="/players/:id",status="200"} 2`)
+	assert.Contains(test, body, `http_requests_total{method="GET",route="/players/:id",status="404"} 1`)
+	assert.Contains(test, body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(test, body, `http_request_duration_seconds_count{method="GET",route="/players/:id",status="200"} 2`)
+	assert.NotContains(test, body, MakeExistingPlayer().ID)
+	assert.Contains(test, body, "cache_hits_total 1")
+	assert.Contains(test, body, "cache_misses_total 2")
+}
+
+// TestMetricsInstrumentCacheCountsEvictions tests that deleting a cached
+// entry counts as an eviction, and deleting an absent one does not.
+func TestMetricsInstrumentCacheCountsEvictions(test *testing.T) {
+	appMetrics := metrics.New()
+	store := appMetrics.InstrumentCache(persistence.NewInMemoryStore(time.Hour))
+	require.NoError(test, store.Set("key", "value", time.Hour))
+
+	assert.NoError(test, store.Delete("key"))
+	assert.ErrorIs(test, store.Delete("key"), persistence.ErrCacheMiss)
+
+	assert.Contains(test, scrape(test, appMetrics), "cache_evictions_total 1")
+}
+
+// TestMetricsInstrumentDBRecordsQueries tests that GORM statements are timed
+// and their rows counted by operation and table, and that failed statements
+// are counted as errors.
+func TestMetricsInstrumentDBRecordsQueries(test *testing.T) {
+	appMetrics := metrics.New()
+	db := data.Connect("file:metrics?mode=memory&cache=shared", logger.Silent)
+	test.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	require.NoError(test, appMetrics.InstrumentDB(db))
+
+	players, err := service.NewPlayerService(db).RetrieveAll(context.Background())
+	require.NoError(test, err)
+	assert.Error(test, db.Exec("SELECT * FROM nowhere").Error)
+
+	body := scrape(test, appMetrics)
+	assert.Contains(test, body, `gorm_query_duration_seconds_count{operation="query",table="players"} 1`)
+	assert.Contains(test, body, `gorm_query_rows_sum{operation="query",table="players"} `+strconv.Itoa(len(players)))
+	assert.Contains(test, body, `gorm_query_errors_total{operation="raw",table=""} 1`)
+	assert.NotContains(test, body, `gorm_query_errors_total{operation="query"`)
+}
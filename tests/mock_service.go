@@ -3,9 +3,14 @@
 package tests
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
 	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
 )
 
 // MockPlayerService is a test double that implements service.PlayerService.
@@ -14,8 +19,8 @@ import (
 //
 // In Go, a type satisfies an interface simply by having the right method
 // signatures — no "implements" keyword or explicit declaration is required.
-// MockPlayerService satisfies service.PlayerService because it defines all six
-// methods with matching signatures.  The compiler verifies this at the call
+// MockPlayerService satisfies service.PlayerService because it defines every
+// method of the interface with a matching signature.  The compiler verifies this at the call
 // site (e.g. controller.NewPlayerController(mockService)).
 //
 // # Opt-in override pattern
@@ -24,64 +29,164 @@ import (
 // sets one of these fields, the method delegates to it; when the field is nil
 // the method returns a safe zero-value default.  This lets each test override
 // only the methods relevant to the scenario being tested, leaving the rest as
-// no-ops, without creating a new type per scenario.
+// no-ops, without creating a new type per scenario.  Every Func field receives
+// the context the method was called with, so a test can check that it is the
+// request's, deadline included, or block until it is cancelled.
 type MockPlayerService struct {
-	CreateFunc                func(player *model.Player) error
-	RetrieveAllFunc           func() ([]model.Player, error)
-	RetrieveByIDFunc          func(id string) (model.Player, error)
-	RetrieveBySquadNumberFunc func(squadNumber int) (model.Player, error)
-	UpdateFunc                func(player *model.Player) error
-	DeleteFunc                func(player *model.Player) error
+	CreateFunc                func(ctx context.Context, player *model.Player) error
+	CreateBatchFunc           func(ctx context.Context, players []model.Player, atomic bool) ([]error, error)
+	RetrieveAllFunc           func(ctx context.Context) ([]model.Player, error)
+	StreamAllFunc             func(ctx context.Context, visit func(player model.Player) error) error
+	RetrievePageFunc          func(ctx context.Context, query service.PlayerQuery) (service.PlayerPage, error)
+	RetrieveByIDFunc          func(ctx context.Context, id string) (model.Player, error)
+	RetrieveBySquadNumberFunc func(ctx context.Context, squadNumber int) (model.Player, error)
+	UpdateFunc                func(ctx context.Context, player *model.Player) error
+	DeleteFunc                func(ctx context.Context, player *model.Player) error
+	RetrieveDeletedFunc       func(ctx context.Context) ([]model.Player, error)
+	RestoreFunc               func(ctx context.Context, squadNumber int) (model.Player, error)
+	PurgeFunc                 func(ctx context.Context) (int64, error)
 }
 
 // Create delegates to CreateFunc if set, otherwise returns nil (no-op success).
-func (m *MockPlayerService) Create(player *model.Player) error {
+func (m *MockPlayerService) Create(ctx context.Context, player *model.Player) error {
 	if m.CreateFunc != nil {
-		return m.CreateFunc(player)
+		return m.CreateFunc(ctx, player)
 	}
 	return nil
 }
 
+// CreateBatch delegates to CreateBatchFunc if set, otherwise reports every row as inserted.
+func (m *MockPlayerService) CreateBatch(ctx context.Context, players []model.Player, atomic bool) ([]error, error) {
+	if m.CreateBatchFunc != nil {
+		return m.CreateBatchFunc(ctx, players, atomic)
+	}
+	return make([]error, len(players)), nil
+}
+
 // RetrieveAll delegates to RetrieveAllFunc if set, otherwise returns an empty slice.
-func (m *MockPlayerService) RetrieveAll() ([]model.Player, error) {
+func (m *MockPlayerService) RetrieveAll(ctx context.Context) ([]model.Player, error) {
 	if m.RetrieveAllFunc != nil {
-		return m.RetrieveAllFunc()
+		return m.RetrieveAllFunc(ctx)
 	}
 	return []model.Player{}, nil
 }
 
+// StreamAll delegates to StreamAllFunc if set, otherwise visits no players.
+func (m *MockPlayerService) StreamAll(ctx context.Context, visit func(player model.Player) error) error {
+	if m.StreamAllFunc != nil {
+		return m.StreamAllFunc(ctx, visit)
+	}
+	return nil
+}
+
+// RetrievePage delegates to RetrievePageFunc if set, otherwise returns an empty page.
+func (m *MockPlayerService) RetrievePage(ctx context.Context, query service.PlayerQuery) (service.PlayerPage, error) {
+	if m.RetrievePageFunc != nil {
+		return m.RetrievePageFunc(ctx, query)
+	}
+	return service.PlayerPage{Players: []model.Player{}}, nil
+}
+
 // RetrieveByID delegates to RetrieveByIDFunc if set, otherwise returns a zero-value Player.
-func (m *MockPlayerService) RetrieveByID(id string) (model.Player, error) {
+func (m *MockPlayerService) RetrieveByID(ctx context.Context, id string) (model.Player, error) {
 	if m.RetrieveByIDFunc != nil {
-		return m.RetrieveByIDFunc(id)
+		return m.RetrieveByIDFunc(ctx, id)
 	}
 	return model.Player{}, nil
 }
 
 // RetrieveBySquadNumber delegates to RetrieveBySquadNumberFunc if set, otherwise returns a zero-value Player.
-func (m *MockPlayerService) RetrieveBySquadNumber(squadNumber int) (model.Player, error) {
+func (m *MockPlayerService) RetrieveBySquadNumber(ctx context.Context, squadNumber int) (model.Player, error) {
 	if m.RetrieveBySquadNumberFunc != nil {
-		return m.RetrieveBySquadNumberFunc(squadNumber)
+		return m.RetrieveBySquadNumberFunc(ctx, squadNumber)
 	}
 	return model.Player{}, nil
 }
 
 // Update delegates to UpdateFunc if set, otherwise returns nil (no-op success).
-func (m *MockPlayerService) Update(player *model.Player) error {
+func (m *MockPlayerService) Update(ctx context.Context, player *model.Player) error {
 	if m.UpdateFunc != nil {
-		return m.UpdateFunc(player)
+		return m.UpdateFunc(ctx, player)
 	}
 	return nil
 }
 
 // Delete delegates to DeleteFunc if set, otherwise returns nil (no-op success).
-func (m *MockPlayerService) Delete(player *model.Player) error {
+func (m *MockPlayerService) Delete(ctx context.Context, player *model.Player) error {
 	if m.DeleteFunc != nil {
-		return m.DeleteFunc(player)
+		return m.DeleteFunc(ctx, player)
 	}
 	return nil
 }
 
+// RetrieveDeleted delegates to RetrieveDeletedFunc if set, otherwise returns an empty slice.
+func (m *MockPlayerService) RetrieveDeleted(ctx context.Context) ([]model.Player, error) {
+	if m.RetrieveDeletedFunc != nil {
+		return m.RetrieveDeletedFunc(ctx)
+	}
+	return []model.Player{}, nil
+}
+
+// Restore delegates to RestoreFunc if set, otherwise returns a zero-value Player.
+func (m *MockPlayerService) Restore(ctx context.Context, squadNumber int) (model.Player, error) {
+	if m.RestoreFunc != nil {
+		return m.RestoreFunc(ctx, squadNumber)
+	}
+	return model.Player{}, nil
+}
+
+// Purge delegates to PurgeFunc if set, otherwise reports that nothing was purged.
+func (m *MockPlayerService) Purge(ctx context.Context) (int64, error) {
+	if m.PurgeFunc != nil {
+		return m.PurgeFunc(ctx)
+	}
+	return 0, nil
+}
+
+// WithPrincipal returns the mock itself: the principal only matters to the
+// audit trail, which mock-assisted tests do not exercise.
+func (m *MockPlayerService) WithPrincipal(principal auth.Principal) service.PlayerService {
+	return m
+}
+
+// MockAuditService is a test double that implements service.AuditService,
+// following the same opt-in override pattern as MockPlayerService.
+type MockAuditService struct {
+	HistoryFunc func(ctx context.Context, playerID string) ([]model.AuditEvent, error)
+	EventsFunc  func(ctx context.Context, query service.AuditQuery) ([]model.AuditEvent, error)
+}
+
+// History delegates to HistoryFunc if set, otherwise returns an empty slice.
+func (m *MockAuditService) History(ctx context.Context, playerID string) ([]model.AuditEvent, error) {
+	if m.HistoryFunc != nil {
+		return m.HistoryFunc(ctx, playerID)
+	}
+	return []model.AuditEvent{}, nil
+}
+
+// Events delegates to EventsFunc if set, otherwise returns an empty slice.
+func (m *MockAuditService) Events(ctx context.Context, query service.AuditQuery) ([]model.AuditEvent, error) {
+	if m.EventsFunc != nil {
+		return m.EventsFunc(ctx, query)
+	}
+	return []model.AuditEvent{}, nil
+}
+
+// MockCacheStore is a test double for persistence.CacheStore.  Methods
+// without a Func field delegate to the embedded CacheStore, which must be set.
+type MockCacheStore struct {
+	persistence.CacheStore
+	SetFunc func(key string, value any, expire time.Duration) error
+}
+
+// Set delegates to SetFunc if set, otherwise to the embedded CacheStore.
+func (m *MockCacheStore) Set(key string, value any, expire time.Duration) error {
+	if m.SetFunc != nil {
+		return m.SetFunc(key, value, expire)
+	}
+	return m.CacheStore.Set(key, value, expire)
+}
+
 // Sentinel errors used by mock-assisted tests to simulate failure conditions
 // that cannot be triggered naturally with a healthy in-memory SQLite database.
 var (
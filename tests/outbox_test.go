@@ -0,0 +1,365 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/outbox"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// outboxBackend opens an empty PlayerService, and the OutboxService holding
+// its changes, on one storage backend.
+type outboxBackend struct {
+	name string
+	open func(test *testing.T) (service.PlayerService, service.OutboxService)
+}
+
+// outboxBackends lists the backends the outbox tests run against.
+func outboxBackends() []outboxBackend {
+	return []outboxBackend{
+		{"Memory", func(test *testing.T) (service.PlayerService, service.OutboxService) {
+			players, _, outboxService := service.NewMemoryServices(nil)
+			return players, outboxService
+		}},
+		{"SQLite", func(test *testing.T) (service.PlayerService, service.OutboxService) {
+			db := openSQLiteDB(test)
+			require.NoError(test, db.Exec("DELETE FROM players").Error)
+			return service.NewAuditedPlayerService(db), service.NewOutboxService(db)
+		}},
+	}
+}
+
+// openSQLiteDB connects to a migrated in-memory SQLite database of the
+// test's own.
+func openSQLiteDB(test *testing.T) *gorm.DB {
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(test.Name())
+	db := data.Connect(fmt.Sprintf("file:%s?mode=memory&cache=shared", name), logger.Silent)
+	test.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// testOutboxConfig publishes up to batchSize messages per round.
+func testOutboxConfig(batchSize int) config.OutboxConfig {
+	return config.OutboxConfig{
+		PollInterval: config.Duration(time.Second),
+		BatchSize:    batchSize,
+	}
+}
+
+// relaying returns a notify function for controller.WithNotify that drains
+// outboxService to publishers at once, so a change is published before its
+// request is answered.
+func relaying(test *testing.T, outboxService service.OutboxService, publishers ...events.Publisher) func() {
+	relay := outbox.NewRelay(outboxService, outbox.Bus(publishers...), testOutboxConfig(100))
+	return func() {
+		_, err := relay.Drain(context.Background())
+		assert.NoError(test, err)
+	}
+}
+
+// recordingPublisher records the messages it publishes, failing those of the
+// players in failing.
+type recordingPublisher struct {
+	mutex     sync.Mutex
+	failing   map[string]bool
+	published []model.OutboxMessage
+}
+
+// Publish records message, unless its player is failing.
+func (p *recordingPublisher) Publish(ctx context.Context, message model.OutboxMessage) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.failing[message.PlayerID] {
+		return errors.New("publisher unavailable")
+	}
+	p.published = append(p.published, message)
+	return nil
+}
+
+// changeRecorder is an events.Publisher recording the event types it is
+// handed.
+type changeRecorder struct {
+	mutex sync.Mutex
+	types []string
+}
+
+// Publish records eventType.
+func (r *changeRecorder) Publish(eventType string, player model.Player) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.types = append(r.types, eventType)
+}
+
+// eventTypes returns the event types of messages.
+func eventTypes(messages []model.OutboxMessage) []string {
+	types := make([]string, len(messages))
+	for i, message := range messages {
+		types[i] = message.EventType
+	}
+	return types
+}
+
+/* Outbox ------------------------------------------------------------------- */
+
+// TestOutboxServiceMutationsWriteMessagesInOrder tests that every mutation,
+// and only a successful one, writes an outbox message, in order.
+func TestOutboxServiceMutationsWriteMessagesInOrder(test *testing.T) {
+	for _, backend := range outboxBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			players, outboxService := backend.open(t)
+			player := makeContractPlayer(10, "Messi", "Paris Saint-Germain")
+			require.NoError(t, players.Create(context.Background(), &player))
+			duplicate := makeContractPlayer(11, "Di María", "Rosario Central")
+			duplicate.SquadNumber = 10
+			require.ErrorIs(t, players.Create(context.Background(), &duplicate), gorm.ErrDuplicatedKey)
+			player.Team = "Inter Miami"
+			require.NoError(t, players.Update(context.Background(), &player))
+			require.NoError(t, players.Delete(context.Background(), &player))
+			_, err := players.Restore(context.Background(), 10)
+			require.NoError(t, err)
+			current, err := players.RetrieveBySquadNumber(context.Background(), 10)
+			require.NoError(t, err)
+			require.NoError(t, players.Delete(context.Background(), &current))
+			_, err = players.Purge(context.Background())
+			require.NoError(t, err)
+
+			messages, err := outboxService.Pending(context.Background(), 100)
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{
+				events.PlayerCreated, events.PlayerUpdated, events.PlayerDeleted,
+				events.PlayerCreated, events.PlayerDeleted, events.PlayerPurged,
+			}, eventTypes(messages))
+			for i, message := range messages {
+				assert.Equal(t, player.ID, message.PlayerID)
+				if i > 0 {
+					assert.Greater(t, message.ID, messages[i-1].ID)
+				}
+			}
+			assert.Equal(t, "Inter Miami", teamOf(t, messages[1].Payload))
+			assert.Equal(t, "Inter Miami", teamOf(t, messages[5].Payload))
+		})
+	}
+}
+
+// TestOutboxRelayRetriesFailedMessagesInPlayerOrder tests that a message
+// whose publication failed stays in the outbox, holding back the later
+// messages of its player but not those of others, and is published again.
+func TestOutboxRelayRetriesFailedMessagesInPlayerOrder(test *testing.T) {
+	for _, backend := range outboxBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			players, outboxService := backend.open(t)
+			messi, diMaria := makeContractPlayer(10, "Messi", "Inter Miami"), makeContractPlayer(11, "Di María", "Rosario Central")
+			require.NoError(t, players.Create(context.Background(), &messi))
+			require.NoError(t, players.Create(context.Background(), &diMaria))
+			messi.Team = "Barcelona"
+			require.NoError(t, players.Update(context.Background(), &messi))
+			publisher := &recordingPublisher{failing: map[string]bool{messi.ID: true}}
+			relay := outbox.NewRelay(outboxService, publisher, testOutboxConfig(2))
+
+			failed, err := relay.Drain(context.Background())
+			require.NoError(t, err)
+			pending, err := outboxService.Pending(context.Background(), 100)
+			require.NoError(t, err)
+			publisher.failing = nil
+			retried, err := relay.Drain(context.Background())
+			require.NoError(t, err)
+			remaining, err := outboxService.Pending(context.Background(), 100)
+			require.NoError(t, err)
+
+			assert.Equal(t, 1, failed)
+			assert.Equal(t, []string{events.PlayerCreated, events.PlayerUpdated}, eventTypes(pending))
+			assert.Equal(t, 2, retried)
+			require.Len(t, publisher.published, 3)
+			assert.Equal(t, diMaria.ID, publisher.published[0].PlayerID)
+			assert.Equal(t, []string{events.PlayerCreated, events.PlayerCreated, events.PlayerUpdated}, eventTypes(publisher.published))
+			assert.Equal(t, "Barcelona", teamOf(t, publisher.published[2].Payload))
+			assert.Empty(t, remaining)
+		})
+	}
+}
+
+// TestOutboxServiceLeaseIsExclusive tests that the relay lease is held by
+// one relay at a time, renewed by its holder, and taken over once expired.
+func TestOutboxServiceLeaseIsExclusive(test *testing.T) {
+	outboxService := service.NewOutboxService(openSQLiteDB(test))
+	now := time.Now()
+
+	var leases atomic.Int32
+	var wait sync.WaitGroup
+	for i := range 4 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			held, err := outboxService.Lease(context.Background(), fmt.Sprint("relay-", i), now, time.Minute)
+			assert.NoError(test, err)
+			if held {
+				leases.Add(1)
+			}
+		}()
+	}
+	wait.Wait()
+	later := now.Add(2 * time.Minute)
+	expired, errExpired := outboxService.Lease(context.Background(), "relay-a", later, time.Minute)
+	renewed, errRenewed := outboxService.Lease(context.Background(), "relay-a", later.Add(30*time.Second), time.Minute)
+	held, errHeld := outboxService.Lease(context.Background(), "relay-b", later.Add(time.Minute), time.Minute)
+
+	require.NoError(test, errors.Join(errExpired, errRenewed, errHeld))
+	assert.Equal(test, int32(1), leases.Load())
+	assert.True(test, expired)
+	assert.True(test, renewed)
+	assert.False(test, held, "relay-a renewed its lease until later+1m30s")
+}
+
+// TestAuditedPlayerServicePublishesCommittedChangesLocally tests that each
+// replica hands the changes it commits, and only those, to its own local
+// publishers, even while another replica holds the relay lease.
+func TestAuditedPlayerServicePublishesCommittedChangesLocally(test *testing.T) {
+	db := openSQLiteDB(test)
+	require.NoError(test, db.Exec("DELETE FROM players").Error)
+	local, other := &changeRecorder{}, &changeRecorder{}
+	replica := service.NewAuditedPlayerService(db, local)
+	_ = service.NewAuditedPlayerService(db, other)
+	outboxService := service.NewOutboxService(db)
+	held, err := outboxService.Lease(context.Background(), "other-replica", time.Now(), time.Minute)
+	require.NoError(test, err)
+	require.True(test, held)
+	relay := outbox.NewRelay(outboxService, &recordingPublisher{}, testOutboxConfig(100))
+
+	player := makeContractPlayer(10, "Messi", "Inter Miami")
+	require.NoError(test, replica.Create(context.Background(), &player))
+	duplicate := makeContractPlayer(11, "Di María", "Rosario Central")
+	duplicate.SquadNumber = 10
+	require.ErrorIs(test, replica.Create(context.Background(), &duplicate), gorm.ErrDuplicatedKey)
+	player.Team = "Barcelona"
+	require.NoError(test, replica.Update(context.Background(), &player))
+	relayed, err := relay.Drain(context.Background())
+
+	require.NoError(test, err)
+	assert.Zero(test, relayed, "the other replica holds the lease")
+	assert.Equal(test, []string{events.PlayerCreated, events.PlayerUpdated}, local.types)
+	assert.Empty(test, other.types)
+}
+
+/* NATS --------------------------------------------------------------------- */
+
+// runNATSServer runs a NATS server in the process, on a free port, until the
+// end of the test, and returns a connection subscribed to every subject.
+func runNATSServer(test *testing.T) (*natsserver.Server, *nats.Subscription) {
+	options := natstest.DefaultTestOptions
+	options.Port = -1
+	server := natstest.RunServer(&options)
+	test.Cleanup(server.Shutdown)
+	return server, subscribeNATS(test, server.ClientURL())
+}
+
+// subscribeNATS connects to the NATS server at url, over the network, and
+// subscribes to every subject until the end of the test.
+func subscribeNATS(test *testing.T, url string) *nats.Subscription {
+	conn, err := nats.Connect(url)
+	require.NoError(test, err)
+	test.Cleanup(conn.Close)
+	subscription, err := conn.SubscribeSync(">")
+	require.NoError(test, err)
+	require.NoError(test, conn.Flush())
+	return subscription
+}
+
+// freeNATSURL returns a nats:// URL on a local port nothing listens on.
+func freeNATSURL(test *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(test, err)
+	defer listener.Close()
+	return "nats://" + listener.Addr().String()
+}
+
+// makeOutboxMessage returns the outbox message of an update of the existing
+// player, with the given ID.
+func makeOutboxMessage(id int64) model.OutboxMessage {
+	return model.OutboxMessage{
+		ID:        id,
+		PlayerID:  MakeExistingPlayer().ID,
+		EventType: events.PlayerUpdated,
+		Payload:   []byte(`{"squadNumber":23}`),
+	}
+}
+
+// TestOutboxNATSPublisherSendsMessage tests that the NATS publisher sends a
+// message to the subject of its event type, with its ID as Nats-Msg-Id, to
+// an external NATS server.
+func TestOutboxNATSPublisherSendsMessage(test *testing.T) {
+	server, subscription := runNATSServer(test)
+	publisher, err := outbox.ConnectNATS(server.ClientURL(), "players")
+	require.NoError(test, err)
+	defer publisher.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = publisher.Publish(ctx, makeOutboxMessage(42))
+
+	require.NoError(test, err)
+	message, err := subscription.NextMsg(5 * time.Second)
+	require.NoError(test, err)
+	assert.Equal(test, "players."+events.PlayerUpdated, message.Subject)
+	assert.Equal(test, "42", message.Header.Get(nats.MsgIdHdr))
+	assert.Equal(test, `{"squadNumber":23}`, string(message.Data))
+}
+
+// TestOutboxEmbeddedNATSStoresMessagesOnce tests that the embedded NATS
+// server takes the messages of the NATS publisher in process, serves them to
+// clients over the network, and keeps a message published twice, as after a
+// lost acknowledgement, once in its stream.
+func TestOutboxEmbeddedNATSStoresMessagesOnce(test *testing.T) {
+	embedded, err := outbox.StartNATS(freeNATSURL(test), test.TempDir(), "players")
+	require.NoError(test, err)
+	test.Cleanup(embedded.Shutdown)
+	subscription := subscribeNATS(test, embedded.ClientURL())
+	publisher, err := embedded.Connect("players")
+	require.NoError(test, err)
+	defer publisher.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, id := range []int64{42, 42, 43} {
+		require.NoError(test, publisher.Publish(ctx, makeOutboxMessage(id)))
+	}
+
+	message, err := subscription.NextMsg(5 * time.Second)
+	require.NoError(test, err)
+	assert.Equal(test, "players."+events.PlayerUpdated, message.Subject)
+	assert.Equal(test, "42", message.Header.Get(nats.MsgIdHdr))
+	conn, err := nats.Connect(embedded.ClientURL())
+	require.NoError(test, err)
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	require.NoError(test, err)
+	stream, err := js.Stream(ctx, "PLAYERS")
+	require.NoError(test, err)
+	info, err := stream.Info(ctx)
+	require.NoError(test, err)
+	assert.Equal(test, uint64(2), info.State.Msgs)
+}
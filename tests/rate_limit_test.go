@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanotaboada/go-samples-gin-restful/auth"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/ratelimit"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The rate limit headers.
+const (
+	RateLimitLimit     = "RateLimit-Limit"
+	RateLimitRemaining = "RateLimit-Remaining"
+	RetryAfter         = "Retry-After"
+)
+
+// setupRateLimitedRouter returns a router serving the player routes with
+// reads limited to 2 requests and writes to 1 request an hour, per client.
+func setupRateLimitedRouter(test *testing.T) *gin.Engine {
+	test.Helper()
+	authenticator, err := auth.New(testAuthConfig())
+	require.NoError(test, err)
+	router := gin.New()
+	route.RegisterPlayerRoutes(router, playerController, inMemoryCaching(persistence.NewInMemoryStore(time.Hour)),
+		controller.Authenticate(authenticator), false,
+		controller.RateLimit(ratelimit.New(config.Rate{Requests: 2, Period: time.Hour})),
+		controller.RateLimit(ratelimit.New(config.Rate{Requests: 1, Period: time.Hour})), nil)
+	return router
+}
+
+// serveFrom sends a request to router from the client address remoteAddr,
+// with the given headers.
+func serveFrom(test *testing.T, router *gin.Engine, method string, path string, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	test.Helper()
+	request, err := http.NewRequest(method, path, nil)
+	require.NoError(test, err)
+	request.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+/* GET /players/squadnumber/:squadnumber (rate limiting) -------------------- */
+
+// TestRequestGETPlayerBySquadNumberOverLimitResponseTooManyRequests tests
+// that GET requests to /players/squadnumber/:squadnumber
+// count down RateLimit-Remaining, and that the request over the limit returns
+// a 429 Too Many Requests problem with a Retry-After header.
+func TestRequestGETPlayerBySquadNumberOverLimitResponseTooManyRequests(test *testing.T) {
+
+	// Arrange
+	router := setupRateLimitedRouter(test)
+	path := buildSquadNumberPath("10")
+
+	// Act
+	first := serveFrom(test, router, http.MethodGet, path, "192.0.2.1:1234", nil)
+	second := serveFrom(test, router, http.MethodGet, path, "192.0.2.1:1234", nil)
+	third := serveFrom(test, router, http.MethodGet, path, "192.0.2.1:1234", nil)
+
+	// Assert
+	assert.Equal(test, http.StatusOK, first.Code)
+	assert.Equal(test, "2", first.Header().Get(RateLimitLimit))
+	assert.Equal(test, "1", first.Header().Get(RateLimitRemaining))
+	assert.Equal(test, http.StatusOK, second.Code)
+	assert.Equal(test, "0", second.Header().Get(RateLimitRemaining))
+	assert.Equal(test, http.StatusTooManyRequests, third.Code)
+	assert.Equal(test, ApplicationProblemJSON, third.Header().Get(ContentType))
+	assert.Equal(test, "0", third.Header().Get(RateLimitRemaining))
+	retryAfter, err := strconv.Atoi(third.Header().Get(RetryAfter))
+	require.NoError(test, err)
+	assert.InDelta(test, 1800, retryAfter, 5) // Half an hour refills one of 2 tokens an hour
+	var problem model.ProblemDetails
+	require.NoError(test, json.Unmarshal(third.Body.Bytes(), &problem))
+	assert.Equal(test, http.StatusTooManyRequests, problem.Status)
+}
+
+// TestRequestGETPlayerBySquadNumberOtherClientResponseOK tests that a
+// GET request to /players/squadnumber/:squadnumber
+// from another address is served once the first one is out of tokens.
+func TestRequestGETPlayerBySquadNumberOtherClientResponseOK(test *testing.T) {
+
+	// Arrange
+	router := setupRateLimitedRouter(test)
+	path := buildSquadNumberPath("10")
+	for range 3 {
+		serveFrom(test, router, http.MethodGet, path, "192.0.2.1:1234", nil)
+	}
+
+	// Act
+	recorder := serveFrom(test, router, http.MethodGet, path, "192.0.2.2:1234", nil)
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Equal(test, "1", recorder.Header().Get(RateLimitRemaining))
+}
+
+/* DELETE /players/squadnumber/:squadnumber (rate limiting) ----------------- */
+
+// TestRequestDELETEPlayerOverWriteLimitResponseByClient tests that the
+// tighter write limit applies to each authenticated client wherever it
+// sends from, and not to other clients sharing its address.
+func TestRequestDELETEPlayerOverWriteLimitResponseByClient(test *testing.T) {
+	router := setupRateLimitedRouter(test)
+	path := buildSquadNumberPath("99") // No such player: allowed requests get 404
+	coach := map[string]string{Authorization: "Bearer " + signToken(test, jwt.RegisteredClaims{Subject: "coach"}, auth.RoleAdmin)}
+	scout := map[string]string{Authorization: "Bearer " + signToken(test, jwt.RegisteredClaims{Subject: "scout"}, auth.RoleAdmin)}
+	cases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"First", "192.0.2.1:1234", coach, http.StatusNotFound},
+		{"SameClientOtherAddress", "192.0.2.2:1234", coach, http.StatusTooManyRequests},
+		{"OtherClientSameAddress", "192.0.2.1:1234", scout, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := serveFrom(t, router, http.MethodDelete, path, tc.remoteAddr, tc.headers)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			assert.Equal(t, "1", recorder.Header().Get(RateLimitLimit))
+		})
+	}
+}
+
+/* Rejected credentials (rate limiting) ------------------------------------ */
+
+// TestRequestDELETEPlayerRejectedCredentialsOverLimitResponseTooManyRequests
+// tests that requests with credentials rejected with 401 are limited per
+// address, before authentication, whatever credentials the address sends
+// next, and that accepted credentials are not counted.
+func TestRequestDELETEPlayerRejectedCredentialsOverLimitResponseTooManyRequests(test *testing.T) {
+	authenticator, err := auth.New(testAuthConfig())
+	require.NoError(test, err)
+	authenticate := controller.LimitFailedAuthentication(ratelimit.New(config.Rate{Requests: 2, Period: time.Hour}), controller.Authenticate(authenticator))
+	router := gin.New()
+	route.RegisterPlayerRoutes(router, playerController, inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, nil)
+	path := buildSquadNumberPath("99") // No such player: authenticated requests get 404
+	valid := map[string]string{XAPIKey: TestAPIKey}
+	wrongKey := map[string]string{XAPIKey: "guessed-key"}
+	wrongToken := map[string]string{Authorization: "Bearer not.a.token"}
+	cases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"ValidFirst", "192.0.2.1:1234", valid, http.StatusNotFound},
+		{"ValidSecond", "192.0.2.1:1234", valid, http.StatusNotFound},
+		{"ValidThird", "192.0.2.1:1234", valid, http.StatusNotFound},
+		{"WrongKey", "192.0.2.1:1234", wrongKey, http.StatusUnauthorized},
+		{"WrongToken", "192.0.2.1:1234", wrongToken, http.StatusUnauthorized},
+		{"WrongKeyOverLimit", "192.0.2.1:1234", wrongKey, http.StatusTooManyRequests},
+		{"ValidOverLimit", "192.0.2.1:1234", valid, http.StatusTooManyRequests},
+		{"WrongKeyOtherAddress", "192.0.2.2:1234", wrongKey, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := serveFrom(t, router, http.MethodDelete, path, tc.remoteAddr, tc.headers)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			if tc.wantStatus == http.StatusTooManyRequests {
+				assert.NotEmpty(t, recorder.Header().Get(RetryAfter))
+			}
+		})
+	}
+}
+
+// TestRateLimitNilLimiterAllowsEverything tests that an off rate yields a
+// nil Limiter that allows every request.
+func TestRateLimitNilLimiterAllowsEverything(test *testing.T) {
+	limiter := ratelimit.New(config.Rate{})
+
+	decision := limiter.Allow("client")
+
+	assert.Nil(test, limiter)
+	assert.True(test, decision.Allowed)
+}
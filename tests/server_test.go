@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer runs handler through server.Run on a free local port with the
+// given readiness delay and grace period.  It returns the server's base URL, the function that
+// triggers the shutdown (as a signal would), a channel closed when the
+// draining hook runs and the channel Run's result is sent on.
+func startServer(test *testing.T, handler http.Handler, readinessDelay, gracePeriod time.Duration) (string, context.CancelFunc, <-chan struct{}, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(test, err)
+	ctx, shutdown := context.WithCancel(context.Background())
+	test.Cleanup(shutdown)
+	drained := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		httpServer := server.New(config.Default().Server, handler)
+		result <- server.Run(ctx, httpServer, listener, readinessDelay, gracePeriod, func() { close(drained) })
+	}()
+	return "http://" + listener.Addr().String(), shutdown, drained, result
+}
+
+// TestServerShutdownCompletesInFlightRequest tests that a request in progress
+// when the shutdown starts still receives its response, that the draining
+// hook runs first, and that Run then returns nil.
+func TestServerShutdownCompletesInFlightRequest(test *testing.T) {
+
+	// Arrange
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-release
+		writer.WriteHeader(http.StatusNoContent)
+	})
+	baseURL, shutdown, drained, result := startServer(test, handler, 0, 5*time.Second)
+	response := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(baseURL)
+		if err != nil {
+			test.Errorf("in-flight request failed: %v", err)
+			close(response)
+			return
+		}
+		response <- resp
+	}()
+	<-started
+
+	// Act
+	shutdown()
+	<-drained
+	close(release)
+
+	// Assert
+	resp, ok := <-response
+	require.True(test, ok)
+	defer resp.Body.Close()
+	assert.Equal(test, http.StatusNoContent, resp.StatusCode)
+	assert.NoError(test, <-result)
+	_, err := http.Get(baseURL)
+	assert.Error(test, err, "the listener must be closed after shutdown")
+}
+
+// TestServerShutdownGracePeriodExceededReturnsError tests that Run gives up on
+// requests still running when the grace period ends and reports it.
+func TestServerShutdownGracePeriodExceededReturnsError(test *testing.T) {
+
+	// Arrange
+	started := make(chan struct{})
+	release := make(chan struct{})
+	test.Cleanup(func() { close(release) })
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-release
+	})
+	baseURL, shutdown, _, result := startServer(test, handler, 0, 50*time.Millisecond)
+	go func() {
+		if resp, err := http.Get(baseURL); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	// Act
+	shutdown()
+
+	// Assert
+	err := <-result
+	assert.True(test, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
+
+// TestServerShutdownReadinessDelayKeepsServing tests that requests arriving
+// after the draining hook has run are still served until the readiness delay
+// ends, and that Run then shuts down cleanly.
+func TestServerShutdownReadinessDelayKeepsServing(test *testing.T) {
+
+	// Arrange
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusNoContent)
+	})
+	baseURL, shutdown, drained, result := startServer(test, handler, 500*time.Millisecond, 5*time.Second)
+
+	// Act
+	shutdown()
+	<-drained
+	resp, err := http.Get(baseURL)
+
+	// Assert
+	require.NoError(test, err, "the listener must stay open during the readiness delay")
+	defer resp.Body.Close()
+	assert.Equal(test, http.StatusNoContent, resp.StatusCode)
+	assert.NoError(test, <-result)
+	_, err = http.Get(baseURL)
+	assert.Error(test, err, "the listener must be closed after shutdown")
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/nanotaboada/go-samples-gin-restful/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm/logger"
+)
+
+// The W3C trace context header, and the caller's span it carries as an
+// upstream service would send it.
+const (
+	Traceparent       = "traceparent"
+	CallerTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	CallerTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	CallerSpanID      = "00f067aa0ba902b7"
+)
+
+// setupTracedRouter returns a router serving the player routes from a
+// database of its own, with every request and statement traced to the
+// returned recorder.
+func setupTracedRouter(test *testing.T) (*gin.Engine, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	appTracing := tracing.New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), "test")
+	db := data.Connect("file:tracing?mode=memory&cache=shared", logger.Silent)
+	test.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	require.NoError(test, appTracing.InstrumentDB(db))
+
+	router := gin.New()
+	router.Use(appTracing.Middleware()...)
+	route.RegisterPlayerRoutes(router, controller.NewPlayerController(service.NewPlayerService(db)), inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, nil)
+	return router, recorder
+}
+
+// serverSpan returns the single server span among spans.
+func serverSpan(test *testing.T, spans []sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	var found []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.SpanKind() == trace.SpanKindServer {
+			found = append(found, span)
+		}
+	}
+	require.Len(test, found, 1)
+	return found[0]
+}
+
+// attributeValue returns the value of the attribute key of span, or "".
+func attributeValue(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+/* GET /players/:id --------------------------------------------------------- */
+
+// TestRequestGETPlayerByIDTraceparentResponseSpansContinueCallerTrace tests
+// that a GET request to /players/:id
+// carrying a traceparent header is traced as a child of the caller's span,
+// with its database statements as children of the request span.
+func TestRequestGETPlayerByIDTraceparentResponseSpansContinueCallerTrace(test *testing.T) {
+
+	// Arrange
+	router, spans := setupTracedRouter(test)
+	request, err := http.NewRequest(http.MethodGet, route.PlayersPath+"/"+MakeExistingPlayer().ID, nil)
+	if err != nil {
+		test.Fatalf(ErrNewRequest, err)
+	}
+	request.Header.Set(Traceparent, CallerTraceparent)
+	recorder := httptest.NewRecorder()
+
+	// Act
+	router.ServeHTTP(recorder, request)
+
+	// Assert
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	ended := spans.Ended()
+	server := serverSpan(test, ended)
+	assert.Equal(test, "GET "+route.GetByIDPath, server.Name())
+	assert.Equal(test, CallerTraceID, server.SpanContext().TraceID().String())
+	assert.Equal(test, CallerSpanID, server.Parent().SpanID().String())
+	assert.True(test, server.Parent().IsRemote())
+	require.Len(test, ended, 2)
+	for _, span := range ended {
+		if span.SpanKind() == trace.SpanKindClient {
+			assert.Equal(test, "gorm.query", span.Name())
+			assert.Equal(test, server.SpanContext().SpanID(), span.Parent().SpanID())
+			assert.Equal(test, "players", attributeValue(span, "db.collection.name"))
+			assert.Equal(test, "sqlite", attributeValue(span, "db.system.name"))
+		}
+	}
+}
+
+// TestRequestGETPlayerByIDRepeatedResponseSpansRecordCacheStatus tests that
+// repeated GET requests to /players/:id
+// record a cache miss, then a cache hit that runs no statement.
+func TestRequestGETPlayerByIDRepeatedResponseSpansRecordCacheStatus(test *testing.T) {
+
+	// Arrange
+	router, spans := setupTracedRouter(test)
+	path := route.PlayersPath + "/" + MakeExistingPlayer().ID
+
+	// Act
+	first := get(test, router, path)
+	missSpans := spans.Ended()
+	second := get(test, router, path)
+	hitSpans := spans.Ended()[len(missSpans):]
+
+	// Assert
+	assert.Equal(test, http.StatusOK, first)
+	assert.Equal(test, http.StatusOK, second)
+	assert.Equal(test, route.CacheMiss, attributeValue(serverSpan(test, missSpans), tracing.CacheStatusAttribute))
+	assert.Equal(test, route.CacheHit, attributeValue(serverSpan(test, hitSpans), tracing.CacheStatusAttribute))
+	assert.Len(test, hitSpans, 1)
+}
+
+// TestTracingSetupStdoutExportsSpans tests that the stdout exporter writes
+// the spans once the tracing is shut down, with no collector running.
+func TestTracingSetupStdoutExportsSpans(test *testing.T) {
+	var output bytes.Buffer
+	appTracing, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: "stdout", ServiceName: "test"}, &output)
+	require.NoError(test, err)
+	router := gin.New()
+	router.Use(appTracing.Middleware()...)
+	router.GET("/ping", func(context *gin.Context) { context.Status(http.StatusNoContent) })
+
+	get(test, router, "/ping")
+	require.NoError(test, appTracing.Shutdown(context.Background()))
+
+	assert.Contains(test, output.String(), `"Name":"GET /ping"`)
+}
@@ -0,0 +1,396 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cache/persistence"
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/controller"
+	"github.com/nanotaboada/go-samples-gin-restful/data"
+	"github.com/nanotaboada/go-samples-gin-restful/events"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+	"github.com/nanotaboada/go-samples-gin-restful/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+// webhookBackend opens an empty WebhookService on one storage backend.
+type webhookBackend struct {
+	name string
+	open func(test *testing.T) service.WebhookService
+}
+
+// webhookBackends lists the backends the webhook tests run against.
+func webhookBackends() []webhookBackend {
+	return []webhookBackend{
+		{"Memory", func(test *testing.T) service.WebhookService {
+			return service.NewMemoryWebhookService()
+		}},
+		{"SQLite", func(test *testing.T) service.WebhookService {
+			name := strings.NewReplacer("/", "_", " ", "_").Replace(test.Name())
+			db := data.Connect(fmt.Sprintf("file:%s?mode=memory&cache=shared", name), logger.Silent)
+			test.Cleanup(func() {
+				if sqlDB, err := db.DB(); err == nil {
+					_ = sqlDB.Close()
+				}
+			})
+			return service.NewWebhookService(db)
+		}},
+	}
+}
+
+// testWebhooksConfig retries after 1ms, doubling up to 4ms, so tests can
+// wait for retries to come due.
+func testWebhooksConfig(maxAttempts int) config.WebhooksConfig {
+	return config.WebhooksConfig{
+		MaxAttempts:  maxAttempts,
+		Backoff:      config.Duration(time.Millisecond),
+		MaxBackoff:   config.Duration(4 * time.Millisecond),
+		Timeout:      config.Duration(5 * time.Second),
+		PollInterval: config.Duration(time.Second),
+	}
+}
+
+// receivedWebhook is a request received by a webhookReceiver.
+type receivedWebhook struct {
+	Header http.Header
+	Body   []byte
+}
+
+// webhookReceiver is a local webhook receiver answering with the statuses it
+// is given in turn, then with its last one.
+type webhookReceiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	received []receivedWebhook
+}
+
+// newWebhookReceiver starts a webhookReceiver answering with statuses.
+func newWebhookReceiver(test *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		receiver.mutex.Lock()
+		defer receiver.mutex.Unlock()
+		receiver.received = append(receiver.received, receivedWebhook{Header: request.Header.Clone(), Body: body})
+		status := receiver.statuses[0]
+		if len(receiver.statuses) > 1 {
+			receiver.statuses = receiver.statuses[1:]
+		}
+		writer.WriteHeader(status)
+	}))
+	test.Cleanup(receiver.Close)
+	return receiver
+}
+
+// answer makes the receiver answer with status from now on.
+func (r *webhookReceiver) answer(status int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.statuses = []int{status}
+}
+
+// requests returns the requests received so far.
+func (r *webhookReceiver) requests() []receivedWebhook {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]receivedWebhook{}, r.received...)
+}
+
+// setupWebhookRouter returns a router serving the webhook administration
+// routes, and the player routes of a controller on an empty in-memory
+// backend, whose changes are relayed to dispatcher.
+func setupWebhookRouter(test *testing.T, webhookService service.WebhookService, dispatcher *webhook.Dispatcher) *gin.Engine {
+	playerService, _, outboxService := service.NewMemoryServices(nil)
+	router := gin.New()
+	router.Use(withTestCredentials)
+	playerController := controller.NewPlayerController(playerService).WithNotify(relaying(test, outboxService, dispatcher))
+	route.RegisterPlayerRoutes(router, playerController, inMemoryCaching(persistence.NewInMemoryStore(time.Hour)), authenticate, false, nil, nil, nil)
+	route.RegisterWebhookRoutes(router, controller.NewWebhookController(webhookService, dispatcher), authenticate)
+	return router
+}
+
+// subscribe creates a subscription to url for eventTypes through router, and
+// returns it with its secret.
+func subscribe(test *testing.T, router *gin.Engine, url string, eventTypes ...string) model.WebhookSubscription {
+	test.Helper()
+	body, err := json.Marshal(model.WebhookSubscription{URL: url, Events: eventTypes})
+	require.NoError(test, err)
+	recorder := serve(test, router, http.MethodPost, route.WebhooksPath, ApplicationJSON, string(body))
+	require.Equal(test, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var subscription model.WebhookSubscription
+	require.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &subscription))
+	return subscription
+}
+
+// postPlayer creates the nonexistent player through router.
+func postPlayer(test *testing.T, router *gin.Engine) {
+	test.Helper()
+	body, err := json.Marshal(MakeNonexistentPlayer())
+	require.NoError(test, err)
+	recorder := serve(test, router, http.MethodPost, route.GetAllPath, ApplicationJSON, string(body))
+	require.Equal(test, http.StatusCreated, recorder.Code, recorder.Body.String())
+}
+
+// deliverUntil calls dispatcher.DeliverDue until done holds, waiting for
+// retries to come due in between.
+func deliverUntil(test *testing.T, dispatcher *webhook.Dispatcher, done func() bool) {
+	test.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		require.True(test, time.Now().Before(deadline), "deliveries did not settle")
+		_, err := dispatcher.DeliverDue(context.Background())
+		require.NoError(test, err)
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+/* /admin/webhooks ---------------------------------------------------------- */
+
+// TestRequestWebhookSubscriptionsResponseByLifecycle tests that a
+// subscription created with a POST request to /admin/webhooks
+// is returned with its secret once, listed and retrieved without it, and
+// removed with a DELETE request.
+func TestRequestWebhookSubscriptionsResponseByLifecycle(test *testing.T) {
+	for _, backend := range webhookBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			webhookService := backend.open(t)
+			router := setupWebhookRouter(t, webhookService, webhook.NewDispatcher(webhookService, testWebhooksConfig(1)))
+
+			created := subscribe(t, router, "https://example.com/hooks", events.PlayerDeleted)
+			listed := serve(t, router, http.MethodGet, route.WebhooksPath, "", "")
+			retrieved := serve(t, router, http.MethodGet, route.WebhooksPath+"/"+created.ID, "", "")
+			deleted := serve(t, router, http.MethodDelete, route.WebhooksPath+"/"+created.ID, "", "")
+			missing := serve(t, router, http.MethodGet, route.WebhooksPath+"/"+created.ID, "", "")
+
+			assert.NotEmpty(t, created.ID)
+			assert.Len(t, created.Secret, 64)
+			assert.Equal(t, []string{events.PlayerDeleted}, created.Events)
+			var subscriptions []model.WebhookSubscription
+			require.NoError(t, json.Unmarshal(listed.Body.Bytes(), &subscriptions))
+			require.Len(t, subscriptions, 1)
+			assert.Equal(t, created.ID, subscriptions[0].ID)
+			assert.Empty(t, subscriptions[0].Secret)
+			assert.Equal(t, http.StatusOK, retrieved.Code)
+			assert.NotContains(t, retrieved.Body.String(), created.Secret)
+			assert.Equal(t, http.StatusNoContent, deleted.Code)
+			assert.Equal(t, http.StatusNotFound, missing.Code)
+			assert.Equal(t, ApplicationProblemJSON, missing.Header().Get(ContentType))
+		})
+	}
+}
+
+// TestRequestPOSTWebhooksInvalidResponseStatus tests that a POST request to
+// /admin/webhooks
+// with malformed JSON returns 400, and one with an invalid URL or event type
+// returns 422 with the same field errors as the player routes.
+func TestRequestPOSTWebhooksInvalidResponseStatus(test *testing.T) {
+	webhookService := service.NewMemoryWebhookService()
+	router := setupWebhookRouter(test, webhookService, webhook.NewDispatcher(webhookService, testWebhooksConfig(1)))
+	cases := []struct {
+		name       string
+		body       string
+		want       int
+		wantErrors []model.FieldError
+	}{
+		{"MalformedJSON", `{"url":`, http.StatusBadRequest, nil},
+		{"MissingURL", `{"events":[]}`, http.StatusUnprocessableEntity, []model.FieldError{
+			{Field: "url", Rule: "required", Message: "is required"},
+		}},
+		{"NotHTTPURL", `{"url":"ftp://example.com/hooks"}`, http.StatusUnprocessableEntity, []model.FieldError{
+			{Field: "url", Rule: "http_url", Message: "must be an http or https URL"},
+		}},
+		{"UnknownEvent", `{"url":"https://example.com/hooks","events":["player.renamed"]}`, http.StatusUnprocessableEntity, []model.FieldError{
+			{Field: "events[0]", Rule: "oneof", Message: "must be one of player.created player.updated player.deleted player.purged"},
+		}},
+	}
+	for _, tc := range cases {
+		test.Run(tc.name, func(t *testing.T) {
+			recorder := serve(t, router, http.MethodPost, route.WebhooksPath, ApplicationJSON, tc.body)
+
+			var problem model.ProblemDetails
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+			assert.Equal(t, tc.want, recorder.Code)
+			assert.Equal(t, ApplicationProblemJSON, recorder.Header().Get(ContentType))
+			assert.Equal(t, tc.wantErrors, problem.Errors)
+		})
+	}
+}
+
+/* Delivery ----------------------------------------------------------------- */
+
+// TestWebhookDispatcherDeliversSignedEvent tests that a player change is
+// POSTed to the subscriptions wanting its event type, signed with their
+// secret.
+func TestWebhookDispatcherDeliversSignedEvent(test *testing.T) {
+	for _, backend := range webhookBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			webhookService := backend.open(t)
+			dispatcher := webhook.NewDispatcher(webhookService, testWebhooksConfig(1))
+			router := setupWebhookRouter(t, webhookService, dispatcher)
+			wanted := newWebhookReceiver(t, http.StatusNoContent)
+			unwanted := newWebhookReceiver(t, http.StatusNoContent)
+			subscription := subscribe(t, router, wanted.URL, events.PlayerCreated)
+			subscribe(t, router, unwanted.URL, events.PlayerDeleted)
+
+			postPlayer(t, router)
+			attempted, err := dispatcher.DeliverDue(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, attempted)
+			assert.Empty(t, unwanted.requests())
+			requests := wanted.requests()
+			require.Len(t, requests, 1)
+			header, body := requests[0].Header, requests[0].Body
+			assert.Equal(t, ApplicationJSON, header.Get(ContentType))
+			assert.Equal(t, events.PlayerCreated, header.Get(webhook.EventHeader))
+			assert.NotEmpty(t, header.Get(webhook.DeliveryHeader))
+			assert.Equal(t, webhook.Sign(subscription.Secret, header.Get(webhook.TimestampHeader), body), header.Get(webhook.SignatureHeader))
+			assert.NotEqual(t, webhook.Sign("another secret", header.Get(webhook.TimestampHeader), body), header.Get(webhook.SignatureHeader))
+			var event model.WebhookEvent
+			require.NoError(t, json.Unmarshal(body, &event))
+			assert.Equal(t, events.PlayerCreated, event.Type)
+			assert.Equal(t, "Giovani", event.Player.FirstName)
+		})
+	}
+}
+
+// TestWebhookDispatcherRetriesFailedDelivery tests that a delivery the
+// receiver fails is retried, with the same delivery ID and body, until it
+// succeeds.
+func TestWebhookDispatcherRetriesFailedDelivery(test *testing.T) {
+	for _, backend := range webhookBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			webhookService := backend.open(t)
+			dispatcher := webhook.NewDispatcher(webhookService, testWebhooksConfig(5))
+			router := setupWebhookRouter(t, webhookService, dispatcher)
+			receiver := newWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK)
+			subscribe(t, router, receiver.URL)
+
+			postPlayer(t, router)
+			deliverUntil(t, dispatcher, func() bool { return len(receiver.requests()) == 3 })
+
+			requests := receiver.requests()
+			for _, request := range requests[1:] {
+				assert.Equal(t, requests[0].Header.Get(webhook.DeliveryHeader), request.Header.Get(webhook.DeliveryHeader))
+				assert.Equal(t, requests[0].Body, request.Body)
+			}
+			attempted, err := dispatcher.DeliverDue(context.Background())
+			require.NoError(t, err)
+			assert.Zero(t, attempted)
+			deadLetters, err := webhookService.DeadLetters(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, deadLetters)
+		})
+	}
+}
+
+// TestRequestWebhookDeadLettersResponseRedelivered tests that a delivery
+// failing MaxAttempts times is listed by a GET request to
+// /admin/webhooks/dead-letters, and delivered again after a POST request to
+// /admin/webhooks/deliveries/{id}/redeliver.
+func TestRequestWebhookDeadLettersResponseRedelivered(test *testing.T) {
+	for _, backend := range webhookBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			webhookService := backend.open(t)
+			dispatcher := webhook.NewDispatcher(webhookService, testWebhooksConfig(3))
+			router := setupWebhookRouter(t, webhookService, dispatcher)
+			receiver := newWebhookReceiver(t, http.StatusBadGateway)
+			subscribe(t, router, receiver.URL)
+			postPlayer(t, router)
+			deliverUntil(t, dispatcher, func() bool {
+				deadLetters, err := webhookService.DeadLetters(context.Background())
+				require.NoError(t, err)
+				return len(deadLetters) == 1
+			})
+
+			listed := serve(t, router, http.MethodGet, route.DeadLettersPath, "", "")
+			var deadLetters []model.WebhookDelivery
+			require.NoError(t, json.Unmarshal(listed.Body.Bytes(), &deadLetters))
+			require.Len(t, deadLetters, 1)
+			assert.Equal(t, model.DeliveryDead, deadLetters[0].State)
+			assert.Equal(t, 3, deadLetters[0].Attempts)
+			assert.Equal(t, http.StatusBadGateway, deadLetters[0].LastStatus)
+			assert.Len(t, receiver.requests(), 3)
+
+			receiver.answer(http.StatusOK)
+			id := strconv.FormatInt(deadLetters[0].ID, 10)
+			redelivered := serve(t, router, http.MethodPost, route.WebhooksPath+"/deliveries/"+id+"/redeliver", "", "")
+			unknown := serve(t, router, http.MethodPost, route.WebhooksPath+"/deliveries/9999/redeliver", "", "")
+			attempted, err := dispatcher.DeliverDue(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusAccepted, redelivered.Code)
+			assert.Equal(t, http.StatusNotFound, unknown.Code)
+			assert.Equal(t, 1, attempted)
+			assert.Len(t, receiver.requests(), 4)
+			remaining, err := webhookService.DeadLetters(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, remaining)
+		})
+	}
+}
+
+// TestWebhookServiceClaimIsExclusive tests that a due delivery is claimed
+// by one dispatcher only, and again once its lease has expired.
+func TestWebhookServiceClaimIsExclusive(test *testing.T) {
+	for _, backend := range webhookBackends() {
+		test.Run(backend.name, func(t *testing.T) {
+			webhookService := backend.open(t)
+			require.NoError(t, webhookService.Subscribe(context.Background(), &model.WebhookSubscription{URL: "https://example.com/hooks"}))
+			_, err := webhookService.Enqueue(context.Background(), events.PlayerUpdated, json.RawMessage(`{}`))
+			require.NoError(t, err)
+			now := time.Now()
+
+			var claims atomic.Int32
+			var wait sync.WaitGroup
+			for range 4 {
+				wait.Add(1)
+				go func() {
+					defer wait.Done()
+					claimed, err := webhookService.Claim(context.Background(), now, time.Minute, 10)
+					assert.NoError(t, err)
+					claims.Add(int32(len(claimed)))
+				}()
+			}
+			wait.Wait()
+			expired, err := webhookService.Claim(context.Background(), now.Add(2*time.Minute), time.Minute, 10)
+
+			require.NoError(t, err)
+			assert.Equal(t, int32(1), claims.Load())
+			assert.Len(t, expired, 1)
+		})
+	}
+}
+
+// TestWebhookDispatcherBackoffDoublesUpToMaximum tests the delay before
+// each retry.
+func TestWebhookDispatcherBackoffDoublesUpToMaximum(test *testing.T) {
+	dispatcher := webhook.NewDispatcher(service.NewMemoryWebhookService(), config.WebhooksConfig{
+		Backoff:    config.Duration(time.Second),
+		MaxBackoff: config.Duration(10 * time.Second),
+	})
+
+	delays := []time.Duration{}
+	for attempts := 1; attempts <= 6; attempts++ {
+		delays = append(delays, dispatcher.Backoff(attempts))
+	}
+
+	assert.Equal(test, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}, delays)
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/nanotaboada/go-samples-gin-restful/data"
 	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm/logger"
 )
 
 func main() {
@@ -32,7 +33,7 @@ func main() {
 	}
 	log.Println("Removed existing DB (or none existed)")
 
-	db := data.Connect(dbPath)
+	db := data.Connect(dbPath, logger.Info)
 	log.Println("Created DB and applied schema via AutoMigrate")
 
 	players := []model.Player{
@@ -20,6 +20,7 @@ import (
 
 	"github.com/nanotaboada/go-samples-gin-restful/data"
 	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"gorm.io/gorm/logger"
 )
 
 func main() {
@@ -27,7 +28,7 @@ func main() {
 
 	// data.Connect calls AutoMigrate, which is idempotent — safe to call even
 	// if the schema was already created by seed_001.
-	db := data.Connect(dbPath)
+	db := data.Connect(dbPath, logger.Info)
 
 	players := []model.Player{
 		{
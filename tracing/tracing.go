@@ -0,0 +1,214 @@
+// Package tracing traces HTTP requests and the GORM statements they run with
+// OpenTelemetry, and exports the spans to stdout or an OTLP collector.
+//
+// # Spans
+//
+//	GET /players/:id                 server span of the request, named by its route template
+//	├── gorm.query                   one client span per GORM statement
+//	└── …
+//
+// The request span carries cache.status ("hit" or "miss") on the cached
+// routes (see route.CachePage).  Statement spans carry the dialect, table,
+// operation, SQL text (with placeholders, never the values) and row count,
+// and are marked as errors when the statement fails other than with
+// gorm.ErrRecordNotFound.
+//
+// # Propagation
+//
+// An incoming W3C traceparent header (and baggage) makes the request span a
+// child of the caller's span, so a trace continues across services.
+// Statements become children of the request span only when they run with the
+// request's context, which is why every PlayerService method takes it.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/route"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+)
+
+// scopeName is the instrumentation scope of the statement spans.
+const scopeName = "github.com/nanotaboada/go-samples-gin-restful/tracing"
+
+// CacheStatusAttribute is the request span attribute recording whether a
+// cached route was answered from the cache: route.CacheHit or
+// route.CacheMiss.
+const CacheStatusAttribute = attribute.Key("cache.status")
+
+// Tracing holds the tracer provider spans are started with and the
+// propagator reading traceparent headers.
+//
+// It never touches OpenTelemetry's global provider and propagator, so tests
+// can create as many as they need, each recording to its own exporter.
+type Tracing struct {
+	provider    trace.TracerProvider
+	tracer      trace.Tracer
+	propagator  propagation.TextMapPropagator
+	serviceName string
+	shutdown    func(context.Context) error
+}
+
+// New returns a Tracing that starts its spans with provider and names the
+// server serviceName.
+func New(provider trace.TracerProvider, serviceName string) *Tracing {
+	return &Tracing{
+		provider:    provider,
+		tracer:      provider.Tracer(scopeName),
+		propagator:  propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+		serviceName: serviceName,
+		shutdown:    func(context.Context) error { return nil },
+	}
+}
+
+// Setup returns a Tracing exporting its spans as cfg.Exporter says: nowhere
+// ("none"), as JSON written to stdout ("stdout"), or over OTLP/HTTP to
+// cfg.Endpoint ("otlp").  Spans are exported in batches; call
+// Shutdown to flush the last one.
+func Setup(ctx context.Context, cfg config.TracingConfig, stdout io.Writer) (*Tracing, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "none":
+		return New(noop.NewTracerProvider(), cfg.ServiceName), nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	default:
+		err = fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	serviceResource, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(serviceResource))
+	tracing := New(provider, cfg.ServiceName)
+	tracing.shutdown = provider.Shutdown
+	return tracing, nil
+}
+
+// Shutdown exports the spans still buffered and stops the exporter, giving
+// up when ctx is done.
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	return t.shutdown(ctx)
+}
+
+// Middleware returns the Gin middleware that starts the span of every
+// request, as a child of the caller's span when the request carries a
+// traceparent header, and records its cache status.  It must be installed
+// with router.Use(tracing.Middleware()...) before the routes it traces.
+func (t *Tracing) Middleware() gin.HandlersChain {
+	return gin.HandlersChain{
+		otelgin.Middleware(t.serviceName, otelgin.WithTracerProvider(t.provider), otelgin.WithPropagators(t.propagator)),
+		func(context *gin.Context) {
+			context.Next()
+			if status := context.GetString(route.CacheStatusKey); status != "" {
+				trace.SpanFromContext(context.Request.Context()).SetAttributes(CacheStatusAttribute.String(status))
+			}
+		},
+	}
+}
+
+// spanKey is the statement instance key holding a statement's span.
+const spanKey = "tracing:span"
+
+// InstrumentDB registers GORM callbacks that trace every statement run
+// through db (and the sessions and transactions derived from it) as a child
+// of the span in the statement's context.  Migrations run by goose bypass
+// GORM and are not traced.
+func (t *Tracing) InstrumentDB(db *gorm.DB) error {
+	callbacks := db.Callback()
+	processors := []struct {
+		operation     string
+		before, after callbackRegistrar
+	}{
+		{"create", callbacks.Create().Before("*"), callbacks.Create().After("*")},
+		{"query", callbacks.Query().Before("*"), callbacks.Query().After("*")},
+		{"update", callbacks.Update().Before("*"), callbacks.Update().After("*")},
+		{"delete", callbacks.Delete().Before("*"), callbacks.Delete().After("*")},
+		{"row", callbacks.Row().Before("*"), callbacks.Row().After("*")},
+		{"raw", callbacks.Raw().Before("*"), callbacks.Raw().After("*")},
+	}
+	for _, p := range processors {
+		if err := p.before.Register("tracing:before_"+p.operation, t.start(p.operation)); err != nil {
+			return err
+		}
+		if err := p.after.Register("tracing:after_"+p.operation, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callbackRegistrar is the part of GORM's (unexported) callback type used by
+// InstrumentDB.
+type callbackRegistrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// start returns the callback starting the span of a statement of operation.
+func (t *Tracing) start(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		_, span := t.tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBOperationName(operation)))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// end ends the span of a finished statement.
+func end(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		dbSystem(db.Dialector.Name()),
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		semconv.DBResponseReturnedRows(int(db.Statement.RowsAffected)),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
+
+// dbSystem returns the db.system.name of a GORM dialect.
+func dbSystem(dialect string) attribute.KeyValue {
+	switch dialect {
+	case "postgres":
+		return semconv.DBSystemNamePostgreSQL
+	case "sqlite":
+		return semconv.DBSystemNameSQLite
+	}
+	return semconv.DBSystemNameKey.String(dialect)
+}
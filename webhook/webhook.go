@@ -0,0 +1,229 @@
+// Package webhook delivers player changes to the URLs of webhook
+// subscriptions.
+//
+// # Queue
+//
+// Publish queues the change as one delivery per interested subscription, in
+// the webhook_deliveries table (see service.WebhookService), and wakes the
+// Dispatcher's worker.  The worker claims due deliveries, POSTs each, and
+// records the outcome: delivered on a 2xx status, otherwise retried with
+// exponential backoff (config.WebhooksConfig.Backoff, doubling up to
+// MaxBackoff) until MaxAttempts have failed, when it becomes a dead letter.
+// Dead letters stay until they are redelivered (POST
+// /admin/webhooks/deliveries/{id}/redeliver) or their subscription is
+// removed.
+//
+// Being stored, the queue survives restarts, and claiming lets the workers
+// of several replicas share it: each delivery is attempted by one at a time.
+// Delivery is at least once, so receivers should ignore deliveries whose
+// DeliveryHeader they have already processed.
+//
+// # Signatures
+//
+// Every attempt carries the headers below.  SignatureHeader is "sha256="
+// followed by the hex HMAC-SHA256, keyed by the subscription's secret, of
+// the TimestampHeader value, a dot and the body (see Sign).  Receivers
+// recompute it to know the delivery is genuine, and reject old timestamps to
+// foil replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nanotaboada/go-samples-gin-restful/config"
+	"github.com/nanotaboada/go-samples-gin-restful/model"
+	"github.com/nanotaboada/go-samples-gin-restful/service"
+)
+
+// Headers of every attempt.
+const (
+	EventHeader     = "X-Webhook-Event"     // The event type, e.g. player.updated
+	DeliveryHeader  = "X-Webhook-Delivery"  // WebhookDelivery.ID, the same on every attempt
+	TimestampHeader = "X-Webhook-Timestamp" // Unix time of the attempt, in seconds
+	SignatureHeader = "X-Webhook-Signature" // sha256=<hex HMAC-SHA256>; see Sign
+)
+
+// claimBatch is how many deliveries the worker claims at a time.
+const claimBatch = 50
+
+// Sign returns the SignatureHeader value of an attempt with timestamp and
+// body, for the subscription with secret.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher queues player changes for the webhook subscriptions and
+// delivers them.
+type Dispatcher struct {
+	service service.WebhookService
+	config  config.WebhooksConfig
+	client  *http.Client
+	wake    chan struct{} // Signalled when deliveries are queued
+}
+
+// NewDispatcher returns a Dispatcher working the queue of service as cfg
+// says.  Its worker only runs once Run is called.
+func NewDispatcher(service service.WebhookService, cfg config.WebhooksConfig) *Dispatcher {
+	return &Dispatcher{
+		service: service,
+		config:  cfg,
+		client:  &http.Client{Timeout: time.Duration(cfg.Timeout)},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Publish queues the change of type eventType to player for every
+// subscription wanting it, and wakes the worker.  A failure to queue is
+// logged: the change itself is already committed.
+func (d *Dispatcher) Publish(eventType string, player model.Player) {
+	payload, err := json.Marshal(model.WebhookEvent{Type: eventType, OccurredAt: time.Now().UTC(), Player: player})
+	if err == nil {
+		_, err = d.service.Enqueue(context.Background(), eventType, payload)
+	}
+	if err != nil {
+		slog.Error("webhook: queueing failed", "event", eventType, "player", player.ID, "error", err)
+		return
+	}
+	d.notify()
+}
+
+// Redeliver gives the delivery with the given ID a fresh budget of attempts,
+// due at once, and wakes the worker.
+func (d *Dispatcher) Redeliver(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	delivery, err := d.service.Redeliver(ctx, id, time.Now())
+	if err == nil {
+		d.notify()
+	}
+	return delivery, err
+}
+
+// notify wakes the worker, unless it is already due to wake.
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works the queue until ctx is done: whenever Publish queues deliveries,
+// and every PollInterval for the retries that came due.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(d.config.PollInterval))
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("webhook: claiming deliveries failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue attempts every delivery due now, one after the other, and
+// returns how many it attempted.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		// A claim outlives the attempts of the whole batch, so no other
+		// worker claims a delivery waiting its turn.
+		lease := time.Duration(d.config.Timeout) * (claimBatch + 1)
+		deliveries, err := d.service.Claim(ctx, time.Now(), lease, claimBatch)
+		if err != nil {
+			return attempted, err
+		}
+		for i := range deliveries {
+			if ctx.Err() != nil {
+				return attempted, ctx.Err()
+			}
+			d.attempt(ctx, &deliveries[i])
+			if err := d.service.Record(ctx, &deliveries[i]); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		if len(deliveries) < claimBatch {
+			return attempted, nil
+		}
+	}
+}
+
+// attempt POSTs delivery to its subscription and updates its outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+	status, err := d.post(ctx, delivery)
+	delivery.LastStatus = status
+	now := time.Now().UTC()
+	if err == nil {
+		delivery.State = model.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.config.MaxAttempts {
+		delivery.State = model.DeliveryDead
+		slog.Warn("webhook: delivery is dead", "delivery", delivery.ID, "subscription", delivery.SubscriptionID, "attempts", delivery.Attempts, "error", err)
+		return
+	}
+	delivery.NextAttemptAt = now.Add(d.Backoff(delivery.Attempts))
+}
+
+// post sends one attempt and returns the status it got, and an error unless
+// it is a 2xx.
+func (d *Dispatcher) post(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	subscription, err := d.service.Subscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, delivery.Payload))
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+	_ = response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// Backoff returns the delay before the next attempt after attempts have
+// failed: Backoff, doubled for each failure after the first, at most
+// MaxBackoff.
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	delay := time.Duration(d.config.Backoff)
+	for i := 1; i < attempts && delay < time.Duration(d.config.MaxBackoff); i++ {
+		delay *= 2
+	}
+	return min(delay, time.Duration(d.config.MaxBackoff))
+}